@@ -57,11 +57,19 @@ type EtcdConfig struct {
 	EtcdCertFile     string `json:"etcdCertFile" envconfig:"ETCD_CERT_FILE"`
 	EtcdCACertFile   string `json:"etcdCACertFile" envconfig:"ETCD_CA_CERT_FILE"`
 
+	// The file containing the username and password, as an alternative to EtcdUsername and
+	// EtcdPassword.  The etcdv3 client reconnects when this file or the certificate files change.
+	EtcdCredentialsFile string `json:"etcdCredentialsFile" envconfig:"ETCD_CREDENTIALS_FILE"`
+
 	// These config file parameters are to support inline certificates, keys and CA / Trusted certificate.
 	// There are no corresponding environment variables to avoid accidental exposure.
 	EtcdKey    string `json:"etcdKey" ignored:"true"`
 	EtcdCert   string `json:"etcdCert" ignored:"true"`
 	EtcdCACert string `json:"etcdCACert" ignored:"true"`
+
+	// The file containing the keys used to encrypt the values of selected resource kinds at
+	// rest, and the kinds to encrypt.  If not specified, values are stored unencrypted.
+	EtcdEncryptionKeyFile string `json:"etcdEncryptionKeyFile" envconfig:"ETCD_ENCRYPTION_KEY_FILE"`
 }
 
 type KubeConfig struct {
@@ -36,6 +36,9 @@ type BGPPeer struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 	// Specification of the BGPPeer.
 	Spec BGPPeerSpec `json:"spec,omitempty"`
+	// Status of the BGPPeer.  The status is only written by UpdateStatus, and updates to the
+	// rest of the BGPPeer leave it unchanged.
+	Status BGPPeerStatus `json:"status,omitempty"`
 }
 
 // BGPPeerSpec contains the specification for a BGPPeer resource.
@@ -60,6 +63,43 @@ type BGPPeerSpec struct {
 	PeerSelector string `json:"peerSelector,omitempty"`
 }
 
+// BGPPeerStatus contains the status of a BGPPeer resource.
+type BGPPeerStatus struct {
+	// The state of the BGP sessions with the peer, one for each node and peer IP address that
+	// peers using this BGPPeer.
+	Sessions []BGPSessionStatus `json:"sessions,omitempty"`
+	// The time that the status was last updated.
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+}
+
+// BGPSessionStatus contains the state of a BGP session between a Calico node and a peer.
+type BGPSessionStatus struct {
+	// The node name of the Calico node instance at the local end of the session.  This is not set
+	// in the status of a Node, which only holds the sessions of that node.
+	Node string `json:"node,omitempty" validate:"omitempty,name"`
+	// The IP address of the peer.
+	PeerIP string `json:"peerIP" validate:"ip"`
+	// The state of the session.
+	State BGPSessionState `json:"state"`
+	// The time that the session entered its current state.
+	Since *metav1.Time `json:"since,omitempty"`
+	// Further information about the state of the session, for example the reason that it is
+	// not established.
+	Info string `json:"info,omitempty"`
+}
+
+// BGPSessionState is the state of a BGP session, as defined by the BGP finite state machine.
+type BGPSessionState string
+
+const (
+	BGPSessionStateIdle        BGPSessionState = "Idle"
+	BGPSessionStateConnect     BGPSessionState = "Connect"
+	BGPSessionStateActive      BGPSessionState = "Active"
+	BGPSessionStateOpenSent    BGPSessionState = "OpenSent"
+	BGPSessionStateOpenConfirm BGPSessionState = "OpenConfirm"
+	BGPSessionStateEstablished BGPSessionState = "Established"
+)
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // BGPPeerList contains a list of BGPPeer resources.
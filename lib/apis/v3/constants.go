@@ -40,6 +40,16 @@ const (
 	// orchestrator.
 	LabelOrchestrator = "projectcalico.org/orchestrator"
 
+	// Label used to denote the zone of a node.  IP pools with the SameZone borrowing policy only allow
+	// addresses to be borrowed between nodes with the same value for this label.
+	LabelZone = "topology.kubernetes.io/zone"
+
+	// Annotation used to split a large GlobalNetworkSet or NetworkSet across multiple resources.  All
+	// sets that have this annotation with the same value (and, for NetworkSets, are in the same
+	// namespace) are shards of a single logical set named by the annotation value.  The syncer
+	// reassembles the shards and sends the union of their contents as one network set.
+	AnnotationNetworkSetShardOf = "projectcalico.org/network-set-shard-of"
+
 	// Known orchestrators.  Orchestrators are not limited to this list.
 	OrchestratorKubernetes = "k8s"
 	OrchestratorCNI        = "cni"
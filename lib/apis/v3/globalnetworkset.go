@@ -40,6 +40,9 @@ type GlobalNetworkSet struct {
 type GlobalNetworkSetSpec struct {
 	// The list of IP networks that belong to this set.
 	Nets []string `json:"nets,omitempty" validate:"omitempty,dive,cidr"`
+	// The list of domain names that belong to this set.  Wildcards of the form "*.example.com"
+	// match any subdomain of the given name.
+	Domains []string `json:"domains,omitempty" validate:"omitempty,dive,domain"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
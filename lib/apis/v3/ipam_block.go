@@ -44,6 +44,9 @@ type IPAMBlockSpec struct {
 	Unallocated    []int                 `json:"unallocated"`
 	Attributes     []AllocationAttribute `json:"attributes"`
 	Deleted        bool                  `json:"deleted`
+	// Released records when each previously allocated, but now unallocated, ordinal was released
+	// and the handle that held it.
+	Released map[int]ReleasedAddress `json:"released,omitempty"`
 }
 
 type AllocationAttribute struct {
@@ -51,6 +54,11 @@ type AllocationAttribute struct {
 	AttrSecondary map[string]string `json:"secondary"`
 }
 
+type ReleasedAddress struct {
+	HandleID *string `json:"handle_id,omitempty"`
+	Time     int64   `json:"time"`
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // IPAMBlockList contains a list of IPAMBlock resources.
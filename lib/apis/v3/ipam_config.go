@@ -39,6 +39,15 @@ type IPAMConfig struct {
 type IPAMConfigSpec struct {
 	StrictAffinity     bool `json:"strictAffinity"`
 	AutoAllocateBlocks bool `json:"autoAllocateBlocks"`
+	// ReleaseCooldown is the minimum time after an address is released before it may be automatically
+	// assigned again.  If not specified, released addresses may be reassigned immediately.
+	ReleaseCooldown *metav1.Duration `json:"releaseCooldown,omitempty"`
+	// AuditRetention is how long the assignments and releases of addresses are recorded in the IPAM
+	// audit log.  If not specified, the audit log is disabled.
+	AuditRetention *metav1.Duration `json:"auditRetention,omitempty"`
+	// HonorPodCIDRs makes Calico IPAM treat the pod CIDRs that Kubernetes allocates to each node as
+	// blocks affine to that node.  Other nodes do not claim blocks within a node's pod CIDRs.
+	HonorPodCIDRs bool `json:"honorPodCIDRs,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
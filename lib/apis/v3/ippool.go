@@ -36,6 +36,9 @@ type IPPool struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 	// Specification of the IPPool.
 	Spec IPPoolSpec `json:"spec,omitempty"`
+	// Status of the IPPool.  The status is only written by UpdateStatus, and updates to the
+	// rest of the IPPool leave it unchanged.
+	Status IPPoolStatus `json:"status,omitempty"`
 }
 
 // IPPoolSpec contains the specification for an IPPool resource.
@@ -58,12 +61,31 @@ type IPPoolSpec struct {
 	// When disabled is true, Calico IPAM will not assign addresses from this pool.
 	Disabled bool `json:"disabled,omitempty"`
 
-	// The block size to use for IP address assignments from this pool. Defaults to 26 for IPv4 and 112 for IPv6.
+	// The block size to use for IP address assignments from this pool. Defaults to 26 for IPv4 and 122 for IPv6.
 	BlockSize int `json:"blockSize,omitempty"`
 
 	// Allows IPPool to allocate for a specific node by label selector.
 	NodeSelector string `json:"nodeSelector,omitempty" validate:"omitempty,selector"`
 
+	// The strategy used to choose the blocks and addresses that are assigned from this pool.  If not
+	// specified, then this is defaulted to "Random".
+	AllocationStrategy AllocationStrategy `json:"allocationStrategy,omitempty" validate:"omitempty,allocationStrategy"`
+
+	// Controls whether a host that has run out of affine blocks may borrow addresses from blocks in
+	// this pool that are affine to other hosts.  Borrowing only happens when StrictAffinity is
+	// disabled.  If not specified, then this is defaulted to "Always".
+	BorrowingPolicy BorrowingPolicy `json:"borrowingPolicy,omitempty" validate:"omitempty,borrowingPolicy"`
+
+	// The number of addresses at the start of each block in this pool that Calico IPAM will not
+	// assign automatically, for platforms that cannot use a block's network or gateway addresses.
+	// The addresses may still be assigned explicitly.
+	ReservedBlockStart int `json:"reservedBlockStart,omitempty"`
+
+	// The number of addresses at the end of each block in this pool that Calico IPAM will not
+	// assign automatically, for platforms that cannot use a block's broadcast address.  The
+	// addresses may still be assigned explicitly.
+	ReservedBlockEnd int `json:"reservedBlockEnd,omitempty"`
+
 	// Deprecated: this field is only used for APIv1 backwards compatibility.
 	// Setting this field is not allowed, this field is for internal use only.
 	IPIP *apiv1.IPIPConfiguration `json:"ipip,omitempty" validate:"omitempty,mustBeNil"`
@@ -73,6 +95,17 @@ type IPPoolSpec struct {
 	NATOutgoingV1 bool `json:"nat-outgoing,omitempty" validate:"omitempty,mustBeFalse"`
 }
 
+// IPPoolStatus contains the status of an IPPool resource, which reports how much of the pool is
+// in use.
+type IPPoolStatus struct {
+	// The number of blocks of the pool that have been allocated.
+	AllocatedBlocks int `json:"allocatedBlocks,omitempty"`
+	// The number of addresses in the pool that have been assigned.
+	AllocatedIPs int `json:"allocatedIPs,omitempty"`
+	// The time that the status was last updated.
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+}
+
 // SelectsNode determines whether or not the IPPool's nodeSelector
 // matches the labels on the given node.
 func (pool IPPool) SelectsNode(n Node) (bool, error) {
@@ -97,6 +130,39 @@ const (
 	VXLANModeCrossSubnet           = "CrossSubnet"
 )
 
+// AllocationStrategy determines the order in which Calico IPAM claims the blocks of an IP pool and
+// assigns the addresses within each block.
+type AllocationStrategy string
+
+const (
+	// Blocks are claimed in a pseudo-random order seeded by the hostname, and addresses are assigned
+	// in the order that they became free.
+	AllocationStrategyRandom AllocationStrategy = "Random"
+	// Blocks are claimed, and addresses assigned, lowest first.
+	AllocationStrategySequential = "Sequential"
+	// Addresses that have never been assigned are used first, followed by those that were released
+	// longest ago.  Of a host's blocks, the one with the least recently released free address is
+	// used first.
+	AllocationStrategyLeastRecentlyReleased = "LeastRecentlyReleased"
+	// Addresses last held by the requesting handle are preferred.  Otherwise, this behaves like
+	// LeastRecentlyReleased.
+	AllocationStrategySticky = "Sticky"
+)
+
+// BorrowingPolicy determines whether a host may borrow addresses from the blocks of an IP pool that
+// are affine to other hosts.
+type BorrowingPolicy string
+
+const (
+	// Addresses may be borrowed from any host's blocks.
+	BorrowingPolicyAlways BorrowingPolicy = "Always"
+	// Addresses are never borrowed from other hosts' blocks.
+	BorrowingPolicyNever = "Never"
+	// Addresses may only be borrowed from the blocks of hosts with the same value for the zone label
+	// (LabelZone) as the borrowing host.
+	BorrowingPolicySameZone = "SameZone"
+)
+
 type IPIPMode string
 
 const (
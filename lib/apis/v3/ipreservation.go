@@ -0,0 +1,75 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v3
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	KindIPReservation     = "IPReservation"
+	KindIPReservationList = "IPReservationList"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// IPReservation contains a set of IP addresses and CIDRs that Calico IPAM will not automatically
+// assign.  Reserved addresses may still be assigned explicitly (for example, using AssignIP).
+type IPReservation struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object's metadata.
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// Specification of the IPReservation.
+	Spec IPReservationSpec `json:"spec,omitempty"`
+}
+
+// IPReservationSpec contains the specification for an IPReservation resource.
+type IPReservationSpec struct {
+	// ReservedCIDRs is a list of CIDRs and/or IP addresses that Calico IPAM will exclude from
+	// new allocations.
+	ReservedCIDRs []string `json:"reservedCIDRs,omitempty" validate:"omitempty,dive,cidr"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// IPReservationList contains a list of IPReservation resources.
+type IPReservationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+	Items           []IPReservation `json:"items"`
+}
+
+// NewIPReservation creates a new (zeroed) IPReservation struct with the TypeMetadata initialised to the current
+// version.
+func NewIPReservation() *IPReservation {
+	return &IPReservation{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       KindIPReservation,
+			APIVersion: GroupVersionCurrent,
+		},
+	}
+}
+
+// NewIPReservationList creates a new (zeroed) IPReservationList struct with the TypeMetadata initialised to the current
+// version.
+func NewIPReservationList() *IPReservationList {
+	return &IPReservationList{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       KindIPReservationList,
+			APIVersion: GroupVersionCurrent,
+		},
+	}
+}
@@ -25,6 +25,11 @@ const (
 	KindNodeList = "NodeList"
 )
 
+// NodeDecommissionFinalizer is set on a Node while it is being decommissioned, and removed when the
+// decommission completes.  On Kubernetes, this stops the Node being deleted until all of its data
+// has been cleaned up.
+const NodeDecommissionFinalizer = "projectcalico.org/node-decommission"
+
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
@@ -35,6 +40,9 @@ type Node struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 	// Specification of the Node.
 	Spec NodeSpec `json:"spec,omitempty"`
+	// Status of the Node.  The status is only written by UpdateStatus, and updates to the rest
+	// of the Node leave it unchanged.
+	Status NodeStatus `json:"status,omitempty"`
 }
 
 // NodeSpec contains the specification for a Node resource.
@@ -52,6 +60,21 @@ type NodeSpec struct {
 	OrchRefs []OrchRef `json:"orchRefs,omitempty" validate:"omitempty"`
 }
 
+// NodeStatus contains the status of a Node resource, as reported by the Calico components
+// running on the node.
+type NodeStatus struct {
+	// The versions of the Calico components running on the node, keyed by component name, for
+	// example "calico-node" or "felix".
+	AgentVersions map[string]string `json:"agentVersions,omitempty"`
+	// The state of the BGP sessions between this node and its peers.
+	BGPSessions []BGPSessionStatus `json:"bgpSessions,omitempty"`
+	// The time that the status was last reported.
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+	// The decommission steps that have completed, if the node is being decommissioned.  This
+	// allows a failed decommission to be resumed without repeating those steps.
+	DecommissionCompletedSteps []string `json:"decommissionCompletedSteps,omitempty"`
+}
+
 // OrchRef is used to correlate a Calico node to its corresponding representation in a given orchestrator
 type OrchRef struct {
 	// NodeName represents the name for this node according to the orchestrator.
@@ -161,6 +161,13 @@ type EntityRule struct {
 	// ServiceAccounts is an optional field that restricts the rule to only apply to traffic that originates from (or
 	// terminates at) a pod running as a matching service account.
 	ServiceAccounts *ServiceAccountMatch `json:"serviceAccounts,omitempty" validate:"omitempty"`
+
+	// Domains is an optional field that restricts the rule to only apply to traffic that originates
+	// from (or terminates at) IP addresses that the listed DNS names resolve to.  Each entry is either
+	// an exact domain name (e.g. "api.example.com") or a wildcard that matches any subdomain of the
+	// given name (e.g. "*.example.com").  Since the resolved addresses may change at any time, the
+	// dataplane is responsible for tracking them.
+	Domains []string `json:"domains,omitempty" validate:"omitempty,dive,domain"`
 }
 
 type ServiceAccountMatch struct {
@@ -17,6 +17,7 @@ package api
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"context"
 
@@ -120,8 +121,47 @@ type Client interface {
 	// Clean removes Calico data from the backend datastore.  Used for test purposes.
 	Clean() error
 
-	// Close the client.
-	//Close()
+	// Close releases the resources held by the client, such as its connections to the
+	// datastore and any goroutines that it runs in the background.  The client must not be
+	// used once it is closed.
+	Close() error
+}
+
+// SessionClient is implemented by backend clients that can bind entries to the lifetime of a
+// client session.  It is used for entries that should only exist while the process that writes
+// them is alive, such as status reports (model.ActiveStatusReportKey) and liveness keys.
+type SessionClient interface {
+	// NewSession starts a session that is kept alive until it is closed, or until the datastore
+	// hasn't heard from the client for the TTL, for example because the process has died.
+	NewSession(ctx context.Context, ttl time.Duration) (Session, error)
+}
+
+// Session binds entries to the lifetime of a client session.  When the session ends, all of
+// the entries that were written through it are deleted.
+type Session interface {
+	// Apply updates or creates the object specified in the KVPair, binding it to the session.
+	// The TTL of the KVPair is ignored.
+	Apply(ctx context.Context, object *model.KVPair) (*model.KVPair, error)
+
+	// Done returns a channel that is closed when the session ends, after which its entries
+	// are deleted by the datastore.  The owner should then start a new session and re-apply
+	// its entries.
+	Done() <-chan struct{}
+
+	// Close ends the session and deletes its entries.
+	Close() error
+}
+
+// StatusClient is implemented by backend clients that store the status of a resource separately
+// from the rest of the resource, for the kinds of resource that have a status (Node, IPPool and
+// BGPPeer).  Create and Update ignore the status of these resources: it is only written by
+// UpdateStatus.
+type StatusClient interface {
+	// UpdateStatus replaces the status of the existing resource specified in the KVPair,
+	// ignoring the rest of the resource.  Revision information is ignored, so status updates
+	// never conflict with updates to the rest of the resource.  On success, returns a KVPair
+	// for the resource with the new status and revision information filled-in.
+	UpdateStatus(ctx context.Context, object *model.KVPair) (*model.KVPair, error)
 }
 
 type Syncer interface {
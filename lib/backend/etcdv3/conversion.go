@@ -30,11 +30,16 @@ import (
 // convertListResponse converts etcdv3 Kv to a model.KVPair with parsed values.
 // If the etcdv3 key or value does not represent the resource specified by the ListInterface,
 // or if value cannot be parsed, this method returns nil.
-func convertListResponse(ekv *mvccpb.KeyValue, l model.ListInterface) *model.KVPair {
+func (c *etcdV3Client) convertListResponse(ekv *mvccpb.KeyValue, l model.ListInterface) *model.KVPair {
 	log.WithField("etcdv3-etcdKey", string(ekv.Key)).Debug("Processing etcdv3 entry")
 	if k := l.KeyFromDefaultPath(string(ekv.Key)); k != nil {
 		log.WithField("model-etcdKey", k).Debug("Key is valid and converted to model-etcdKey")
-		if v, err := model.ParseValue(k, ekv.Value); err == nil {
+		value, err := c.encrypter.decrypt(string(ekv.Key), ekv.Value)
+		if err != nil {
+			log.WithError(err).WithField("etcdv3-etcdKey", string(ekv.Key)).Warning("Failed to decrypt value")
+			return nil
+		}
+		if v, err := model.ParseValue(k, value); err == nil {
 			log.Debug("Value is valid - return KVPair with parsed value")
 			return &model.KVPair{Key: k, Value: v, Revision: strconv.FormatInt(ekv.ModRevision, 10)}
 		}
@@ -44,7 +49,7 @@ func convertListResponse(ekv *mvccpb.KeyValue, l model.ListInterface) *model.KVP
 
 // convertWatchEvent converts an etcdv3 watch event to an api.WatchEvent, or nil if the
 // event did not correspond to an event that we are interested in.
-func convertWatchEvent(e *clientv3.Event, l model.ListInterface) (*api.WatchEvent, error) {
+func (c *etcdV3Client) convertWatchEvent(e *clientv3.Event, l model.ListInterface) (*api.WatchEvent, error) {
 	log.WithField("etcdv3-etcdKey", string(e.Kv.Key)).Debug("Processing etcdv3 event")
 
 	var eventType api.WatchEventType
@@ -64,13 +69,13 @@ func convertWatchEvent(e *clientv3.Event, l model.ListInterface) (*api.WatchEven
 
 		if eventType != api.WatchDeleted {
 			// Add or modify, parse the new value.
-			if newKV, err = etcdToKVPair(k, e.Kv); err != nil {
+			if newKV, err = c.etcdToKVPair(k, e.Kv); err != nil {
 				return nil, err
 			}
 		}
 		if eventType != api.WatchAdded {
 			// Delete or modify, parse the old value.
-			if oldKV, err = etcdToKVPair(k, e.PrevKv); err != nil {
+			if oldKV, err = c.etcdToKVPair(k, e.PrevKv); err != nil {
 				if eventType == api.WatchDeleted || err != ErrMissingValue {
 					// Ignore missing value for modified events, but we need them for deletion.
 					return nil, err
@@ -93,13 +98,23 @@ var (
 	ErrMissingValue = fmt.Errorf("missing etcd KV")
 )
 
-// etcdToKVPair converts an etcd KeyValue in to model.KVPair.
-func etcdToKVPair(key model.Key, ekv *mvccpb.KeyValue) (*model.KVPair, error) {
+// etcdToKVPair converts an etcd KeyValue in to model.KVPair, decrypting the value if it is
+// encrypted.
+func (c *etcdV3Client) etcdToKVPair(key model.Key, ekv *mvccpb.KeyValue) (*model.KVPair, error) {
 	if ekv == nil {
 		return nil, ErrMissingValue
 	}
 
-	v, err := model.ParseValue(key, ekv.Value)
+	value, err := c.encrypter.decrypt(string(ekv.Key), ekv.Value)
+	if err != nil {
+		return nil, errors.ErrorParsingDatastoreEntry{
+			RawKey:   string(ekv.Key),
+			RawValue: string(ekv.Value),
+			Err:      err,
+		}
+	}
+
+	v, err := model.ParseValue(key, value)
 	if err != nil {
 		if len(ekv.Value) == 0 {
 			// We do this check after the ParseValue call because ParseValue has some special-case logic for handling
@@ -0,0 +1,86 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdv3
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/unai-ttxu/libcalico-go/lib/apiconfig"
+)
+
+// etcdCredentials is the format of the credentials file.
+type etcdCredentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// loadCredentials reads the username and password from the credentials file.
+func loadCredentials(filename string) (*etcdCredentials, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not read credentials file: %v", err)
+	}
+	var creds etcdCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("could not parse credentials file %s: %v", filename, err)
+	}
+	if creds.Username == "" || creds.Password == "" {
+		return nil, fmt.Errorf("credentials file %s must contain a username and password", filename)
+	}
+	return &creds, nil
+}
+
+// credentialFiles returns the files that the etcd client's TLS configuration and credentials are
+// read from.  The client is rebuilt when any of them change.
+func credentialFiles(config *apiconfig.EtcdConfig) []string {
+	var files []string
+	for _, f := range []string{config.EtcdCertFile, config.EtcdKeyFile, config.EtcdCACertFile, config.EtcdCredentialsFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// fingerprintFiles returns a hash of the contents of the files, which changes whenever any of
+// them change.  Files that can't be read are hashed as empty, so that they are fingerprinted
+// again once they are restored.
+func fingerprintFiles(files []string) string {
+	h := sha256.New()
+	for _, f := range files {
+		data, _ := ioutil.ReadFile(f)
+		fmt.Fprintf(h, "%s:%d:", f, len(data))
+		h.Write(data)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// certificateExpiry returns the time at which the certificate expires.
+func certificateExpiry(cert *tls.Certificate) (time.Time, error) {
+	if len(cert.Certificate) == 0 {
+		return time.Time{}, fmt.Errorf("no certificate found")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return time.Time{}, err
+	}
+	return leaf.NotAfter, nil
+}
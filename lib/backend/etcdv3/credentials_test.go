@@ -0,0 +1,107 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdv3
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/unai-ttxu/libcalico-go/lib/apiconfig"
+)
+
+var _ = Describe("etcdv3 certificates and credentials", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "etcdv3")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	writeFile := func(name, contents string) string {
+		filename := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(filename, []byte(contents), 0600)).NotTo(HaveOccurred())
+		return filename
+	}
+
+	It("should load the credentials file", func() {
+		creds, err := loadCredentials(writeFile("creds", `{"username": "user", "password": "password"}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*creds).To(Equal(etcdCredentials{Username: "user", Password: "password"}))
+
+		_, err = loadCredentials(writeFile("creds", `{"username": "user"}`))
+		Expect(err).To(HaveOccurred())
+		_, err = loadCredentials(filepath.Join(dir, "missing"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should fingerprint the configured files, changing when their contents change", func() {
+		config := &apiconfig.EtcdConfig{
+			EtcdCertFile:        writeFile("cert", "cert"),
+			EtcdCACertFile:      writeFile("ca", "ca"),
+			EtcdCredentialsFile: writeFile("creds", "creds"),
+		}
+		files := credentialFiles(config)
+		Expect(files).To(Equal([]string{config.EtcdCertFile, config.EtcdCACertFile, config.EtcdCredentialsFile}))
+
+		fingerprint := fingerprintFiles(files)
+		Expect(fingerprintFiles(files)).To(Equal(fingerprint))
+
+		writeFile("ca", "new ca")
+		Expect(fingerprintFiles(files)).NotTo(Equal(fingerprint))
+
+		By("treating a missing file as a change")
+		fingerprint = fingerprintFiles(files)
+		Expect(os.Remove(config.EtcdCACertFile)).NotTo(HaveOccurred())
+		Expect(fingerprintFiles(files)).NotTo(Equal(fingerprint))
+	})
+
+	It("should report the expiry of the certificate", func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		notAfter := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "etcd-client"},
+			NotBefore:    time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+			NotAfter:     notAfter,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		Expect(err).NotTo(HaveOccurred())
+
+		expiry, err := certificateExpiry(&tls.Certificate{Certificate: [][]byte{der}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(expiry).To(BeTemporally("==", notAfter))
+
+		_, err = certificateExpiry(&tls.Certificate{})
+		Expect(err).To(HaveOccurred())
+	})
+})
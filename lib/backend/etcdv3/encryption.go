@@ -0,0 +1,297 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdv3
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+)
+
+// Encrypted values are stored as this prefix followed by a JSON encoded envelope.  Values
+// without the prefix are plaintext.
+const encryptedValuePrefix = "calico:enc:v1:"
+
+// The size of the data keys that are generated to encrypt each value.
+const dataKeySize = 32
+
+// encryptionConfig is the format of the encryption key file.  The first key is used to
+// encrypt new values, and all of the keys are used to decrypt existing values.  To rotate the
+// keys, add a new key at the start of the list, re-encrypt all of the values with
+// ReencryptAll, and then remove the old key.
+//
+// For example:
+//
+//	{
+//	  "kinds": ["BGPPeer"],
+//	  "keys": [{"name": "key2", "secret": "<base64 encoded AES key>"}, {"name": "key1", ...}]
+//	}
+type encryptionConfig struct {
+	// The resource kinds whose values are encrypted, such as "BGPPeer".
+	Kinds []string `json:"kinds"`
+
+	// The keys, with the key used to encrypt new values first.
+	Keys []encryptionKey `json:"keys"`
+}
+
+type encryptionKey struct {
+	Name string `json:"name"`
+
+	// The base64 encoded 16, 24 or 32 byte AES key.
+	Secret string `json:"secret"`
+}
+
+// envelope is an encrypted value.  The value is encrypted with a data key that is generated
+// for the value, and the data key is itself encrypted with a named key from the key file.
+type envelope struct {
+	Key     string `json:"key"`
+	DataKey []byte `json:"dataKey"`
+	Data    []byte `json:"data"`
+}
+
+// valueEncrypter encrypts and decrypts the values stored in etcd.  A nil valueEncrypter
+// stores all values as plaintext, and fails to decrypt encrypted values.
+type valueEncrypter struct {
+	kinds     map[string]bool
+	activeKey string
+	keys      map[string]cipher.AEAD
+}
+
+// loadValueEncrypter reads the encryption key file and returns the valueEncrypter that it
+// configures.
+func loadValueEncrypter(filename string) (*valueEncrypter, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not read encryption key file: %v", err)
+	}
+	var cfg encryptionConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("could not parse encryption key file %s: %v", filename, err)
+	}
+	return newValueEncrypter(cfg)
+}
+
+func newValueEncrypter(cfg encryptionConfig) (*valueEncrypter, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, errors.New("no encryption keys specified")
+	}
+	e := &valueEncrypter{
+		kinds:     map[string]bool{},
+		activeKey: cfg.Keys[0].Name,
+		keys:      map[string]cipher.AEAD{},
+	}
+	for _, kind := range cfg.Kinds {
+		canonical := model.CanonicalResourceKind(kind)
+		if canonical == "" {
+			return nil, fmt.Errorf("unknown resource kind to encrypt: %s", kind)
+		}
+		e.kinds[canonical] = true
+	}
+	for _, k := range cfg.Keys {
+		if k.Name == "" {
+			return nil, errors.New("encryption keys must be named")
+		}
+		if _, ok := e.keys[k.Name]; ok {
+			return nil, fmt.Errorf("duplicate encryption key name: %s", k.Name)
+		}
+		secret, err := base64.StdEncoding.DecodeString(k.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %s is not base64 encoded: %v", k.Name, err)
+		}
+		aead, err := newAEAD(secret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key %s: %v", k.Name, err)
+		}
+		e.keys[k.Name] = aead
+	}
+	return e, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plaintext, authenticating it along with the additional data.  The nonce is
+// prepended to the returned ciphertext.
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts ciphertext that was encrypted by seal.
+func open(aead cipher.AEAD, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	nonce := ciphertext[:aead.NonceSize()]
+	return aead.Open(nil, nonce, ciphertext[aead.NonceSize():], additionalData)
+}
+
+// encrypts returns true if values of the given key are encrypted.
+func (e *valueEncrypter) encrypts(k model.Key) bool {
+	if e == nil {
+		return false
+	}
+	rk, ok := k.(model.ResourceKey)
+	return ok && e.kinds[model.CanonicalResourceKind(rk.Kind)]
+}
+
+// encryptsPath returns true if the values stored at the etcd key are encrypted.  The status of a
+// resource is encrypted if the rest of the resource is.
+func (e *valueEncrypter) encryptsPath(path string) bool {
+	if e == nil {
+		return false
+	}
+	path = resourcePath(path)
+	for kind := range e.kinds {
+		if (model.ResourceListOptions{Kind: kind}).KeyFromDefaultPath(path) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// encryptValue returns the value to store at the etcd key for the given model key: the value
+// encrypted with the active key if values of the key are encrypted, or the value itself
+// otherwise.
+func (e *valueEncrypter) encryptValue(k model.Key, path, value string) (string, error) {
+	if !e.encrypts(k) {
+		return value, nil
+	}
+	encrypted, err := e.encrypt(path, []byte(value))
+	if err != nil {
+		return "", err
+	}
+	return string(encrypted), nil
+}
+
+// encrypt encrypts the value stored at the etcd key with the active key.  The value is bound
+// to the etcd key, so that it can't be decrypted if it is moved to another key.
+func (e *valueEncrypter) encrypt(path string, value []byte) ([]byte, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	env := envelope{Key: e.activeKey}
+	if env.Data, err = seal(dataAEAD, value, []byte(path)); err != nil {
+		return nil, err
+	}
+	if env.DataKey, err = seal(e.keys[e.activeKey], dataKey, []byte(path)); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(encryptedValuePrefix), data...), nil
+}
+
+// isEncrypted returns true if the stored value is encrypted.
+func isEncrypted(value []byte) bool {
+	return bytes.HasPrefix(value, []byte(encryptedValuePrefix))
+}
+
+// parseEnvelope parses an encrypted value.
+func parseEnvelope(value []byte) (*envelope, error) {
+	var env envelope
+	if err := json.Unmarshal(value[len(encryptedValuePrefix):], &env); err != nil {
+		return nil, fmt.Errorf("could not parse encrypted value: %v", err)
+	}
+	return &env, nil
+}
+
+// decrypt returns the plaintext of the value stored at the etcd key.  Values that are not
+// encrypted are returned unchanged.
+func (e *valueEncrypter) decrypt(path string, value []byte) ([]byte, error) {
+	if !isEncrypted(value) {
+		return value, nil
+	}
+	if e == nil {
+		return nil, errors.New("value is encrypted but no encryption key file is configured")
+	}
+	env, err := parseEnvelope(value)
+	if err != nil {
+		return nil, err
+	}
+	aead, ok := e.keys[env.Key]
+	if !ok {
+		return nil, fmt.Errorf("value is encrypted with unknown key %s", env.Key)
+	}
+	dataKey, err := open(aead, env.DataKey, []byte(path))
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt data key: %v", err)
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(dataAEAD, env.Data, []byte(path))
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt value: %v", err)
+	}
+	return plaintext, nil
+}
+
+// reencrypt returns the value that should be stored at the etcd key in place of the given
+// value, and whether it differs: values of the encrypted kinds are encrypted with the active key,
+// and the values of other kinds are decrypted.
+func (e *valueEncrypter) reencrypt(path string, value []byte) ([]byte, bool, error) {
+	want := e.encryptsPath(path)
+	if !isEncrypted(value) {
+		if !want {
+			return value, false, nil
+		}
+		encrypted, err := e.encrypt(path, value)
+		return encrypted, err == nil, err
+	}
+	if want {
+		env, err := parseEnvelope(value)
+		if err != nil {
+			return nil, false, err
+		}
+		if env.Key == e.activeKey {
+			return value, false, nil
+		}
+	}
+	plaintext, err := e.decrypt(path, value)
+	if err != nil {
+		return nil, false, err
+	}
+	if !want {
+		return plaintext, true, nil
+	}
+	encrypted, err := e.encrypt(path, plaintext)
+	return encrypted, err == nil, err
+}
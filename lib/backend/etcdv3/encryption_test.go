@@ -0,0 +1,169 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdv3
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+)
+
+var _ = Describe("etcdv3 value encryption", func() {
+	secret1 := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	secret2 := base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210"))
+	key1 := encryptionKey{Name: "key1", Secret: secret1}
+	key2 := encryptionKey{Name: "key2", Secret: secret2}
+	peerKey := model.ResourceKey{Kind: apiv3.KindBGPPeer, Name: "peer"}
+	peerPath := "/calico/resources/v3/projectcalico.org/bgppeers/peer"
+	poolPath := "/calico/resources/v3/projectcalico.org/ippools/pool"
+	value := `{"spec":{"password":"secret"}}`
+
+	newEncrypter := func(keys ...encryptionKey) *valueEncrypter {
+		e, err := newValueEncrypter(encryptionConfig{Kinds: []string{"bgppeer"}, Keys: keys})
+		Expect(err).NotTo(HaveOccurred())
+		return e
+	}
+
+	It("should encrypt values of the configured kinds and decrypt them", func() {
+		e := newEncrypter(key1)
+		stored, err := e.encryptValue(peerKey, peerPath, value)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stored).To(HavePrefix(encryptedValuePrefix))
+		Expect(stored).NotTo(ContainSubstring("secret"))
+
+		plaintext, err := e.decrypt(peerPath, []byte(stored))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plaintext)).To(Equal(value))
+
+		By("not decrypting the value at another key")
+		_, err = e.decrypt("/calico/resources/v3/projectcalico.org/bgppeers/other", []byte(stored))
+		Expect(err).To(HaveOccurred())
+
+		By("not encrypting the values of other kinds")
+		stored, err = e.encryptValue(model.ResourceKey{Kind: apiv3.KindIPPool, Name: "pool"}, poolPath, value)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stored).To(Equal(value))
+	})
+
+	It("should pass plaintext values through, with or without an encrypter", func() {
+		var nilEncrypter *valueEncrypter
+		stored, err := nilEncrypter.encryptValue(peerKey, peerPath, value)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stored).To(Equal(value))
+		for _, e := range []*valueEncrypter{nilEncrypter, newEncrypter(key1)} {
+			plaintext, err := e.decrypt(peerPath, []byte(value))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(plaintext)).To(Equal(value))
+		}
+
+		By("failing to decrypt encrypted values without an encrypter")
+		encrypted, err := newEncrypter(key1).encrypt(peerPath, []byte(value))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = nilEncrypter.decrypt(peerPath, encrypted)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should decrypt values encrypted with an old key and re-encrypt them with the active key", func() {
+		old, err := newEncrypter(key1).encrypt(peerPath, []byte(value))
+		Expect(err).NotTo(HaveOccurred())
+
+		rotated := newEncrypter(key2, key1)
+		plaintext, err := rotated.decrypt(peerPath, old)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plaintext)).To(Equal(value))
+
+		reencrypted, changed, err := rotated.reencrypt(peerPath, old)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		env, err := parseEnvelope(reencrypted)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env.Key).To(Equal("key2"))
+
+		By("not changing values that are already encrypted with the active key")
+		_, changed, err = rotated.reencrypt(peerPath, reencrypted)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeFalse())
+
+		By("failing to decrypt once the old key is removed")
+		_, err = newEncrypter(key2).decrypt(peerPath, old)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should encrypt plaintext values and decrypt values of kinds that are no longer encrypted", func() {
+		e := newEncrypter(key1)
+		encrypted, changed, err := e.reencrypt(peerPath, []byte(value))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(isEncrypted(encrypted)).To(BeTrue())
+
+		poolValue, err := e.encrypt(poolPath, []byte(value))
+		Expect(err).NotTo(HaveOccurred())
+		plaintext, changed, err := e.reencrypt(poolPath, poolValue)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(string(plaintext)).To(Equal(value))
+
+		_, changed, err = e.reencrypt(poolPath, []byte(value))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeFalse())
+	})
+
+	It("should encrypt the status of a resource along with the rest of the resource", func() {
+		e := newEncrypter(key1)
+		Expect(e.encryptsPath(statusPath(peerPath))).To(BeTrue())
+		Expect(e.encryptsPath(statusPath(poolPath))).To(BeFalse())
+
+		encrypted, changed, err := e.reencrypt(statusPath(peerPath), []byte(`{"state":"Established"}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(isEncrypted(encrypted)).To(BeTrue())
+	})
+
+	DescribeTable("should reject invalid encryption configuration",
+		func(cfg encryptionConfig) {
+			_, err := newValueEncrypter(cfg)
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("no keys", encryptionConfig{Kinds: []string{"BGPPeer"}}),
+		Entry("unknown kind", encryptionConfig{Kinds: []string{"Secret"}, Keys: []encryptionKey{key1}}),
+		Entry("unnamed key", encryptionConfig{Keys: []encryptionKey{{Secret: secret1}}}),
+		Entry("duplicate key", encryptionConfig{Keys: []encryptionKey{key1, {Name: "key1", Secret: secret2}}}),
+		Entry("key not base64", encryptionConfig{Keys: []encryptionKey{{Name: "key1", Secret: "not base64!"}}}),
+		Entry("key of the wrong size", encryptionConfig{Keys: []encryptionKey{{Name: "key1", Secret: "c2hvcnQ="}}}),
+	)
+
+	It("should load the encryption key file", func() {
+		f, err := ioutil.TempFile("", "encryption")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(`{"kinds": ["BGPPeer"], "keys": [{"name": "key1", "secret": "` + secret1 + `"}]}`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Close()).NotTo(HaveOccurred())
+
+		e, err := loadValueEncrypter(f.Name())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(e.encrypts(peerKey)).To(BeTrue())
+
+		_, err = loadValueEncrypter("/fake/path")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -20,11 +20,13 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"crypto/tls"
 
 	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/mvcc/mvccpb"
 	"github.com/coreos/etcd/pkg/srv"
 	"github.com/coreos/etcd/pkg/transport"
 	log "github.com/sirupsen/logrus"
@@ -41,8 +43,25 @@ var (
 	keepaliveTimeout = 10 * time.Second
 )
 
+// The number of entries read from the datastore at a time by ReencryptAll.
+const reencryptPageSize = 500
+
 type etcdV3Client struct {
+	// The etcd client and the expiry time of its certificate, which are replaced when the
+	// certificates or credentials are rotated.
+	clientLock sync.RWMutex
 	etcdClient *clientv3.Client
+	certExpiry time.Time
+
+	encrypter *valueEncrypter
+
+	// The leases shared by entries written with the same TTL, keyed by TTL in seconds.
+	leaseLock sync.Mutex
+	leases    map[int64]*ttlLease
+
+	// Closed to stop watching the certificates and credentials.
+	stop      chan struct{}
+	closeOnce sync.Once
 }
 
 func NewEtcdV3Client(config *apiconfig.EtcdConfig) (api.Client, error) {
@@ -70,61 +89,121 @@ func NewEtcdV3Client(config *apiconfig.EtcdConfig) (api.Client, error) {
 		return nil, errors.New("no etcd endpoints specified")
 	}
 
+	// Build the etcdv3 config.
+	cfg, certExpiry, err := buildClientConfig(config, etcdLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load the encryption keys, if values are encrypted at rest.
+	var encrypter *valueEncrypter
+	if config.EtcdEncryptionKeyFile != "" {
+		encrypter, err = loadValueEncrypter(config.EtcdEncryptionKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not initialize etcdv3 client: %v", err)
+		}
+	}
+
+	client, err := clientv3.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &etcdV3Client{
+		etcdClient: client,
+		certExpiry: certExpiry,
+		encrypter:  encrypter,
+		leases:     map[int64]*ttlLease{},
+		stop:       make(chan struct{}),
+	}
+
+	// Rebuild the client whenever the certificates or credentials are rotated.
+	if files := credentialFiles(config); len(files) != 0 {
+		go c.watchCredentials(config, etcdLocation, files)
+	}
+	return c, nil
+}
+
+// buildClientConfig builds the etcdv3 client config from the API config, reading the
+// certificates and credentials from their files.  Also returns the expiry time of the client
+// certificate, which is zero if there isn't one.
+func buildClientConfig(config *apiconfig.EtcdConfig, etcdLocation []string) (clientv3.Config, time.Time, error) {
 	// Create the etcd client
 	// If Etcd Certificate and Key are provided inline through command line agrument,
 	// then the inline values take precedence over the ones in the config file.
 	// All the three parametes, Certificate, key and CA certificate are to be provided inline for processing.
-	var tls *tls.Config
+	var tlsConfig *tls.Config
 	var err error
 
 	haveInline := config.EtcdCert != "" || config.EtcdKey != "" || config.EtcdCACert != ""
 	haveFiles := config.EtcdCertFile != "" || config.EtcdKeyFile != "" || config.EtcdCACertFile != ""
 
 	if haveInline && haveFiles {
-		return nil, fmt.Errorf("Cannot mix inline certificate-key and certificate / key files")
+		return clientv3.Config{}, time.Time{}, fmt.Errorf("Cannot mix inline certificate-key and certificate / key files")
 	}
 
+	var cert *tls.Certificate
 	if haveInline {
 		tlsInfo := &TlsInlineCertKey{
 			CACert: config.EtcdCACert,
 			Cert:   config.EtcdCert,
 			Key:    config.EtcdKey,
 		}
-		tls, err = tlsInfo.ClientConfigInlineCertKey()
+		tlsConfig, err = tlsInfo.ClientConfigInlineCertKey()
+		if err == nil {
+			cert, err = newCert([]byte(config.EtcdCert), []byte(config.EtcdKey))
+		}
 	} else {
 		tlsInfo := &transport.TLSInfo{
 			CAFile:   config.EtcdCACertFile,
 			CertFile: config.EtcdCertFile,
 			KeyFile:  config.EtcdKeyFile,
 		}
-		tls, err = tlsInfo.ClientConfig()
+		tlsConfig, err = tlsInfo.ClientConfig()
+		if err == nil && config.EtcdCertFile != "" && config.EtcdKeyFile != "" {
+			var c tls.Certificate
+			c, err = tls.LoadX509KeyPair(config.EtcdCertFile, config.EtcdKeyFile)
+			cert = &c
+		}
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("could not initialize etcdv3 client: %+v", err)
+		return clientv3.Config{}, time.Time{}, fmt.Errorf("could not initialize etcdv3 client: %+v", err)
+	}
+
+	var certExpiry time.Time
+	if cert != nil {
+		if certExpiry, err = certificateExpiry(cert); err != nil {
+			return clientv3.Config{}, time.Time{}, fmt.Errorf("could not parse etcd client certificate: %v", err)
+		}
 	}
 
 	// Build the etcdv3 config.
 	cfg := clientv3.Config{
 		Endpoints:            etcdLocation,
-		TLS:                  tls,
+		TLS:                  tlsConfig,
 		DialTimeout:          clientTimeout,
 		DialKeepAliveTime:    keepaliveTime,
 		DialKeepAliveTimeout: keepaliveTimeout,
 	}
 
 	// Plumb through the username and password if both are configured.
-	if config.EtcdUsername != "" && config.EtcdPassword != "" {
+	if config.EtcdCredentialsFile != "" {
+		if config.EtcdUsername != "" || config.EtcdPassword != "" {
+			return clientv3.Config{}, time.Time{}, fmt.Errorf("Cannot mix a credentials file and an inline username / password")
+		}
+		creds, err := loadCredentials(config.EtcdCredentialsFile)
+		if err != nil {
+			return clientv3.Config{}, time.Time{}, err
+		}
+		cfg.Username = creds.Username
+		cfg.Password = creds.Password
+	} else if config.EtcdUsername != "" && config.EtcdPassword != "" {
 		cfg.Username = config.EtcdUsername
 		cfg.Password = config.EtcdPassword
 	}
 
-	client, err := clientv3.New(cfg)
-	if err != nil {
-		return nil, err
-	}
-
-	return &etcdV3Client{etcdClient: client}, nil
+	return cfg, certExpiry, nil
 }
 
 // Create an entry in the datastore.  If the entry already exists, this will return
@@ -137,6 +216,11 @@ func (c *etcdV3Client) Create(ctx context.Context, d *model.KVPair) (*model.KVPa
 	if err != nil {
 		return nil, err
 	}
+	stored, err := c.encrypter.encryptValue(d.Key, key, value)
+	if err != nil {
+		logCxt.WithError(err).Error("Failed to encrypt value")
+		return nil, cerrors.ErrorDatastoreError{Err: err, Identifier: d.Key}
+	}
 	logCxt = logCxt.WithField("etcdv3-etcdKey", key)
 
 	putOpts, err := c.getTTLOption(ctx, d)
@@ -144,18 +228,26 @@ func (c *etcdV3Client) Create(ctx context.Context, d *model.KVPair) (*model.KVPa
 		return nil, err
 	}
 
+	// The status of a resource is only written by UpdateStatus, so delete any status left over
+	// from a previous resource with the same key.
+	ops := []clientv3.Op{clientv3.OpPut(key, stored, putOpts...)}
+	if statusField(d.Key, d.Value).IsValid() {
+		ops = append(ops, clientv3.OpDelete(statusPath(key)))
+	}
+
 	// Checking for 0 version of the etcdKey, which means it doesn't exists yet,
 	// and if it does, get the current value.
 	logCxt.Debug("Performing etcdv3 transaction for Create request")
-	txnResp, err := c.etcdClient.Txn(ctx).If(
+	txnResp, err := c.getEtcdClient().Txn(ctx).If(
 		clientv3.Compare(clientv3.Version(key), "=", 0),
 	).Then(
-		clientv3.OpPut(key, value, putOpts...),
+		ops...,
 	).Else(
 		clientv3.OpGet(key),
 	).Commit()
 	if err != nil {
 		logCxt.WithError(err).Warning("Create failed")
+		c.checkLeaseError(err, d.TTL)
 		return nil, cerrors.ErrorDatastoreError{Err: err}
 	}
 
@@ -166,7 +258,7 @@ func (c *etcdV3Client) Create(ctx context.Context, d *model.KVPair) (*model.KVPa
 		var existing *model.KVPair
 		getResp := (*clientv3.GetResponse)(txnResp.Responses[0].GetResponseRange())
 		if len(getResp.Kvs) != 0 {
-			existing, _ = etcdToKVPair(d.Key, getResp.Kvs[0])
+			existing, _ = c.etcdToKVPair(d.Key, getResp.Kvs[0])
 		}
 		return existing, cerrors.ErrorResourceAlreadyExists{Identifier: d.Key}
 	}
@@ -191,6 +283,11 @@ func (c *etcdV3Client) Update(ctx context.Context, d *model.KVPair) (*model.KVPa
 	if err != nil {
 		return nil, err
 	}
+	stored, err := c.encrypter.encryptValue(d.Key, key, value)
+	if err != nil {
+		logCxt.WithError(err).Error("Failed to encrypt value")
+		return nil, cerrors.ErrorDatastoreError{Err: err, Identifier: d.Key}
+	}
 	logCxt = logCxt.WithField("etcdv3-etcdKey", key)
 
 	opts, err := c.getTTLOption(ctx, d)
@@ -205,17 +302,25 @@ func (c *etcdV3Client) Update(ctx context.Context, d *model.KVPair) (*model.KVPa
 	}
 	conds := []clientv3.Cmp{clientv3.Compare(clientv3.ModRevision(key), "=", rev)}
 
+	// The status of a resource is not updated, but get it to return with the resource.
+	ops := []clientv3.Op{clientv3.OpPut(key, stored, opts...)}
+	hasStatus := statusField(d.Key, d.Value).IsValid()
+	if hasStatus {
+		ops = append(ops, clientv3.OpGet(statusPath(key)))
+	}
+
 	logCxt.Debug("Performing etcdv3 transaction for Update request")
-	txnResp, err := c.etcdClient.Txn(ctx).If(
+	txnResp, err := c.getEtcdClient().Txn(ctx).If(
 		conds...,
 	).Then(
-		clientv3.OpPut(key, value, opts...),
+		ops...,
 	).Else(
 		clientv3.OpGet(key),
 	).Commit()
 
 	if err != nil {
 		logCxt.WithError(err).Warning("Update failed")
+		c.checkLeaseError(err, d.TTL)
 		return nil, cerrors.ErrorDatastoreError{Err: err}
 	}
 
@@ -230,7 +335,7 @@ func (c *etcdV3Client) Update(ctx context.Context, d *model.KVPair) (*model.KVPa
 		}
 
 		logCxt.Debug("Update transaction failed due to resource update conflict")
-		existing, _ := etcdToKVPair(d.Key, getResp.Kvs[0])
+		existing, _ := c.etcdToKVPair(d.Key, getResp.Kvs[0])
 		return existing, cerrors.ErrorResourceUpdateConflict{Identifier: d.Key}
 	}
 
@@ -238,6 +343,13 @@ func (c *etcdV3Client) Update(ctx context.Context, d *model.KVPair) (*model.KVPa
 	cerrors.PanicIfErrored(err, "Unexpected error parsing stored datastore entry: %v", value)
 	d.Value = v
 	d.Revision = strconv.FormatInt(txnResp.Header.Revision, 10)
+	if hasStatus {
+		var status *mvccpb.KeyValue
+		if getResp := txnResp.Responses[1].GetResponseRange(); len(getResp.Kvs) != 0 {
+			status = getResp.Kvs[0]
+		}
+		c.setStatus(d, status)
+	}
 
 	return d, nil
 }
@@ -248,22 +360,32 @@ func (c *etcdV3Client) Update(ctx context.Context, d *model.KVPair) (*model.KVPa
 // It's possible that we will just perform that processing in the clients (e.g. calicoctl),
 // but that is to be decided.
 func (c *etcdV3Client) Apply(ctx context.Context, d *model.KVPair) (*model.KVPair, error) {
+	putOpts, err := c.getTTLOption(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	return c.put(ctx, d, putOpts)
+}
+
+// put unconditionally writes the entry with the given options.
+func (c *etcdV3Client) put(ctx context.Context, d *model.KVPair, putOpts []clientv3.OpOption) (*model.KVPair, error) {
 	logCxt := log.WithFields(log.Fields{"etcdKey": d.Key, "value": d.Value, "ttl": d.TTL, "rev": d.Revision})
 	logCxt.Debug("Processing Apply request")
 	key, value, err := getKeyValueStrings(d)
 	if err != nil {
 		return nil, err
 	}
-
-	putOpts, err := c.getTTLOption(ctx, d)
+	stored, err := c.encrypter.encryptValue(d.Key, key, value)
 	if err != nil {
-		return nil, err
+		logCxt.WithError(err).Error("Failed to encrypt value")
+		return nil, cerrors.ErrorDatastoreError{Err: err, Identifier: d.Key}
 	}
 
 	logCxt.Debug("Performing etcdv3 Put for Apply request")
-	resp, err := c.etcdClient.Put(ctx, key, value, putOpts...)
+	resp, err := c.getEtcdClient().Put(ctx, key, stored, putOpts...)
 	if err != nil {
 		logCxt.WithError(err).Warning("Apply failed")
+		c.checkLeaseError(err, d.TTL)
 		return nil, cerrors.ErrorDatastoreError{Err: err}
 	}
 
@@ -298,12 +420,18 @@ func (c *etcdV3Client) Delete(ctx context.Context, k model.Key, revision string)
 		conds = append(conds, clientv3.Compare(clientv3.ModRevision(key), "=", rev))
 	}
 
+	// Delete the status of a resource along with the resource.
+	ops := []clientv3.Op{clientv3.OpDelete(key, clientv3.WithPrevKV())}
+	if _, ok := k.(model.ResourceKey); ok {
+		ops = append(ops, clientv3.OpDelete(statusPath(key)))
+	}
+
 	// Perform the delete transaction - note that this is an exact delete, not a prefix delete.
 	logCxt.Debug("Performing etcdv3 transaction for Delete request")
-	txnResp, err := c.etcdClient.Txn(ctx).If(
+	txnResp, err := c.getEtcdClient().Txn(ctx).If(
 		conds...,
 	).Then(
-		clientv3.OpDelete(key, clientv3.WithPrevKV()),
+		ops...,
 	).Else(
 		clientv3.OpGet(key),
 	).Commit()
@@ -322,7 +450,7 @@ func (c *etcdV3Client) Delete(ctx context.Context, k model.Key, revision string)
 			logCxt.Debug("Delete transaction failed due to resource not existing")
 			return nil, cerrors.ErrorResourceDoesNotExist{Identifier: k}
 		}
-		latestValue, err := etcdToKVPair(k, getResp.Kvs[0])
+		latestValue, err := c.etcdToKVPair(k, getResp.Kvs[0])
 		if err != nil {
 			return nil, err
 		}
@@ -338,7 +466,7 @@ func (c *etcdV3Client) Delete(ctx context.Context, k model.Key, revision string)
 
 	// Parse the deleted value.  Don't propagate the error in this case since the
 	// delete did succeed.
-	previousValue, _ := etcdToKVPair(k, delResp.PrevKvs[0])
+	previousValue, _ := c.etcdToKVPair(k, delResp.PrevKvs[0])
 	return previousValue, nil
 }
 
@@ -364,7 +492,7 @@ func (c *etcdV3Client) Get(ctx context.Context, k model.Key, revision string) (*
 	}
 
 	logCxt.Debug("Calling Get on etcdv3 client")
-	resp, err := c.etcdClient.Get(ctx, key, ops...)
+	resp, err := c.getEtcdClient().Get(ctx, key, ops...)
 	if err != nil {
 		logCxt.WithError(err).Debug("Error returned from etcdv3 client")
 		return nil, cerrors.ErrorDatastoreError{Err: err}
@@ -374,7 +502,14 @@ func (c *etcdV3Client) Get(ctx context.Context, k model.Key, revision string) (*
 		return nil, cerrors.ErrorResourceDoesNotExist{Identifier: k}
 	}
 
-	return etcdToKVPair(k, resp.Kvs[0])
+	kvp, err := c.etcdToKVPair(k, resp.Kvs[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := c.getStatus(ctx, kvp, key, statusRevision(revision, resp.Header.Revision)); err != nil {
+		return nil, err
+	}
+	return kvp, nil
 }
 
 // List entries in the datastore.  This may return an empty list of there are
@@ -397,7 +532,7 @@ func (c *etcdV3Client) List(ctx context.Context, l model.ListInterface, revision
 	}
 
 	logCxt.Debug("Calling Get on etcdv3 client")
-	resp, err := c.etcdClient.Get(ctx, key, ops...)
+	resp, err := c.getEtcdClient().Get(ctx, key, ops...)
 	if err != nil {
 		logCxt.WithError(err).Debug("Error returned from etcdv3 client")
 		return nil, cerrors.ErrorDatastoreError{Err: err}
@@ -407,10 +542,13 @@ func (c *etcdV3Client) List(ctx context.Context, l model.ListInterface, revision
 	// Filter/process the results.
 	list := []*model.KVPair{}
 	for _, p := range resp.Kvs {
-		if kv := convertListResponse(p, l); kv != nil {
+		if kv := c.convertListResponse(p, l); kv != nil {
 			list = append(list, kv)
 		}
 	}
+	if err := c.listStatus(ctx, list, key, ops, statusRevision(revision, resp.Header.Revision)); err != nil {
+		return nil, err
+	}
 
 	return &model.KVPairList{
 		KVPairs:  list,
@@ -457,7 +595,7 @@ func (c *etcdV3Client) EnsureInitialized() error {
 // Clean removes all of the Calico data from the datastore.
 func (c *etcdV3Client) Clean() error {
 	log.Warning("Cleaning etcdv3 datastore of all Calico data")
-	_, err := c.etcdClient.Txn(context.Background()).If().Then(
+	_, err := c.getEtcdClient().Txn(context.Background()).If().Then(
 		clientv3.OpDelete("/calico/", clientv3.WithPrefix()),
 	).Commit()
 
@@ -472,7 +610,7 @@ func (c *etcdV3Client) Clean() error {
 // direct consumers of the backend API to access this.
 func (c *etcdV3Client) IsClean() (bool, error) {
 	log.Debug("Calling Get on etcdv3 client")
-	resp, err := c.etcdClient.Get(context.Background(), "/calico/", clientv3.WithPrefix())
+	resp, err := c.getEtcdClient().Get(context.Background(), "/calico/", clientv3.WithPrefix())
 	if err != nil {
 		log.WithError(err).Debug("Error returned from etcdv3 client")
 		return false, cerrors.ErrorDatastoreError{Err: err}
@@ -482,26 +620,112 @@ func (c *etcdV3Client) IsClean() (bool, error) {
 	return len(resp.Kvs) == 0, nil
 }
 
-// getTTLOption returns a OpOption slice containing a Lease granted for the TTL.
+// ReencryptAll rewrites the /calico/ prefixed entries whose stored form does not match the
+// encryption configuration: values of the encrypted kinds that are plaintext or encrypted with an
+// old key are encrypted with the active key, and encrypted values of other kinds are decrypted.
+// The entries are read a page at a time from a single revision of the datastore.  Returns the
+// number of entries that were rewritten.  Entries that are modified concurrently are skipped, since
+// they are rewritten using the current configuration anyway.  An entry that cannot be rewritten
+// does not stop the others being rewritten: the keys of the entries that failed are returned in an
+// ErrorPartialFailure.  This is not part of the exposed API, but is public to allow direct
+// consumers of the backend API to access this.
+func (c *etcdV3Client) ReencryptAll(ctx context.Context) (int, error) {
+	log.Info("Re-encrypting etcdv3 datastore")
+	numRewritten := 0
+	var failed []string
+	key, end := "/calico/", clientv3.GetPrefixRangeEnd("/calico/")
+	var rev int64
+	for {
+		opts := []clientv3.OpOption{clientv3.WithRange(end), clientv3.WithLimit(reencryptPageSize)}
+		if rev != 0 {
+			opts = append(opts, clientv3.WithRev(rev))
+		}
+		resp, err := c.getEtcdClient().Get(ctx, key, opts...)
+		if err != nil {
+			log.WithError(err).Debug("Error returned from etcdv3 client")
+			return numRewritten, cerrors.ErrorDatastoreError{Err: err}
+		}
+		rev = resp.Header.Revision
+
+		for _, kv := range resp.Kvs {
+			rewritten, err := c.reencrypt(ctx, kv)
+			if err != nil {
+				failed = append(failed, string(kv.Key))
+			} else if rewritten {
+				numRewritten++
+			}
+		}
+		if !resp.More || len(resp.Kvs) == 0 {
+			break
+		}
+		// Continue from the key after the last key of the page.
+		key = string(resp.Kvs[len(resp.Kvs)-1].Key) + "\x00"
+	}
+
+	log.WithFields(log.Fields{"numRewritten": numRewritten, "numFailed": len(failed)}).Info("Re-encrypted etcdv3 datastore")
+	if len(failed) != 0 {
+		return numRewritten, cerrors.ErrorPartialFailure{
+			Err: fmt.Errorf("failed to re-encrypt %d entries: %s", len(failed), strings.Join(failed, ", ")),
+		}
+	}
+	return numRewritten, nil
+}
+
+// reencrypt rewrites a single entry for ReencryptAll, if its stored form does not match the
+// encryption configuration.  Returns true if the entry was rewritten.
+func (c *etcdV3Client) reencrypt(ctx context.Context, kv *mvccpb.KeyValue) (bool, error) {
+	logCxt := log.WithField("etcdv3-etcdKey", string(kv.Key))
+	value, changed, err := c.encrypter.reencrypt(string(kv.Key), kv.Value)
+	if err != nil {
+		logCxt.WithError(err).Error("Failed to re-encrypt value")
+		return false, err
+	}
+	if !changed {
+		return false, nil
+	}
+
+	// Keep the entry's lease, so that entries with a TTL still expire.
+	var putOpts []clientv3.OpOption
+	if kv.Lease != 0 {
+		putOpts = append(putOpts, clientv3.WithLease(clientv3.LeaseID(kv.Lease)))
+	}
+	txnResp, err := c.getEtcdClient().Txn(ctx).If(
+		clientv3.Compare(clientv3.ModRevision(string(kv.Key)), "=", kv.ModRevision),
+	).Then(
+		clientv3.OpPut(string(kv.Key), string(value), putOpts...),
+	).Commit()
+	if err != nil {
+		logCxt.WithError(err).Warning("Re-encrypt failed")
+		return false, cerrors.ErrorDatastoreError{Err: err}
+	}
+	if !txnResp.Succeeded {
+		logCxt.Debug("Entry was modified concurrently, skipping it")
+		return false, nil
+	}
+	return true, nil
+}
+
+// getTTLOption returns a OpOption slice containing the Lease shared by entries with the TTL.
 func (c *etcdV3Client) getTTLOption(ctx context.Context, d *model.KVPair) ([]clientv3.OpOption, error) {
 	putOpts := []clientv3.OpOption{}
 
 	if d.TTL != 0 {
-		resp, err := c.etcdClient.Lease.Grant(ctx, int64(d.TTL.Seconds()))
+		id, err := c.leaseForTTL(ctx, d.TTL)
 		if err != nil {
 			log.WithError(err).Error("Failed to grant a lease")
 			return nil, cerrors.ErrorDatastoreError{Err: err}
 		}
 
-		putOpts = append(putOpts, clientv3.WithLease(resp.ID))
+		putOpts = append(putOpts, clientv3.WithLease(id))
 	}
 
 	return putOpts, nil
 }
 
 // getKeyValueStrings returns the etcdv3 etcdKey and serialized value calculated from the
-// KVPair.
+// KVPair.  The status of a resource is stored separately, so it is not included in the value.
 func getKeyValueStrings(d *model.KVPair) (string, string, error) {
+	d = withoutStatus(d)
 	logCxt := log.WithFields(log.Fields{"model-etcdKey": d.Key, "value": d.Value})
 	key, err := model.KeyToDefaultPath(d.Key)
 	if err != nil {
@@ -125,6 +125,24 @@ var _ = Describe("RulesAPIToBackend", func() {
 		Expect(err).To(HaveOccurred())
 	})
 
+	It("should raise an error if the specified credentials file doesn't exist", func() {
+		_, err := etcdv3.NewEtcdV3Client(&apiconfig.EtcdConfig{
+			EtcdCredentialsFile: "/fake/path",
+			EtcdEndpoints:       "http://fake:2379",
+		})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should raise an error for providing a credentials file and an inline username and password", func() {
+		_, err := etcdv3.NewEtcdV3Client(&apiconfig.EtcdConfig{
+			EtcdCredentialsFile: "/fake/path",
+			EtcdUsername:        "user",
+			EtcdPassword:        "password",
+			EtcdEndpoints:       "http://fake:2379",
+		})
+		Expect(err).To(HaveOccurred())
+	})
+
 	It("should raise an error if conflicting endpoint discovery configuration provided", func() {
 		_, err := etcdv3.NewEtcdV3Client(&apiconfig.EtcdConfig{
 			EtcdEndpoints:    "https://127.0.0.1:5007",
@@ -0,0 +1,142 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdv3
+
+import (
+	"context"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
+	log "github.com/sirupsen/logrus"
+)
+
+// Entries written with a TTL share a lease with the other entries written with the same TTL,
+// rather than each write granting a new lease.  A lease is handed out to new writes for a third
+// of its TTL, after which a new lease is granted for the TTL.  Each lease is kept alive until
+// its last write is a TTL old, so an entry expires between its TTL and four thirds of its TTL
+// after it was written.
+type ttlLease struct {
+	id      clientv3.LeaseID
+	ttl     time.Duration
+	granted time.Time
+
+	// The time of the last write that used the lease.  Guarded by the client's leaseLock.
+	lastUsed time.Time
+}
+
+// leaseSeconds returns the lease TTL, in whole seconds, used for entries written with the TTL.
+func leaseSeconds(ttl time.Duration) int64 {
+	seconds := int64((ttl + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// shareable returns true if new writes may use the lease.
+func (l *ttlLease) shareable(now time.Time) bool {
+	return now.Sub(l.granted) < l.ttl/3
+}
+
+// leaseForTTL returns the lease to attach to an entry written with the TTL, granting a new lease
+// if there isn't one that can be shared.
+func (c *etcdV3Client) leaseForTTL(ctx context.Context, ttl time.Duration) (clientv3.LeaseID, error) {
+	seconds := leaseSeconds(ttl)
+	now := time.Now()
+
+	c.leaseLock.Lock()
+	if l := c.leases[seconds]; l != nil && l.shareable(now) {
+		l.lastUsed = now
+		c.leaseLock.Unlock()
+		return l.id, nil
+	}
+	c.leaseLock.Unlock()
+
+	resp, err := c.getEtcdClient().Lease.Grant(ctx, seconds)
+	if err != nil {
+		return 0, err
+	}
+	l := &ttlLease{
+		id:       resp.ID,
+		ttl:      time.Duration(seconds) * time.Second,
+		granted:  now,
+		lastUsed: now,
+	}
+	log.WithFields(log.Fields{"lease": l.id, "ttl": l.ttl}).Debug("Granted shared lease")
+
+	c.leaseLock.Lock()
+	c.leases[seconds] = l
+	c.leaseLock.Unlock()
+
+	go c.keepLeaseAlive(l)
+	return l.id, nil
+}
+
+// keepLeaseAlive refreshes the lease for as long as it is written to.  Once there have been no
+// writes since the last refresh, the lease is left to expire along with its entries.
+func (c *etcdV3Client) keepLeaseAlive(l *ttlLease) {
+	logCxt := log.WithFields(log.Fields{"lease": l.id, "ttl": l.ttl})
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	lastRefresh := l.granted
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+
+		c.leaseLock.Lock()
+		lastUsed := l.lastUsed
+		c.leaseLock.Unlock()
+		if !lastUsed.After(lastRefresh) {
+			logCxt.Debug("Shared lease no longer in use, leaving it to expire")
+			return
+		}
+
+		now := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), clientTimeout)
+		_, err := c.getEtcdClient().KeepAliveOnce(ctx, l.id)
+		cancel()
+		if err != nil {
+			logCxt.WithError(err).Warning("Failed to refresh shared lease")
+			c.forgetLease(l)
+			return
+		}
+		lastRefresh = now
+	}
+}
+
+// forgetLease stops handing out the lease to new writes.
+func (c *etcdV3Client) forgetLease(l *ttlLease) {
+	c.leaseLock.Lock()
+	defer c.leaseLock.Unlock()
+	seconds := leaseSeconds(l.ttl)
+	if c.leases[seconds] == l {
+		delete(c.leases, seconds)
+	}
+}
+
+// checkLeaseError forgets the shared lease for the TTL if a write failed because the lease no
+// longer exists, so that the next write with the TTL is granted a new lease.
+func (c *etcdV3Client) checkLeaseError(err error, ttl time.Duration) {
+	if ttl == 0 || err != rpctypes.ErrLeaseNotFound {
+		return
+	}
+	c.leaseLock.Lock()
+	defer c.leaseLock.Unlock()
+	delete(c.leases, leaseSeconds(ttl))
+}
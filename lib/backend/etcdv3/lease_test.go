@@ -0,0 +1,42 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdv3
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("etcdv3 shared leases", func() {
+	DescribeTable("should round TTLs up to whole seconds",
+		func(ttl time.Duration, seconds int64) {
+			Expect(leaseSeconds(ttl)).To(Equal(seconds))
+		},
+		Entry("whole seconds", 90*time.Second, int64(90)),
+		Entry("fraction of a second", 1500*time.Millisecond, int64(2)),
+		Entry("less than a second", time.Millisecond, int64(1)),
+	)
+
+	It("should only share a lease for a third of its TTL", func() {
+		granted := time.Now()
+		l := &ttlLease{ttl: 90 * time.Second, granted: granted}
+		Expect(l.shareable(granted)).To(BeTrue())
+		Expect(l.shareable(granted.Add(29 * time.Second))).To(BeTrue())
+		Expect(l.shareable(granted.Add(30 * time.Second))).To(BeFalse())
+	})
+})
@@ -0,0 +1,111 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdv3
+
+import (
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/unai-ttxu/libcalico-go/lib/apiconfig"
+)
+
+var (
+	// How often the certificate and credentials files are checked for changes.
+	credentialsPollInterval = 10 * time.Second
+)
+
+// getEtcdClient returns the current etcd client.
+func (c *etcdV3Client) getEtcdClient() *clientv3.Client {
+	c.clientLock.RLock()
+	defer c.clientLock.RUnlock()
+	return c.etcdClient
+}
+
+// CertificateExpiry returns the time at which the client certificate that is currently in use
+// expires, or the zero time if no client certificate is configured.  This is not part of the
+// exposed API, but is public to allow direct consumers of the backend API to report it.
+func (c *etcdV3Client) CertificateExpiry() time.Time {
+	c.clientLock.RLock()
+	defer c.clientLock.RUnlock()
+	return c.certExpiry
+}
+
+// Close stops watching the certificate and credentials files, stops refreshing the shared leases
+// and closes the etcd client.
+func (c *etcdV3Client) Close() error {
+	c.closeOnce.Do(func() { close(c.stop) })
+	return c.getEtcdClient().Close()
+}
+
+// watchCredentials polls the certificate and credentials files, and rebuilds the etcd client
+// whenever their contents change.  If the client can't be rebuilt, for example because only some
+// of the files have been updated so far, the current client is kept and the rebuild is retried at
+// the next poll.
+func (c *etcdV3Client) watchCredentials(config *apiconfig.EtcdConfig, etcdLocation []string, files []string) {
+	logCxt := log.WithField("files", files)
+	logCxt.Debug("Watching etcd certificates and credentials")
+	last := fingerprintFiles(files)
+	ticker := time.NewTicker(credentialsPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			logCxt.Debug("Stopped watching etcd certificates and credentials")
+			return
+		case <-ticker.C:
+		}
+
+		fingerprint := fingerprintFiles(files)
+		if fingerprint == last {
+			continue
+		}
+		logCxt.Info("etcd certificates or credentials changed, reconnecting")
+		if err := c.reloadClient(config, etcdLocation); err != nil {
+			logCxt.WithError(err).Warning("Failed to reconnect with the new certificates or credentials, will retry")
+			continue
+		}
+		last = fingerprint
+	}
+}
+
+// reloadClient replaces the etcd client with one built from the current certificates and
+// credentials.  The old client is closed once requests in progress have had time to complete,
+// which terminates its watches; their owners restart them using the new client.
+func (c *etcdV3Client) reloadClient(config *apiconfig.EtcdConfig, etcdLocation []string) error {
+	cfg, certExpiry, err := buildClientConfig(config, etcdLocation)
+	if err != nil {
+		return err
+	}
+	client, err := clientv3.New(cfg)
+	if err != nil {
+		return err
+	}
+
+	c.clientLock.Lock()
+	old := c.etcdClient
+	c.etcdClient = client
+	c.certExpiry = certExpiry
+	c.clientLock.Unlock()
+	log.WithField("certExpiry", certExpiry).Info("Reconnected to etcd")
+
+	time.AfterFunc(clientTimeout, func() {
+		if err := old.Close(); err != nil {
+			log.WithError(err).Debug("Error closing previous etcd client")
+		}
+	})
+	return nil
+}
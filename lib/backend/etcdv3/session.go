@@ -0,0 +1,109 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdv3
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/unai-ttxu/libcalico-go/lib/backend/api"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+)
+
+var errSessionEnded = errors.New("session has ended")
+
+// etcdSession binds entries to a lease that is kept alive until the session is closed.  The
+// session also ends if the lease can't be kept alive, for example because etcd was unreachable
+// for longer than the TTL, or because the etcd client was rebuilt with new credentials.
+type etcdSession struct {
+	client *etcdV3Client
+	id     clientv3.LeaseID
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	closeOnce sync.Once
+}
+
+// NewSession starts a session whose entries are deleted when it is closed, or when etcd
+// hasn't heard from the client for the TTL.
+func (c *etcdV3Client) NewSession(ctx context.Context, ttl time.Duration) (api.Session, error) {
+	etcdClient := c.getEtcdClient()
+	resp, err := etcdClient.Lease.Grant(ctx, leaseSeconds(ttl))
+	if err != nil {
+		log.WithError(err).Error("Failed to grant a session lease")
+		return nil, cerrors.ErrorDatastoreError{Err: err}
+	}
+
+	keepAliveCtx, cancel := context.WithCancel(context.Background())
+	keepAlives, err := etcdClient.Lease.KeepAlive(keepAliveCtx, resp.ID)
+	if err != nil {
+		cancel()
+		log.WithError(err).Error("Failed to keep the session lease alive")
+		return nil, cerrors.ErrorDatastoreError{Err: err}
+	}
+
+	s := &etcdSession{
+		client: c,
+		id:     resp.ID,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	log.WithFields(log.Fields{"lease": s.id, "ttl": ttl}).Debug("Started session")
+	go s.keepAlive(keepAlives)
+	return s, nil
+}
+
+// keepAlive consumes the keepalive responses until the lease can no longer be kept alive, or
+// the session is closed.
+func (s *etcdSession) keepAlive(keepAlives <-chan *clientv3.LeaseKeepAliveResponse) {
+	for range keepAlives {
+	}
+	log.WithField("lease", s.id).Info("Session ended")
+	close(s.done)
+}
+
+func (s *etcdSession) Apply(ctx context.Context, d *model.KVPair) (*model.KVPair, error) {
+	select {
+	case <-s.done:
+		return nil, cerrors.ErrorDatastoreError{Err: errSessionEnded, Identifier: d.Key}
+	default:
+	}
+	return s.client.put(ctx, d, []clientv3.OpOption{clientv3.WithLease(s.id)})
+}
+
+func (s *etcdSession) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close stops keeping the lease alive and revokes it, which deletes the session's entries.
+func (s *etcdSession) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		s.cancel()
+		ctx, cancel := context.WithTimeout(context.Background(), clientTimeout)
+		defer cancel()
+		if _, err = s.client.getEtcdClient().Revoke(ctx, s.id); err != nil {
+			log.WithError(err).WithField("lease", s.id).Warning("Failed to revoke session lease")
+			err = cerrors.ErrorDatastoreError{Err: err}
+		}
+	})
+	return err
+}
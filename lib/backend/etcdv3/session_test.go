@@ -0,0 +1,85 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdv3_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/unai-ttxu/libcalico-go/lib/apiconfig"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/api"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/etcdv3"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+)
+
+var _ = Describe("[Datastore] etcdv3 sessions and TTLs", func() {
+	ctx := context.Background()
+	statusKey := model.ActiveStatusReportKey{Hostname: "node1", RegionString: "no-region"}
+	statusReport := &model.StatusReport{Timestamp: "2019-01-01T00:00:00Z", UptimeSeconds: 10}
+	var client api.Client
+
+	BeforeEach(func() {
+		var err error
+		client, err = etcdv3.NewEtcdV3Client(&apiconfig.EtcdConfig{EtcdEndpoints: "http://127.0.0.1:2379"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(client.Clean()).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(client.Close()).NotTo(HaveOccurred())
+	})
+
+	It("should delete the entries bound to a session when it is closed", func() {
+		session, err := client.(api.SessionClient).NewSession(ctx, 10*time.Second)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = session.Apply(ctx, &model.KVPair{Key: statusKey, Value: statusReport})
+		Expect(err).NotTo(HaveOccurred())
+
+		kvp, err := client.Get(ctx, statusKey, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kvp.Value).To(Equal(statusReport))
+
+		Expect(session.Close()).NotTo(HaveOccurred())
+		Eventually(session.Done()).Should(BeClosed())
+		_, err = client.Get(ctx, statusKey, "")
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorResourceDoesNotExist{}))
+
+		By("failing to apply entries once the session has ended")
+		_, err = session.Apply(ctx, &model.KVPair{Key: statusKey, Value: statusReport})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should expire entries written with a TTL", func() {
+		for _, hostname := range []string{"node1", "node2"} {
+			_, err := client.Apply(ctx, &model.KVPair{
+				Key:   model.ActiveStatusReportKey{Hostname: hostname, RegionString: "no-region"},
+				Value: statusReport,
+				TTL:   2 * time.Second,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		}
+		_, err := client.Get(ctx, statusKey, "")
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func() error {
+			_, err := client.Get(ctx, statusKey, "")
+			return err
+		}, 10*time.Second, 200*time.Millisecond).Should(BeAssignableToTypeOf(cerrors.ErrorResourceDoesNotExist{}))
+	})
+})
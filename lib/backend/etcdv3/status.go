@@ -0,0 +1,305 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdv3
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/unai-ttxu/libcalico-go/lib/backend/api"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+)
+
+// The status of a resource is stored under a separate key from the rest of the resource, so that
+// status updates never conflict with updates to the rest of the resource.  The status keys
+// mirror the resource keys under a separate root, and hold the status as JSON, encrypted if the
+// rest of the resource is.  The status is filled in by Get, List and Watch, and a watch reports
+// a change to the status as a modification of the resource.
+const (
+	resourcesRoot = "/calico/resources/v3/"
+	statusRoot    = "/calico/status/v3/"
+)
+
+var _ api.StatusClient = (*etcdV3Client)(nil)
+
+// UpdateStatus replaces the status of an existing resource.  This errors if the resource does
+// not exist, or if the kind of resource does not have a status.
+func (c *etcdV3Client) UpdateStatus(ctx context.Context, d *model.KVPair) (*model.KVPair, error) {
+	logCxt := log.WithFields(log.Fields{"model-etcdKey": d.Key, "value": d.Value})
+	logCxt.Debug("Processing UpdateStatus request")
+
+	status := statusField(d.Key, d.Value)
+	if !status.IsValid() {
+		return nil, cerrors.ErrorOperationNotSupported{
+			Identifier: d.Key,
+			Operation:  "UpdateStatus",
+		}
+	}
+	key, err := model.KeyToDefaultPath(d.Key)
+	if err != nil {
+		return nil, cerrors.ErrorDatastoreError{Err: err, Identifier: d.Key}
+	}
+	logCxt = logCxt.WithField("etcdv3-etcdKey", statusPath(key))
+
+	// An empty status is deleted rather than stored.
+	op := clientv3.OpDelete(statusPath(key))
+	if !isZero(status) {
+		value, err := json.Marshal(status.Interface())
+		if err != nil {
+			return nil, cerrors.ErrorDatastoreError{Err: err, Identifier: d.Key}
+		}
+		stored, err := c.encrypter.encryptValue(d.Key, statusPath(key), string(value))
+		if err != nil {
+			logCxt.WithError(err).Error("Failed to encrypt status")
+			return nil, cerrors.ErrorDatastoreError{Err: err, Identifier: d.Key}
+		}
+		op = clientv3.OpPut(statusPath(key), stored)
+	}
+
+	// Only write the status if the resource exists, and get the rest of the resource to return.
+	logCxt.Debug("Performing etcdv3 transaction for UpdateStatus request")
+	txnResp, err := c.getEtcdClient().Txn(ctx).If(
+		clientv3.Compare(clientv3.Version(key), ">", 0),
+	).Then(
+		clientv3.OpGet(key), op,
+	).Commit()
+	if err != nil {
+		logCxt.WithError(err).Warning("UpdateStatus failed")
+		return nil, cerrors.ErrorDatastoreError{Err: err}
+	}
+	if !txnResp.Succeeded {
+		logCxt.Debug("UpdateStatus transaction failed due to resource not existing")
+		return nil, cerrors.ErrorResourceDoesNotExist{Identifier: d.Key}
+	}
+
+	getResp := txnResp.Responses[0].GetResponseRange()
+	kvp, err := c.etcdToKVPair(d.Key, getResp.Kvs[0])
+	if err != nil {
+		return nil, err
+	}
+	statusField(kvp.Key, kvp.Value).Set(status)
+	return kvp, nil
+}
+
+// statusField returns the status of the resource in the KVPair value, or an invalid value if the
+// value is not a resource with a status.
+func statusField(k model.Key, value interface{}) reflect.Value {
+	if _, ok := k.(model.ResourceKey); !ok {
+		return reflect.Value{}
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	return v.Elem().FieldByName("Status")
+}
+
+// listHasStatus returns true if the listed resources have a status.
+func listHasStatus(l model.ListInterface) bool {
+	rl, ok := l.(model.ResourceListOptions)
+	if !ok {
+		return false
+	}
+	key := model.ResourceKey{Kind: rl.Kind}
+	value, err := model.ParseValue(key, []byte("{}"))
+	return err == nil && statusField(key, value).IsValid()
+}
+
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+// statusPath returns the etcdv3 key of the status of the resource with the etcdv3 key.
+func statusPath(key string) string {
+	return statusRoot + strings.TrimPrefix(key, resourcesRoot)
+}
+
+// resourcePath returns the etcdv3 key of the resource whose status has the etcdv3 key, or the
+// key itself if it is not the key of a status.
+func resourcePath(key string) string {
+	if !strings.HasPrefix(key, statusRoot) {
+		return key
+	}
+	return resourcesRoot + strings.TrimPrefix(key, statusRoot)
+}
+
+// withoutStatus returns the KVPair with the status of its resource cleared, copying the resource
+// rather than modifying it.
+func withoutStatus(d *model.KVPair) *model.KVPair {
+	status := statusField(d.Key, d.Value)
+	if !status.IsValid() || isZero(status) {
+		return d
+	}
+	out := *d
+	out.Value = d.Value.(runtime.Object).DeepCopyObject()
+	statusField(out.Key, out.Value).Set(reflect.Zero(status.Type()))
+	return &out
+}
+
+// setStatus sets the status of the resource in the KVPair from the etcdv3 status entry, which is
+// nil if the resource has no status.
+func (c *etcdV3Client) setStatus(d *model.KVPair, ekv *mvccpb.KeyValue) {
+	status := statusField(d.Key, d.Value)
+	if !status.IsValid() {
+		return
+	}
+	status.Set(reflect.Zero(status.Type()))
+	if ekv == nil {
+		return
+	}
+	value, err := c.encrypter.decrypt(string(ekv.Key), ekv.Value)
+	if err != nil {
+		log.WithError(err).WithField("etcdv3-etcdKey", string(ekv.Key)).Warning("Failed to decrypt resource status")
+		return
+	}
+	v := reflect.New(status.Type())
+	if err := json.Unmarshal(value, v.Interface()); err != nil {
+		log.WithError(err).WithField("etcdv3-etcdKey", string(ekv.Key)).Warning("Failed to parse resource status")
+		return
+	}
+	status.Set(v.Elem())
+}
+
+// getStatus gets the status of the resource in the KVPair, which has the etcdv3 key, at the
+// revision.
+func (c *etcdV3Client) getStatus(ctx context.Context, d *model.KVPair, key string, rev int64) error {
+	if !statusField(d.Key, d.Value).IsValid() {
+		return nil
+	}
+	resp, err := c.getEtcdClient().Get(ctx, statusPath(key), clientv3.WithRev(rev))
+	if err != nil {
+		log.WithError(err).Debug("Error returned from etcdv3 client")
+		return cerrors.ErrorDatastoreError{Err: err}
+	}
+	var ekv *mvccpb.KeyValue
+	if len(resp.Kvs) != 0 {
+		ekv = resp.Kvs[0]
+	}
+	c.setStatus(d, ekv)
+	return nil
+}
+
+// listStatus gets the statuses of the listed resources at the revision.  The key and options
+// are those used to list the resources.
+func (c *etcdV3Client) listStatus(ctx context.Context, kvps []*model.KVPair, key string, ops []clientv3.OpOption, rev int64) error {
+	if len(kvps) == 0 || !statusField(kvps[0].Key, kvps[0].Value).IsValid() {
+		return nil
+	}
+	statusOps := append([]clientv3.OpOption{}, ops...)
+	statusOps = append(statusOps, clientv3.WithRev(rev))
+	resp, err := c.getEtcdClient().Get(ctx, statusPath(key), statusOps...)
+	if err != nil {
+		log.WithError(err).Debug("Error returned from etcdv3 client")
+		return cerrors.ErrorDatastoreError{Err: err}
+	}
+
+	statuses := map[string]*mvccpb.KeyValue{}
+	for _, ekv := range resp.Kvs {
+		statuses[string(ekv.Key)] = ekv
+	}
+	for _, kvp := range kvps {
+		path, err := model.KeyToDefaultPath(kvp.Key)
+		if err != nil {
+			return err
+		}
+		c.setStatus(kvp, statuses[statusPath(path)])
+	}
+	return nil
+}
+
+// statusRevision returns the revision at which to read the status of resources that were read
+// at the requested revision, which may be empty, and the revision of the response.
+func statusRevision(revision string, respRev int64) int64 {
+	if rev, err := strconv.ParseInt(revision, 10, 64); err == nil {
+		return rev
+	}
+	return respRev
+}
+
+// convertStatusEvent converts an etcdv3 watch event on the status of a resource to an
+// api.WatchEvent modifying the resource, or nil if the event did not correspond to a resource
+// that we are interested in.  The status of a resource is deleted along with the resource, so
+// there is no event if the resource no longer exists.  If the resource was created after the
+// revision of the last event reported for it, the event adds the resource.
+func (c *etcdV3Client) convertStatusEvent(ctx context.Context, e *clientv3.Event, l model.ListInterface, since int64) (*api.WatchEvent, error) {
+	path := resourcePath(string(e.Kv.Key))
+	log.WithField("etcdv3-etcdKey", string(e.Kv.Key)).Debug("Processing etcdv3 status event")
+	k := l.KeyFromDefaultPath(path)
+	if k == nil {
+		log.WithField("key", path).Debug("key filtered")
+		return nil, nil
+	}
+
+	resp, err := c.getEtcdClient().Get(ctx, path, clientv3.WithRev(e.Kv.ModRevision))
+	if err != nil {
+		log.WithError(err).Debug("Error returned from etcdv3 client")
+		return nil, cerrors.ErrorDatastoreError{Err: err}
+	}
+	if len(resp.Kvs) == 0 {
+		log.WithField("key", path).Debug("Resource deleted with its status")
+		return nil, nil
+	}
+
+	newKV, err := c.etcdToKVPair(k, resp.Kvs[0])
+	if err != nil {
+		return nil, err
+	}
+	if e.Type != clientv3.EventTypeDelete {
+		c.setStatus(newKV, e.Kv)
+	} else {
+		c.setStatus(newKV, nil)
+	}
+	if resp.Kvs[0].CreateRevision > since {
+		return &api.WatchEvent{New: newKV, Type: api.WatchAdded}, nil
+	}
+
+	// Parse the resource again for the resource with the old status.
+	oldKV, err := c.etcdToKVPair(k, resp.Kvs[0])
+	if err != nil {
+		return nil, err
+	}
+	c.setStatus(oldKV, e.PrevKv)
+	return &api.WatchEvent{
+		Old:  oldKV,
+		New:  newKV,
+		Type: api.WatchModified,
+	}, nil
+}
+
+// watchEventStatus sets the status of the resources in a watch event for a change to the rest of
+// the resource, from the status at the revision of the change.
+func (c *etcdV3Client) watchEventStatus(ctx context.Context, ae *api.WatchEvent, e *clientv3.Event) error {
+	key := string(e.Kv.Key)
+	if ae.New != nil {
+		if err := c.getStatus(ctx, ae.New, key, e.Kv.ModRevision); err != nil {
+			return err
+		}
+	}
+	if ae.Old != nil {
+		if err := c.getStatus(ctx, ae.Old, key, e.Kv.ModRevision-1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcdv3_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/unai-ttxu/libcalico-go/lib/apiconfig"
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/api"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/etcdv3"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+)
+
+var _ = Describe("[Datastore] etcdv3 resource status", func() {
+	ctx := context.Background()
+	nodeKey := model.ResourceKey{Kind: apiv3.KindNode, Name: "node1"}
+	status := apiv3.NodeStatus{AgentVersions: map[string]string{"felix": "v3.10.0"}}
+	var client api.Client
+
+	newNode := func() *apiv3.Node {
+		node := apiv3.NewNode()
+		node.Name = "node1"
+		node.Spec.IPv4VXLANTunnelAddr = "10.0.0.1"
+		node.Status = status
+		return node
+	}
+
+	BeforeEach(func() {
+		var err error
+		client, err = etcdv3.NewEtcdV3Client(&apiconfig.EtcdConfig{EtcdEndpoints: "http://127.0.0.1:2379"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(client.Clean()).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(client.Close()).NotTo(HaveOccurred())
+	})
+
+	It("should store the status separately from the rest of the resource", func() {
+		created, err := client.Create(ctx, &model.KVPair{Key: nodeKey, Value: newNode()})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(created.Value.(*apiv3.Node).Status).To(Equal(apiv3.NodeStatus{}))
+
+		updated, err := client.(api.StatusClient).UpdateStatus(ctx, &model.KVPair{Key: nodeKey, Value: newNode()})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Value.(*apiv3.Node).Status).To(Equal(status))
+		Expect(updated.Revision).To(Equal(created.Revision))
+
+		kvp, err := client.Get(ctx, nodeKey, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kvp.Value.(*apiv3.Node).Status).To(Equal(status))
+		kvps, err := client.List(ctx, model.ResourceListOptions{Kind: apiv3.KindNode}, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kvps.KVPairs).To(HaveLen(1))
+		Expect(kvps.KVPairs[0].Value.(*apiv3.Node).Status).To(Equal(status))
+
+		By("Updating the rest of the resource with the revision from before the status update")
+		node := newNode()
+		node.Spec.IPv4VXLANTunnelAddr = "10.0.0.2"
+		node.Status = apiv3.NodeStatus{}
+		updated, err = client.Update(ctx, &model.KVPair{Key: nodeKey, Value: node, Revision: created.Revision})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Value.(*apiv3.Node).Spec.IPv4VXLANTunnelAddr).To(Equal("10.0.0.2"))
+		Expect(updated.Value.(*apiv3.Node).Status).To(Equal(status))
+
+		By("Reading the status at an earlier revision")
+		kvp, err = client.Get(ctx, nodeKey, created.Revision)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kvp.Value.(*apiv3.Node).Status).To(Equal(apiv3.NodeStatus{}))
+
+		By("Deleting the resource along with its status")
+		_, err = client.Delete(ctx, nodeKey, "")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.Create(ctx, &model.KVPair{Key: nodeKey, Value: newNode()})
+		Expect(err).NotTo(HaveOccurred())
+		kvp, err = client.Get(ctx, nodeKey, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kvp.Value.(*apiv3.Node).Status).To(Equal(apiv3.NodeStatus{}))
+	})
+
+	It("should only update the status of existing resources that have a status", func() {
+		_, err := client.(api.StatusClient).UpdateStatus(ctx, &model.KVPair{Key: nodeKey, Value: newNode()})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorResourceDoesNotExist{}))
+
+		profile := apiv3.NewProfile()
+		profile.Name = "profile1"
+		profileKey := model.ResourceKey{Kind: apiv3.KindProfile, Name: "profile1"}
+		_, err = client.Create(ctx, &model.KVPair{Key: profileKey, Value: profile})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.(api.StatusClient).UpdateStatus(ctx, &model.KVPair{Key: profileKey, Value: profile})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorOperationNotSupported{}))
+	})
+
+	It("should report changes to the status in watch events", func() {
+		created, err := client.Create(ctx, &model.KVPair{Key: nodeKey, Value: newNode()})
+		Expect(err).NotTo(HaveOccurred())
+		w, err := client.Watch(ctx, model.ResourceListOptions{Kind: apiv3.KindNode}, created.Revision)
+		Expect(err).NotTo(HaveOccurred())
+		defer w.Stop()
+
+		By("Reporting a status update as a modification of the resource")
+		_, err = client.(api.StatusClient).UpdateStatus(ctx, &model.KVPair{Key: nodeKey, Value: newNode()})
+		Expect(err).NotTo(HaveOccurred())
+		var event api.WatchEvent
+		Eventually(w.ResultChan()).Should(Receive(&event))
+		Expect(event.Type).To(Equal(api.WatchModified))
+		Expect(event.Old.Value.(*apiv3.Node).Status).To(Equal(apiv3.NodeStatus{}))
+		Expect(event.New.Value.(*apiv3.Node).Status).To(Equal(status))
+		Expect(event.New.Revision).To(Equal(created.Revision))
+
+		By("Including the status in events for updates to the rest of the resource")
+		node := newNode()
+		node.Spec.IPv4VXLANTunnelAddr = "10.0.0.2"
+		_, err = client.Update(ctx, &model.KVPair{Key: nodeKey, Value: node, Revision: created.Revision})
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(w.ResultChan()).Should(Receive(&event))
+		Expect(event.Type).To(Equal(api.WatchModified))
+		Expect(event.New.Value.(*apiv3.Node).Spec.IPv4VXLANTunnelAddr).To(Equal("10.0.0.2"))
+		Expect(event.New.Value.(*apiv3.Node).Status).To(Equal(status))
+
+		By("Not reporting the status delete when the resource is deleted")
+		_, err = client.Delete(ctx, nodeKey, "")
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(w.ResultChan()).Should(Receive(&event))
+		Expect(event.Type).To(Equal(api.WatchDeleted))
+		Expect(event.Old.Value.(*apiv3.Node).Status).To(Equal(status))
+		Consistently(w.ResultChan()).ShouldNot(Receive())
+	})
+})
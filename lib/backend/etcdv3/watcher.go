@@ -63,6 +63,20 @@ type watcher struct {
 	resultChan chan api.WatchEvent
 	list       model.ListInterface
 	terminated uint32
+
+	// The revision of the last event reported for each resource, by etcdv3 key, when also
+	// watching the statuses of the resources.  The entry for a deleted resource is needed to
+	// skip the status events older than the delete, so it is kept until the status watch has
+	// passed the revision of the delete.
+	revisions map[string]int64
+	deletes   []deletedResource
+	statusRev int64
+}
+
+// deletedResource is a resource whose delete has been reported by the watcher.
+type deletedResource struct {
+	path     string
+	revision int64
 }
 
 // Stop stops the watcher and releases associated resources.
@@ -107,8 +121,28 @@ func (wc *watcher) watchLoop() {
 		"rev":            wc.initialRev,
 	})
 	logCxt.Debug("Starting etcdv3 watch")
-	wch := wc.client.etcdClient.Watch(wc.ctx, key, opts...)
-	for wres := range wch {
+	wch := wc.client.getEtcdClient().Watch(wc.ctx, key, opts...)
+
+	// The status of a resource is stored separately, so also watch the statuses of the
+	// resources.  A nil channel is never ready, so there are no status events otherwise.
+	var sch clientv3.WatchChan
+	if listHasStatus(wc.list) {
+		logCxt.Debug("Starting etcdv3 status watch")
+		sch = wc.client.getEtcdClient().Watch(wc.ctx, statusPath(key), append(opts, clientv3.WithProgressNotify())...)
+		wc.revisions = map[string]int64{}
+	}
+
+	for {
+		var wres clientv3.WatchResponse
+		var ok, status bool
+		select {
+		case wres, ok = <-wch:
+		case wres, ok = <-sch:
+			status = true
+		}
+		if !ok {
+			break
+		}
 		if wres.Err() != nil {
 			// A watch channel error is a terminating event, so exit the loop.
 			err := wres.Err()
@@ -116,15 +150,46 @@ func (wc *watcher) watchLoop() {
 			wc.sendError(err, true)
 			return
 		}
+		if status && wres.IsProgressNotify() {
+			wc.statusWatchedTo(wres.Header.Revision)
+			continue
+		}
 		for _, e := range wres.Events {
 			// Convert the etcdv3 event to the equivalent Watcher event.  An error
 			// parsing the event is returned as an error, but don't exit the watcher as
 			// restarting the watcher is unlikely to fix the conversion error.
-			if ae, err := convertWatchEvent(e, wc.list); ae != nil {
-				wc.sendEvent(ae)
+			path := resourcePath(string(e.Kv.Key))
+			if sch != nil && e.Kv.ModRevision <= wc.revisions[path] {
+				log.WithField("etcdv3-etcdKey", string(e.Kv.Key)).Debug("Skipping event older than the last event for the resource")
+				continue
+			}
+			var ae *api.WatchEvent
+			var err error
+			if status {
+				ae, err = wc.client.convertStatusEvent(wc.ctx, e, wc.list, wc.lastRevision(path))
+			} else if ae, err = wc.client.convertWatchEvent(e, wc.list); ae != nil && sch != nil {
+				err = wc.client.watchEventStatus(wc.ctx, ae, e)
+			}
+			if _, ok := err.(errors.ErrorDatastoreError); ok {
+				// Failing to read the status is a terminating event, so that the
+				// watch is restarted rather than missing the status.
+				log.WithError(err).Error("Failed to read resource status for watch event")
+				wc.sendError(err, true)
+				return
 			} else if err != nil {
 				wc.sendError(err, false)
+			} else if ae != nil {
+				if sch != nil {
+					wc.revisions[path] = e.Kv.ModRevision
+				}
+				wc.sendEvent(ae)
 			}
+			if sch != nil && !status && e.Type == clientv3.EventTypeDelete {
+				wc.deleted(path, e.Kv.ModRevision)
+			}
+		}
+		if status && len(wres.Events) > 0 {
+			wc.statusWatchedTo(wres.Events[len(wres.Events)-1].Kv.ModRevision)
 		}
 	}
 
@@ -134,6 +199,42 @@ func (wc *watcher) watchLoop() {
 	wc.sendError(goerrors.New("etcdv3 watch channel closed"), true)
 }
 
+// lastRevision returns the revision of the last event reported for the resource with the etcdv3
+// key, or the revision that the watch started from if there hasn't been one.
+//
+// The resource and status watches are not ordered with respect to each other.  Each event
+// reports the resource, and its status, as they were at the revision of the event, so an event
+// older than the last event reported for the resource is skipped.
+func (wc *watcher) lastRevision(path string) int64 {
+	if rev := wc.revisions[path]; rev > wc.initialRev {
+		return rev
+	}
+	return wc.initialRev
+}
+
+// deleted records that the resource with the etcdv3 key was deleted at the revision, and forgets
+// the last revision reported for it once there can be no older status events for it.
+func (wc *watcher) deleted(path string, rev int64) {
+	wc.deletes = append(wc.deletes, deletedResource{path: path, revision: rev})
+	wc.statusWatchedTo(wc.statusRev)
+}
+
+// statusWatchedTo records that the status watch has reported all of the events up to the
+// revision, and forgets the last revisions reported for the resources deleted by then.  The
+// resource watch reports deletes in order of revision.
+func (wc *watcher) statusWatchedTo(rev int64) {
+	if rev > wc.statusRev {
+		wc.statusRev = rev
+	}
+	for len(wc.deletes) > 0 && wc.deletes[0].revision <= wc.statusRev {
+		d := wc.deletes[0]
+		wc.deletes = wc.deletes[1:]
+		if wc.revisions[d.path] <= d.revision {
+			delete(wc.revisions, d.path)
+		}
+	}
+}
+
 // listCurrent retrieves the existing entries and sends an event for each listed
 func (wc *watcher) listCurrent() error {
 	log.Info("Performing initial list with no revision")
@@ -141,6 +141,12 @@ func NewKubeClient(ca *apiconfig.CalicoAPIConfigSpec) (api.Client, error) {
 		apiv3.KindIPPool,
 		resources.NewIPPoolClient(cs, crdClientV1),
 	)
+	kubeClient.registerResourceClient(
+		reflect.TypeOf(model.ResourceKey{}),
+		reflect.TypeOf(model.ResourceListOptions{}),
+		apiv3.KindIPReservation,
+		resources.NewIPReservationClient(cs, crdClientV1),
+	)
 	kubeClient.registerResourceClient(
 		reflect.TypeOf(model.ResourceKey{}),
 		reflect.TypeOf(model.ResourceListOptions{}),
@@ -214,6 +220,10 @@ func NewKubeClient(ca *apiconfig.CalicoAPIConfigSpec) (api.Client, error) {
 		resources.NewWorkloadEndpointClient(cs),
 	)
 
+	// The nodes' pod CIDRs can be listed in either IPAM mode, so that Calico IPAM can honour them.
+	podCIDRClient := resources.NewPodCIDRBlockAffinityClient(cs)
+	kubeClient.clientsByListType[reflect.TypeOf(model.PodCIDRListOptions{})] = podCIDRClient
+
 	if ca.K8sUsePodCIDR {
 		// Using host-local IPAM. Use Kubernetes pod CIDRs to back IPAM.
 		log.Info("Using host-local IPAM")
@@ -221,7 +231,7 @@ func NewKubeClient(ca *apiconfig.CalicoAPIConfigSpec) (api.Client, error) {
 			reflect.TypeOf(model.BlockAffinityKey{}),
 			reflect.TypeOf(model.BlockAffinityListOptions{}),
 			apiv3.KindBlockAffinity,
-			resources.NewPodCIDRBlockAffinityClient(cs),
+			podCIDRClient,
 		)
 	} else {
 		// Using Calico IPAM - use CRDs to back IPAM resources.
@@ -303,6 +313,12 @@ func (c *KubeClient) EnsureInitialized() error {
 	return nil
 }
 
+// Close is a no-op: the Kubernetes clients don't run anything in the background that needs to be
+// stopped.
+func (c *KubeClient) Close() error {
+	return nil
+}
+
 // Remove Calico-creatable data from the datastore.  This is purely used for the
 // test framework.
 func (c *KubeClient) Clean() error {
@@ -317,6 +333,7 @@ func (c *KubeClient) Clean() error {
 		apiv3.KindNetworkPolicy,
 		apiv3.KindNetworkSet,
 		apiv3.KindIPPool,
+		apiv3.KindIPReservation,
 		apiv3.KindHostEndpoint,
 	}
 	ctx := context.Background()
@@ -396,6 +413,8 @@ func buildCRDClientV1(cfg rest.Config) (*rest.RESTClient, error) {
 				&apiv3.FelixConfigurationList{},
 				&apiv3.IPPool{},
 				&apiv3.IPPoolList{},
+				&apiv3.IPReservation{},
+				&apiv3.IPReservationList{},
 				&apiv3.BGPPeer{},
 				&apiv3.BGPPeerList{},
 				&apiv3.BGPConfiguration{},
@@ -458,6 +477,21 @@ func (c *KubeClient) Update(ctx context.Context, d *model.KVPair) (*model.KVPair
 	return client.Update(ctx, d)
 }
 
+// UpdateStatus updates the status of an existing resource in the datastore.  This errors if the
+// resource does not exist, or if the kind of resource does not have a status.
+func (c *KubeClient) UpdateStatus(ctx context.Context, d *model.KVPair) (*model.KVPair, error) {
+	log.Debugf("Performing 'UpdateStatus' for %+v", d)
+	client, ok := c.getResourceClientFromKey(d.Key).(resources.K8sStatusClient)
+	if !ok {
+		log.Debug("Attempt to 'UpdateStatus' using kubernetes backend is not supported.")
+		return nil, cerrors.ErrorOperationNotSupported{
+			Identifier: d.Key,
+			Operation:  "UpdateStatus",
+		}
+	}
+	return client.UpdateStatus(ctx, d)
+}
+
 // Set an existing entry in the datastore.  This ignores whether an entry already
 // exists.  This is not exposed in the main client - but we keep here for the backend
 // API.
@@ -617,28 +651,36 @@ func (c *KubeClient) listHostConfig(ctx context.Context, l model.HostConfigListO
 }
 
 func getTunIp(n *v1.Node) (*model.KVPair, error) {
-	if n.Spec.PodCIDR == "" {
+	podCIDRs := resources.NodePodCIDRs(n)
+	if len(podCIDRs) == 0 {
 		log.Warnf("Node %s does not have podCIDR for HostConfig", n.Name)
 		return nil, nil
 	}
 
-	ip, _, err := net.ParseCIDR(n.Spec.PodCIDR)
-	if err != nil {
-		log.Warnf("Invalid podCIDR for HostConfig: %s, %s", n.Name, n.Spec.PodCIDR)
-		return nil, err
-	}
-	// We need to get the IP for the podCIDR and increment it to the
-	// first IP in the CIDR.
-	tunIp := ip.To4()
-	tunIp[3]++
+	for _, podCIDR := range podCIDRs {
+		ip, _, err := net.ParseCIDR(podCIDR)
+		if err != nil {
+			log.Warnf("Invalid podCIDR for HostConfig: %s, %s", n.Name, podCIDR)
+			return nil, err
+		}
+		// We need to get the IP for the podCIDR and increment it to the
+		// first IP in the CIDR.  The tunnel address is always IPv4.
+		tunIp := ip.To4()
+		if tunIp == nil {
+			continue
+		}
+		tunIp[3]++
+
+		kvp := &model.KVPair{
+			Key: model.HostConfigKey{
+				Hostname: n.Name,
+				Name:     "IpInIpTunnelAddr",
+			},
+			Value: tunIp.String(),
+		}
 
-	kvp := &model.KVPair{
-		Key: model.HostConfigKey{
-			Hostname: n.Name,
-			Name:     "IpInIpTunnelAddr",
-		},
-		Value: tunIp.String(),
+		return kvp, nil
 	}
-
-	return kvp, nil
+	log.Warnf("Node %s does not have an IPv4 podCIDR for HostConfig", n.Name)
+	return nil, nil
 }
@@ -71,6 +71,16 @@ type K8sResourceClient interface {
 	EnsureInitialized() error
 }
 
+// K8sStatusClient extends the K8sResourceClient for resources whose status is updated
+// separately from the rest of the resource.
+type K8sStatusClient interface {
+	K8sResourceClient
+	// UpdateStatus replaces the status of the existing object specified in the KVPair,
+	// ignoring the rest of the object.  On success, returns a KVPair for the object with
+	// revision information filled-in.
+	UpdateStatus(ctx context.Context, object *model.KVPair) (*model.KVPair, error)
+}
+
 // K8sNodeResourceClient extends the K8sResourceClient to add a helper method to
 // extract resources from the supplied K8s Node.  This convenience interface is
 // expected to be removed in a future libcalico-go release.
@@ -16,6 +16,7 @@ package resources
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
@@ -149,6 +150,56 @@ func (c *customK8sResourceClient) Update(ctx context.Context, kvp *model.KVPair)
 	return kvp, nil
 }
 
+// UpdateStatus replaces the status of an existing Custom K8s Resource instance using the status
+// subresource of the custom resource.  The status is written with a JSON patch, which has no
+// precondition on the resource version, so that it does not conflict with updates to the rest
+// of the resource.
+func (c *customK8sResourceClient) UpdateStatus(ctx context.Context, kvp *model.KVPair) (*model.KVPair, error) {
+	logContext := log.WithFields(log.Fields{
+		"Key":      kvp.Key,
+		"Value":    kvp.Value,
+		"Resource": c.resource,
+	})
+	logContext.Debug("Update custom Kubernetes resource status")
+
+	// Only some kinds of resource have a status.
+	status := reflect.ValueOf(kvp.Value).Elem().FieldByName("Status")
+	if !status.IsValid() {
+		return nil, cerrors.ErrorOperationNotSupported{
+			Identifier: kvp.Key,
+			Operation:  "UpdateStatus",
+		}
+	}
+
+	// The add operation replaces the status if the resource already has one.
+	patch, err := json.Marshal([]map[string]interface{}{{
+		"op":    "add",
+		"path":  "/status",
+		"value": status.Interface(),
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	key := kvp.Key.(model.ResourceKey)
+	resOut := reflect.New(c.k8sResourceType).Interface().(Resource)
+	err = c.restClient.Patch(types.JSONPatchType).
+		Context(ctx).
+		NamespaceIfScoped(key.Namespace, c.namespaced).
+		Resource(c.resource).
+		Name(key.Name).
+		SubResource("status").
+		Body(patch).
+		Do().Into(resOut)
+	if err != nil {
+		logContext.WithError(err).Debug("Error updating resource status")
+		return nil, K8sErrorToCalico(err, kvp.Key)
+	}
+
+	// Update the return data with the metadata populated by the (Kubernetes) datastore.
+	return c.convertResourceToKVPair(resOut)
+}
+
 func (c *customK8sResourceClient) DeleteKVP(ctx context.Context, kvp *model.KVPair) (*model.KVPair, error) {
 	return c.Delete(ctx, kvp.Key, kvp.Revision, kvp.UID)
 }
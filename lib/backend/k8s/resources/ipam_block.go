@@ -85,6 +85,15 @@ func (c ipamBlockClient) toV1(kvpv3 *model.KVPair) (*model.KVPair, error) {
 		})
 	}
 
+	// Convert released addresses.
+	var released map[int]model.ReleasedAddress
+	if ab.Spec.Released != nil {
+		released = map[int]model.ReleasedAddress{}
+		for o, r := range ab.Spec.Released {
+			released[o] = model.ReleasedAddress{HandleID: r.HandleID, Time: r.Time}
+		}
+	}
+
 	return &model.KVPair{
 		Key: model.BlockKey{
 			CIDR: *cidr,
@@ -97,6 +106,7 @@ func (c ipamBlockClient) toV1(kvpv3 *model.KVPair) (*model.KVPair, error) {
 			Unallocated:    ab.Spec.Unallocated,
 			Attributes:     attrs,
 			Deleted:        ab.Spec.Deleted,
+			Released:       released,
 		},
 		Revision: kvpv3.Revision,
 		UID:      &ab.UID,
@@ -123,6 +133,15 @@ func (c ipamBlockClient) toV3(kvpv1 *model.KVPair) *model.KVPair {
 		})
 	}
 
+	// Convert released addresses.
+	var released map[int]apiv3.ReleasedAddress
+	if ab.Released != nil {
+		released = map[int]apiv3.ReleasedAddress{}
+		for o, r := range ab.Released {
+			released[o] = apiv3.ReleasedAddress{HandleID: r.HandleID, Time: r.Time}
+		}
+	}
+
 	return &model.KVPair{
 		Key: model.ResourceKey{
 			Name: name,
@@ -145,6 +164,7 @@ func (c ipamBlockClient) toV3(kvpv1 *model.KVPair) *model.KVPair {
 				StrictAffinity: ab.StrictAffinity,
 				Attributes:     attrs,
 				Deleted:        ab.Deleted,
+				Released:       released,
 			},
 		},
 		Revision: kvpv1.Revision,
@@ -19,6 +19,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	kapiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
@@ -36,8 +37,8 @@ func NewPodCIDRBlockAffinityClient(c *kubernetes.Clientset) K8sResourceClient {
 }
 
 // podCIDRBlockClient implements the api.Client interface for block affinities using Kubernetes pod CIDR
-// allocations as the backing store. For use with host-local IPAM. For the Calico IPAM
-// implementation, see ipam_block.go.
+// allocations as the backing store. For use with host-local IPAM, and by Calico IPAM to read the nodes'
+// pod CIDRs. For the Calico IPAM implementation, see ipam_block.go.
 type podCIDRBlockClient struct {
 	clientSet *kubernetes.Clientset
 }
@@ -75,11 +76,22 @@ func (c *podCIDRBlockClient) Delete(ctx context.Context, key model.Key, revision
 }
 
 func (c *podCIDRBlockClient) Get(ctx context.Context, key model.Key, revision string) (*model.KVPair, error) {
-	log.Warn("Operation Get is not supported on block affinities when using host-local IPAM")
-	return nil, cerrors.ErrorOperationNotSupported{
-		Identifier: key,
-		Operation:  "Get",
+	log.Debug("Received Get request on block affinities (using host-local IPAM)")
+	k := key.(model.BlockAffinityKey)
+	node, err := c.clientSet.CoreV1().Nodes().Get(k.Host, metav1.GetOptions{ResourceVersion: revision})
+	if err != nil {
+		return nil, K8sErrorToCalico(err, key)
+	}
+	kvps, err := podCIDRAffinities(node, k.CIDR.Version())
+	if err != nil {
+		return nil, err
 	}
+	for _, kvp := range kvps {
+		if kvp.Key.(model.BlockAffinityKey).CIDR.String() == k.CIDR.String() {
+			return kvp, nil
+		}
+	}
+	return nil, cerrors.ErrorResourceDoesNotExist{Identifier: key}
 }
 
 func (c *podCIDRBlockClient) Watch(ctx context.Context, list model.ListInterface, revision string) (api.WatchInterface, error) {
@@ -90,18 +102,27 @@ func (c *podCIDRBlockClient) Watch(ctx context.Context, list model.ListInterface
 	}
 }
 
+// List returns a confirmed block affinity for each of the pod CIDRs of the nodes.  It accepts both
+// BlockAffinityListOptions, for use with host-local IPAM, and PodCIDRListOptions, for use by
+// Calico IPAM when it honours the nodes' pod CIDRs.
 func (c *podCIDRBlockClient) List(ctx context.Context, list model.ListInterface, revision string) (*model.KVPairList, error) {
 	log.Debug("Received List request on block affinities (using host-local IPAM)")
-	bl := list.(model.BlockAffinityListOptions)
+	var host string
+	var ipVersion int
+	switch l := list.(type) {
+	case model.BlockAffinityListOptions:
+		host, ipVersion = l.Host, l.IPVersion
+	case model.PodCIDRListOptions:
+		host, ipVersion = l.Host, l.IPVersion
+	}
 	kvpl := &model.KVPairList{
 		KVPairs:  []*model.KVPair{},
 		Revision: revision,
 	}
 
-	// If a host is specified, then do an exact lookup (ip version should not be expected in the query)
-	if bl.Host != "" && bl.IPVersion == 0 {
-		// Get the node settings, we use the nodes PodCIDR as the only node affinity block.
-		node, err := c.clientSet.CoreV1().Nodes().Get(bl.Host, metav1.GetOptions{ResourceVersion: revision})
+	// If a host is specified, then do an exact lookup.
+	if host != "" {
+		node, err := c.clientSet.CoreV1().Nodes().Get(host, metav1.GetOptions{ResourceVersion: revision})
 		if err != nil {
 			err = K8sErrorToCalico(err, list)
 			if _, ok := err.(cerrors.ErrorResourceDoesNotExist); !ok {
@@ -109,73 +130,66 @@ func (c *podCIDRBlockClient) List(ctx context.Context, list model.ListInterface,
 			}
 			return kvpl, nil
 		}
+		kvpl.Revision = node.ResourceVersion
+		kvpl.KVPairs, err = podCIDRAffinities(node, ipVersion)
+		if err != nil {
+			return nil, err
+		}
+		return kvpl, nil
+	}
 
-		// Return no results if the pod CIDR is not assigned.
-		podcidr := node.Spec.PodCIDR
-		if len(podcidr) == 0 {
-			return kvpl, nil
+	// When host is not specified, return the pod CIDRs of all nodes.
+	nodeList, err := c.clientSet.CoreV1().Nodes().List(metav1.ListOptions{ResourceVersion: revision})
+	if err != nil {
+		err = K8sErrorToCalico(err, list)
+		if _, ok := err.(cerrors.ErrorResourceDoesNotExist); !ok {
+			return nil, err
 		}
+		return kvpl, nil
+	}
+	kvpl.Revision = nodeList.ResourceVersion
+	for i := range nodeList.Items {
+		kvps, err := podCIDRAffinities(&nodeList.Items[i], ipVersion)
+		if err != nil {
+			return nil, err
+		}
+		kvpl.KVPairs = append(kvpl.KVPairs, kvps...)
+	}
+	return kvpl, nil
+}
 
-		_, cidr, err := cnet.ParseCIDR(podcidr)
+// podCIDRAffinities returns a confirmed block affinity for each of the node's pod CIDRs of the given
+// IP version, or of both IP versions if ipVersion is 0.
+func podCIDRAffinities(node *kapiv1.Node, ipVersion int) ([]*model.KVPair, error) {
+	kvps := []*model.KVPair{}
+	for _, podCIDR := range NodePodCIDRs(node) {
+		_, cidr, err := cnet.ParseCIDR(podCIDR)
 		if err != nil {
 			return nil, err
 		}
-		kvpl.Revision = node.ResourceVersion
-		kvpl.KVPairs = append(kvpl.KVPairs, &model.KVPair{
+		if ipVersion != 0 && cidr.Version() != ipVersion {
+			continue
+		}
+		kvps = append(kvps, &model.KVPair{
 			Key: model.BlockAffinityKey{
 				CIDR: *cidr,
-				Host: bl.Host,
+				Host: node.Name,
 			},
 			Value:    &model.BlockAffinity{State: model.StateConfirmed},
 			Revision: node.ResourceVersion,
 		})
-
-		return kvpl, nil
-	}
-
-	// When host is not specified...
-	if bl.IPVersion == 0 {
-		// Get the node settings, we use the nodes PodCIDR as the only node affinity block.
-		nodeList, err := c.clientSet.CoreV1().Nodes().List(metav1.ListOptions{ResourceVersion: revision})
-		if err != nil {
-			err = K8sErrorToCalico(err, list)
-			if _, ok := err.(cerrors.ErrorResourceDoesNotExist); !ok {
-				return nil, err
-			}
-			return kvpl, nil
-		}
-
-		kvpl.Revision = nodeList.ResourceVersion
-		for _, node := range nodeList.Items {
-			// Return no results if the pod CIDR is not assigned.
-			podcidr := node.Spec.PodCIDR
-			if len(podcidr) == 0 {
-				continue
-			}
-
-			_, cidr, err := cnet.ParseCIDR(podcidr)
-			if err != nil {
-				return nil, err
-			}
-			kvpl.KVPairs = append(kvpl.KVPairs, &model.KVPair{
-				Key: model.BlockAffinityKey{
-					CIDR: *cidr,
-					Host: node.Name,
-				},
-				Value:    &model.BlockAffinity{State: model.StateConfirmed},
-				Revision: node.ResourceVersion,
-			})
-		}
-		return kvpl, nil
 	}
+	return kvps, nil
+}
 
-	// Currently querying the affinity block is only used by the BGP syncer *and* we always
-	// query for a specific Node, so for now fail List requests for all nodes.
-	log.Warn("Operation List (all nodes or all IP versions) is not supported on block affinities when using host-local IPAM")
-	return nil, cerrors.ErrorOperationNotSupported{
-		Identifier: list,
-		Operation:  "List",
+// NodePodCIDRs returns the pod CIDRs that Kubernetes has allocated to the node.  Only the primary
+// pod CIDR (Spec.PodCIDR) is returned: the pod CIDRs of a dual-stack node (Spec.PodCIDRs) need
+// k8s.io/api 1.16 or later, and this is pinned to 1.15.
+func NodePodCIDRs(node *kapiv1.Node) []string {
+	if node.Spec.PodCIDR != "" {
+		return []string{node.Spec.PodCIDR}
 	}
+	return nil
 }
 
 func (c *podCIDRBlockClient) EnsureInitialized() error {
@@ -17,6 +17,7 @@ package resources
 import (
 	"context"
 	"reflect"
+	"time"
 
 	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
 	"github.com/unai-ttxu/libcalico-go/lib/backend/api"
@@ -69,6 +70,9 @@ func (c ipamConfigClient) toV1(kvpv3 *model.KVPair) (*model.KVPair, error) {
 		Value: &model.IPAMConfig{
 			StrictAffinity:     v3obj.Spec.StrictAffinity,
 			AutoAllocateBlocks: v3obj.Spec.AutoAllocateBlocks,
+			ReleaseCooldown:    fromV3Duration(v3obj.Spec.ReleaseCooldown),
+			AuditRetention:     fromV3Duration(v3obj.Spec.AuditRetention),
+			HonorPodCIDRs:      v3obj.Spec.HonorPodCIDRs,
 		},
 		Revision: kvpv3.Revision,
 		UID:      &kvpv3.Value.(*apiv3.IPAMConfig).UID,
@@ -96,12 +100,31 @@ func (c ipamConfigClient) toV3(kvpv1 *model.KVPair) *model.KVPair {
 			Spec: apiv3.IPAMConfigSpec{
 				StrictAffinity:     v1obj.StrictAffinity,
 				AutoAllocateBlocks: v1obj.AutoAllocateBlocks,
+				ReleaseCooldown:    toV3Duration(v1obj.ReleaseCooldown),
+				AuditRetention:     toV3Duration(v1obj.AuditRetention),
+				HonorPodCIDRs:      v1obj.HonorPodCIDRs,
 			},
 		},
 		Revision: kvpv1.Revision,
 	}
 }
 
+// fromV3Duration converts an optional v3 duration, treating a missing duration as zero.
+func fromV3Duration(d *metav1.Duration) time.Duration {
+	if d == nil {
+		return 0
+	}
+	return d.Duration
+}
+
+// toV3Duration converts a duration to an optional v3 duration, omitting a zero duration.
+func toV3Duration(d time.Duration) *metav1.Duration {
+	if d == 0 {
+		return nil
+	}
+	return &metav1.Duration{Duration: d}
+}
+
 func (c *ipamConfigClient) Create(ctx context.Context, kvp *model.KVPair) (*model.KVPair, error) {
 	log.Debug("Received Create request on IPAMConfig type")
 	nkvp, err := c.rc.Create(ctx, c.toV3(kvp))
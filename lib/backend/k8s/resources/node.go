@@ -45,6 +45,7 @@ const (
 	nodeBgpAsnAnnotation                 = "projectcalico.org/ASNumber"
 	nodeBgpCIDAnnotation                 = "projectcalico.org/RouteReflectorClusterID"
 	nodeK8sLabelAnnotation               = "projectcalico.org/kube-labels"
+	nodeStatusAnnotation                 = "projectcalico.org/status"
 )
 
 func NewNodeClient(c *kubernetes.Clientset, usePodCIDR bool) K8sResourceClient {
@@ -96,6 +97,46 @@ func (c *nodeClient) Update(ctx context.Context, kvp *model.KVPair) (*model.KVPa
 	return newCalicoNode, nil
 }
 
+// UpdateStatus replaces the status of the Calico Node, which is stored in an annotation on the
+// Kubernetes Node.  The annotation is written with a merge patch, which has no precondition on
+// the resource version, so that it does not conflict with updates to the rest of the Node.
+func (c *nodeClient) UpdateStatus(ctx context.Context, kvp *model.KVPair) (*model.KVPair, error) {
+	log.Debug("Received UpdateStatus request on Node type")
+	calicoNode := kvp.Value.(*apiv3.Node)
+
+	// A null value in a merge patch removes the annotation.
+	var status interface{}
+	if !reflect.DeepEqual(calicoNode.Status, apiv3.NodeStatus{}) {
+		b, err := json.Marshal(calicoNode.Status)
+		if err != nil {
+			return nil, err
+		}
+		status = string(b)
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{nodeStatusAnnotation: status},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	newNode, err := c.clientSet.CoreV1().Nodes().Patch(kvp.Key.(model.ResourceKey).Name, types.MergePatchType, patch)
+	if err != nil {
+		log.WithError(err).Info("Error updating Node status")
+		return nil, K8sErrorToCalico(err, kvp.Key)
+	}
+
+	newCalicoNode, err := K8sNodeToCalico(newNode, c.usePodCIDR)
+	if err != nil {
+		log.Errorf("Failed to parse returned Node after call to update status %+v", newNode)
+		return nil, err
+	}
+
+	return newCalicoNode, nil
+}
+
 func (c *nodeClient) DeleteKVP(ctx context.Context, kvp *model.KVPair) (*model.KVPair, error) {
 	return c.Delete(ctx, kvp.Key, kvp.Revision, kvp.UID)
 }
@@ -234,7 +275,7 @@ func K8sNodeToCalico(k8sNode *kapiv1.Node, usePodCIDR bool) (*model.KVPair, erro
 	bgpSpec.IPv4IPIPTunnelAddr = annotations[nodeBgpIpv4IPIPTunnelAddrAnnotation]
 
 	// If using host-local IPAM, assign an IPIP tunnel address statically.
-	if usePodCIDR && k8sNode.Spec.PodCIDR != "" {
+	if usePodCIDR && len(NodePodCIDRs(k8sNode)) != 0 {
 		// For back compatibility with v2.6.x, always generate a tunnel address if we have the pod CIDR.
 		bgpSpec.IPv4IPIPTunnelAddr, err = getIPIPTunnelAddress(k8sNode)
 		if err != nil {
@@ -251,6 +292,20 @@ func K8sNodeToCalico(k8sNode *kapiv1.Node, usePodCIDR bool) (*model.KVPair, erro
 	calicoNode.Spec.IPv4VXLANTunnelAddr = annotations[nodeBgpIpv4VXLANTunnelAddrAnnotation]
 	calicoNode.Spec.VXLANTunnelMACAddr = annotations[nodeBgpVXLANTunnelMACAddrAnnotation]
 
+	// Only the decommission finalizer is owned by Calico.
+	for _, f := range k8sNode.Finalizers {
+		if f == apiv3.NodeDecommissionFinalizer {
+			calicoNode.Finalizers = append(calicoNode.Finalizers, f)
+		}
+	}
+
+	// Extract the status stored in the annotation.
+	if status, ok := annotations[nodeStatusAnnotation]; ok {
+		if err := json.Unmarshal([]byte(status), &calicoNode.Status); err != nil {
+			log.WithError(err).Infof("failed to read node status from annotation: %s", nodeStatusAnnotation)
+		}
+	}
+
 	// Create the resource key from the node name.
 	return &model.KVPair{
 		Key: model.ResourceKey{
@@ -332,6 +387,21 @@ func mergeCalicoNodeIntoK8sNode(calicoNode *apiv3.Node, k8sNode *kapiv1.Node) (*
 		delete(k8sNode.Annotations, nodeBgpCIDAnnotation)
 	}
 
+	// The decommission finalizer is the only finalizer that is written through from the Calico
+	// node, so that the other finalizers on the Kubernetes node are left alone.
+	var finalizers []string
+	for _, f := range k8sNode.Finalizers {
+		if f != apiv3.NodeDecommissionFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	for _, f := range calicoNode.Finalizers {
+		if f == apiv3.NodeDecommissionFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	k8sNode.Finalizers = finalizers
+
 	return k8sNode, nil
 }
 
@@ -415,23 +485,26 @@ func restoreCalicoLabels(calicoNode *apiv3.Node) (*apiv3.Node, error) {
 	return calicoNode, nil
 }
 
-// getIPIPTunnelAddress calculates the IPv4 address to use for the IPIP tunnel based on the node's pod CIDR, for use
-// in conjunction with host-local IPAM backed by node.Spec.PodCIDR allocations.
+// getIPIPTunnelAddress calculates the IPv4 address to use for the IPIP tunnel based on the node's IPv4 pod CIDR, for
+// use in conjunction with host-local IPAM backed by node.Spec.PodCIDR allocations.
 func getIPIPTunnelAddress(n *kapiv1.Node) (string, error) {
-	ip, _, err := net.ParseCIDR(n.Spec.PodCIDR)
-	if err != nil {
-		log.Warnf("Invalid pod CIDR for node: %s, %s", n.Name, n.Spec.PodCIDR)
-		return "", err
-	}
+	for _, podCIDR := range NodePodCIDRs(n) {
+		ip, _, err := net.ParseCIDR(podCIDR)
+		if err != nil {
+			log.Warnf("Invalid pod CIDR for node: %s, %s", n.Name, podCIDR)
+			return "", err
+		}
 
-	// We need to get the IP for the podCIDR and increment it to the
-	// first IP in the CIDR.
-	tunIp := ip.To4()
-	if tunIp == nil {
-		log.WithField("podCIDR", n.Spec.PodCIDR).Infof("Cannot pick an IPv4 tunnel address from the given CIDR")
-		return "", nil
-	}
-	tunIp[3]++
+		// We need to get the IP for the podCIDR and increment it to the
+		// first IP in the CIDR.
+		tunIp := ip.To4()
+		if tunIp == nil {
+			continue
+		}
+		tunIp[3]++
 
-	return tunIp.String(), nil
+		return tunIp.String(), nil
+	}
+	log.WithField("podCIDRs", NodePodCIDRs(n)).Infof("Cannot pick an IPv4 tunnel address from the given CIDRs")
+	return "", nil
 }
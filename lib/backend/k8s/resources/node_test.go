@@ -23,6 +23,7 @@ import (
 	k8sapi "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
 	"github.com/unai-ttxu/libcalico-go/lib/net"
 	"github.com/unai-ttxu/libcalico-go/lib/numorstring"
 )
@@ -277,6 +278,70 @@ var _ = Describe("Test Node conversion", func() {
 		Expect(newCalicoNode.Value).To(Equal(calicoNodeWithMergedLabels))
 	})
 
+	It("Should store the owner references of Calico Nodes in an annotation", func() {
+		k8sNode := &k8sapi.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "TestNode",
+				ResourceVersion: "1234",
+			},
+		}
+		refs := []metav1.OwnerReference{{
+			APIVersion: apiv3.GroupVersionCurrent,
+			Kind:       apiv3.KindIPPool,
+			Name:       "pool1",
+			UID:        "pool1-uid",
+		}}
+		calicoNode := apiv3.NewNode()
+		calicoNode.Name = "TestNode"
+		calicoNode.OwnerReferences = refs
+
+		newK8sNode, err := mergeCalicoNodeIntoK8sNode(calicoNode, k8sNode)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(newK8sNode.OwnerReferences).To(BeEmpty())
+		Expect(newK8sNode.Annotations).To(HaveKey(ownerReferencesAnnotation))
+
+		newCalicoNode, err := K8sNodeToCalico(newK8sNode, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(newCalicoNode.Value.(*apiv3.Node).OwnerReferences).To(Equal(refs))
+
+		By("Removing the owner references")
+		calicoNode.OwnerReferences = nil
+		newK8sNode, err = mergeCalicoNodeIntoK8sNode(calicoNode, newK8sNode)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(newK8sNode.Annotations).NotTo(HaveKey(ownerReferencesAnnotation))
+	})
+
+	It("Should read the status of Calico Nodes from an annotation", func() {
+		k8sNode := &k8sapi.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "TestNode",
+				ResourceVersion: "1234",
+				Annotations: map[string]string{
+					nodeStatusAnnotation: `{"agentVersions":{"felix":"v3.10.0"},"bgpSessions":[{"peerIP":"172.17.17.11","state":"Established"}]}`,
+				},
+			},
+		}
+		status := apiv3.NodeStatus{
+			AgentVersions: map[string]string{"felix": "v3.10.0"},
+			BGPSessions: []apiv3.BGPSessionStatus{{
+				PeerIP: "172.17.17.11",
+				State:  apiv3.BGPSessionStateEstablished,
+			}},
+		}
+
+		calicoNode, err := K8sNodeToCalico(k8sNode, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(calicoNode.Value.(*apiv3.Node).Status).To(Equal(status))
+
+		By("Updating the rest of the Node")
+		calicoNode.Value.(*apiv3.Node).Status = apiv3.NodeStatus{}
+		newK8sNode, err := mergeCalicoNodeIntoK8sNode(calicoNode.Value.(*apiv3.Node), k8sNode)
+		Expect(err).NotTo(HaveOccurred())
+		newCalicoNode, err := K8sNodeToCalico(newK8sNode, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(newCalicoNode.Value.(*apiv3.Node).Status).To(Equal(status))
+	})
+
 	It("Should shadow labels correctly", func() {
 		kl := map[string]string{
 			"net.beta.kubernetes.io/role": "master",
@@ -454,5 +519,55 @@ var _ = Describe("Test Node conversion", func() {
 			Expect(ipInIpAddr).To(Equal(""))
 		})
 
+		It("should not pick an IPIP tunnel address from an IPv6 pod CIDR", func() {
+			node := k8sapi.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "TestNode",
+					ResourceVersion: "1234",
+				},
+				Spec: k8sapi.NodeSpec{
+					PodCIDR: "fd00:10::/120",
+				},
+			}
+
+			n, err := K8sNodeToCalico(&node, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n.Value.(*apiv3.Node).Spec.BGP).To(BeNil())
+		})
+	})
+
+	Context("listing pod CIDRs as block affinities", func() {
+		node := &k8sapi.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "TestNode",
+				ResourceVersion: "1234",
+			},
+			Spec: k8sapi.NodeSpec{
+				PodCIDR: "fd00:10::/120",
+			},
+		}
+
+		affinityCIDRs := func(ipVersion int) []string {
+			kvps, err := podCIDRAffinities(node, ipVersion)
+			Expect(err).NotTo(HaveOccurred())
+			var cidrs []string
+			for _, kvp := range kvps {
+				Expect(kvp.Key.(model.BlockAffinityKey).Host).To(Equal("TestNode"))
+				Expect(kvp.Value).To(Equal(&model.BlockAffinity{State: model.StateConfirmed}))
+				cidrs = append(cidrs, kvp.Key.(model.BlockAffinityKey).CIDR.String())
+			}
+			return cidrs
+		}
+
+		It("should return an affinity for the pod CIDR if it is of the requested IP version", func() {
+			Expect(affinityCIDRs(0)).To(Equal([]string{"fd00:10::/120"}))
+			Expect(affinityCIDRs(4)).To(BeEmpty())
+			Expect(affinityCIDRs(6)).To(Equal([]string{"fd00:10::/120"}))
+		})
+
+		It("should return the single pod CIDR", func() {
+			Expect(NodePodCIDRs(&k8sapi.Node{Spec: k8sapi.NodeSpec{PodCIDR: "10.0.0.0/24"}})).To(Equal([]string{"10.0.0.0/24"}))
+			Expect(NodePodCIDRs(&k8sapi.Node{})).To(BeEmpty())
+		})
 	})
 })
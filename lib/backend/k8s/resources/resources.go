@@ -25,9 +25,10 @@ import (
 )
 
 const (
-	labelsAnnotation      = "projectcalico.org/labels"
-	annotationsAnnotation = "projectcalico.org/annotations"
-	metadataAnnotation    = "projectcalico.org/metadata"
+	labelsAnnotation          = "projectcalico.org/labels"
+	annotationsAnnotation     = "projectcalico.org/annotations"
+	ownerReferencesAnnotation = "projectcalico.org/ownerReferences"
+	metadataAnnotation        = "projectcalico.org/metadata"
 )
 
 // Interface that all Kubernetes and Calico resources implement.
@@ -47,8 +48,10 @@ type ResourceList interface {
 type ConvertK8sResourceToKVPair func(Resource) (*model.KVPair, error)
 
 // Store Calico Metadata in the k8s resource annotations for non-CRD backed resources.
-// Currently this just stores Annotations and Labels and drops all other metadata
-// attributes.
+// Currently this just stores Annotations, Labels and OwnerReferences and drops all other
+// metadata attributes.  The OwnerReferences refer to Calico resources, so they are stored
+// as an annotation rather than on the k8s resource, where the k8s garbage collector would
+// act on them.
 func SetK8sAnnotationsFromCalicoMetadata(k8sRes Resource, calicoRes Resource) {
 	a := k8sRes.GetObjectMeta().GetAnnotations()
 	if a == nil {
@@ -74,12 +77,22 @@ func SetK8sAnnotationsFromCalicoMetadata(k8sRes Resource, calicoRes Resource) {
 		// There are no Calico annotations - nil out the k8s res.
 		delete(a, annotationsAnnotation)
 	}
+	if refs := calicoRes.GetObjectMeta().GetOwnerReferences(); len(refs) > 0 {
+		if rann, err := json.Marshal(refs); err != nil {
+			log.WithError(err).Warning("unable to store owner references as an annotation")
+		} else {
+			a[ownerReferencesAnnotation] = string(rann)
+		}
+	} else {
+		// There are no Calico owner references - nil out the k8s res.
+		delete(a, ownerReferencesAnnotation)
+	}
 	k8sRes.GetObjectMeta().SetAnnotations(a)
 }
 
 // Extract the Calico resource Metadata from the k8s resource annotations for non-CRD
-// backed resources.  This extracts the Annotations and Labels stored as a annotation,
-// and fills in the CreationTimestamp and UID from the k8s resource.
+// backed resources.  This extracts the Annotations, Labels and OwnerReferences stored as
+// annotations, and fills in the CreationTimestamp and UID from the k8s resource.
 func SetCalicoMetadataFromK8sAnnotations(calicoRes Resource, k8sRes Resource) {
 	com := calicoRes.GetObjectMeta()
 	kom := k8sRes.GetObjectMeta()
@@ -107,6 +120,14 @@ func SetCalicoMetadataFromK8sAnnotations(calicoRes Resource, k8sRes Resource) {
 			com.SetAnnotations(annotations)
 		}
 	}
+	if rann, ok := a[ownerReferencesAnnotation]; ok {
+		var refs []metav1.OwnerReference
+		if err := json.Unmarshal([]byte(rann), &refs); err != nil {
+			log.WithError(err).Warning("unable to parse owner references annotation")
+		} else {
+			com.SetOwnerReferences(refs)
+		}
+	}
 }
 
 // Store Calico Metadata in the in the k8s resource annotations for CRD backed resources.
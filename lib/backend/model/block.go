@@ -104,6 +104,10 @@ type AllocationBlock struct {
 	Attributes     []AllocationAttribute `json:"attributes"`
 	Deleted        bool                  `json:"deleted"`
 
+	// Released records when each previously allocated, but now unallocated, ordinal was released
+	// and the handle that held it.  This is used to order addresses for assignment.
+	Released map[int]ReleasedAddress `json:"released,omitempty"`
+
 	// HostAffinity is deprecated in favor of Affinity.
 	// This is only to keep compatibility with existing deployments.
 	// The data format should be `Affinity: host:hostname` (not `hostAffinity: hostname`).
@@ -183,3 +187,10 @@ type AllocationAttribute struct {
 	AttrPrimary   *string           `json:"handle_id"`
 	AttrSecondary map[string]string `json:"secondary"`
 }
+
+type ReleasedAddress struct {
+	// The handle that held the address when it was released, if any.
+	HandleID *string `json:"handle_id,omitempty"`
+	// The time at which the address was released, in nanoseconds since the Unix epoch.
+	Time int64 `json:"time"`
+}
@@ -111,3 +111,19 @@ func (options BlockAffinityListOptions) KeyFromDefaultPath(path string) Key {
 	}
 	return BlockAffinityKey{CIDR: *cidr, Host: host}
 }
+
+// PodCIDRListOptions lists the pod CIDRs that Kubernetes has allocated to nodes, in the form of
+// confirmed block affinities.  Only the Kubernetes datastore stores pod CIDRs, so listing them
+// from any other datastore returns no results.
+type PodCIDRListOptions struct {
+	Host      string
+	IPVersion int
+}
+
+func (options PodCIDRListOptions) defaultPathRoot() string {
+	return "/calico/ipam/v2/podcidr/"
+}
+
+func (options PodCIDRListOptions) KeyFromDefaultPath(path string) Key {
+	return nil
+}
@@ -16,6 +16,7 @@ package model
 
 import (
 	"reflect"
+	"time"
 )
 
 const (
@@ -49,6 +50,9 @@ func (key IPAMConfigKey) String() string {
 }
 
 type IPAMConfig struct {
-	StrictAffinity     bool `json:"strict_affinity,omitempty"`
-	AutoAllocateBlocks bool `json:"auto_allocate_blocks,omitempty"`
+	StrictAffinity     bool          `json:"strict_affinity,omitempty"`
+	AutoAllocateBlocks bool          `json:"auto_allocate_blocks,omitempty"`
+	ReleaseCooldown    time.Duration `json:"release_cooldown,omitempty"`
+	AuditRetention     time.Duration `json:"audit_retention,omitempty"`
+	HonorPodCIDRs      bool          `json:"honor_pod_cidrs,omitempty"`
 }
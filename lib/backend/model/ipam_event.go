@@ -0,0 +1,170 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/unai-ttxu/libcalico-go/lib/errors"
+	"github.com/unai-ttxu/libcalico-go/lib/net"
+)
+
+const (
+	// Types of IPAM event.
+	AllocationEventAssign  = "assign"
+	AllocationEventRelease = "release"
+)
+
+// Each event in the IPAM audit log is stored twice: once indexed by the address and once indexed
+// by the handle (if any).  The time of the event is zero padded in both keys so that the entries
+// for an address or a handle are listed in time order.
+var (
+	matchIPAMEventByIP     = regexp.MustCompile("^/?calico/ipam/v2/event/ip/ipv./([^/]+)/([0-9]+)-([a-z]+)$")
+	matchIPAMEventByHandle = regexp.MustCompile("^/?calico/ipam/v2/event/handle/([^/]+)/([0-9]+)-([^/-]+)-([a-z]+)$")
+	typeIPAMEvent          = reflect.TypeOf(IPAMEvent{})
+)
+
+// IPAMEventKey is the key of an event in the IPAM audit log, indexed by address.
+type IPAMEventKey struct {
+	IP   net.IP `json:"-"`
+	Time int64  `json:"-"`
+	Type string `json:"-"`
+}
+
+func (key IPAMEventKey) defaultPath() (string, error) {
+	if key.IP.IP == nil || key.Type == "" {
+		return "", errors.ErrorInsufficientIdentifiers{}
+	}
+	e := fmt.Sprintf("/calico/ipam/v2/event/ip/ipv%d/%s/%019d-%s", key.IP.Version(), key.IP, key.Time, key.Type)
+	return e, nil
+}
+
+func (key IPAMEventKey) defaultDeletePath() (string, error) {
+	return key.defaultPath()
+}
+
+func (key IPAMEventKey) defaultDeleteParentPaths() ([]string, error) {
+	return nil, nil
+}
+
+func (key IPAMEventKey) valueType() (reflect.Type, error) {
+	return typeIPAMEvent, nil
+}
+
+func (key IPAMEventKey) String() string {
+	return fmt.Sprintf("IPAMEventKey(ip=%s, time=%d, type=%s)", key.IP, key.Time, key.Type)
+}
+
+// IPAMEventHandleKey is the key of an event in the IPAM audit log, indexed by handle.
+type IPAMEventHandleKey struct {
+	HandleID string `json:"-"`
+	IP       net.IP `json:"-"`
+	Time     int64  `json:"-"`
+	Type     string `json:"-"`
+}
+
+func (key IPAMEventHandleKey) defaultPath() (string, error) {
+	if key.HandleID == "" || key.IP.IP == nil || key.Type == "" {
+		return "", errors.ErrorInsufficientIdentifiers{}
+	}
+	e := fmt.Sprintf("/calico/ipam/v2/event/handle/%s/%019d-%s-%s", key.HandleID, key.Time, key.IP, key.Type)
+	return e, nil
+}
+
+func (key IPAMEventHandleKey) defaultDeletePath() (string, error) {
+	return key.defaultPath()
+}
+
+func (key IPAMEventHandleKey) defaultDeleteParentPaths() ([]string, error) {
+	return nil, nil
+}
+
+func (key IPAMEventHandleKey) valueType() (reflect.Type, error) {
+	return typeIPAMEvent, nil
+}
+
+func (key IPAMEventHandleKey) String() string {
+	return fmt.Sprintf("IPAMEventHandleKey(handle=%s, ip=%s, time=%d, type=%s)", key.HandleID, key.IP, key.Time, key.Type)
+}
+
+// IPAMEventListOptions lists the events in the IPAM audit log.  If HandleID is specified, the
+// events are listed from the handle index, otherwise they are listed from the address index.
+type IPAMEventListOptions struct {
+	IP       *net.IP `json:"-"`
+	HandleID *string `json:"-"`
+}
+
+func (options IPAMEventListOptions) defaultPathRoot() string {
+	if options.HandleID != nil {
+		return fmt.Sprintf("/calico/ipam/v2/event/handle/%s/", *options.HandleID)
+	}
+	if options.IP != nil {
+		return fmt.Sprintf("/calico/ipam/v2/event/ip/ipv%d/%s/", options.IP.Version(), options.IP)
+	}
+	return "/calico/ipam/v2/event/ip/"
+}
+
+func (options IPAMEventListOptions) KeyFromDefaultPath(path string) Key {
+	log.Debugf("Get IPAM event key from %s", path)
+	if r := matchIPAMEventByIP.FindStringSubmatch(path); r != nil {
+		ip := net.ParseIP(r[1])
+		t, err := strconv.ParseInt(r[2], 10, 64)
+		if ip == nil || err != nil {
+			log.Debugf("%s is not a valid IPAM event key", path)
+			return nil
+		}
+		if options.IP != nil && !options.IP.Equal(ip.IP) {
+			log.Debugf("Didn't match IP %s != %s", options.IP, ip)
+			return nil
+		}
+		return IPAMEventKey{IP: *ip, Time: t, Type: r[3]}
+	}
+	if r := matchIPAMEventByHandle.FindStringSubmatch(path); r != nil {
+		ip := net.ParseIP(r[3])
+		t, err := strconv.ParseInt(r[2], 10, 64)
+		if ip == nil || err != nil {
+			log.Debugf("%s is not a valid IPAM event key", path)
+			return nil
+		}
+		if options.IP != nil && !options.IP.Equal(ip.IP) {
+			log.Debugf("Didn't match IP %s != %s", options.IP, ip)
+			return nil
+		}
+		return IPAMEventHandleKey{HandleID: r[1], IP: *ip, Time: t, Type: r[4]}
+	}
+	log.Debugf("%s didn't match regex", path)
+	return nil
+}
+
+// IPAMEvent is an assignment or release of an address, recorded in the IPAM audit log.
+type IPAMEvent struct {
+	// The type of event: AllocationEventAssign or AllocationEventRelease.
+	Type string `json:"type"`
+	// The address.
+	IP net.IP `json:"ip"`
+	// The handle that the address was assigned with, if any.
+	HandleID *string `json:"handle_id,omitempty"`
+	// The attributes that the address was assigned with.
+	Attrs map[string]string `json:"attrs,omitempty"`
+	// The host that the address was assigned on.
+	Host string `json:"host,omitempty"`
+	// The time of the event, in nanoseconds since the Unix epoch.
+	Time int64 `json:"time"`
+}
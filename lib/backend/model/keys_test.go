@@ -295,3 +295,35 @@ func mustParseCIDR(s string) net.IPNet {
 	}
 	return *ipNet
 }
+
+var _ = Describe("IPAM event keys", func() {
+	ip := net.MustParseIP("10.0.0.1")
+	handle := "handle-1"
+
+	It("should round trip an event key indexed by address", func() {
+		k := IPAMEventKey{IP: ip, Time: 1571234567890123456, Type: AllocationEventAssign}
+		path, err := KeyToDefaultPath(k)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(path).To(Equal("/calico/ipam/v2/event/ip/ipv4/10.0.0.1/1571234567890123456-assign"))
+		Expect((IPAMEventListOptions{}).KeyFromDefaultPath(path)).To(Equal(k))
+	})
+
+	It("should round trip an event key indexed by handle", func() {
+		k := IPAMEventHandleKey{HandleID: handle, IP: net.MustParseIP("fd00::1"), Time: 12, Type: AllocationEventRelease}
+		path, err := KeyToDefaultPath(k)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(path).To(Equal("/calico/ipam/v2/event/handle/handle-1/0000000000000000012-fd00::1-release"))
+		Expect((IPAMEventListOptions{HandleID: &handle}).KeyFromDefaultPath(path)).To(Equal(k))
+	})
+
+	It("should filter events by address", func() {
+		other := net.MustParseIP("10.0.0.10")
+		Expect((IPAMEventListOptions{IP: &ip}).KeyFromDefaultPath("/calico/ipam/v2/event/ip/ipv4/10.0.0.10/0000000000000000012-assign")).To(BeNil())
+		Expect((IPAMEventListOptions{IP: &other, HandleID: &handle}).KeyFromDefaultPath("/calico/ipam/v2/event/handle/handle-1/0000000000000000012-10.0.0.1-assign")).To(BeNil())
+	})
+
+	It("should list the events of an address from its own prefix", func() {
+		Expect(ListOptionsToDefaultPathRoot(IPAMEventListOptions{IP: &ip})).To(Equal("/calico/ipam/v2/event/ip/ipv4/10.0.0.1/"))
+		Expect(ListOptionsToDefaultPathRoot(IPAMEventListOptions{IP: &ip, HandleID: &handle})).To(Equal("/calico/ipam/v2/event/handle/handle-1/"))
+	})
+})
@@ -0,0 +1,70 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/unai-ttxu/libcalico-go/lib/errors"
+	"github.com/unai-ttxu/libcalico-go/lib/net"
+)
+
+var (
+	typeNetworkSetDelta = reflect.TypeOf(NetworkSetDelta{})
+)
+
+// NetworkSetDeltaKey is the key of a delta-encoded network set update.  It is only generated by
+// the syncer update processors and is never stored in the datastore.  It shares its naming with
+// the NetworkSetKey of the same logical set.
+type NetworkSetDeltaKey struct {
+	Name string `json:"-" validate:"required,namespacedName"`
+}
+
+func (key NetworkSetDeltaKey) defaultPath() (string, error) {
+	if key.Name == "" {
+		return "", errors.ErrorInsufficientIdentifiers{Name: "name"}
+	}
+	e := fmt.Sprintf("/calico/v1/netsetdelta/%s", escapeName(key.Name))
+	return e, nil
+}
+
+func (key NetworkSetDeltaKey) defaultDeletePath() (string, error) {
+	return key.defaultPath()
+}
+
+func (key NetworkSetDeltaKey) defaultDeleteParentPaths() ([]string, error) {
+	return nil, nil
+}
+
+func (key NetworkSetDeltaKey) valueType() (reflect.Type, error) {
+	return typeNetworkSetDelta, nil
+}
+
+func (key NetworkSetDeltaKey) String() string {
+	return fmt.Sprintf("NetworkSetDelta(name=%s)", key.Name)
+}
+
+// NetworkSetDelta describes the change to a network set since the previous update for the same
+// key.  When Full is true, AddedNets contains the complete contents of the set and any previously
+// received nets should be discarded; this is always the case for the first update of a key after
+// the syncer (re)starts.  Labels and Domains are always sent in full.
+type NetworkSetDelta struct {
+	Full        bool              `json:"full,omitempty"`
+	AddedNets   []net.IPNet       `json:"added_nets,omitempty" validate:"omitempty,dive,cidr"`
+	RemovedNets []net.IPNet       `json:"removed_nets,omitempty" validate:"omitempty,dive,cidr"`
+	Domains     []string          `json:"domains,omitempty" validate:"omitempty"`
+	Labels      map[string]string `json:"labels,omitempty" validate:"omitempty,labels"`
+}
@@ -41,17 +41,22 @@ var (
 )
 
 func registerResourceInfo(kind string, plural string, typeOf reflect.Type) {
-	kind = strings.ToLower(kind)
 	plural = strings.ToLower(plural)
 	ri := resourceInfo{
 		typeOf: typeOf,
 		kind:   kind,
 		plural: plural,
 	}
-	resourceInfoByKind[kind] = ri
+	resourceInfoByKind[strings.ToLower(kind)] = ri
 	resourceInfoByPlural[plural] = ri
 }
 
+// CanonicalResourceKind returns the registered name of the resource kind, which is matched
+// case-insensitively, or an empty string if the kind is not a known resource kind.
+func CanonicalResourceKind(kind string) string {
+	return resourceInfoByKind[strings.ToLower(kind)].kind
+}
+
 func init() {
 	registerResourceInfo(
 		apiv3.KindBGPPeer,
@@ -93,6 +98,11 @@ func init() {
 		"ippools",
 		reflect.TypeOf(apiv3.IPPool{}),
 	)
+	registerResourceInfo(
+		apiv3.KindIPReservation,
+		"ipreservations",
+		reflect.TypeOf(apiv3.IPReservation{}),
+	)
 	registerResourceInfo(
 		apiv3.KindNetworkPolicy,
 		"networkpolicies",
@@ -51,6 +51,11 @@ type Rule struct {
 	DstNets     []*net.IPNet       `json:"dst_nets,omitempty" validate:"omitempty"`
 	DstPorts    []numorstring.Port `json:"dst_ports,omitempty" validate:"omitempty,dive"`
 
+	// Domain name matches are passed through unresolved; it is up to the dataplane to track the
+	// addresses that each name resolves to.
+	SrcDomains []string `json:"src_domains,omitempty" validate:"omitempty"`
+	DstDomains []string `json:"dst_domains,omitempty" validate:"omitempty"`
+
 	NotSrcTag      string             `json:"!src_tag,omitempty" validate:"omitempty,tag"`
 	NotSrcNet      *net.IPNet         `json:"!src_net,omitempty" validate:"omitempty"`
 	NotSrcNets     []*net.IPNet       `json:"!src_nets,omitempty" validate:"omitempty"`
@@ -175,6 +180,9 @@ func (r Rule) String() string {
 		if len(srcNets) != 0 {
 			fromParts = append(fromParts, "cidr", joinNets(srcNets))
 		}
+		if len(r.SrcDomains) != 0 {
+			fromParts = append(fromParts, "domains", strings.Join(r.SrcDomains, ","))
+		}
 		if len(r.NotSrcPorts) > 0 {
 			notSrcPorts := make([]string, len(r.NotSrcPorts))
 			for ii, port := range r.NotSrcPorts {
@@ -219,6 +227,9 @@ func (r Rule) String() string {
 		if len(dstNets) != 0 {
 			toParts = append(toParts, "cidr", joinNets(dstNets))
 		}
+		if len(r.DstDomains) != 0 {
+			toParts = append(toParts, "domains", strings.Join(r.DstDomains, ","))
+		}
 		if len(r.NotDstPorts) > 0 {
 			notDstPorts := make([]string, len(r.NotDstPorts))
 			for ii, port := range r.NotDstPorts {
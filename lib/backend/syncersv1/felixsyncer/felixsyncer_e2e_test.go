@@ -23,6 +23,7 @@ import (
 
 	"github.com/unai-ttxu/libcalico-go/lib/backend/encap"
 	"github.com/unai-ttxu/libcalico-go/lib/backend/syncersv1/felixsyncer"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/syncersv1/updateprocessors"
 
 	"github.com/unai-ttxu/libcalico-go/lib/apiconfig"
 	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
@@ -504,3 +505,63 @@ var _ = testutils.E2eDatastoreDescribe("Felix syncer tests (KDD only)", testutil
 		syncTester.ExpectStatusUpdate(api.InSync)
 	})
 })
+
+var _ = testutils.E2eDatastoreDescribe("Felix syncer tests (network set options)", testutils.DatastoreEtcdV3, func(config apiconfig.CalicoAPIConfig) {
+	var ctx context.Context
+	var c clientv3.Interface
+	var be api.Client
+	var syncTester *testutils.SyncerTester
+	var err error
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		c, err = clientv3.New(config)
+		Expect(err).NotTo(HaveOccurred())
+
+		be, err = backend.NewClient(config)
+		Expect(err).NotTo(HaveOccurred())
+		be.Clean()
+
+		syncTester = testutils.NewSyncerTester()
+	})
+
+	It("should send aggregated network set deltas", func() {
+		syncer := felixsyncer.NewWithOptions(be, config.Spec, syncTester, felixsyncer.Options{
+			NetworkSets: updateprocessors.NetworkSetOptions{SendDeltas: true},
+		})
+		syncer.Start()
+		defer syncer.Stop()
+		syncTester.ExpectStatusUpdate(api.WaitForDatastore)
+		syncTester.ExpectStatusUpdate(api.ResyncInProgress)
+		syncTester.ExpectStatusUpdate(api.InSync)
+
+		By("Creating a GlobalNetworkSet and expecting the full aggregated set")
+		gns := apiv3.NewGlobalNetworkSet()
+		gns.Name = "threat-feed"
+		gns.Spec.Nets = []string{"10.0.0.0/24", "10.0.1.0/24", "10.0.0.10/32"}
+		gns, err = c.GlobalNetworkSets().Create(ctx, gns, options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		_, agg, _ := net.ParseCIDR("10.0.0.0/23")
+		syncTester.ExpectData(model.KVPair{
+			Key: model.NetworkSetDeltaKey{Name: "threat-feed"},
+			Value: &model.NetworkSetDelta{
+				Full:      true,
+				AddedNets: []net.IPNet{*agg},
+			},
+		})
+
+		By("Updating the GlobalNetworkSet and expecting only the changed CIDRs")
+		gns.Spec.Nets = []string{"10.0.0.0/24", "192.168.0.0/16"}
+		_, err = c.GlobalNetworkSets().Update(ctx, gns, options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		_, first, _ := net.ParseCIDR("10.0.0.0/24")
+		_, added, _ := net.ParseCIDR("192.168.0.0/16")
+		syncTester.ExpectData(model.KVPair{
+			Key: model.NetworkSetDeltaKey{Name: "threat-feed"},
+			Value: &model.NetworkSetDelta{
+				AddedNets:   []net.IPNet{*first, *added},
+				RemovedNets: []net.IPNet{*agg},
+			},
+		})
+	})
+})
@@ -23,8 +23,20 @@ import (
 	"github.com/unai-ttxu/libcalico-go/lib/backend/watchersyncer"
 )
 
+// Options configures optional behaviour of the Felix v1 Syncer.
+type Options struct {
+	// NetworkSets configures how the GlobalNetworkSet and NetworkSet data is sent.  By default
+	// the nets of each network set are sent in full, exactly as configured.
+	NetworkSets updateprocessors.NetworkSetOptions
+}
+
 // New creates a new Felix v1 Syncer.
 func New(client bapi.Client, cfg api.CalicoAPIConfigSpec, callbacks bapi.SyncerCallbacks) bapi.Syncer {
+	return NewWithOptions(client, cfg, callbacks, Options{})
+}
+
+// NewWithOptions creates a new Felix v1 Syncer with the supplied options.
+func NewWithOptions(client bapi.Client, cfg api.CalicoAPIConfigSpec, callbacks bapi.SyncerCallbacks, opts Options) bapi.Syncer {
 	// Create the set of ResourceTypes required for Felix.  Since the update processors
 	// also cache state, we need to create individual ones per syncer rather than create
 	// a common global set.
@@ -43,7 +55,7 @@ func New(client bapi.Client, cfg api.CalicoAPIConfigSpec, callbacks bapi.SyncerC
 		},
 		{
 			ListInterface:   model.ResourceListOptions{Kind: apiv3.KindGlobalNetworkSet},
-			UpdateProcessor: updateprocessors.NewGlobalNetworkSetUpdateProcessor(),
+			UpdateProcessor: updateprocessors.NewGlobalNetworkSetUpdateProcessorWithOptions(opts.NetworkSets),
 		},
 		{
 			ListInterface:   model.ResourceListOptions{Kind: apiv3.KindIPPool},
@@ -67,7 +79,7 @@ func New(client bapi.Client, cfg api.CalicoAPIConfigSpec, callbacks bapi.SyncerC
 		},
 		{
 			ListInterface:   model.ResourceListOptions{Kind: apiv3.KindNetworkSet},
-			UpdateProcessor: updateprocessors.NewNetworkSetUpdateProcessor(),
+			UpdateProcessor: updateprocessors.NewNetworkSetUpdateProcessorWithOptions(opts.NetworkSets),
 		},
 		{
 			ListInterface:   model.ResourceListOptions{Kind: apiv3.KindHostEndpoint},
@@ -1,4 +1,4 @@
-// Copyright (c) 2018 Tigera, Inc. All rights reserved.
+// Copyright (c) 2018-2019 Tigera, Inc. All rights reserved.
 
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -27,11 +27,17 @@ import (
 // Create a new SyncerUpdateProcessor to sync GlobalNetworkSet data in v1 format for
 // consumption by Felix.
 func NewGlobalNetworkSetUpdateProcessor() watchersyncer.SyncerUpdateProcessor {
-	return NewConflictResolvingCacheUpdateProcessor(apiv3.KindGlobalNetworkSet, convertGlobalNetworkSetV3ToV1)
+	return NewGlobalNetworkSetUpdateProcessorWithOptions(NetworkSetOptions{})
 }
 
-// Convert v3 KVPair to the equivalent v1 KVPair.
-func convertGlobalNetworkSetV3ToV1(kvp *model.KVPair) (*model.KVPair, error) {
+// Create a new SyncerUpdateProcessor to sync GlobalNetworkSet data, optionally aggregating the
+// nets of each set or sending them as NetworkSetDelta updates.
+func NewGlobalNetworkSetUpdateProcessorWithOptions(opts NetworkSetOptions) watchersyncer.SyncerUpdateProcessor {
+	return newNetSetUpdateProcessor(apiv3.KindGlobalNetworkSet, convertGlobalNetworkSetV3ToShard, opts)
+}
+
+// Convert v3 KVPair to the network set shard it represents.
+func convertGlobalNetworkSetV3ToShard(kvp *model.KVPair) (*netSetShard, error) {
 	// Validate against incorrect key/value kinds.  This indicates a code bug rather
 	// than a user error.
 	v3key, ok := kvp.Key.(model.ResourceKey)
@@ -43,8 +49,9 @@ func convertGlobalNetworkSetV3ToV1(kvp *model.KVPair) (*model.KVPair, error) {
 		return nil, errors.New("Value is not a valid GlobalNetworkSet resource key")
 	}
 
-	v1key := model.NetworkSetKey{
-		Name: v3res.GetName(),
+	setName := v3res.GetName()
+	if shardOf := v3res.GetAnnotations()[apiv3.AnnotationNetworkSetShardOf]; shardOf != "" {
+		setName = shardOf
 	}
 
 	var addrs []cnet.IPNet
@@ -61,14 +68,10 @@ func convertGlobalNetworkSetV3ToV1(kvp *model.KVPair) (*model.KVPair, error) {
 		addrs = append(addrs, *ipNet)
 	}
 
-	v1value := &model.NetworkSet{
-		Labels: v3res.GetLabels(),
-		Nets:   addrs,
-	}
-
-	return &model.KVPair{
-		Key:      v1key,
-		Value:    v1value,
-		Revision: kvp.Revision,
+	return &netSetShard{
+		setName: setName,
+		labels:  v3res.GetLabels(),
+		nets:    addrs,
+		domains: v3res.Spec.Domains,
 	}, nil
 }
@@ -0,0 +1,186 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updateprocessors_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/syncersv1/updateprocessors"
+	"github.com/unai-ttxu/libcalico-go/lib/net"
+)
+
+var _ = Describe("Test the GlobalNetworkSet update processor", func() {
+	v3Key := func(name string) model.ResourceKey {
+		return model.ResourceKey{Kind: apiv3.KindGlobalNetworkSet, Name: name}
+	}
+	gns := func(name, shardOf string, labels map[string]string, nets ...string) *model.KVPair {
+		res := apiv3.NewGlobalNetworkSet()
+		res.Name = name
+		res.Labels = labels
+		res.Spec.Nets = nets
+		if shardOf != "" {
+			res.Annotations = map[string]string{apiv3.AnnotationNetworkSetShardOf: shardOf}
+		}
+		return &model.KVPair{Key: v3Key(name), Value: res, Revision: "1234"}
+	}
+	cidr := func(s string) net.IPNet {
+		_, n, err := net.ParseCIDROrIP(s)
+		Expect(err).NotTo(HaveOccurred())
+		return *n
+	}
+
+	It("should not aggregate CIDRs by default", func() {
+		up := updateprocessors.NewGlobalNetworkSetUpdateProcessor()
+
+		kvps, err := up.Process(gns("gns", "", nil, "10.0.1.0/24", "10.0.0.0/24", "10.0.0.10/32"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kvps).To(Equal([]*model.KVPair{
+			{
+				Key: model.NetworkSetKey{Name: "gns"},
+				Value: &model.NetworkSet{
+					Nets: []net.IPNet{cidr("10.0.1.0/24"), cidr("10.0.0.0/24"), cidr("10.0.0.10/32")},
+				},
+				Revision: "1234",
+			},
+		}))
+	})
+
+	It("should aggregate adjacent and overlapping CIDRs", func() {
+		up := updateprocessors.NewGlobalNetworkSetUpdateProcessorWithOptions(updateprocessors.NetworkSetOptions{AggregateNets: true})
+
+		kvps, err := up.Process(gns("gns", "", nil, "10.0.1.0/24", "10.0.0.0/24", "10.0.0.10/32", "10.0.0.0/24", "fd00::/64"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kvps).To(Equal([]*model.KVPair{
+			{
+				Key: model.NetworkSetKey{Name: "gns"},
+				Value: &model.NetworkSet{
+					Nets: []net.IPNet{cidr("10.0.0.0/23"), cidr("fd00::/64")},
+				},
+				Revision: "1234",
+			},
+		}))
+	})
+
+	It("should merge the shards of a network set", func() {
+		up := updateprocessors.NewGlobalNetworkSetUpdateProcessorWithOptions(updateprocessors.NetworkSetOptions{AggregateNets: true})
+		setKey := model.NetworkSetKey{Name: "big-set"}
+
+		By("adding the first shard")
+		kvps, err := up.Process(gns("shard-b", "big-set", map[string]string{"shard": "b"}, "10.0.0.0/24"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kvps).To(Equal([]*model.KVPair{
+			{
+				Key: setKey,
+				Value: &model.NetworkSet{
+					Labels: map[string]string{"shard": "b"},
+					Nets:   []net.IPNet{cidr("10.0.0.0/24")},
+				},
+				Revision: "1234",
+			},
+		}))
+
+		By("adding a second shard with a lower name")
+		kvps, err = up.Process(gns("shard-a", "big-set", map[string]string{"shard": "a"}, "10.0.1.0/24", "192.168.0.0/16"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kvps).To(Equal([]*model.KVPair{
+			{
+				Key: setKey,
+				Value: &model.NetworkSet{
+					Labels: map[string]string{"shard": "a"},
+					Nets:   []net.IPNet{cidr("10.0.0.0/23"), cidr("192.168.0.0/16")},
+				},
+				Revision: "1234",
+			},
+		}))
+
+		By("moving the second shard to its own set")
+		kvps, err = up.Process(gns("shard-a", "", map[string]string{"shard": "a"}, "10.0.1.0/24"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kvps).To(Equal([]*model.KVPair{
+			{
+				Key: setKey,
+				Value: &model.NetworkSet{
+					Labels: map[string]string{"shard": "b"},
+					Nets:   []net.IPNet{cidr("10.0.0.0/24")},
+				},
+				Revision: "1234",
+			},
+			{
+				Key: model.NetworkSetKey{Name: "shard-a"},
+				Value: &model.NetworkSet{
+					Labels: map[string]string{"shard": "a"},
+					Nets:   []net.IPNet{cidr("10.0.1.0/24")},
+				},
+				Revision: "1234",
+			},
+		}))
+
+		By("deleting the last shard of the set")
+		kvps, err = up.Process(&model.KVPair{Key: v3Key("shard-b")})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kvps).To(Equal([]*model.KVPair{{Key: setKey}}))
+	})
+
+	It("should send deltas when configured to do so", func() {
+		up := updateprocessors.NewGlobalNetworkSetUpdateProcessorWithOptions(updateprocessors.NetworkSetOptions{SendDeltas: true})
+		deltaKey := model.NetworkSetDeltaKey{Name: "gns"}
+
+		By("sending the full set on the first update")
+		kvps, err := up.Process(gns("gns", "", nil, "10.0.0.0/24", "10.1.0.0/24"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kvps).To(Equal([]*model.KVPair{
+			{
+				Key: deltaKey,
+				Value: &model.NetworkSetDelta{
+					Full:      true,
+					AddedNets: []net.IPNet{cidr("10.0.0.0/24"), cidr("10.1.0.0/24")},
+				},
+				Revision: "1234",
+			},
+		}))
+
+		By("sending only the changed nets on the next update")
+		kvps, err = up.Process(gns("gns", "", nil, "10.0.0.0/24", "10.2.0.0/24"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kvps).To(Equal([]*model.KVPair{
+			{
+				Key: deltaKey,
+				Value: &model.NetworkSetDelta{
+					AddedNets:   []net.IPNet{cidr("10.2.0.0/24")},
+					RemovedNets: []net.IPNet{cidr("10.1.0.0/24")},
+				},
+				Revision: "1234",
+			},
+		}))
+
+		By("sending the full set again after a resync")
+		up.OnSyncerStarting()
+		kvps, err = up.Process(gns("gns", "", nil, "10.0.0.0/24", "10.2.0.0/24"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kvps).To(Equal([]*model.KVPair{
+			{
+				Key: deltaKey,
+				Value: &model.NetworkSetDelta{
+					Full:      true,
+					AddedNets: []net.IPNet{cidr("10.0.0.0/24"), cidr("10.2.0.0/24")},
+				},
+				Revision: "1234",
+			},
+		}))
+	})
+})
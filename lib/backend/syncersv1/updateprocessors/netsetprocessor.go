@@ -0,0 +1,213 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updateprocessors
+
+import (
+	"fmt"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/watchersyncer"
+	cnet "github.com/unai-ttxu/libcalico-go/lib/net"
+)
+
+// netSetShard is the converted contents of a single GlobalNetworkSet or NetworkSet resource.
+type netSetShard struct {
+	// The name of the logical (v1) network set that this resource is a shard of.
+	setName string
+	labels  map[string]string
+	nets    []cnet.IPNet
+	domains []string
+}
+
+// Function to convert a v3 GlobalNetworkSet or NetworkSet to the shard it represents.
+type convertNetSetShard func(kvp *model.KVPair) (*netSetShard, error)
+
+// NetworkSetOptions configures the GlobalNetworkSet and NetworkSet update processors.
+type NetworkSetOptions struct {
+	// AggregateNets causes the nets of each network set to be de-duplicated and aggregated so
+	// that duplicate, overlapping and adjacent CIDRs are collapsed into the minimal set of CIDRs.
+	AggregateNets bool
+
+	// SendDeltas causes the processor to send NetworkSetDelta updates (keyed off
+	// NetworkSetDeltaKey) containing only the nets that were added or removed since the previous
+	// update for that set.  Deltas are always calculated from the aggregated nets, so this
+	// implies AggregateNets.
+	SendDeltas bool
+}
+
+// newNetSetUpdateProcessor implements an update processor for GlobalNetworkSets and NetworkSets.
+//
+// Each v3 resource is converted to a shard of a logical network set.  A resource is a shard of the
+// set named by its AnnotationNetworkSetShardOf annotation, or if that is not present, the set with
+// the same name as the resource.  The processor caches all of the shards and sends the union of
+// the shards' nets and domains as a single v1 network set, with the nets ordered by shard name.
+// The labels of the logical set are taken from the shard with the lowest alphanumeric name.
+func newNetSetUpdateProcessor(v3Kind string, converter convertNetSetShard, opts NetworkSetOptions) watchersyncer.SyncerUpdateProcessor {
+	return &netSetUpdateProcessor{
+		v3Kind:            v3Kind,
+		converter:         converter,
+		aggregate:         opts.AggregateNets || opts.SendDeltas,
+		delta:             opts.SendDeltas,
+		shardsByName:      make(map[string]*netSetShard),
+		orderedNamesBySet: make(map[string][]string),
+		lastNetsSentBySet: make(map[string][]cnet.IPNet),
+	}
+}
+
+type netSetUpdateProcessor struct {
+	v3Kind            string
+	converter         convertNetSetShard
+	aggregate         bool
+	delta             bool
+	shardsByName      map[string]*netSetShard
+	orderedNamesBySet map[string][]string
+	lastNetsSentBySet map[string][]cnet.IPNet
+}
+
+func (p *netSetUpdateProcessor) Process(kvp *model.KVPair) ([]*model.KVPair, error) {
+	rk, ok := kvp.Key.(model.ResourceKey)
+	if !ok || rk.Kind != p.v3Kind {
+		return nil, fmt.Errorf("Incorrect key type - expecting resource of kind %s", p.v3Kind)
+	}
+	// Shards of a NetworkSet are only ever combined within a namespace, so the namespace is
+	// part of the name that we cache against.
+	name := rk.Name
+	if rk.Namespace != "" {
+		name = rk.Namespace + "/" + rk.Name
+	}
+	logCxt := log.WithField("Name", name)
+
+	var shard *netSetShard
+	if kvp.Value != nil {
+		var err error
+		if shard, err = p.converter(kvp); err != nil {
+			// Treat any values that fail to convert properly as a deletion event.
+			logCxt.WithError(err).Warn("Unable to process resource data - treating as deleted")
+			shard = nil
+		}
+	}
+
+	var response []*model.KVPair
+	if existing := p.shardsByName[name]; existing != nil {
+		// Remove the existing shard from its set.  If the shard is moving to a different set (or
+		// is being deleted) then send an update for the set it was previously part of.
+		p.removeShard(name, existing.setName)
+		if shard == nil || shard.setName != existing.setName {
+			response = append(response, p.buildUpdate(existing.setName, kvp.Revision))
+		}
+	}
+
+	if shard != nil {
+		logCxt.WithField("NetworkSet", shard.setName).Debug("Adding network set shard")
+		p.addShard(name, shard)
+		response = append(response, p.buildUpdate(shard.setName, kvp.Revision))
+	}
+
+	return response, nil
+}
+
+// addShard adds the shard to the cache, keeping the list of shard names for the set ordered.
+func (p *netSetUpdateProcessor) addShard(name string, shard *netSetShard) {
+	names := append(p.orderedNamesBySet[shard.setName], name)
+	sort.Strings(names)
+	p.orderedNamesBySet[shard.setName] = names
+	p.shardsByName[name] = shard
+}
+
+// removeShard removes the named shard from the cache.
+func (p *netSetUpdateProcessor) removeShard(name, setName string) {
+	delete(p.shardsByName, name)
+	names := p.orderedNamesBySet[setName]
+	newNames := make([]string, 0, len(names))
+	for _, n := range names {
+		if n != name {
+			newNames = append(newNames, n)
+		}
+	}
+	if len(newNames) == 0 {
+		delete(p.orderedNamesBySet, setName)
+	} else {
+		p.orderedNamesBySet[setName] = newNames
+	}
+}
+
+// buildUpdate returns the syncer update for the current contents of the logical network set.
+// A nil value in the returned KVPair indicates that the set no longer has any shards.
+func (p *netSetUpdateProcessor) buildUpdate(setName, revision string) *model.KVPair {
+	var key model.Key = model.NetworkSetKey{Name: setName}
+	if p.delta {
+		key = model.NetworkSetDeltaKey{Name: setName}
+	}
+
+	names := p.orderedNamesBySet[setName]
+	if len(names) == 0 {
+		log.WithField("NetworkSet", setName).Debug("No remaining shards: sending delete")
+		delete(p.lastNetsSentBySet, setName)
+		return &model.KVPair{Key: key}
+	}
+
+	var nets []cnet.IPNet
+	var domains []string
+	for _, n := range names {
+		nets = append(nets, p.shardsByName[n].nets...)
+		domains = append(domains, p.shardsByName[n].domains...)
+	}
+	if p.aggregate {
+		nets = aggregateNets(nets)
+	}
+	domains = NormalizeDomains(domains)
+	labels := p.shardsByName[names[0]].labels
+
+	if !p.delta {
+		return &model.KVPair{
+			Key: key,
+			Value: &model.NetworkSet{
+				Labels:  labels,
+				Nets:    nets,
+				Domains: domains,
+			},
+			Revision: revision,
+		}
+	}
+
+	prev, sent := p.lastNetsSentBySet[setName]
+	p.lastNetsSentBySet[setName] = nets
+	value := &model.NetworkSetDelta{
+		Full:    !sent,
+		Labels:  labels,
+		Domains: domains,
+	}
+	if sent {
+		value.AddedNets, value.RemovedNets = diffNets(prev, nets)
+	} else {
+		value.AddedNets = nets
+	}
+	return &model.KVPair{
+		Key:      key,
+		Value:    value,
+		Revision: revision,
+	}
+}
+
+// OnSyncerStarting clears the cache.  Any delta updates sent after this will be full updates.
+func (p *netSetUpdateProcessor) OnSyncerStarting() {
+	log.Debug("Clearing network set cache for resync")
+	p.shardsByName = make(map[string]*netSetShard)
+	p.orderedNamesBySet = make(map[string][]string)
+	p.lastNetsSentBySet = make(map[string][]cnet.IPNet)
+}
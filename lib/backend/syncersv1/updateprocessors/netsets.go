@@ -0,0 +1,49 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package updateprocessors
+
+import (
+	cnet "github.com/unai-ttxu/libcalico-go/lib/net"
+)
+
+// aggregateNets returns the minimal list of CIDRs that covers exactly the same addresses as the
+// supplied nets.  Duplicates and nets contained within other nets are removed, and pairs of
+// adjacent nets that make up a larger CIDR are merged.  The result is ordered by IP version and
+// then by address.
+func aggregateNets(nets []cnet.IPNet) []cnet.IPNet {
+	return cnet.NewCIDRSet(nets...).Nets()
+}
+
+// diffNets returns the nets that are in next but not in prev, and the nets that are in prev but
+// not in next.
+func diffNets(prev, next []cnet.IPNet) (added, removed []cnet.IPNet) {
+	prevSet := make(map[string]bool, len(prev))
+	for _, n := range prev {
+		prevSet[n.String()] = true
+	}
+	nextSet := make(map[string]bool, len(next))
+	for _, n := range next {
+		nextSet[n.String()] = true
+		if !prevSet[n.String()] {
+			added = append(added, n)
+		}
+	}
+	for _, n := range prev {
+		if !nextSet[n.String()] {
+			removed = append(removed, n)
+		}
+	}
+	return
+}
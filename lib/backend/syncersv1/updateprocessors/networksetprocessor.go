@@ -26,24 +26,32 @@ import (
 
 // Create a new SyncerUpdateProcessor to sync NetworkSet data in v1 format for consumption by Felix.
 func NewNetworkSetUpdateProcessor() watchersyncer.SyncerUpdateProcessor {
-	return NewSimpleUpdateProcessor(apiv3.KindNetworkSet, convertNetworkSetV2ToV1Key, convertNetworkSetV2ToV1Value)
+	return NewNetworkSetUpdateProcessorWithOptions(NetworkSetOptions{})
 }
 
-func convertNetworkSetV2ToV1Key(v3key model.ResourceKey) (model.Key, error) {
-	if v3key.Name == "" || v3key.Namespace == "" {
-		return model.NetworkSetKey{}, errors.New("Missing Name or Namespace field to create a v1 NetworkSet Key")
-	}
-	return model.NetworkSetKey{
-		Name: v3key.Namespace + "/" + v3key.Name,
-	}, nil
+// Create a new SyncerUpdateProcessor to sync NetworkSet data, optionally aggregating the nets of
+// each set or sending them as NetworkSetDelta updates.
+func NewNetworkSetUpdateProcessorWithOptions(opts NetworkSetOptions) watchersyncer.SyncerUpdateProcessor {
+	return newNetSetUpdateProcessor(apiv3.KindNetworkSet, convertNetworkSetV3ToShard, opts)
 }
 
-func convertNetworkSetV2ToV1Value(val interface{}) (interface{}, error) {
-	v3res, ok := val.(*apiv3.NetworkSet)
+// Convert v3 KVPair to the network set shard it represents.  The v1 name of the set is the
+// namespaced name of the logical set.
+func convertNetworkSetV3ToShard(kvp *model.KVPair) (*netSetShard, error) {
+	v3key, ok := kvp.Key.(model.ResourceKey)
+	if !ok || v3key.Name == "" || v3key.Namespace == "" {
+		return nil, errors.New("Missing Name or Namespace field to create a v1 NetworkSet Key")
+	}
+	v3res, ok := kvp.Value.(*apiv3.NetworkSet)
 	if !ok {
 		return nil, errors.New("Value is not a valid NetworkSet resource value")
 	}
 
+	setName := v3key.Name
+	if shardOf := v3res.GetAnnotations()[apiv3.AnnotationNetworkSetShardOf]; shardOf != "" {
+		setName = shardOf
+	}
+
 	var addrs []cnet.IPNet
 	for _, cidrString := range v3res.Spec.Nets {
 		_, ipNet, err := cnet.ParseCIDROrIP(cidrString)
@@ -52,6 +60,7 @@ func convertNetworkSetV2ToV1Value(val interface{}) (interface{}, error) {
 				"CIDR":       cidrString,
 				"networkSet": v3res.GetName(),
 			}).Warn("Invalid CIDR")
+			continue
 		}
 		addrs = append(addrs, *ipNet)
 	}
@@ -63,10 +72,9 @@ func convertNetworkSetV2ToV1Value(val interface{}) (interface{}, error) {
 	}
 	labelsWithCalicoNamespace[apiv3.LabelNamespace] = v3res.Namespace
 
-	v1value := &model.NetworkSet{
-		Nets:   addrs,
-		Labels: labelsWithCalicoNamespace,
-	}
-
-	return v1value, nil
+	return &netSetShard{
+		setName: v3key.Namespace + "/" + setName,
+		labels:  labelsWithCalicoNamespace,
+		nets:    addrs,
+	}, nil
 }
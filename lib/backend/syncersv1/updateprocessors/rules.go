@@ -167,6 +167,9 @@ func RuleAPIV2ToBackend(ar apiv3.Rule, ns string) model.Rule {
 		DstSelector: destSelector,
 		DstPorts:    ar.Destination.Ports,
 
+		SrcDomains: NormalizeDomains(ar.Source.Domains),
+		DstDomains: NormalizeDomains(ar.Destination.Domains),
+
 		NotSrcNets:     convertStringsToNets(ar.Source.NotNets),
 		NotSrcSelector: ar.Source.NotSelector,
 		NotSrcPorts:    ar.Source.NotPorts,
@@ -274,6 +277,26 @@ func normalizeIPNets(nets []string) []*cnet.IPNet {
 	return out
 }
 
+// NormalizeDomains converts a list of v3 domain names to the canonical form used in the v1
+// data model: lower case, without the trailing "." of a fully qualified name and with any
+// duplicates removed.
+func NormalizeDomains(domains []string) []string {
+	if len(domains) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(domains))
+	out := make([]string, 0, len(domains))
+	for _, d := range domains {
+		d = strings.TrimSuffix(strings.ToLower(d), ".")
+		if d == "" || seen[d] {
+			continue
+		}
+		seen[d] = true
+		out = append(out, d)
+	}
+	return out
+}
+
 // ruleActionAPIV2ToBackend converts the rule action field value from the API
 // value to the equivalent backend value.
 func ruleActionAPIV2ToBackend(action apiv3.Action) string {
@@ -460,4 +460,29 @@ var _ = Describe("Test the Rules Conversion Functions", func() {
 			Expect(rulev1.DstSelector).To(Equal(dste))
 		})
 	})
+
+	It("should parse a rule with domain matches", func() {
+		r := apiv3.Rule{
+			Action: apiv3.Allow,
+			Destination: apiv3.EntityRule{
+				Nets:    []string{"10.0.0.0/8"},
+				Domains: []string{"API.example.com.", "*.example.org", "api.example.com"},
+			},
+		}
+
+		// Process the rule and get the corresponding v1 representation.
+		rulev1 := updateprocessors.RuleAPIV2ToBackend(r, "")
+
+		By("generating no source domains", func() {
+			Expect(rulev1.SrcDomains).To(BeNil())
+		})
+
+		By("generating normalized, de-duplicated destination domains", func() {
+			Expect(rulev1.DstDomains).To(Equal([]string{"api.example.com", "*.example.org"}))
+		})
+
+		By("passing the nets through alongside the domains", func() {
+			Expect(rulev1.DstNets).To(Equal([]*cnet.IPNet{mustParseCIDR("10.0.0.0/8")}))
+		})
+	})
 })
@@ -0,0 +1,190 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	bapi "github.com/unai-ttxu/libcalico-go/lib/backend/api"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+)
+
+const (
+	// The format and version written in the archive header.
+	archiveFormat  = "calico-backup"
+	archiveVersion = 1
+
+	checksumPrefix = "sha256:"
+)
+
+// archiveKind is a kind of entry in the archive.
+type archiveKind struct {
+	kind string
+
+	// Lists the entries of the kind, and parses their paths.  Nil for the IPAM configuration,
+	// which is a single entry.
+	list model.ListInterface
+}
+
+// The kinds of entry in the archive, in dependency order: entries are restored after the
+// entries of the kinds that come before them.
+var archiveKinds = []archiveKind{
+	{apiv3.KindClusterInformation, model.ResourceListOptions{Kind: apiv3.KindClusterInformation}},
+	{apiv3.KindFelixConfiguration, model.ResourceListOptions{Kind: apiv3.KindFelixConfiguration}},
+	{apiv3.KindBGPConfiguration, model.ResourceListOptions{Kind: apiv3.KindBGPConfiguration}},
+	{apiv3.KindIPPool, model.ResourceListOptions{Kind: apiv3.KindIPPool}},
+	{apiv3.KindIPReservation, model.ResourceListOptions{Kind: apiv3.KindIPReservation}},
+	{apiv3.KindNode, model.ResourceListOptions{Kind: apiv3.KindNode}},
+	{apiv3.KindBGPPeer, model.ResourceListOptions{Kind: apiv3.KindBGPPeer}},
+	{apiv3.KindHostEndpoint, model.ResourceListOptions{Kind: apiv3.KindHostEndpoint}},
+	{apiv3.KindGlobalNetworkSet, model.ResourceListOptions{Kind: apiv3.KindGlobalNetworkSet}},
+	{apiv3.KindNetworkSet, model.ResourceListOptions{Kind: apiv3.KindNetworkSet}},
+	{apiv3.KindProfile, model.ResourceListOptions{Kind: apiv3.KindProfile}},
+	{apiv3.KindGlobalNetworkPolicy, model.ResourceListOptions{Kind: apiv3.KindGlobalNetworkPolicy}},
+	{apiv3.KindNetworkPolicy, model.ResourceListOptions{Kind: apiv3.KindNetworkPolicy}},
+	{apiv3.KindWorkloadEndpoint, model.ResourceListOptions{Kind: apiv3.KindWorkloadEndpoint}},
+	{apiv3.KindIPAMConfig, nil},
+	{apiv3.KindIPAMBlock, model.BlockListOptions{}},
+	{apiv3.KindBlockAffinity, model.BlockAffinityListOptions{}},
+	{apiv3.KindIPAMHandle, model.IPAMHandleListOptions{}},
+}
+
+// header is the first line of the archive.
+type header struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+}
+
+// entry is a line of the archive following the header: either an entry holding a datastore
+// entry, or the trailer that ends the archive.
+type entry struct {
+	Kind  string          `json:"kind,omitempty"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+
+	End     bool `json:"end,omitempty"`
+	Entries int  `json:"entries,omitempty"`
+
+	// For an entry, the checksum of the entry.  For the trailer, the checksum of the checksums
+	// of all of the entries.
+	Checksum string `json:"checksum"`
+}
+
+// entryChecksum returns the checksum of the entry.
+func entryChecksum(e *entry) (string, error) {
+	var value bytes.Buffer
+	if err := json.Compact(&value, e.Value); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", e.Kind, e.Path)
+	h.Write(value.Bytes())
+	return checksumPrefix + fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// archiveChecksum returns the running checksum of the checksums of the entries.
+func archiveChecksum(h hash.Hash) string {
+	return checksumPrefix + fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// Backup writes a snapshot of all of the v3 resources and IPAM data in the datastore to the
+// writer, streaming each entry as it is read.  Values that are encrypted in the datastore are
+// written in plaintext.  Returns the number of entries written.
+func Backup(ctx context.Context, c bapi.Client, w io.Writer) (int, error) {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(header{Format: archiveFormat, Version: archiveVersion, Created: time.Now().UTC()}); err != nil {
+		return 0, err
+	}
+
+	checksums := sha256.New()
+	n := 0
+	for _, ak := range archiveKinds {
+		kvps, err := listKind(ctx, c, ak)
+		if err != nil {
+			return n, fmt.Errorf("unable to list %s entries: %v", ak.kind, err)
+		}
+		log.WithFields(log.Fields{"kind": ak.kind, "entries": len(kvps)}).Debug("Backing up entries")
+		for _, kvp := range kvps {
+			e, err := newEntry(ak.kind, kvp)
+			if err != nil {
+				return n, fmt.Errorf("unable to back up %s: %v", kvp.Key, err)
+			}
+			if err := enc.Encode(e); err != nil {
+				return n, err
+			}
+			checksums.Write([]byte(e.Checksum))
+			n++
+		}
+	}
+
+	if err := enc.Encode(entry{End: true, Entries: n, Checksum: archiveChecksum(checksums)}); err != nil {
+		return n, err
+	}
+	log.WithField("entries", n).Info("Backed up datastore")
+	return n, nil
+}
+
+// listKind returns all of the datastore entries of the kind.
+func listKind(ctx context.Context, c bapi.Client, ak archiveKind) ([]*model.KVPair, error) {
+	if ak.list == nil {
+		kvp, err := c.Get(ctx, model.IPAMConfigKey{}, "")
+		if err != nil {
+			if _, ok := err.(cerrors.ErrorResourceDoesNotExist); ok {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return []*model.KVPair{kvp}, nil
+	}
+	kvps, err := c.List(ctx, ak.list, "")
+	if err != nil {
+		return nil, err
+	}
+	return kvps.KVPairs, nil
+}
+
+// newEntry returns the archive entry for the datastore entry.  The revision of the entry is
+// specific to the datastore it was read from, so it is not stored.
+func newEntry(kind string, kvp *model.KVPair) (*entry, error) {
+	path, err := model.KeyToDefaultPath(kvp.Key)
+	if err != nil {
+		return nil, err
+	}
+	if r, ok := kvp.Value.(metav1.ObjectMetaAccessor); ok {
+		r.GetObjectMeta().SetResourceVersion("")
+	}
+	value, err := model.SerializeValue(kvp)
+	if err != nil {
+		return nil, err
+	}
+	e := &entry{Kind: kind, Path: path, Value: value}
+	if e.Checksum, err = entryChecksum(e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	bapi "github.com/unai-ttxu/libcalico-go/lib/backend/api"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+	"github.com/unai-ttxu/libcalico-go/lib/net"
+)
+
+// fakeBackendClient is an in-memory backend client that supports the operations used by backup
+// and restore.  Like a real datastore, it stores and returns copies of the resources.
+type fakeBackendClient struct {
+	bapi.Client
+	kvps    map[string]*model.KVPair
+	created []model.Key
+
+	// The kinds of resource that the datastore cannot create.
+	unsupported map[string]bool
+}
+
+func newFakeBackendClient(kvps ...*model.KVPair) *fakeBackendClient {
+	c := &fakeBackendClient{kvps: map[string]*model.KVPair{}}
+	for _, kvp := range kvps {
+		c.kvps[kvp.Key.String()] = copyKVPair(kvp)
+	}
+	return c
+}
+
+func copyKVPair(kvp *model.KVPair) *model.KVPair {
+	out := *kvp
+	if r, ok := kvp.Value.(runtime.Object); ok {
+		out.Value = r.DeepCopyObject()
+	}
+	return &out
+}
+
+func (c *fakeBackendClient) Create(ctx context.Context, kvp *model.KVPair) (*model.KVPair, error) {
+	if k, ok := kvp.Key.(model.ResourceKey); ok && c.unsupported[k.Kind] {
+		return nil, cerrors.ErrorOperationNotSupported{Identifier: kvp.Key, Operation: "Create"}
+	}
+	if _, ok := c.kvps[kvp.Key.String()]; ok {
+		return nil, cerrors.ErrorResourceAlreadyExists{Identifier: kvp.Key}
+	}
+	c.kvps[kvp.Key.String()] = copyKVPair(kvp)
+	c.created = append(c.created, kvp.Key)
+	return kvp, nil
+}
+
+func (c *fakeBackendClient) Update(ctx context.Context, kvp *model.KVPair) (*model.KVPair, error) {
+	if _, ok := c.kvps[kvp.Key.String()]; !ok {
+		return nil, cerrors.ErrorResourceDoesNotExist{Identifier: kvp.Key}
+	}
+	c.kvps[kvp.Key.String()] = copyKVPair(kvp)
+	return kvp, nil
+}
+
+func (c *fakeBackendClient) Get(ctx context.Context, key model.Key, revision string) (*model.KVPair, error) {
+	if kvp, ok := c.kvps[key.String()]; ok {
+		return copyKVPair(kvp), nil
+	}
+	return nil, cerrors.ErrorResourceDoesNotExist{Identifier: key}
+}
+
+func (c *fakeBackendClient) List(ctx context.Context, list model.ListInterface, revision string) (*model.KVPairList, error) {
+	l := &model.KVPairList{}
+	for _, kvp := range c.kvps {
+		var match bool
+		switch lo := list.(type) {
+		case model.ResourceListOptions:
+			k, ok := kvp.Key.(model.ResourceKey)
+			match = ok && k.Kind == lo.Kind
+		case model.BlockListOptions:
+			_, match = kvp.Key.(model.BlockKey)
+		case model.BlockAffinityListOptions:
+			_, match = kvp.Key.(model.BlockAffinityKey)
+		case model.IPAMHandleListOptions:
+			_, match = kvp.Key.(model.IPAMHandleKey)
+		}
+		if match {
+			l.KVPairs = append(l.KVPairs, copyKVPair(kvp))
+		}
+	}
+	return l, nil
+}
+
+var _ = Describe("Datastore backup and restore", func() {
+	var src *fakeBackendClient
+
+	poolKey := model.ResourceKey{Kind: apiv3.KindIPPool, Name: "pool1"}
+	policyKey := model.ResourceKey{Kind: apiv3.KindNetworkPolicy, Name: "default.allow", Namespace: "ns1"}
+	_, cidr, _ := net.ParseCIDR("192.168.0.0/26")
+	blockKey := model.BlockKey{CIDR: *cidr}
+	affinityKey := model.BlockAffinityKey{CIDR: *cidr, Host: "node1"}
+	handleKey := model.IPAMHandleKey{HandleID: "handle1"}
+
+	newPool := func(cidr string) *model.KVPair {
+		p := apiv3.NewIPPool()
+		p.Name = "pool1"
+		p.ResourceVersion = "5"
+		p.Spec.CIDR = cidr
+		return &model.KVPair{Key: poolKey, Value: p, Revision: "5"}
+	}
+	backup := func(c bapi.Client) string {
+		var buf bytes.Buffer
+		_, err := Backup(context.Background(), c, &buf)
+		Expect(err).NotTo(HaveOccurred())
+		return buf.String()
+	}
+	restore := func(c bapi.Client, archive string, opts RestoreOptions) (*RestoreResult, error) {
+		return Restore(context.Background(), c, strings.NewReader(archive), opts)
+	}
+
+	BeforeEach(func() {
+		policy := apiv3.NewNetworkPolicy()
+		policy.Name = "default.allow"
+		policy.Namespace = "ns1"
+		affinity := "host:node1"
+
+		src = newFakeBackendClient(
+			&model.KVPair{Key: handleKey, Value: &model.IPAMHandle{HandleID: "handle1", Block: map[string]int{cidr.String(): 1}}},
+			&model.KVPair{Key: affinityKey, Value: &model.BlockAffinity{State: model.StateConfirmed}},
+			&model.KVPair{Key: blockKey, Value: &model.AllocationBlock{CIDR: *cidr, Affinity: &affinity}},
+			&model.KVPair{Key: model.IPAMConfigKey{}, Value: &model.IPAMConfig{StrictAffinity: true}},
+			&model.KVPair{Key: policyKey, Value: policy},
+			newPool("192.168.0.0/16"),
+		)
+	})
+
+	It("should back up and restore all of the entries in dependency order", func() {
+		archive := backup(src)
+		Expect(strings.Count(archive, "\n")).To(Equal(8))
+		Expect(archive).NotTo(ContainSubstring(`"resourceVersion"`))
+
+		dst := newFakeBackendClient()
+		result, err := restore(dst, archive, RestoreOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Overwritten).To(BeEmpty())
+		Expect(result.Skipped).To(BeEmpty())
+		Expect(dst.created).To(Equal([]model.Key{
+			poolKey, policyKey, model.IPAMConfigKey{}, blockKey, affinityKey, handleKey,
+		}))
+		Expect(result.Created).To(Equal(dst.created))
+
+		kvp, err := dst.Get(context.Background(), poolKey, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kvp.Value.(*apiv3.IPPool).Spec.CIDR).To(Equal("192.168.0.0/16"))
+		kvp, err = dst.Get(context.Background(), blockKey, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*kvp.Value.(*model.AllocationBlock).Affinity).To(Equal("host:node1"))
+		kvp, err = dst.Get(context.Background(), model.IPAMConfigKey{}, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kvp.Value.(*model.IPAMConfig).StrictAffinity).To(BeTrue())
+	})
+
+	It("should handle existing entries according to the conflict policy", func() {
+		archive := backup(src)
+
+		dst := newFakeBackendClient(newPool("10.0.0.0/16"))
+		_, err := restore(dst, archive, RestoreOptions{OnConflict: ConflictFail})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorResourceAlreadyExists{}))
+
+		dst = newFakeBackendClient(newPool("10.0.0.0/16"))
+		result, err := restore(dst, archive, RestoreOptions{OnConflict: ConflictSkip})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Skipped).To(Equal([]model.Key{poolKey}))
+		Expect(result.Created).To(HaveLen(5))
+		kvp, _ := dst.Get(context.Background(), poolKey, "")
+		Expect(kvp.Value.(*apiv3.IPPool).Spec.CIDR).To(Equal("10.0.0.0/16"))
+
+		dst = newFakeBackendClient(newPool("10.0.0.0/16"))
+		result, err = restore(dst, archive, RestoreOptions{OnConflict: ConflictOverwrite})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Overwritten).To(Equal([]model.Key{poolKey}))
+		kvp, _ = dst.Get(context.Background(), poolKey, "")
+		Expect(kvp.Value.(*apiv3.IPPool).Spec.CIDR).To(Equal("192.168.0.0/16"))
+	})
+
+	It("should report the entries that the datastore cannot store without failing", func() {
+		node := apiv3.NewNode()
+		node.Name = "node1"
+		nodeKey := model.ResourceKey{Kind: apiv3.KindNode, Name: "node1"}
+		_, err := src.Create(context.Background(), &model.KVPair{Key: nodeKey, Value: node})
+		Expect(err).NotTo(HaveOccurred())
+		archive := backup(src)
+
+		dst := newFakeBackendClient()
+		dst.unsupported = map[string]bool{apiv3.KindNode: true}
+		result, err := restore(dst, archive, RestoreOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Unsupported).To(Equal([]model.Key{nodeKey}))
+		Expect(result.Created).To(HaveLen(6))
+		_, err = dst.Get(context.Background(), nodeKey, "")
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorResourceDoesNotExist{}))
+	})
+
+	It("should only skip the profiles of namespaces and service accounts on the Kubernetes datastore", func() {
+		Expect(derivedFromKubernetes(model.ResourceKey{Kind: apiv3.KindProfile, Name: "kns.default"})).To(BeTrue())
+		Expect(derivedFromKubernetes(model.ResourceKey{Kind: apiv3.KindProfile, Name: "ksa.default.default"})).To(BeTrue())
+		Expect(derivedFromKubernetes(model.ResourceKey{Kind: apiv3.KindProfile, Name: "profile1"})).To(BeFalse())
+		Expect(derivedFromKubernetes(model.ResourceKey{Kind: apiv3.KindWorkloadEndpoint, Name: "wep1", Namespace: "ns1"})).To(BeTrue())
+		Expect(derivedFromKubernetes(poolKey)).To(BeFalse())
+		Expect(derivedFromKubernetes(blockKey)).To(BeFalse())
+	})
+
+	It("should report what would be restored without writing for a dry run", func() {
+		archive := backup(src)
+
+		dst := newFakeBackendClient(newPool("10.0.0.0/16"))
+		result, err := restore(dst, archive, RestoreOptions{OnConflict: ConflictOverwrite, DryRun: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Overwritten).To(Equal([]model.Key{poolKey}))
+		Expect(result.Created).To(HaveLen(5))
+		Expect(dst.created).To(BeEmpty())
+		Expect(dst.kvps).To(HaveLen(1))
+		kvp, _ := dst.Get(context.Background(), poolKey, "")
+		Expect(kvp.Value.(*apiv3.IPPool).Spec.CIDR).To(Equal("10.0.0.0/16"))
+
+		_, err = restore(dst, archive, RestoreOptions{DryRun: true})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorResourceAlreadyExists{}))
+	})
+
+	It("should reject a modified archive without restoring anything", func() {
+		archive := backup(src)
+		lines := strings.SplitAfter(archive, "\n")
+
+		for _, tampered := range []string{
+			strings.Replace(archive, "192.168.0.0/16", "10.0.0.0/16", 1),
+			strings.Join(append(lines[:2:2], lines[3:]...), ""),
+			strings.Join(lines[:len(lines)-2], ""),
+			strings.Replace(archive, `"version":1`, `"version":2`, 1),
+			strings.Replace(archive, `"format":"calico-backup"`, `"format":"other"`, 1),
+		} {
+			dst := newFakeBackendClient()
+			_, err := restore(dst, tampered, RestoreOptions{})
+			Expect(err).To(HaveOccurred())
+			Expect(dst.kvps).To(BeEmpty())
+		}
+	})
+})
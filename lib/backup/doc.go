@@ -0,0 +1,37 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package backup takes snapshots of the Calico data in a datastore, and restores them.
+
+A backup is an archive of JSON lines.  The first line is a header identifying the format and
+its version, each of the following lines is an entry holding the kind, datastore path and
+value of a v3 resource or IPAM object along with a checksum of the entry, and the final line
+is a trailer holding the number of entries and a checksum over all of the entries.  For
+example:
+
+	{"format":"calico-backup","version":1,"created":"2019-06-01T12:00:00Z"}
+	{"kind":"IPPool","path":"/calico/resources/v3/projectcalico.org/ippools/pool1","value":{...},"checksum":"sha256:..."}
+	{"end":true,"entries":1,"checksum":"sha256:..."}
+
+Since each entry records its own kind and path, an archive can be restored to any type of
+datastore.
+
+The archive is not encrypted.  Entries are read through the backend client, which decrypts the
+values of the kinds that the etcdv3 datastore is configured to encrypt, so those values are
+stored in the archive in plaintext.  Archives must be stored with the same care as the
+encryption keys.  Restoring an archive to an etcdv3 datastore encrypts the values again according
+to the datastore's encryption configuration.
+*/
+package backup
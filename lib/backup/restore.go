@@ -0,0 +1,268 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	bapi "github.com/unai-ttxu/libcalico-go/lib/backend/api"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/k8s"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/k8s/conversion"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+)
+
+// ConflictPolicy determines how Restore handles entries that already exist in the datastore.
+type ConflictPolicy int
+
+const (
+	// Fail the restore.
+	ConflictFail ConflictPolicy = iota
+	// Leave the existing entry in place.
+	ConflictSkip
+	// Replace the existing entry with the one from the archive.
+	ConflictOverwrite
+)
+
+// RestoreOptions are the options for Restore.
+type RestoreOptions struct {
+	// How to handle entries that already exist in the datastore.
+	OnConflict ConflictPolicy
+
+	// Check the archive and the datastore, and report what would be restored without
+	// changing the datastore.
+	DryRun bool
+}
+
+// RestoreResult reports the entries that were restored, or that would be restored for a dry
+// run.
+type RestoreResult struct {
+	Created     []model.Key
+	Overwritten []model.Key
+	Skipped     []model.Key
+
+	// The entries that the datastore cannot store, which were not restored.  On the Kubernetes
+	// datastore, these are the Nodes, WorkloadEndpoints and the Profiles of namespaces and
+	// service accounts, which are derived from the Kubernetes resources.
+	Unsupported []model.Key
+}
+
+// Restore restores the entries in the archive to the datastore.  The whole archive is read and
+// verified before any entries are restored, so a corrupt archive does not partially restore.
+// Entries are restored in dependency order, for example IP pools before the IPAM blocks within
+// them.  Entries that the datastore cannot store are reported rather than failing the restore.
+// If an error is returned, the result reports the entries that were restored before the error.
+func Restore(ctx context.Context, c bapi.Client, r io.Reader, opts RestoreOptions) (*RestoreResult, error) {
+	kvps, err := readArchive(r)
+	if err != nil {
+		return nil, err
+	}
+
+	_, kdd := c.(*k8s.KubeClient)
+	result := &RestoreResult{}
+	for _, kvp := range kvps {
+		logCxt := log.WithField("key", kvp.Key)
+		if kdd && derivedFromKubernetes(kvp.Key) {
+			logCxt.Info("Entry is derived from a Kubernetes resource, not restoring it")
+			result.Unsupported = append(result.Unsupported, kvp.Key)
+			continue
+		}
+
+		existing, err := c.Get(ctx, kvp.Key, "")
+		if err == nil {
+			switch opts.OnConflict {
+			case ConflictSkip:
+				logCxt.Debug("Entry already exists, skipping")
+				result.Skipped = append(result.Skipped, kvp.Key)
+				continue
+			case ConflictOverwrite:
+				if !opts.DryRun {
+					logCxt.Debug("Entry already exists, overwriting")
+					kvp.Revision = existing.Revision
+					if _, err := c.Update(ctx, kvp); isUnsupported(err) {
+						logCxt.Info("Datastore cannot store the entry, not restoring it")
+						result.Unsupported = append(result.Unsupported, kvp.Key)
+						continue
+					} else if err != nil {
+						return result, err
+					}
+				}
+				result.Overwritten = append(result.Overwritten, kvp.Key)
+				continue
+			default:
+				return result, cerrors.ErrorResourceAlreadyExists{Identifier: kvp.Key}
+			}
+		} else if isUnsupported(err) {
+			logCxt.Info("Datastore cannot store the entry, not restoring it")
+			result.Unsupported = append(result.Unsupported, kvp.Key)
+			continue
+		} else if _, ok := err.(cerrors.ErrorResourceDoesNotExist); !ok {
+			return result, err
+		}
+
+		if !opts.DryRun {
+			logCxt.Debug("Creating entry")
+			if _, err := c.Create(ctx, kvp); isUnsupported(err) {
+				logCxt.Info("Datastore cannot store the entry, not restoring it")
+				result.Unsupported = append(result.Unsupported, kvp.Key)
+				continue
+			} else if err != nil {
+				return result, err
+			}
+		}
+		result.Created = append(result.Created, kvp.Key)
+	}
+
+	log.WithFields(log.Fields{
+		"created":     len(result.Created),
+		"overwritten": len(result.Overwritten),
+		"skipped":     len(result.Skipped),
+		"unsupported": len(result.Unsupported),
+		"dryRun":      opts.DryRun,
+	}).Info("Restored datastore")
+	return result, nil
+}
+
+// derivedFromKubernetes returns true if, on the Kubernetes datastore, the entry is derived from a
+// Kubernetes resource and so cannot be restored.
+func derivedFromKubernetes(key model.Key) bool {
+	rk, ok := key.(model.ResourceKey)
+	if !ok {
+		return false
+	}
+	switch rk.Kind {
+	case apiv3.KindNode, apiv3.KindWorkloadEndpoint:
+		return true
+	case apiv3.KindProfile:
+		return strings.HasPrefix(rk.Name, conversion.NamespaceProfileNamePrefix) ||
+			strings.HasPrefix(rk.Name, conversion.ServiceAccountProfileNamePrefix)
+	}
+	return false
+}
+
+// isUnsupported returns true if the error indicates that the datastore cannot store the entry.
+func isUnsupported(err error) bool {
+	_, ok := err.(cerrors.ErrorOperationNotSupported)
+	return ok
+}
+
+// readArchive reads and verifies the archive, returning its entries in dependency order.
+func readArchive(r io.Reader) ([]*model.KVPair, error) {
+	dec := json.NewDecoder(r)
+	var h header
+	if err := dec.Decode(&h); err != nil {
+		return nil, fmt.Errorf("unable to read archive header: %v", err)
+	}
+	if h.Format != archiveFormat {
+		return nil, fmt.Errorf("not a Calico backup archive: format %q", h.Format)
+	}
+	if h.Version < 1 || h.Version > archiveVersion {
+		return nil, fmt.Errorf("unsupported archive version %d, expected version %d or earlier", h.Version, archiveVersion)
+	}
+
+	order := map[string]int{}
+	for i, ak := range archiveKinds {
+		order[ak.kind] = i
+	}
+
+	var kvps []*model.KVPair
+	var kindOrder []int
+	checksums := sha256.New()
+	for {
+		var e entry
+		if err := dec.Decode(&e); err == io.EOF {
+			return nil, errors.New("archive is truncated: no trailer found")
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to read archive entry %d: %v", len(kvps)+1, err)
+		}
+
+		if e.End {
+			if e.Entries != len(kvps) {
+				return nil, fmt.Errorf("archive has %d entries but its trailer expects %d", len(kvps), e.Entries)
+			}
+			if e.Checksum != archiveChecksum(checksums) {
+				return nil, errors.New("archive checksum does not match its entries")
+			}
+			break
+		}
+
+		kvp, err := parseEntry(&e)
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive entry %d: %v", len(kvps)+1, err)
+		}
+		checksums.Write([]byte(e.Checksum))
+		kvps = append(kvps, kvp)
+		kindOrder = append(kindOrder, order[e.Kind])
+	}
+
+	// Sort the entries by kind, keeping the order of the entries of each kind.
+	idx := make([]int, len(kvps))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return kindOrder[idx[i]] < kindOrder[idx[j]] })
+	sorted := make([]*model.KVPair, len(kvps))
+	for i, n := range idx {
+		sorted[i] = kvps[n]
+	}
+	return sorted, nil
+}
+
+// parseEntry verifies the archive entry and returns the datastore entry that it holds.
+func parseEntry(e *entry) (*model.KVPair, error) {
+	checksum, err := entryChecksum(e)
+	if err != nil {
+		return nil, err
+	}
+	if checksum != e.Checksum {
+		return nil, fmt.Errorf("checksum of %s does not match", e.Path)
+	}
+
+	var key model.Key
+	for _, ak := range archiveKinds {
+		if ak.kind != e.Kind {
+			continue
+		}
+		if ak.list == nil {
+			if path, _ := model.KeyToDefaultPath(model.IPAMConfigKey{}); path == e.Path {
+				key = model.IPAMConfigKey{}
+			}
+		} else {
+			key = ak.list.KeyFromDefaultPath(e.Path)
+		}
+		if key == nil {
+			return nil, fmt.Errorf("%s is not the path of a %s", e.Path, e.Kind)
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("unknown kind %s", e.Kind)
+	}
+
+	value, err := model.ParseValue(key, e.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &model.KVPair{Key: key, Value: value}, nil
+}
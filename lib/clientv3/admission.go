@@ -0,0 +1,130 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientv3
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+	validator "github.com/unai-ttxu/libcalico-go/lib/validator/v3"
+)
+
+// AdmissionOperation is the operation on a resource that is being admitted.
+type AdmissionOperation string
+
+const (
+	AdmissionCreate AdmissionOperation = "Create"
+	AdmissionUpdate AdmissionOperation = "Update"
+	AdmissionDelete AdmissionOperation = "Delete"
+)
+
+// AdmissionRequest describes a create, update or delete of a resource for the admission hooks.
+// The hooks run after the resource has passed validation, and see the resource as it is stored,
+// so the names of policies include their tier, for example "default.allow-dns".  A resource
+// modified by the mutating hooks is validated again before the validating hooks run.  A delete
+// is admitted before any of the data associated with the resource is cleaned up.
+type AdmissionRequest struct {
+	Operation AdmissionOperation
+	Kind      string
+	Namespace string
+	Name      string
+
+	// The stored resource, for an update or delete.  Nil for a create.  Hooks must not modify
+	// it.
+	Old runtime.Object
+
+	// The resource being written, for a create or update.  Nil for a delete.  Mutating hooks
+	// may modify it, other than its name and namespace.
+	New runtime.Object
+}
+
+// MutatingHook is an admission hook that may modify resources as they are created or updated.
+// An error rejects the request.
+type MutatingHook func(ctx context.Context, req AdmissionRequest) error
+
+// ValidatingHook is an admission hook that checks resources as they are created, updated or
+// deleted.  An error rejects the request.
+type ValidatingHook func(ctx context.Context, req AdmissionRequest) error
+
+// admissionHooks are the admission hooks configured on a client.  The mutating hooks run in
+// order, followed by validation of the mutated resource, and then the validating hooks in order.
+type admissionHooks struct {
+	mutating   []MutatingHook
+	validating []ValidatingHook
+}
+
+func (h admissionHooks) empty() bool {
+	return len(h.mutating) == 0 && len(h.validating) == 0
+}
+
+// run runs the admission hooks for the request.  Errors from the hooks are returned as
+// validation errors.
+func (h admissionHooks) run(ctx context.Context, req AdmissionRequest) error {
+	if req.Operation != AdmissionDelete {
+		for _, hook := range h.mutating {
+			if err := hook(ctx, req); err != nil {
+				return admissionError(req, err)
+			}
+		}
+		meta := req.New.(resource).GetObjectMeta()
+		if meta.GetName() != req.Name || meta.GetNamespace() != req.Namespace {
+			return cerrors.ErrorValidation{
+				ErroredFields: []cerrors.ErroredField{{
+					Name:   "Metadata",
+					Reason: "name and namespace cannot be changed by an admission hook",
+				}},
+			}
+		}
+		if len(h.mutating) > 0 {
+			if err := validateMutated(req.Kind, req.New.(resource)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, hook := range h.validating {
+		if err := hook(ctx, req); err != nil {
+			return admissionError(req, err)
+		}
+	}
+	return nil
+}
+
+// validateMutated validates a resource that has been modified by the mutating hooks.  Policies
+// are stored with their tier prefixed to their name, which the validator does not accept, so the
+// prefix is removed from a copy of the policy before it is validated.
+func validateMutated(kind string, in resource) error {
+	res := in.DeepCopyObject().(resource)
+	if kind == apiv3.KindGlobalNetworkPolicy || kind == apiv3.KindNetworkPolicy {
+		res.GetObjectMeta().SetName(convertPolicyNameFromStorage(res.GetObjectMeta().GetName()))
+	}
+	return validator.Validate(res)
+}
+
+// admissionError returns the error from an admission hook as a validation error.
+func admissionError(req AdmissionRequest, err error) error {
+	if _, ok := err.(cerrors.ErrorValidation); ok {
+		return err
+	}
+	return cerrors.ErrorValidation{
+		ErroredFields: []cerrors.ErroredField{{
+			Name:   req.Kind,
+			Value:  req.Name,
+			Reason: err.Error(),
+		}},
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientv3_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/unai-ttxu/libcalico-go/lib/apiconfig"
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	"github.com/unai-ttxu/libcalico-go/lib/backend"
+	"github.com/unai-ttxu/libcalico-go/lib/clientv3"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+	"github.com/unai-ttxu/libcalico-go/lib/options"
+	"github.com/unai-ttxu/libcalico-go/lib/testutils"
+)
+
+var _ = testutils.E2eDatastoreDescribe("Admission hook tests", testutils.DatastoreAll, func(config apiconfig.CalicoAPIConfig) {
+	ctx := context.Background()
+	var c clientv3.Interface
+	var requests []clientv3.AdmissionRequest
+
+	// Require natOutgoing on IPPools, and protect IPPools labelled as such from deletion.
+	requireNATOutgoing := func(ctx context.Context, req clientv3.AdmissionRequest) error {
+		requests = append(requests, req)
+		if pool, ok := req.New.(*apiv3.IPPool); ok && !pool.Spec.NATOutgoing {
+			return errors.New("IPPools must have natOutgoing")
+		}
+		if pool, ok := req.Old.(*apiv3.IPPool); ok && req.Operation == clientv3.AdmissionDelete && pool.Labels["protected"] == "true" {
+			return errors.New("IPPool is protected")
+		}
+		return nil
+	}
+	// Label every IPPool with the team that owns it.
+	addOwner := func(ctx context.Context, req clientv3.AdmissionRequest) error {
+		if pool, ok := req.New.(*apiv3.IPPool); ok {
+			if pool.Labels == nil {
+				pool.Labels = map[string]string{}
+			}
+			pool.Labels["owner"] = "network-team"
+		}
+		return nil
+	}
+	newPool := func(natOutgoing bool) *apiv3.IPPool {
+		p := apiv3.NewIPPool()
+		p.Name = "pool-1"
+		p.Spec.CIDR = "10.1.0.0/16"
+		p.Spec.NATOutgoing = natOutgoing
+		return p
+	}
+
+	BeforeEach(func() {
+		var err error
+		c, err = clientv3.New(config,
+			clientv3.WithMutatingHooks(addOwner),
+			clientv3.WithValidatingHooks(requireNATOutgoing),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		be, err := backend.NewClient(config)
+		Expect(err).NotTo(HaveOccurred())
+		be.Clean()
+		requests = nil
+	})
+
+	It("should run the hooks on create, update and delete", func() {
+		By("Rejecting a resource that fails a validating hook")
+		_, err := c.IPPools().Create(ctx, newPool(false), options.SetOptions{})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorValidation{}))
+		Expect(err.Error()).To(ContainSubstring("IPPools must have natOutgoing"))
+		_, err = c.IPPools().Get(ctx, "pool-1", options.GetOptions{})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorResourceDoesNotExist{}))
+
+		By("Creating a resource modified by a mutating hook")
+		pool, err := c.IPPools().Create(ctx, newPool(true), options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pool.Labels).To(HaveKeyWithValue("owner", "network-team"))
+		Expect(requests[len(requests)-1].Operation).To(Equal(clientv3.AdmissionCreate))
+		Expect(requests[len(requests)-1].Old).To(BeNil())
+
+		By("Passing the stored resource to the hooks on update")
+		pool.Labels["protected"] = "true"
+		pool, err = c.IPPools().Update(ctx, pool, options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		req := requests[len(requests)-1]
+		Expect(req.Operation).To(Equal(clientv3.AdmissionUpdate))
+		Expect(req.Old.(*apiv3.IPPool).Labels).NotTo(HaveKey("protected"))
+
+		By("Rejecting a delete that fails a validating hook")
+		_, err = c.IPPools().Delete(ctx, "pool-1", options.DeleteOptions{})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorValidation{}))
+		Expect(err.Error()).To(ContainSubstring("IPPool is protected"))
+		req = requests[len(requests)-1]
+		Expect(req.Operation).To(Equal(clientv3.AdmissionDelete))
+		Expect(req.New).To(BeNil())
+
+		pool, err = c.IPPools().Get(ctx, "pool-1", options.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pool.Spec.Disabled).To(BeFalse())
+		delete(pool.Labels, "protected")
+		_, err = c.IPPools().Update(ctx, pool, options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = c.IPPools().Delete(ctx, "pool-1", options.DeleteOptions{})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should validate a resource modified by a mutating hook before the validating hooks run", func() {
+		addBadLabel := func(ctx context.Context, req clientv3.AdmissionRequest) error {
+			if pool, ok := req.New.(*apiv3.IPPool); ok {
+				pool.Labels = map[string]string{"bad label": "x"}
+			}
+			return nil
+		}
+		c, err := clientv3.New(config,
+			clientv3.WithMutatingHooks(addBadLabel),
+			clientv3.WithValidatingHooks(requireNATOutgoing),
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = c.IPPools().Create(ctx, newPool(true), options.SetOptions{})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorValidation{}))
+		Expect(requests).To(BeEmpty())
+		_, err = c.IPPools().Get(ctx, "pool-1", options.GetOptions{})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorResourceDoesNotExist{}))
+	})
+
+	It("should not enable IPIP for a pool that is rejected", func() {
+		pool := newPool(false)
+		pool.Spec.IPIPMode = apiv3.IPIPModeAlways
+		_, err := c.IPPools().Create(ctx, pool, options.SetOptions{})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorValidation{}))
+
+		felixConfig, err := c.FelixConfigurations().Get(ctx, "default", options.GetOptions{})
+		if err == nil {
+			Expect(felixConfig.Spec.IPIPEnabled).To(BeNil())
+		} else {
+			Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorResourceDoesNotExist{}))
+		}
+	})
+
+	It("should check a pool modified by a mutating hook as for a pool modified by the client", func() {
+		moveCIDR := func(ctx context.Context, req clientv3.AdmissionRequest) error {
+			if pool, ok := req.New.(*apiv3.IPPool); ok && req.Operation == clientv3.AdmissionUpdate {
+				pool.Spec.CIDR = "10.2.0.0/16"
+			}
+			return nil
+		}
+		c, err := clientv3.New(config, clientv3.WithMutatingHooks(moveCIDR))
+		Expect(err).NotTo(HaveOccurred())
+
+		pool, err := c.IPPools().Create(ctx, newPool(true), options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = c.IPPools().Update(ctx, pool, options.SetOptions{})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorValidation{}))
+		Expect(err.Error()).To(ContainSubstring("IPPool CIDR cannot be modified"))
+
+		pool, err = c.IPPools().Get(ctx, "pool-1", options.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pool.Spec.CIDR).To(Equal("10.1.0.0/16"))
+	})
+})
+
+var _ = testutils.E2eDatastoreDescribe("Admission hook tests (etcdv3)", testutils.DatastoreEtcdV3, func(config apiconfig.CalicoAPIConfig) {
+	ctx := context.Background()
+
+	It("should reject a node delete before cleaning up the node's data", func() {
+		protectNodes := func(ctx context.Context, req clientv3.AdmissionRequest) error {
+			if req.Kind == apiv3.KindNode && req.Operation == clientv3.AdmissionDelete {
+				return errors.New("Nodes are protected")
+			}
+			return nil
+		}
+		c, err := clientv3.New(config, clientv3.WithValidatingHooks(protectNodes))
+		Expect(err).NotTo(HaveOccurred())
+
+		be, err := backend.NewClient(config)
+		Expect(err).NotTo(HaveOccurred())
+		be.Clean()
+
+		node := apiv3.NewNode()
+		node.Name = "node-1"
+		_, err = c.Nodes().Create(ctx, node, options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		hep := apiv3.NewHostEndpoint()
+		hep.Name = "hep-1"
+		hep.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: apiv3.GroupVersionCurrent,
+			Kind:       apiv3.KindNode,
+			Name:       "node-1",
+		}}
+		hep.Spec.Node = "node-1"
+		hep.Spec.InterfaceName = "eth0"
+		_, err = c.HostEndpoints().Create(ctx, hep, options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = c.Nodes().Delete(ctx, "node-1", options.DeleteOptions{})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorValidation{}))
+		Expect(err.Error()).To(ContainSubstring("Nodes are protected"))
+
+		_, err = c.HostEndpoints().Get(ctx, "hep-1", options.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		node, err = c.Nodes().Get(ctx, "node-1", options.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(node.Status.DecommissionCompletedSteps).To(BeEmpty())
+	})
+})
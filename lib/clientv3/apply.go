@@ -0,0 +1,256 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientv3
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+	"github.com/unai-ttxu/libcalico-go/lib/options"
+	"github.com/unai-ttxu/libcalico-go/lib/patch"
+)
+
+// lastAppliedAnnotation holds the configuration of a resource as last applied by Apply.  Apply
+// uses it to find the fields that were set by a previous configuration but are no longer set,
+// and so should be removed.
+const lastAppliedAnnotation = "projectcalico.org/last-applied-configuration"
+
+// ApplyResult is the result of applying a resource.
+type ApplyResult struct {
+	// The resource as stored, or for a dry run, as it would be stored.
+	Object runtime.Object
+
+	// Whether the resource was created, rather than updated.
+	Created bool
+
+	// The fields changed by the apply, in order of path.  Empty if the resource was already up
+	// to date.
+	Changes []patch.FieldChange
+}
+
+// Apply creates or updates a resource to match its configuration, in the same way as kubectl
+// apply.  The configuration is stored on the resource, and the next apply merges the new
+// configuration with the stored resource: fields that are set by the new configuration are
+// updated, fields that were set by the previous configuration but not by the new one are
+// removed, and fields that neither configuration sets are left as they are.  This preserves
+// fields written by other clients, such as the addresses calico/node writes to its Node.
+//
+// Objects are merged field by field.  Since the v3 API does not define merge keys for its lists,
+// lists are replaced as a whole, as a strategic merge does for lists without a merge key.
+func (c client) Apply(ctx context.Context, res runtime.Object, opts options.ApplyOptions) (*ApplyResult, error) {
+	kind := res.GetObjectKind().GroupVersionKind().Kind
+	in, ok := res.(resource)
+	kc := c.kindClient(kind)
+	if !ok || kc == nil {
+		return nil, cerrors.ErrorOperationNotSupported{
+			Operation:  "Apply",
+			Identifier: kind,
+			Reason:     "not a Calico resource kind",
+		}
+	}
+
+	applied, err := appliedConfiguration(in)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *ApplyResult
+	for i := 0; i < maxApplyRetries; i++ {
+		logWithResource(in).WithField("Retry", i).Debug("Applying resource")
+		current, err := kc.get(ctx, in.GetObjectMeta().GetNamespace(), in.GetObjectMeta().GetName())
+		if _, ok := err.(cerrors.ErrorResourceDoesNotExist); ok {
+			result, err = applyCreate(ctx, kc, in, applied, opts)
+			if _, ok := err.(cerrors.ErrorResourceAlreadyExists); ok {
+				logWithResource(in).Debug("Resource created by another client - retry apply")
+				continue
+			}
+			return result, err
+		} else if err != nil {
+			return nil, err
+		}
+
+		result, err = applyUpdate(ctx, kc, current, applied, opts)
+		if _, ok := err.(cerrors.ErrorResourceUpdateConflict); ok {
+			logWithResource(in).Debug("Resource updated by another client - retry apply")
+			continue
+		}
+		return result, err
+	}
+
+	logWithResource(in).Info("Too many conflict failures attempting to apply resource")
+	return nil, cerrors.ErrorResourceUpdateConflict{Identifier: in.GetObjectMeta().GetName()}
+}
+
+// applyCreate creates a resource that does not exist from its applied configuration.
+func applyCreate(ctx context.Context, kc *kindClient, in resource, applied map[string]interface{}, opts options.ApplyOptions) (*ApplyResult, error) {
+	out := in.DeepCopyObject().(resource)
+	out.GetObjectMeta().SetResourceVersion("")
+	if err := setLastApplied(out, applied); err != nil {
+		return nil, err
+	}
+	result := &ApplyResult{Created: true, Changes: patch.DiffFields(nil, applied)}
+
+	created, err := kc.create(ctx, out, options.SetOptions{DryRun: opts.DryRun})
+	if err != nil {
+		return nil, err
+	}
+	result.Object = created
+	return result, nil
+}
+
+// applyUpdate merges the applied configuration with the current resource, and updates the
+// resource if that changes it.
+func applyUpdate(ctx context.Context, kc *kindClient, current resource, applied map[string]interface{}, opts options.ApplyOptions) (*ApplyResult, error) {
+	var previous map[string]interface{}
+	if last, ok := current.GetObjectMeta().GetAnnotations()[lastAppliedAnnotation]; ok {
+		if err := json.Unmarshal([]byte(last), &previous); err != nil {
+			logWithResource(current).WithError(err).Warning("Ignoring invalid last applied configuration")
+			previous = nil
+		}
+	}
+	currentFields, err := resourceFields(current)
+	if err != nil {
+		return nil, err
+	}
+	mergedFields := threeWayMerge(previous, applied, currentFields)
+
+	out := reflect.New(reflect.TypeOf(current).Elem()).Interface().(resource)
+	if err := fieldsToResource(mergedFields, out); err != nil {
+		return nil, err
+	}
+	if err := setLastApplied(out, applied); err != nil {
+		return nil, err
+	}
+	result := &ApplyResult{Changes: patch.DiffFields(currentFields, mergedFields)}
+
+	if len(result.Changes) == 0 && reflect.DeepEqual(previous, applied) {
+		logWithResource(current).Debug("Resource is already up to date")
+		result.Object = current
+		return result, nil
+	}
+
+	updated, err := kc.update(ctx, out, options.SetOptions{DryRun: opts.DryRun})
+	if err != nil {
+		return nil, err
+	}
+	result.Object = updated
+	return result, nil
+}
+
+// appliedConfiguration returns the fields of the resource that are set by its configuration.
+// Metadata that is set by the datastore, and the status, which is only written by UpdateStatus,
+// are not part of the configuration.
+func appliedConfiguration(in resource) (map[string]interface{}, error) {
+	res := in.DeepCopyObject().(resource)
+	meta := res.GetObjectMeta()
+	meta.SetResourceVersion("")
+	meta.SetUID("")
+	meta.SetSelfLink("")
+	meta.SetCreationTimestamp(metav1.Time{})
+	if annotations := meta.GetAnnotations(); annotations != nil {
+		delete(annotations, lastAppliedAnnotation)
+		if len(annotations) == 0 {
+			meta.SetAnnotations(nil)
+		}
+	}
+	fields, err := resourceFields(res)
+	if err != nil {
+		return nil, err
+	}
+	delete(fields, "status")
+	return fields, nil
+}
+
+// setLastApplied stores the applied configuration on the resource.
+func setLastApplied(res resource, applied map[string]interface{}) error {
+	b, err := json.Marshal(applied)
+	if err != nil {
+		return err
+	}
+	annotations := map[string]string{}
+	for k, v := range res.GetObjectMeta().GetAnnotations() {
+		annotations[k] = v
+	}
+	annotations[lastAppliedAnnotation] = string(b)
+	res.GetObjectMeta().SetAnnotations(annotations)
+	return nil
+}
+
+// resourceFields returns the fields of the resource as unstructured JSON, omitting null fields.
+func resourceFields(res resource) (map[string]interface{}, error) {
+	b, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	removeNullFields(fields)
+	return fields, nil
+}
+
+// fieldsToResource decodes unstructured JSON fields into the resource.
+func fieldsToResource(fields map[string]interface{}, res resource) error {
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, res)
+}
+
+// removeNullFields removes the null fields from the unstructured JSON object, recursively.
+func removeNullFields(fields map[string]interface{}) {
+	for k, v := range fields {
+		switch v := v.(type) {
+		case nil:
+			delete(fields, k)
+		case map[string]interface{}:
+			removeNullFields(v)
+		}
+	}
+}
+
+// threeWayMerge merges the applied configuration with the current fields of a resource, given
+// the previously applied configuration.  Fields set by the applied configuration take its
+// value, fields set only by the previous configuration are removed, and other fields are kept.
+// Objects are merged recursively; other values, including lists, are replaced.
+func threeWayMerge(previous, applied, current map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for k, v := range current {
+		merged[k] = v
+	}
+	for k := range previous {
+		if _, ok := applied[k]; !ok {
+			delete(merged, k)
+		}
+	}
+	for k, v := range applied {
+		appliedObj, appliedIsObj := v.(map[string]interface{})
+		currentObj, currentIsObj := merged[k].(map[string]interface{})
+		if appliedIsObj && currentIsObj {
+			previousObj, _ := previous[k].(map[string]interface{})
+			merged[k] = threeWayMerge(previousObj, appliedObj, currentObj)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientv3_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/unai-ttxu/libcalico-go/lib/apiconfig"
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	"github.com/unai-ttxu/libcalico-go/lib/backend"
+	"github.com/unai-ttxu/libcalico-go/lib/clientv3"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+	"github.com/unai-ttxu/libcalico-go/lib/numorstring"
+	"github.com/unai-ttxu/libcalico-go/lib/options"
+	"github.com/unai-ttxu/libcalico-go/lib/patch"
+	"github.com/unai-ttxu/libcalico-go/lib/testutils"
+)
+
+var _ = testutils.E2eDatastoreDescribe("Apply tests", testutils.DatastoreEtcdV3, func(config apiconfig.CalicoAPIConfig) {
+	ctx := context.Background()
+	var c clientv3.Interface
+
+	nodeConfig := func(asNumber numorstring.ASNumber, labels map[string]string) *apiv3.Node {
+		n := apiv3.NewNode()
+		n.Name = "node-1"
+		n.Labels = labels
+		n.Spec.BGP = &apiv3.NodeBGPSpec{ASNumber: &asNumber}
+		return n
+	}
+	changedPaths := func(changes []patch.FieldChange) []string {
+		var paths []string
+		for _, c := range changes {
+			paths = append(paths, c.FieldPath())
+		}
+		return paths
+	}
+
+	BeforeEach(func() {
+		var err error
+		c, err = clientv3.New(config)
+		Expect(err).NotTo(HaveOccurred())
+
+		be, err := backend.NewClient(config)
+		Expect(err).NotTo(HaveOccurred())
+		be.Clean()
+	})
+
+	It("should create and update resources, preserving fields written by other clients", func() {
+		By("Applying a new Node")
+		result, err := c.Apply(ctx, nodeConfig(64512, map[string]string{"rack": "a", "zone": "1"}), options.ApplyOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Created).To(BeTrue())
+		Expect(changedPaths(result.Changes)).To(ContainElement("spec.bgp.asNumber"))
+
+		By("Updating the Node address, as calico/node does")
+		node, err := c.Nodes().Get(ctx, "node-1", options.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		node.Spec.BGP.IPv4Address = "10.0.0.1/24"
+		_, err = c.Nodes().Update(ctx, node, options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Applying a changed configuration")
+		result, err = c.Apply(ctx, nodeConfig(64513, map[string]string{"rack": "a"}), options.ApplyOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Created).To(BeFalse())
+		Expect(changedPaths(result.Changes)).To(Equal([]string{"metadata.labels.zone", "spec.bgp.asNumber"}))
+
+		node, err = c.Nodes().Get(ctx, "node-1", options.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(node.Labels).To(Equal(map[string]string{"rack": "a"}))
+		Expect(*node.Spec.BGP.ASNumber).To(Equal(numorstring.ASNumber(64513)))
+		Expect(node.Spec.BGP.IPv4Address).To(Equal("10.0.0.1/24"))
+
+		By("Applying the same configuration again")
+		result, err = c.Apply(ctx, nodeConfig(64513, map[string]string{"rack": "a"}), options.ApplyOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Changes).To(BeEmpty())
+		Expect(result.Object.(*apiv3.Node).ResourceVersion).To(Equal(node.ResourceVersion))
+	})
+
+	It("should report the changes without making them for a dry run", func() {
+		_, err := c.Apply(ctx, nodeConfig(64512, nil), options.ApplyOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		result, err := c.Apply(ctx, nodeConfig(64513, nil), options.ApplyOptions{DryRun: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changedPaths(result.Changes)).To(Equal([]string{"spec.bgp.asNumber"}))
+		Expect(*result.Object.(*apiv3.Node).Spec.BGP.ASNumber).To(Equal(numorstring.ASNumber(64513)))
+
+		node, err := c.Nodes().Get(ctx, "node-1", options.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*node.Spec.BGP.ASNumber).To(Equal(numorstring.ASNumber(64512)))
+
+		By("Validating the merged resource")
+		_, err = c.Apply(ctx, nodeConfig(0, map[string]string{"bad label": "x"}), options.ApplyOptions{DryRun: true})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should make the same checks as the client for the kind for a dry run", func() {
+		pool := func(name, cidr string) *apiv3.IPPool {
+			p := apiv3.NewIPPool()
+			p.Name = name
+			p.Spec.CIDR = cidr
+			p.Spec.IPIPMode = apiv3.IPIPModeAlways
+			return p
+		}
+		_, err := c.IPPools().Create(ctx, pool("pool-1", "10.0.0.0/24"), options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("Rejecting a pool that overlaps an existing pool")
+		_, err = c.Apply(ctx, pool("pool-2", "10.0.0.0/25"), options.ApplyOptions{DryRun: true})
+		Expect(err).To(HaveOccurred())
+
+		By("Accepting a pool that doesn't overlap without creating it")
+		_, err = c.FelixConfigurations().Delete(ctx, "default", options.DeleteOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		result, err := c.Apply(ctx, pool("pool-3", "10.0.1.0/24"), options.ApplyOptions{DryRun: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Created).To(BeTrue())
+		Expect(result.Object.(*apiv3.IPPool).Spec.BlockSize).To(Equal(26))
+
+		_, err = c.IPPools().Get(ctx, "pool-3", options.GetOptions{})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorResourceDoesNotExist{}))
+		_, err = c.FelixConfigurations().Get(ctx, "default", options.GetOptions{})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorResourceDoesNotExist{}))
+	})
+})
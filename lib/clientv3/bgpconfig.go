@@ -17,6 +17,8 @@ package clientv3
 import (
 	"context"
 
+	"k8s.io/apimachinery/pkg/types"
+
 	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
 	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
 	"github.com/unai-ttxu/libcalico-go/lib/options"
@@ -28,6 +30,7 @@ import (
 type BGPConfigurationInterface interface {
 	Create(ctx context.Context, res *apiv3.BGPConfiguration, opts options.SetOptions) (*apiv3.BGPConfiguration, error)
 	Update(ctx context.Context, res *apiv3.BGPConfiguration, opts options.SetOptions) (*apiv3.BGPConfiguration, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.BGPConfiguration, error)
 	Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.BGPConfiguration, error)
 	Get(ctx context.Context, name string, opts options.GetOptions) (*apiv3.BGPConfiguration, error)
 	List(ctx context.Context, opts options.ListOptions) (*apiv3.BGPConfigurationList, error)
@@ -78,6 +81,16 @@ func (r bgpConfigurations) Update(ctx context.Context, res *apiv3.BGPConfigurati
 	return nil, err
 }
 
+// Patch applies a JSON merge patch or JSON patch to the BGPConfiguration and updates it.  Returns the
+// stored representation of the BGPConfiguration, and an error, if there is any.
+func (r bgpConfigurations) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.BGPConfiguration, error) {
+	out, err := r.client.patchResource(ctx, apiv3.KindBGPConfiguration, noNamespace, name, pt, data, opts)
+	if out != nil {
+		return out.(*apiv3.BGPConfiguration), err
+	}
+	return nil, err
+}
+
 // Delete takes name of the BGPConfiguration and deletes it. Returns an
 // error if one occurs.
 func (r bgpConfigurations) Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.BGPConfiguration, error) {
@@ -17,6 +17,8 @@ package clientv3
 import (
 	"context"
 
+	"k8s.io/apimachinery/pkg/types"
+
 	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
 	"github.com/unai-ttxu/libcalico-go/lib/options"
 	validator "github.com/unai-ttxu/libcalico-go/lib/validator/v3"
@@ -27,6 +29,8 @@ import (
 type BGPPeerInterface interface {
 	Create(ctx context.Context, res *apiv3.BGPPeer, opts options.SetOptions) (*apiv3.BGPPeer, error)
 	Update(ctx context.Context, res *apiv3.BGPPeer, opts options.SetOptions) (*apiv3.BGPPeer, error)
+	UpdateStatus(ctx context.Context, res *apiv3.BGPPeer, opts options.SetOptions) (*apiv3.BGPPeer, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.BGPPeer, error)
 	Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.BGPPeer, error)
 	Get(ctx context.Context, name string, opts options.GetOptions) (*apiv3.BGPPeer, error)
 	List(ctx context.Context, opts options.ListOptions) (*apiv3.BGPPeerList, error)
@@ -66,6 +70,31 @@ func (r bgpPeers) Update(ctx context.Context, res *apiv3.BGPPeer, opts options.S
 	return nil, err
 }
 
+// UpdateStatus takes the representation of a BGPPeer and updates its status.  The rest of the
+// BGPPeer is not updated.  Returns the stored representation of the BGPPeer, and an error, if there
+// is any.
+func (r bgpPeers) UpdateStatus(ctx context.Context, res *apiv3.BGPPeer, opts options.SetOptions) (*apiv3.BGPPeer, error) {
+	if err := validator.Validate(res); err != nil {
+		return nil, err
+	}
+
+	out, err := r.client.resources.UpdateStatus(ctx, opts, apiv3.KindBGPPeer, res)
+	if out != nil {
+		return out.(*apiv3.BGPPeer), err
+	}
+	return nil, err
+}
+
+// Patch applies a JSON merge patch or JSON patch to the BGPPeer and updates it.  Returns the
+// stored representation of the BGPPeer, and an error, if there is any.
+func (r bgpPeers) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.BGPPeer, error) {
+	out, err := r.client.patchResource(ctx, apiv3.KindBGPPeer, noNamespace, name, pt, data, opts)
+	if out != nil {
+		return out.(*apiv3.BGPPeer), err
+	}
+	return nil, err
+}
+
 // Delete takes name of the BGPPeer and deletes it. Returns an error if one occurs.
 func (r bgpPeers) Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.BGPPeer, error) {
 	out, err := r.client.resources.Delete(ctx, opts, apiv3.KindBGPPeer, noNamespace, name)
@@ -49,17 +49,44 @@ type client struct {
 	resources resourceInterface
 }
 
+// ClientOption is an option for a client returned by New.
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	hooks admissionHooks
+}
+
+// WithMutatingHooks adds admission hooks that may modify resources as they are created or
+// updated.  See AdmissionRequest.
+func WithMutatingHooks(hooks ...MutatingHook) ClientOption {
+	return func(o *clientOptions) {
+		o.hooks.mutating = append(o.hooks.mutating, hooks...)
+	}
+}
+
+// WithValidatingHooks adds admission hooks that check resources as they are created, updated or
+// deleted.  See AdmissionRequest.
+func WithValidatingHooks(hooks ...ValidatingHook) ClientOption {
+	return func(o *clientOptions) {
+		o.hooks.validating = append(o.hooks.validating, hooks...)
+	}
+}
+
 // New returns a connected client. The ClientConfig can either be created explicitly,
 // or can be loaded from a config file or environment variables using the LoadClientConfig() function.
-func New(config apiconfig.CalicoAPIConfig) (Interface, error) {
+func New(config apiconfig.CalicoAPIConfig, opts ...ClientOption) (Interface, error) {
 	be, err := backend.NewClient(config)
 	if err != nil {
 		return nil, err
 	}
+	var o clientOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
 	return client{
 		config:    config,
 		backend:   be,
-		resources: &resources{backend: be},
+		resources: &resources{backend: be, hooks: o.hooks},
 	}, nil
 }
 
@@ -94,6 +121,11 @@ func (c client) IPPools() IPPoolInterface {
 	return ipPools{client: c}
 }
 
+// IPReservations returns an interface for managing IP reservation resources.
+func (c client) IPReservations() IPReservationInterface {
+	return ipReservations{client: c}
+}
+
 // Profiles returns an interface for managing profile resources.
 func (c client) Profiles() ProfileInterface {
 	return profiles{client: c}
@@ -331,6 +363,11 @@ func (c client) ensureClusterInformation(ctx context.Context, calicoVersion, clu
 	return nil
 }
 
+// Close closes the backend client.
+func (c client) Close() error {
+	return c.backend.Close()
+}
+
 // Backend returns the backend client used by the v3 client.  Not exposed on the main
 // client API, but available publicly for consumers that require access to the backend
 // client (e.g. for syncer support).
@@ -18,6 +18,8 @@ import (
 	"context"
 	"errors"
 
+	"k8s.io/apimachinery/pkg/types"
+
 	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
 	"github.com/unai-ttxu/libcalico-go/lib/options"
 	validator "github.com/unai-ttxu/libcalico-go/lib/validator/v3"
@@ -28,6 +30,7 @@ import (
 type ClusterInformationInterface interface {
 	Create(ctx context.Context, res *apiv3.ClusterInformation, opts options.SetOptions) (*apiv3.ClusterInformation, error)
 	Update(ctx context.Context, res *apiv3.ClusterInformation, opts options.SetOptions) (*apiv3.ClusterInformation, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.ClusterInformation, error)
 	Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.ClusterInformation, error)
 	Get(ctx context.Context, name string, opts options.GetOptions) (*apiv3.ClusterInformation, error)
 	List(ctx context.Context, opts options.ListOptions) (*apiv3.ClusterInformationList, error)
@@ -72,6 +75,16 @@ func (r clusterInformation) Update(ctx context.Context, res *apiv3.ClusterInform
 	return nil, err
 }
 
+// Patch applies a JSON merge patch or JSON patch to the ClusterInformation and updates it.  Returns the
+// stored representation of the ClusterInformation, and an error, if there is any.
+func (r clusterInformation) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.ClusterInformation, error) {
+	out, err := r.client.patchResource(ctx, apiv3.KindClusterInformation, noNamespace, name, pt, data, opts)
+	if out != nil {
+		return out.(*apiv3.ClusterInformation), err
+	}
+	return nil, err
+}
+
 // Delete takes name of the ClusterInformation and deletes it. Returns an
 // error if one occurs.
 func (r clusterInformation) Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.ClusterInformation, error) {
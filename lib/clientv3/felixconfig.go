@@ -17,6 +17,8 @@ package clientv3
 import (
 	"context"
 
+	"k8s.io/apimachinery/pkg/types"
+
 	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
 	"github.com/unai-ttxu/libcalico-go/lib/options"
 	validator "github.com/unai-ttxu/libcalico-go/lib/validator/v3"
@@ -27,6 +29,7 @@ import (
 type FelixConfigurationInterface interface {
 	Create(ctx context.Context, res *apiv3.FelixConfiguration, opts options.SetOptions) (*apiv3.FelixConfiguration, error)
 	Update(ctx context.Context, res *apiv3.FelixConfiguration, opts options.SetOptions) (*apiv3.FelixConfiguration, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.FelixConfiguration, error)
 	Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.FelixConfiguration, error)
 	Get(ctx context.Context, name string, opts options.GetOptions) (*apiv3.FelixConfiguration, error)
 	List(ctx context.Context, opts options.ListOptions) (*apiv3.FelixConfigurationList, error)
@@ -68,6 +71,16 @@ func (r felixConfigurations) Update(ctx context.Context, res *apiv3.FelixConfigu
 	return nil, err
 }
 
+// Patch applies a JSON merge patch or JSON patch to the FelixConfiguration and updates it.  Returns the
+// stored representation of the FelixConfiguration, and an error, if there is any.
+func (r felixConfigurations) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.FelixConfiguration, error) {
+	out, err := r.client.patchResource(ctx, apiv3.KindFelixConfiguration, noNamespace, name, pt, data, opts)
+	if out != nil {
+		return out.(*apiv3.FelixConfiguration), err
+	}
+	return nil, err
+}
+
 // Delete takes name of the FelixConfiguration and deletes it. Returns an
 // error if one occurs.
 func (r felixConfigurations) Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.FelixConfiguration, error) {
@@ -18,6 +18,8 @@ import (
 	"context"
 	"strings"
 
+	"k8s.io/apimachinery/pkg/types"
+
 	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
 	"github.com/unai-ttxu/libcalico-go/lib/options"
 	validator "github.com/unai-ttxu/libcalico-go/lib/validator/v3"
@@ -28,6 +30,7 @@ import (
 type GlobalNetworkPolicyInterface interface {
 	Create(ctx context.Context, res *apiv3.GlobalNetworkPolicy, opts options.SetOptions) (*apiv3.GlobalNetworkPolicy, error)
 	Update(ctx context.Context, res *apiv3.GlobalNetworkPolicy, opts options.SetOptions) (*apiv3.GlobalNetworkPolicy, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.GlobalNetworkPolicy, error)
 	Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.GlobalNetworkPolicy, error)
 	Get(ctx context.Context, name string, opts options.GetOptions) (*apiv3.GlobalNetworkPolicy, error)
 	List(ctx context.Context, opts options.ListOptions) (*apiv3.GlobalNetworkPolicyList, error)
@@ -97,6 +100,16 @@ func (r globalNetworkPolicies) Update(ctx context.Context, res *apiv3.GlobalNetw
 	return nil, err
 }
 
+// Patch applies a JSON merge patch or JSON patch to the GlobalNetworkPolicy and updates it.  Returns the
+// stored representation of the GlobalNetworkPolicy, and an error, if there is any.
+func (r globalNetworkPolicies) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.GlobalNetworkPolicy, error) {
+	out, err := r.client.patchResource(ctx, apiv3.KindGlobalNetworkPolicy, noNamespace, name, pt, data, opts)
+	if out != nil {
+		return out.(*apiv3.GlobalNetworkPolicy), err
+	}
+	return nil, err
+}
+
 // Delete takes name of the GlobalNetworkPolicy and deletes it. Returns an error if one occurs.
 func (r globalNetworkPolicies) Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.GlobalNetworkPolicy, error) {
 	out, err := r.client.resources.Delete(ctx, opts, apiv3.KindGlobalNetworkPolicy, noNamespace, convertPolicyNameForStorage(name))
@@ -17,6 +17,8 @@ package clientv3
 import (
 	"context"
 
+	"k8s.io/apimachinery/pkg/types"
+
 	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
 	"github.com/unai-ttxu/libcalico-go/lib/options"
 	validator "github.com/unai-ttxu/libcalico-go/lib/validator/v3"
@@ -27,6 +29,7 @@ import (
 type GlobalNetworkSetInterface interface {
 	Create(ctx context.Context, res *apiv3.GlobalNetworkSet, opts options.SetOptions) (*apiv3.GlobalNetworkSet, error)
 	Update(ctx context.Context, res *apiv3.GlobalNetworkSet, opts options.SetOptions) (*apiv3.GlobalNetworkSet, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.GlobalNetworkSet, error)
 	Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.GlobalNetworkSet, error)
 	Get(ctx context.Context, name string, opts options.GetOptions) (*apiv3.GlobalNetworkSet, error)
 	List(ctx context.Context, opts options.ListOptions) (*apiv3.GlobalNetworkSetList, error)
@@ -66,6 +69,16 @@ func (r globalNetworkSets) Update(ctx context.Context, res *apiv3.GlobalNetworkS
 	return nil, err
 }
 
+// Patch applies a JSON merge patch or JSON patch to the GlobalNetworkSet and updates it.  Returns the
+// stored representation of the GlobalNetworkSet, and an error, if there is any.
+func (r globalNetworkSets) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.GlobalNetworkSet, error) {
+	out, err := r.client.patchResource(ctx, apiv3.KindGlobalNetworkSet, noNamespace, name, pt, data, opts)
+	if out != nil {
+		return out.(*apiv3.GlobalNetworkSet), err
+	}
+	return nil, err
+}
+
 // Delete takes name of the GlobalNetworkSet and deletes it. Returns an error if one occurs.
 func (r globalNetworkSets) Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.GlobalNetworkSet, error) {
 	out, err := r.client.resources.Delete(ctx, opts, apiv3.KindGlobalNetworkSet, noNamespace, name)
@@ -17,6 +17,8 @@ package clientv3
 import (
 	"context"
 
+	"k8s.io/apimachinery/pkg/types"
+
 	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
 	"github.com/unai-ttxu/libcalico-go/lib/options"
 	validator "github.com/unai-ttxu/libcalico-go/lib/validator/v3"
@@ -27,6 +29,7 @@ import (
 type HostEndpointInterface interface {
 	Create(ctx context.Context, res *apiv3.HostEndpoint, opts options.SetOptions) (*apiv3.HostEndpoint, error)
 	Update(ctx context.Context, res *apiv3.HostEndpoint, opts options.SetOptions) (*apiv3.HostEndpoint, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.HostEndpoint, error)
 	Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.HostEndpoint, error)
 	Get(ctx context.Context, name string, opts options.GetOptions) (*apiv3.HostEndpoint, error)
 	List(ctx context.Context, opts options.ListOptions) (*apiv3.HostEndpointList, error)
@@ -66,6 +69,16 @@ func (r hostEndpoints) Update(ctx context.Context, res *apiv3.HostEndpoint, opts
 	return nil, err
 }
 
+// Patch applies a JSON merge patch or JSON patch to the HostEndpoint and updates it.  Returns the
+// stored representation of the HostEndpoint, and an error, if there is any.
+func (r hostEndpoints) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.HostEndpoint, error) {
+	out, err := r.client.patchResource(ctx, apiv3.KindHostEndpoint, noNamespace, name, pt, data, opts)
+	if out != nil {
+		return out.(*apiv3.HostEndpoint), err
+	}
+	return nil, err
+}
+
 // Delete takes name of the HostEndpoint and deletes it. Returns an error if one occurs.
 func (r hostEndpoints) Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.HostEndpoint, error) {
 	out, err := r.client.resources.Delete(ctx, opts, apiv3.KindHostEndpoint, noNamespace, name)
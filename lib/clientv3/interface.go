@@ -17,7 +17,10 @@ package clientv3
 import (
 	"context"
 
+	"k8s.io/apimachinery/pkg/runtime"
+
 	"github.com/unai-ttxu/libcalico-go/lib/ipam"
+	"github.com/unai-ttxu/libcalico-go/lib/options"
 )
 
 type Interface interface {
@@ -29,6 +32,8 @@ type Interface interface {
 	NetworkPolicies() NetworkPolicyInterface
 	// IPPools returns an interface for managing IP pool resources.
 	IPPools() IPPoolInterface
+	// IPReservations returns an interface for managing IP reservation resources.
+	IPReservations() IPReservationInterface
 	// Profiles returns an interface for managing profile resources.
 	Profiles() ProfileInterface
 	// GlobalNetworkSets returns an interface for managing global network sets resources.
@@ -49,6 +54,9 @@ type Interface interface {
 	FelixConfigurations() FelixConfigurationInterface
 	// ClusterInformation returns an interface for managing the cluster information resource.
 	ClusterInformation() ClusterInformationInterface
+	// Apply creates or updates a resource of any kind to match its declarative configuration,
+	// preserving the fields that the configuration does not set.
+	Apply(ctx context.Context, res runtime.Object, opts options.ApplyOptions) (*ApplyResult, error)
 	// EnsureInitialized is used to ensure the backend datastore is correctly
 	// initialized for use by Calico.  This method may be called multiple times, and
 	// will have no effect if the datastore is already correctly initialized.
@@ -56,6 +64,9 @@ type Interface interface {
 	// method and so a general consumer of this API can assume that the datastore
 	// is already initialized.
 	EnsureInitialized(ctx context.Context, calicoVersion, clusterType string) error
+	// Close releases the resources held by the client, such as its connections to the
+	// datastore.  The client must not be used once it is closed.
+	Close() error
 }
 
 // Compile-time assertion that our client implements its interface.
@@ -18,9 +18,11 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"reflect"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/types"
 
 	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
 	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
@@ -35,6 +37,8 @@ import (
 type IPPoolInterface interface {
 	Create(ctx context.Context, res *apiv3.IPPool, opts options.SetOptions) (*apiv3.IPPool, error)
 	Update(ctx context.Context, res *apiv3.IPPool, opts options.SetOptions) (*apiv3.IPPool, error)
+	UpdateStatus(ctx context.Context, res *apiv3.IPPool, opts options.SetOptions) (*apiv3.IPPool, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.IPPool, error)
 	Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.IPPool, error)
 	Get(ctx context.Context, name string, opts options.GetOptions) (*apiv3.IPPool, error)
 	List(ctx context.Context, opts options.ListOptions) (*apiv3.IPPoolList, error)
@@ -56,19 +60,58 @@ func (r ipPools) Create(ctx context.Context, res *apiv3.IPPool, opts options.Set
 		res = &resCopy
 	}
 	// Validate the IPPool before creating the resource.
-	if err := r.validateAndSetDefaults(ctx, res, nil); err != nil {
+	if err := r.validateCreate(ctx, res); err != nil {
 		return nil, err
 	}
 
-	if err := validator.Validate(res); err != nil {
+	// Run the admission hooks before enabling IPIP or VXLAN, so that a pool that is rejected has
+	// no side effects.  The mutating hooks run after the pool has been validated, so if they
+	// change the spec, validate the pool again.
+	spec := res.Spec.DeepCopy()
+	if err := r.client.resources.Admit(ctx, AdmissionCreate, apiv3.KindIPPool, noNamespace, res.Name, res); err != nil {
 		return nil, err
 	}
+	if !reflect.DeepEqual(*spec, res.Spec) {
+		if err := r.validateCreate(ctx, res); err != nil {
+			return nil, err
+		}
+	}
+
+	// Enable IPIP or VXLAN globally if required.  Do this before the Create so if it fails the user
+	// can retry the same command.
+	// A dry run doesn't modify the datastore, so it leaves the global settings alone.
+	if !opts.DryRun {
+		if err := r.maybeEnableIPIP(ctx, res); err != nil {
+			return nil, err
+		}
+		if err := r.maybeEnableVXLAN(ctx, res); err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := r.client.resources.CreateAdmitted(ctx, opts, apiv3.KindIPPool, res)
+	if out != nil {
+		return out.(*apiv3.IPPool), err
+	}
+	return nil, err
+}
+
+// validateCreate validates an IPPool that is being created, and sets the default values of its
+// fields.
+func (r ipPools) validateCreate(ctx context.Context, res *apiv3.IPPool) error {
+	if err := r.validateAndSetDefaults(ctx, res, nil); err != nil {
+		return err
+	}
+
+	if err := validator.Validate(res); err != nil {
+		return err
+	}
 
 	// Check that there are no existing blocks in the pool range that have a different block size.
 	poolBlockSize := res.Spec.BlockSize
 	poolIP, poolCIDR, err := net.ParseCIDR(res.Spec.CIDR)
 	if err != nil {
-		return nil, cerrors.ErrorParsingDatastoreEntry{
+		return cerrors.ErrorParsingDatastoreEntry{
 			RawKey:   "CIDR",
 			RawValue: string(res.Spec.CIDR),
 			Err:      err,
@@ -83,7 +126,7 @@ func (r ipPools) Create(ctx context.Context, res *apiv3.IPPool, opts options.Set
 	blocks, err := r.client.backend.List(ctx, model.BlockListOptions{IPVersion: ipVersion}, "")
 	if _, ok := err.(cerrors.ErrorOperationNotSupported); !ok && err != nil {
 		// There was an error and it wasn't OperationNotSupported - return it.
-		return nil, err
+		return err
 	} else if err == nil {
 		// Skip the block check if the error is OperationUnsupported - listing blocks is not
 		// supported with host-local IPAM on KDD.
@@ -92,7 +135,7 @@ func (r ipPools) Create(ctx context.Context, res *apiv3.IPPool, opts options.Set
 			ones, _ := k.CIDR.Mask.Size()
 			// Check if this block has a different size to the pool, and that it overlaps with the pool.
 			if ones != poolBlockSize && k.CIDR.IsNetOverlap(*poolCIDR) {
-				return nil, cerrors.ErrorValidation{
+				return cerrors.ErrorValidation{
 					ErroredFields: []cerrors.ErroredField{{
 						Name:   "IPPool.Spec.BlockSize",
 						Reason: "IPPool blocksSize conflicts with existing allocations that use a different blockSize",
@@ -102,24 +145,7 @@ func (r ipPools) Create(ctx context.Context, res *apiv3.IPPool, opts options.Set
 			}
 		}
 	}
-
-	// Enable IPIP or VXLAN globally if required.  Do this before the Create so if it fails the user
-	// can retry the same command.
-	err = r.maybeEnableIPIP(ctx, res)
-	if err != nil {
-		return nil, err
-	}
-	err = r.maybeEnableVXLAN(ctx, res)
-	if err != nil {
-		return nil, err
-	}
-
-	out, err := r.client.resources.Create(ctx, opts, apiv3.KindIPPool, res)
-	if out != nil {
-		return out.(*apiv3.IPPool), err
-	}
-	return nil, err
-
+	return nil
 }
 
 // Update takes the representation of a IPPool and updates it. Returns the stored
@@ -139,26 +165,70 @@ func (r ipPools) Update(ctx context.Context, res *apiv3.IPPool, opts options.Set
 	}
 
 	// Validate the IPPool updating the resource.
-	if err := r.validateAndSetDefaults(ctx, res, old); err != nil {
+	if err := r.validateUpdate(ctx, res, old); err != nil {
 		return nil, err
 	}
 
-	if err := validator.Validate(res); err != nil {
+	// Run the admission hooks before enabling IPIP or VXLAN, so that an update that is rejected
+	// has no side effects.  The mutating hooks run after the pool has been validated, so if they
+	// change the spec, validate the pool again.
+	spec := res.Spec.DeepCopy()
+	if err := r.client.resources.Admit(ctx, AdmissionUpdate, apiv3.KindIPPool, noNamespace, res.Name, res); err != nil {
 		return nil, err
 	}
+	if !reflect.DeepEqual(*spec, res.Spec) {
+		if err := r.validateUpdate(ctx, res, old); err != nil {
+			return nil, err
+		}
+	}
 
 	// Enable IPIP globally if required.  Do this before the Update so if it fails the user
 	// can retry the same command.
-	err = r.maybeEnableIPIP(ctx, res)
-	if err != nil {
-		return nil, err
+	// A dry run doesn't modify the datastore, so it leaves the global settings alone.
+	if !opts.DryRun {
+		if err := r.maybeEnableIPIP(ctx, res); err != nil {
+			return nil, err
+		}
+		if err := r.maybeEnableVXLAN(ctx, res); err != nil {
+			return nil, err
+		}
 	}
-	err = r.maybeEnableVXLAN(ctx, res)
-	if err != nil {
+
+	out, err := r.client.resources.UpdateAdmitted(ctx, opts, apiv3.KindIPPool, res)
+	if out != nil {
+		return out.(*apiv3.IPPool), err
+	}
+	return nil, err
+}
+
+// validateUpdate validates an IPPool that is being updated, and sets the default values of its
+// fields.
+func (r ipPools) validateUpdate(ctx context.Context, res, old *apiv3.IPPool) error {
+	if err := r.validateAndSetDefaults(ctx, res, old); err != nil {
+		return err
+	}
+	return validator.Validate(res)
+}
+
+// UpdateStatus takes the representation of an IPPool and updates its status.  The rest of the
+// IPPool is not updated.  Returns the stored representation of the IPPool, and an error, if there
+// is any.
+func (r ipPools) UpdateStatus(ctx context.Context, res *apiv3.IPPool, opts options.SetOptions) (*apiv3.IPPool, error) {
+	if err := validator.Validate(res); err != nil {
 		return nil, err
 	}
 
-	out, err := r.client.resources.Update(ctx, opts, apiv3.KindIPPool, res)
+	out, err := r.client.resources.UpdateStatus(ctx, opts, apiv3.KindIPPool, res)
+	if out != nil {
+		return out.(*apiv3.IPPool), err
+	}
+	return nil, err
+}
+
+// Patch applies a JSON merge patch or JSON patch to the IPPool and updates it.  Returns the
+// stored representation of the IPPool, and an error, if there is any.
+func (r ipPools) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.IPPool, error) {
+	out, err := r.client.patchResource(ctx, apiv3.KindIPPool, noNamespace, name, pt, data, opts)
 	if out != nil {
 		return out.(*apiv3.IPPool), err
 	}
@@ -180,6 +250,12 @@ func (r ipPools) Delete(ctx context.Context, name string, opts options.DeleteOpt
 		return nil, err
 	}
 
+	// Admit the delete before disabling the pool and releasing its affinities, so that a delete
+	// that is rejected leaves the pool as it was.
+	if err := r.client.resources.Admit(ctx, AdmissionDelete, apiv3.KindIPPool, noNamespace, name, nil); err != nil {
+		return nil, err
+	}
+
 	logCxt := log.WithFields(log.Fields{
 		"CIDR": pool.Spec.CIDR,
 		"Name": name,
@@ -226,7 +302,7 @@ func (r ipPools) Delete(ctx context.Context, name string, opts options.DeleteOpt
 
 	// And finally, delete the pool.
 	logCxt.Info("Deleting pool")
-	out, err := r.client.resources.Delete(ctx, opts, apiv3.KindIPPool, noNamespace, name)
+	out, err := r.client.resources.DeleteAdmitted(ctx, opts, apiv3.KindIPPool, noNamespace, name)
 	if out != nil {
 		return out.(*apiv3.IPPool), err
 	}
@@ -361,38 +437,8 @@ func (r ipPools) validateAndSetDefaults(ctx context.Context, new, old *apiv3.IPP
 		})
 	}
 
-	if ipAddr.Version() == 4 {
-		if new.Spec.BlockSize > 32 || new.Spec.BlockSize < 20 {
-			errFields = append(errFields, cerrors.ErroredField{
-				Name:   "IPPool.Spec.BlockSize",
-				Reason: "IPv4 block size must be between 20 and 32",
-				Value:  new.Spec.BlockSize,
-			})
-
-		}
-	} else {
-		if new.Spec.BlockSize > 128 || new.Spec.BlockSize < 116 {
-			errFields = append(errFields, cerrors.ErroredField{
-				Name:   "IPPool.Spec.BlockSize",
-				Reason: "IPv6 block size must be between 116 and 128",
-				Value:  new.Spec.BlockSize,
-			})
-		}
-	}
-
-	// The Calico IPAM places restrictions on the minimum IP pool size.  If
-	// the ippool is enabled, check that the pool is at least the minimum size.
-	if !new.Spec.Disabled {
-		ones, _ := cidr.Mask.Size()
-		log.Debugf("Pool CIDR: %s, mask: %d, blockSize: %d", cidr.String(), ones, new.Spec.BlockSize)
-		if ones > new.Spec.BlockSize {
-			errFields = append(errFields, cerrors.ErroredField{
-				Name:   "IPPool.Spec.CIDR",
-				Reason: "IP pool size is too small for use with Calico IPAM. It must be equal to or greater than the block size.",
-				Value:  new.Spec.CIDR,
-			})
-		}
-	}
+	// The range of the blockSize, and the minimum size of the pool for the blockSize, are
+	// checked by the IPPool validator.
 
 	// If there was no previous pool then this must be a Create.  Check that the CIDR
 	// does not overlap with any other pool CIDRs.
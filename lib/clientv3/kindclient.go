@@ -0,0 +1,217 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientv3
+
+import (
+	"context"
+
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	"github.com/unai-ttxu/libcalico-go/lib/options"
+)
+
+// kindClient gets, creates and updates the resources of a single kind through the client for
+// the kind, so that operations on resources of any kind get the same validation, defaulting and
+// name conversion as the typed clients.
+type kindClient struct {
+	get    func(ctx context.Context, ns, name string) (resource, error)
+	create func(ctx context.Context, res resource, opts options.SetOptions) (resource, error)
+	update func(ctx context.Context, res resource, opts options.SetOptions) (resource, error)
+}
+
+// kindClient returns the kindClient for the kind, or nil if the kind is not a resource kind
+// managed by the client.
+func (c client) kindClient(kind string) *kindClient {
+	switch kind {
+	case apiv3.KindBGPConfiguration:
+		return &kindClient{
+			get: func(ctx context.Context, ns, name string) (resource, error) {
+				return checkResource(c.BGPConfigurations().Get(ctx, name, options.GetOptions{}))
+			},
+			create: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.BGPConfigurations().Create(ctx, res.(*apiv3.BGPConfiguration), opts))
+			},
+			update: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.BGPConfigurations().Update(ctx, res.(*apiv3.BGPConfiguration), opts))
+			},
+		}
+	case apiv3.KindBGPPeer:
+		return &kindClient{
+			get: func(ctx context.Context, ns, name string) (resource, error) {
+				return checkResource(c.BGPPeers().Get(ctx, name, options.GetOptions{}))
+			},
+			create: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.BGPPeers().Create(ctx, res.(*apiv3.BGPPeer), opts))
+			},
+			update: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.BGPPeers().Update(ctx, res.(*apiv3.BGPPeer), opts))
+			},
+		}
+	case apiv3.KindClusterInformation:
+		return &kindClient{
+			get: func(ctx context.Context, ns, name string) (resource, error) {
+				return checkResource(c.ClusterInformation().Get(ctx, name, options.GetOptions{}))
+			},
+			create: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.ClusterInformation().Create(ctx, res.(*apiv3.ClusterInformation), opts))
+			},
+			update: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.ClusterInformation().Update(ctx, res.(*apiv3.ClusterInformation), opts))
+			},
+		}
+	case apiv3.KindFelixConfiguration:
+		return &kindClient{
+			get: func(ctx context.Context, ns, name string) (resource, error) {
+				return checkResource(c.FelixConfigurations().Get(ctx, name, options.GetOptions{}))
+			},
+			create: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.FelixConfigurations().Create(ctx, res.(*apiv3.FelixConfiguration), opts))
+			},
+			update: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.FelixConfigurations().Update(ctx, res.(*apiv3.FelixConfiguration), opts))
+			},
+		}
+	case apiv3.KindGlobalNetworkPolicy:
+		return &kindClient{
+			get: func(ctx context.Context, ns, name string) (resource, error) {
+				return checkResource(c.GlobalNetworkPolicies().Get(ctx, name, options.GetOptions{}))
+			},
+			create: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.GlobalNetworkPolicies().Create(ctx, res.(*apiv3.GlobalNetworkPolicy), opts))
+			},
+			update: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.GlobalNetworkPolicies().Update(ctx, res.(*apiv3.GlobalNetworkPolicy), opts))
+			},
+		}
+	case apiv3.KindGlobalNetworkSet:
+		return &kindClient{
+			get: func(ctx context.Context, ns, name string) (resource, error) {
+				return checkResource(c.GlobalNetworkSets().Get(ctx, name, options.GetOptions{}))
+			},
+			create: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.GlobalNetworkSets().Create(ctx, res.(*apiv3.GlobalNetworkSet), opts))
+			},
+			update: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.GlobalNetworkSets().Update(ctx, res.(*apiv3.GlobalNetworkSet), opts))
+			},
+		}
+	case apiv3.KindHostEndpoint:
+		return &kindClient{
+			get: func(ctx context.Context, ns, name string) (resource, error) {
+				return checkResource(c.HostEndpoints().Get(ctx, name, options.GetOptions{}))
+			},
+			create: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.HostEndpoints().Create(ctx, res.(*apiv3.HostEndpoint), opts))
+			},
+			update: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.HostEndpoints().Update(ctx, res.(*apiv3.HostEndpoint), opts))
+			},
+		}
+	case apiv3.KindIPPool:
+		return &kindClient{
+			get: func(ctx context.Context, ns, name string) (resource, error) {
+				return checkResource(c.IPPools().Get(ctx, name, options.GetOptions{}))
+			},
+			create: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.IPPools().Create(ctx, res.(*apiv3.IPPool), opts))
+			},
+			update: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.IPPools().Update(ctx, res.(*apiv3.IPPool), opts))
+			},
+		}
+	case apiv3.KindIPReservation:
+		return &kindClient{
+			get: func(ctx context.Context, ns, name string) (resource, error) {
+				return checkResource(c.IPReservations().Get(ctx, name, options.GetOptions{}))
+			},
+			create: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.IPReservations().Create(ctx, res.(*apiv3.IPReservation), opts))
+			},
+			update: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.IPReservations().Update(ctx, res.(*apiv3.IPReservation), opts))
+			},
+		}
+	case apiv3.KindNetworkPolicy:
+		return &kindClient{
+			get: func(ctx context.Context, ns, name string) (resource, error) {
+				return checkResource(c.NetworkPolicies().Get(ctx, ns, name, options.GetOptions{}))
+			},
+			create: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.NetworkPolicies().Create(ctx, res.(*apiv3.NetworkPolicy), opts))
+			},
+			update: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.NetworkPolicies().Update(ctx, res.(*apiv3.NetworkPolicy), opts))
+			},
+		}
+	case apiv3.KindNetworkSet:
+		return &kindClient{
+			get: func(ctx context.Context, ns, name string) (resource, error) {
+				return checkResource(c.NetworkSets().Get(ctx, ns, name, options.GetOptions{}))
+			},
+			create: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.NetworkSets().Create(ctx, res.(*apiv3.NetworkSet), opts))
+			},
+			update: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.NetworkSets().Update(ctx, res.(*apiv3.NetworkSet), opts))
+			},
+		}
+	case apiv3.KindNode:
+		return &kindClient{
+			get: func(ctx context.Context, ns, name string) (resource, error) {
+				return checkResource(c.Nodes().Get(ctx, name, options.GetOptions{}))
+			},
+			create: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.Nodes().Create(ctx, res.(*apiv3.Node), opts))
+			},
+			update: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.Nodes().Update(ctx, res.(*apiv3.Node), opts))
+			},
+		}
+	case apiv3.KindProfile:
+		return &kindClient{
+			get: func(ctx context.Context, ns, name string) (resource, error) {
+				return checkResource(c.Profiles().Get(ctx, name, options.GetOptions{}))
+			},
+			create: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.Profiles().Create(ctx, res.(*apiv3.Profile), opts))
+			},
+			update: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.Profiles().Update(ctx, res.(*apiv3.Profile), opts))
+			},
+		}
+	case apiv3.KindWorkloadEndpoint:
+		return &kindClient{
+			get: func(ctx context.Context, ns, name string) (resource, error) {
+				return checkResource(c.WorkloadEndpoints().Get(ctx, ns, name, options.GetOptions{}))
+			},
+			create: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.WorkloadEndpoints().Create(ctx, res.(*apiv3.WorkloadEndpoint), opts))
+			},
+			update: func(ctx context.Context, res resource, opts options.SetOptions) (resource, error) {
+				return checkResource(c.WorkloadEndpoints().Update(ctx, res.(*apiv3.WorkloadEndpoint), opts))
+			},
+		}
+	}
+	return nil
+}
+
+// checkResource returns the resource returned by a typed client, or the error.  The typed
+// clients return a nil pointer with an error, which would otherwise convert to a non-nil
+// resource.
+func checkResource(res resource, err error) (resource, error) {
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
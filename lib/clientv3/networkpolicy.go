@@ -17,6 +17,8 @@ package clientv3
 import (
 	"context"
 
+	"k8s.io/apimachinery/pkg/types"
+
 	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
 	"github.com/unai-ttxu/libcalico-go/lib/options"
 	validator "github.com/unai-ttxu/libcalico-go/lib/validator/v3"
@@ -27,6 +29,7 @@ import (
 type NetworkPolicyInterface interface {
 	Create(ctx context.Context, res *apiv3.NetworkPolicy, opts options.SetOptions) (*apiv3.NetworkPolicy, error)
 	Update(ctx context.Context, res *apiv3.NetworkPolicy, opts options.SetOptions) (*apiv3.NetworkPolicy, error)
+	Patch(ctx context.Context, namespace, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.NetworkPolicy, error)
 	Delete(ctx context.Context, namespace, name string, opts options.DeleteOptions) (*apiv3.NetworkPolicy, error)
 	Get(ctx context.Context, namespace, name string, opts options.GetOptions) (*apiv3.NetworkPolicy, error)
 	List(ctx context.Context, opts options.ListOptions) (*apiv3.NetworkPolicyList, error)
@@ -96,6 +99,16 @@ func (r networkPolicies) Update(ctx context.Context, res *apiv3.NetworkPolicy, o
 	return nil, err
 }
 
+// Patch applies a JSON merge patch or JSON patch to the NetworkPolicy and updates it.  Returns the
+// stored representation of the NetworkPolicy, and an error, if there is any.
+func (r networkPolicies) Patch(ctx context.Context, namespace, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.NetworkPolicy, error) {
+	out, err := r.client.patchResource(ctx, apiv3.KindNetworkPolicy, namespace, name, pt, data, opts)
+	if out != nil {
+		return out.(*apiv3.NetworkPolicy), err
+	}
+	return nil, err
+}
+
 // Delete takes name of the NetworkPolicy and deletes it. Returns an error if one occurs.
 func (r networkPolicies) Delete(ctx context.Context, namespace, name string, opts options.DeleteOptions) (*apiv3.NetworkPolicy, error) {
 	out, err := r.client.resources.Delete(ctx, opts, apiv3.KindNetworkPolicy, namespace, convertPolicyNameForStorage(name))
@@ -17,6 +17,8 @@ package clientv3
 import (
 	"context"
 
+	"k8s.io/apimachinery/pkg/types"
+
 	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
 	"github.com/unai-ttxu/libcalico-go/lib/options"
 	validator "github.com/unai-ttxu/libcalico-go/lib/validator/v3"
@@ -27,6 +29,7 @@ import (
 type NetworkSetInterface interface {
 	Create(ctx context.Context, res *apiv3.NetworkSet, opts options.SetOptions) (*apiv3.NetworkSet, error)
 	Update(ctx context.Context, res *apiv3.NetworkSet, opts options.SetOptions) (*apiv3.NetworkSet, error)
+	Patch(ctx context.Context, namespace, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.NetworkSet, error)
 	Delete(ctx context.Context, namespace, name string, opts options.DeleteOptions) (*apiv3.NetworkSet, error)
 	Get(ctx context.Context, namespace, name string, opts options.GetOptions) (*apiv3.NetworkSet, error)
 	List(ctx context.Context, opts options.ListOptions) (*apiv3.NetworkSetList, error)
@@ -64,6 +67,16 @@ func (r networkSets) Update(ctx context.Context, res *apiv3.NetworkSet, opts opt
 	return nil, err
 }
 
+// Patch applies a JSON merge patch or JSON patch to the NetworkSet and updates it.  Returns the
+// stored representation of the NetworkSet, and an error, if there is any.
+func (r networkSets) Patch(ctx context.Context, namespace, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.NetworkSet, error) {
+	out, err := r.client.patchResource(ctx, apiv3.KindNetworkSet, namespace, name, pt, data, opts)
+	if out != nil {
+		return out.(*apiv3.NetworkSet), err
+	}
+	return nil, err
+}
+
 // Delete takes name of the NetworkSet and deletes it. Returns an error if one occurs.
 func (r networkSets) Delete(ctx context.Context, namespace, name string, opts options.DeleteOptions) (*apiv3.NetworkSet, error) {
 	out, err := r.client.resources.Delete(ctx, opts, apiv3.KindNetworkSet, namespace, name)
@@ -17,24 +17,22 @@ package clientv3
 import (
 	"context"
 
-	"fmt"
+	"k8s.io/apimachinery/pkg/types"
 
 	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
-	"github.com/unai-ttxu/libcalico-go/lib/errors"
-	"github.com/unai-ttxu/libcalico-go/lib/names"
-	"github.com/unai-ttxu/libcalico-go/lib/net"
-	cnet "github.com/unai-ttxu/libcalico-go/lib/net"
 	"github.com/unai-ttxu/libcalico-go/lib/options"
 	validator "github.com/unai-ttxu/libcalico-go/lib/validator/v3"
 	"github.com/unai-ttxu/libcalico-go/lib/watch"
-	log "github.com/sirupsen/logrus"
 )
 
 // NodeInterface has methods to work with Node resources.
 type NodeInterface interface {
 	Create(ctx context.Context, res *apiv3.Node, opts options.SetOptions) (*apiv3.Node, error)
 	Update(ctx context.Context, res *apiv3.Node, opts options.SetOptions) (*apiv3.Node, error)
+	UpdateStatus(ctx context.Context, res *apiv3.Node, opts options.SetOptions) (*apiv3.Node, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.Node, error)
 	Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.Node, error)
+	Decommission(ctx context.Context, name string, opts options.DecommissionOptions) (*NodeDecommissionResult, error)
 	Get(ctx context.Context, name string, opts options.GetOptions) (*apiv3.Node, error)
 	List(ctx context.Context, opts options.ListOptions) (*apiv3.NodeList, error)
 	Watch(ctx context.Context, opts options.ListOptions) (watch.Interface, error)
@@ -52,14 +50,22 @@ func (r nodes) Create(ctx context.Context, res *apiv3.Node, opts options.SetOpti
 		return nil, err
 	}
 
+	// Run the admission hooks before initialising the datastore, so that a create that is rejected
+	// has no side effects.
+	if err := r.client.resources.Admit(ctx, AdmissionCreate, apiv3.KindNode, noNamespace, res.Name, res); err != nil {
+		return nil, err
+	}
+
 	// For host-protection only clusters, we instruct the user to create a Node as the first
 	// operation.  Piggy-back the datastore initialisation on that to ensure the Ready flag gets
 	// set.  Since we're likely being called from calicoctl, we don't know the Calico version.
-	err := r.client.EnsureInitialized(ctx, "", "")
-	if err != nil {
-		return nil, err
+	// A dry run doesn't modify the datastore, so it leaves the datastore uninitialised.
+	if !opts.DryRun {
+		if err := r.client.EnsureInitialized(ctx, "", ""); err != nil {
+			return nil, err
+		}
 	}
-	out, err := r.client.resources.Create(ctx, opts, apiv3.KindNode, res)
+	out, err := r.client.resources.CreateAdmitted(ctx, opts, apiv3.KindNode, res)
 	if out != nil {
 		return out.(*apiv3.Node), err
 	}
@@ -80,112 +86,42 @@ func (r nodes) Update(ctx context.Context, res *apiv3.Node, opts options.SetOpti
 	return nil, err
 }
 
-// Delete takes name of the Node and deletes it. Returns an error if one occurs.
-func (r nodes) Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.Node, error) {
-	pname, err := names.WorkloadEndpointIdentifiers{Node: name}.CalculateWorkloadEndpointName(true)
-	if err != nil {
-		return nil, err
-	}
-
-	// Get all weps belonging to the node
-	weps, err := r.client.WorkloadEndpoints().List(ctx, options.ListOptions{
-		Prefix: true,
-		Name:   pname,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	// Collate all IPs across all endpoints, and then release those IPs.
-	ips := []net.IP{}
-	for _, wep := range weps.Items {
-		// The prefix match is unfortunately not a perfect match on the Node (since it is theoretically possible for
-		// another node to match the prefix (e.g. a node name of the format <thisnode>-foobar would also match a prefix
-		// search of the node <thisnode>). Therefore, we will also need to check that the Spec.Node field matches the Node.
-		if wep.Spec.Node != name {
-			continue
-		}
-		for _, ip := range wep.Spec.IPNetworks {
-			ipAddr, _, err := cnet.ParseCIDROrIP(ip)
-			if err == nil {
-				ips = append(ips, *ipAddr)
-			} else {
-				// Validation for wep insists upon CIDR, so we should always succeed
-				log.WithError(err).Warnf("Failed to parse CIDR: %s", ip)
-			}
-		}
-	}
-	_, err = r.client.IPAM().ReleaseIPs(context.Background(), ips)
-	switch err.(type) {
-	case nil, errors.ErrorResourceDoesNotExist, errors.ErrorOperationNotSupported:
-	default:
-		return nil, err
-	}
-
-	// Delete the weps.
-	for _, wep := range weps.Items {
-		if wep.Spec.Node != name {
-			continue
-		}
-
-		_, err = r.client.WorkloadEndpoints().Delete(ctx, wep.Namespace, wep.Name, options.DeleteOptions{})
-		switch err.(type) {
-		case nil, errors.ErrorResourceDoesNotExist, errors.ErrorOperationNotSupported:
-		default:
-			return nil, err
-		}
-	}
-
-	// Remove the node from the IPAM data if it exists.
-	err = r.client.IPAM().RemoveIPAMHost(ctx, name)
-	switch err.(type) {
-	case nil, errors.ErrorResourceDoesNotExist, errors.ErrorOperationNotSupported:
-	default:
-		return nil, err
-	}
-
-	// Remove BGPPeers.
-	bgpPeers, err := r.client.BGPPeers().List(ctx, options.ListOptions{})
-	if err != nil {
-		return nil, err
-	}
-	for _, peer := range bgpPeers.Items {
-		if peer.Spec.Node != name {
-			continue
-		}
-		_, err = r.client.BGPPeers().Delete(ctx, peer.Name, options.DeleteOptions{})
-		switch err.(type) {
-		case nil, errors.ErrorResourceDoesNotExist, errors.ErrorOperationNotSupported:
-		default:
-			return nil, err
-		}
-	}
-
-	// Delete felix configuration
-	nodeConfName := fmt.Sprintf("node.%s", name)
-	_, err = r.client.FelixConfigurations().Delete(ctx, nodeConfName, options.DeleteOptions{})
-	switch err.(type) {
-	case nil, errors.ErrorResourceDoesNotExist, errors.ErrorOperationNotSupported:
-	default:
+// UpdateStatus takes the representation of a Node and updates its status.  The rest of the
+// Node is not updated.  Returns the stored representation of the Node, and an error, if there
+// is any.
+func (r nodes) UpdateStatus(ctx context.Context, res *apiv3.Node, opts options.SetOptions) (*apiv3.Node, error) {
+	if err := validator.Validate(res); err != nil {
 		return nil, err
 	}
 
-	// Delete bgp configuration
-	_, err = r.client.BGPConfigurations().Delete(ctx, nodeConfName, options.DeleteOptions{})
-	switch err.(type) {
-	case nil, errors.ErrorResourceDoesNotExist, errors.ErrorOperationNotSupported:
-	default:
-		return nil, err
+	out, err := r.client.resources.UpdateStatus(ctx, opts, apiv3.KindNode, res)
+	if out != nil {
+		return out.(*apiv3.Node), err
 	}
+	return nil, err
+}
 
-	// Delete the node.
-	out, err := r.client.resources.Delete(ctx, opts, apiv3.KindNode, noNamespace, name)
+// Patch applies a JSON merge patch or JSON patch to the Node and updates it.  Returns the
+// stored representation of the Node, and an error, if there is any.
+func (r nodes) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.Node, error) {
+	out, err := r.client.patchResource(ctx, apiv3.KindNode, noNamespace, name, pt, data, opts)
 	if out != nil {
 		return out.(*apiv3.Node), err
 	}
 	return nil, err
 }
 
+// Delete takes name of the Node and deletes it, along with all of the data associated with the
+// node (see Decommission).  If opts.ResourceVersion is set, the delete fails before any of the
+// data is cleaned up unless the Node is at that resource version.  Returns an error if one occurs.
+func (r nodes) Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.Node, error) {
+	d := &nodeDecommission{nodes: r, name: name, deleteOpts: opts}
+	if _, err := d.run(ctx); err != nil {
+		return nil, err
+	}
+	return d.deleted, d.deleteErr
+}
+
 // Get takes name of the Node, and returns the corresponding Node object,
 // and an error if there is any.
 func (r nodes) Get(ctx context.Context, name string, opts options.GetOptions) (*apiv3.Node, error) {
@@ -15,6 +15,7 @@
 package clientv3_test
 
 import (
+	"errors"
 	"net"
 	"time"
 
@@ -32,6 +33,7 @@ import (
 	"github.com/unai-ttxu/libcalico-go/lib/backend"
 	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
 	"github.com/unai-ttxu/libcalico-go/lib/clientv3"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
 	"github.com/unai-ttxu/libcalico-go/lib/ipam"
 	cnet "github.com/unai-ttxu/libcalico-go/lib/net"
 	"github.com/unai-ttxu/libcalico-go/lib/options"
@@ -238,6 +240,18 @@ var _ = testutils.E2eDatastoreDescribe("Node tests (etcdv3)", testutils.Datastor
 			})
 			Expect(err).NotTo(HaveOccurred())
 
+			tunnelHandle := "ipip-tunnel-addr-" + name1
+			err = c.IPAM().AssignIP(ctx, ipam.AssignIPArgs{
+				IP:       cnet.IP{net.IP{192, 168, 0, 2}},
+				Hostname: name1,
+				HandleID: &tunnelHandle,
+				Attrs: map[string]string{
+					ipam.AttributeNode: name1,
+					ipam.AttributeType: ipam.AttributeTypeIPIP,
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
 			wep := apiv3.WorkloadEndpoint{
 				ObjectMeta: metav1.ObjectMeta{
 					Name:      "node--1-k8s-mypod-mywep",
@@ -293,6 +307,35 @@ var _ = testutils.E2eDatastoreDescribe("Node tests (etcdv3)", testutils.Datastor
 			_, err = c.BGPConfigurations().Create(ctx, &bgpConf, options.SetOptions{})
 			Expect(err).ShouldNot(HaveOccurred())
 
+			ownedHep := apiv3.HostEndpoint{
+				Spec: apiv3.HostEndpointSpec{
+					Node:          name1,
+					InterfaceName: "*",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "owned-hep",
+					OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: apiv3.GroupVersionCurrent,
+						Kind:       apiv3.KindNode,
+						Name:       name1,
+					}},
+				},
+			}
+			_, err = c.HostEndpoints().Create(ctx, &ownedHep, options.SetOptions{})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			unownedHep := apiv3.HostEndpoint{
+				Spec: apiv3.HostEndpointSpec{
+					Node:          name1,
+					InterfaceName: "eth0",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "unowned-hep",
+				},
+			}
+			_, err = c.HostEndpoints().Create(ctx, &unownedHep, options.SetOptions{})
+			Expect(err).ShouldNot(HaveOccurred())
+
 			// Delete the node.
 			_, err = c.Nodes().Delete(ctx, name1, options.DeleteOptions{})
 			Expect(err).NotTo(HaveOccurred())
@@ -310,6 +353,10 @@ var _ = testutils.E2eDatastoreDescribe("Node tests (etcdv3)", testutils.Datastor
 			ips, err := c.IPAM().IPsByHandle(ctx, handle)
 			Expect(ips).Should(BeNil())
 
+			// Check that the node's tunnel IP was released
+			ips, err = c.IPAM().IPsByHandle(ctx, tunnelHandle)
+			Expect(ips).Should(BeNil())
+
 			// Check that the host affinity pool was released.
 			err = c.IPAM().ReleaseAffinity(ctx, affBlock, name1, false)
 			Expect(err).NotTo(HaveOccurred())
@@ -335,8 +382,207 @@ var _ = testutils.E2eDatastoreDescribe("Node tests (etcdv3)", testutils.Datastor
 			// Check that the bgp config was deleted
 			bconfig, err := c.BGPConfigurations().Get(ctx, nodeConfigName, options.GetOptions{})
 			Expect(bconfig).Should(BeNil())
+
+			// Check that only the host endpoint owned by the node was deleted
+			hep, err := c.HostEndpoints().Get(ctx, "owned-hep", options.GetOptions{})
+			Expect(hep).Should(BeNil())
+			hep, err = c.HostEndpoints().Get(ctx, "unowned-hep", options.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hep).NotTo(BeNil())
+		})
+
+		It("should report what would be cleaned up on a dry run decommission", func() {
+			c, err := clientv3.New(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			be, err := backend.NewClient(config)
+			Expect(err).NotTo(HaveOccurred())
+			be.Clean()
+
+			_, err = c.Nodes().Create(ctx, &apiv3.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: name1},
+				Spec:       spec1,
+			}, options.SetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			bgppeer := apiv3.BGPPeer{
+				Spec: apiv3.BGPPeerSpec{
+					Node: name1,
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "bgppeer1",
+				},
+			}
+			_, err = c.BGPPeers().Create(ctx, &bgppeer, options.SetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			result, err := c.Nodes().Decommission(ctx, name1, options.DecommissionOptions{DryRun: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.DryRun).To(BeTrue())
+			Expect(result.Steps).To(HaveLen(9))
+			for _, step := range result.Steps {
+				Expect(step.Error).NotTo(HaveOccurred())
+				switch step.Step {
+				case clientv3.NodeDecommissionDeleteBGPPeers:
+					Expect(step.Resources).To(Equal([]string{"bgppeer1"}))
+				case clientv3.NodeDecommissionDeleteNode:
+					Expect(step.Resources).To(Equal([]string{name1}))
+				default:
+					Expect(step.Resources).To(BeEmpty())
+				}
+			}
+
+			// Check that nothing was deleted or recorded on the node.
+			node, err := c.Nodes().Get(ctx, name1, options.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(node.Status.DecommissionCompletedSteps).To(BeEmpty())
+			_, err = c.BGPPeers().Get(ctx, "bgppeer1", options.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should not initialize the datastore for a dry run create", func() {
+			c, err := clientv3.New(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			be, err := backend.NewClient(config)
+			Expect(err).NotTo(HaveOccurred())
+			be.Clean()
+
+			_, err = c.Nodes().Create(ctx, &apiv3.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: name1},
+				Spec:       spec1,
+			}, options.SetOptions{DryRun: true})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = c.ClusterInformation().Get(ctx, "default", options.GetOptions{})
+			Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorResourceDoesNotExist{}))
+			_, err = c.Nodes().Get(ctx, name1, options.GetOptions{})
+			Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorResourceDoesNotExist{}))
+		})
+
+		It("should not clean up any node data when deleting with an old resource version", func() {
+			c, err := clientv3.New(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			be, err := backend.NewClient(config)
+			Expect(err).NotTo(HaveOccurred())
+			be.Clean()
+
+			node, err := c.Nodes().Create(ctx, &apiv3.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: name1},
+				Spec:       spec1,
+			}, options.SetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			oldRV := node.ResourceVersion
+			node.Spec = spec2
+			_, err = c.Nodes().Update(ctx, node, options.SetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = c.BGPPeers().Create(ctx, &apiv3.BGPPeer{
+				ObjectMeta: metav1.ObjectMeta{Name: "bgppeer1"},
+				Spec:       apiv3.BGPPeerSpec{Node: name1},
+			}, options.SetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = c.Nodes().Delete(ctx, name1, options.DeleteOptions{ResourceVersion: oldRV})
+			Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorResourceUpdateConflict{}))
+
+			// Check that neither the node nor its BGP peer were deleted.
+			_, err = c.Nodes().Get(ctx, name1, options.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = c.BGPPeers().Get(ctx, "bgppeer1", options.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should resume a decommission from the recorded steps", func() {
+			c, err := clientv3.New(config)
+			Expect(err).NotTo(HaveOccurred())
+
+			be, err := backend.NewClient(config)
+			Expect(err).NotTo(HaveOccurred())
+			be.Clean()
+
+			// Create a node that has already been partially decommissioned.
+			node, err := c.Nodes().Create(ctx, &apiv3.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: name1},
+				Spec:       spec1,
+			}, options.SetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			node.Status.DecommissionCompletedSteps = []string{"ReleaseWorkloadIPs", "DeleteWorkloadEndpoints"}
+			_, err = c.Nodes().UpdateStatus(ctx, node, options.SetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			result, err := c.Nodes().Decommission(ctx, name1, options.DecommissionOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Steps).To(HaveLen(9))
+			Expect(result.Steps[0].AlreadyCompleted).To(BeTrue())
+			Expect(result.Steps[1].AlreadyCompleted).To(BeTrue())
+			for _, step := range result.Steps[2:] {
+				Expect(step.AlreadyCompleted).To(BeFalse())
+				Expect(step.Error).NotTo(HaveOccurred())
+			}
+
+			// Check that the node has been deleted.
+			node, err = c.Nodes().Get(ctx, name1, options.GetOptions{})
+			Expect(err).To(HaveOccurred())
+			Expect(node).To(BeNil())
 		})
 
+		It("should leave the node and record the completed steps when a step fails", func() {
+			protectBGPPeers := func(ctx context.Context, req clientv3.AdmissionRequest) error {
+				if req.Kind == apiv3.KindBGPPeer && req.Operation == clientv3.AdmissionDelete {
+					return errors.New("BGPPeers are protected")
+				}
+				return nil
+			}
+			c, err := clientv3.New(config, clientv3.WithValidatingHooks(protectBGPPeers))
+			Expect(err).NotTo(HaveOccurred())
+
+			be, err := backend.NewClient(config)
+			Expect(err).NotTo(HaveOccurred())
+			be.Clean()
+
+			_, err = c.Nodes().Create(ctx, &apiv3.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: name1},
+				Spec:       spec1,
+			}, options.SetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = c.BGPPeers().Create(ctx, &apiv3.BGPPeer{
+				ObjectMeta: metav1.ObjectMeta{Name: "bgppeer1"},
+				Spec:       apiv3.BGPPeerSpec{Node: name1},
+			}, options.SetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = c.Nodes().Delete(ctx, name1, options.DeleteOptions{})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("BGPPeers are protected"))
+
+			// Check that the node is still present, with the finalizer and the completed steps.
+			node, err := c.Nodes().Get(ctx, name1, options.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(node.Finalizers).To(ContainElement(apiv3.NodeDecommissionFinalizer))
+			Expect(node.Status.DecommissionCompletedSteps).To(Equal([]string{
+				"ReleaseWorkloadIPs",
+				"DeleteWorkloadEndpoints",
+				"ReleaseNodeIPs",
+				"DeleteHostEndpoints",
+				"ReleaseBlockAffinities",
+			}))
+
+			// Resume the decommission without the hook.
+			c, err = clientv3.New(config)
+			Expect(err).NotTo(HaveOccurred())
+			result, err := c.Nodes().Decommission(ctx, name1, options.DecommissionOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Steps).To(HaveLen(9))
+			for _, step := range result.Steps[:5] {
+				Expect(step.AlreadyCompleted).To(BeTrue())
+			}
+			Expect(result.Steps[5].Resources).To(Equal([]string{"bgppeer1"}))
+
+			_, err = c.Nodes().Get(ctx, name1, options.GetOptions{})
+			Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorResourceDoesNotExist{}))
+		})
 	})
 
 	DescribeTable("Node e2e CRUD tests",
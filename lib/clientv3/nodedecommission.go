@@ -0,0 +1,534 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientv3
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	"github.com/unai-ttxu/libcalico-go/lib/errors"
+	"github.com/unai-ttxu/libcalico-go/lib/names"
+	cnet "github.com/unai-ttxu/libcalico-go/lib/net"
+	"github.com/unai-ttxu/libcalico-go/lib/options"
+)
+
+// NodeDecommissionStep identifies a single step of a node decommission.
+type NodeDecommissionStep string
+
+const (
+	// Release the IP addresses of the node's workload endpoints, along with their handles.
+	NodeDecommissionReleaseWorkloadIPs NodeDecommissionStep = "ReleaseWorkloadIPs"
+	// Delete the node's workload endpoints.
+	NodeDecommissionDeleteWorkloadEndpoints NodeDecommissionStep = "DeleteWorkloadEndpoints"
+	// Release the IP addresses assigned to the node itself, such as its tunnel addresses, that are
+	// not used by a workload endpoint.
+	NodeDecommissionReleaseNodeIPs NodeDecommissionStep = "ReleaseNodeIPs"
+	// Delete the host endpoints owned by the node.
+	NodeDecommissionDeleteHostEndpoints NodeDecommissionStep = "DeleteHostEndpoints"
+	// Release the node's block affinities and remove the node's IPAM host data.
+	NodeDecommissionReleaseBlockAffinities NodeDecommissionStep = "ReleaseBlockAffinities"
+	// Delete the BGP peers that apply to the node.
+	NodeDecommissionDeleteBGPPeers NodeDecommissionStep = "DeleteBGPPeers"
+	// Delete the per-node FelixConfiguration.
+	NodeDecommissionDeleteFelixConfiguration NodeDecommissionStep = "DeleteFelixConfiguration"
+	// Delete the per-node BGPConfiguration.
+	NodeDecommissionDeleteBGPConfiguration NodeDecommissionStep = "DeleteBGPConfiguration"
+	// Delete the node itself.
+	NodeDecommissionDeleteNode NodeDecommissionStep = "DeleteNode"
+)
+
+// NodeDecommissionStepResult is the result of a single decommission step.
+type NodeDecommissionStepResult struct {
+	Step NodeDecommissionStep
+
+	// Set if the step was completed by a previous decommission of the node, and so was not run.
+	AlreadyCompleted bool
+
+	// The resources (or IP addresses) that the step cleaned up, or for a dry run, the resources
+	// that it would clean up.
+	Resources []string
+
+	// The error returned by the step.  Decommission stops at the first step that fails.
+	Error error
+}
+
+// NodeDecommissionResult is the result of a node decommission.
+type NodeDecommissionResult struct {
+	Node   string
+	DryRun bool
+	Steps  []NodeDecommissionStepResult
+}
+
+// nodeDecommission tracks the state of a single decommission of a node.
+type nodeDecommission struct {
+	nodes      nodes
+	name       string
+	dryRun     bool
+	deleteOpts options.DeleteOptions
+
+	// The current node resource, or nil if the node does not exist.
+	node      *apiv3.Node
+	completed map[NodeDecommissionStep]bool
+
+	// Whether the delete of the node has been admitted by the admission hooks.
+	admitted bool
+
+	// The deleted node and the error from deleting it, set by the final step.
+	deleted   *apiv3.Node
+	deleteErr error
+}
+
+// Decommission removes all of the data associated with a node, and then deletes the node.  The
+// NodeDecommissionFinalizer is added to the node before any data is cleaned up, and is only removed
+// with the node, so a decommission that is interrupted leaves the node in place.  If a step fails,
+// the steps that have completed are recorded in the node status, and calling Decommission again
+// resumes from the failed step.  Each step is idempotent, so a decommission that is interrupted
+// before it can record its progress is resumed from the start.  If opts.DryRun is set, the
+// datastore is not modified and the result lists the resources that each step would clean up.
+func (r nodes) Decommission(ctx context.Context, name string, opts options.DecommissionOptions) (*NodeDecommissionResult, error) {
+	d := &nodeDecommission{nodes: r, name: name, dryRun: opts.DryRun}
+	return d.run(ctx)
+}
+
+func (d *nodeDecommission) run(ctx context.Context) (*NodeDecommissionResult, error) {
+	logCxt := log.WithFields(log.Fields{"node": d.name, "dryRun": d.dryRun})
+	result := &NodeDecommissionResult{Node: d.name, DryRun: d.dryRun}
+
+	if err := d.loadNode(ctx); err != nil {
+		return result, err
+	}
+	if err := d.admit(ctx); err != nil {
+		return result, err
+	}
+	if err := d.addFinalizer(ctx); err != nil {
+		return result, err
+	}
+
+	steps := []struct {
+		step NodeDecommissionStep
+		run  func(context.Context) ([]string, error)
+	}{
+		{NodeDecommissionReleaseWorkloadIPs, d.releaseWorkloadIPs},
+		{NodeDecommissionDeleteWorkloadEndpoints, d.deleteWorkloadEndpoints},
+		{NodeDecommissionReleaseNodeIPs, d.releaseNodeIPs},
+		{NodeDecommissionDeleteHostEndpoints, d.deleteHostEndpoints},
+		{NodeDecommissionReleaseBlockAffinities, d.releaseBlockAffinities},
+		{NodeDecommissionDeleteBGPPeers, d.deleteBGPPeers},
+		{NodeDecommissionDeleteFelixConfiguration, d.deleteFelixConfiguration},
+		{NodeDecommissionDeleteBGPConfiguration, d.deleteBGPConfiguration},
+		{NodeDecommissionDeleteNode, d.deleteNode},
+	}
+	var completed []string
+	for _, s := range steps {
+		if d.completed[s.step] {
+			completed = append(completed, string(s.step))
+			logCxt.WithField("step", s.step).Info("Decommission step already completed")
+			result.Steps = append(result.Steps, NodeDecommissionStepResult{Step: s.step, AlreadyCompleted: true})
+			continue
+		}
+
+		logCxt.WithField("step", s.step).Info("Running decommission step")
+		resources, err := s.run(ctx)
+		result.Steps = append(result.Steps, NodeDecommissionStepResult{Step: s.step, Resources: resources, Error: err})
+		if err != nil {
+			logCxt.WithError(err).WithField("step", s.step).Warning("Decommission step failed")
+			d.recordCompleted(ctx, completed)
+			return result, err
+		}
+		completed = append(completed, string(s.step))
+	}
+	return result, nil
+}
+
+// loadNode gets the node and the record of completed steps.  A node that does not exist has no
+// completed steps.  If the delete options specify a resource version, this fails (before anything is
+// cleaned up) unless the node exists at that resource version.
+func (d *nodeDecommission) loadNode(ctx context.Context) error {
+	d.completed = map[NodeDecommissionStep]bool{}
+	node, err := d.nodes.Get(ctx, d.name, options.GetOptions{})
+	if _, ok := err.(errors.ErrorResourceDoesNotExist); ok && d.deleteOpts.ResourceVersion == "" {
+		log.WithField("node", d.name).Info("Node does not exist, cleaning up remaining node data")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if rv := d.deleteOpts.ResourceVersion; rv != "" && rv != node.ResourceVersion {
+		return errors.ErrorResourceUpdateConflict{
+			Err:        fmt.Errorf("node is at resource version %s", node.ResourceVersion),
+			Identifier: model.ResourceKey{Kind: apiv3.KindNode, Name: d.name},
+		}
+	}
+	d.node = node
+
+	for _, s := range node.Status.DecommissionCompletedSteps {
+		d.completed[NodeDecommissionStep(s)] = true
+	}
+	return nil
+}
+
+// admit runs the admission hooks for the delete of the node, before any of the node's data is
+// cleaned up, so that a decommission that is rejected has no side effects.  A node that does not
+// exist has nothing to admit.
+func (d *nodeDecommission) admit(ctx context.Context) error {
+	if d.node == nil {
+		return nil
+	}
+	if err := d.nodes.client.resources.Admit(ctx, AdmissionDelete, apiv3.KindNode, noNamespace, d.name, nil); err != nil {
+		return err
+	}
+	d.admitted = true
+	return nil
+}
+
+// addFinalizer adds the NodeDecommissionFinalizer to the node, if it is not already set.  Nothing
+// is written for a dry run, or if the node does not exist.  When deleting a specific resource
+// version of the node, the update is made at that resource version, and the node is then deleted at
+// the resource version of the update.
+func (d *nodeDecommission) addFinalizer(ctx context.Context) error {
+	if d.dryRun || d.node == nil || hasFinalizer(d.node.Finalizers, apiv3.NodeDecommissionFinalizer) {
+		return nil
+	}
+	d.node.Finalizers = append(d.node.Finalizers, apiv3.NodeDecommissionFinalizer)
+	node, err := d.nodes.Update(ctx, d.node, options.SetOptions{})
+	if err != nil {
+		return err
+	}
+	d.node = node
+	if d.deleteOpts.ResourceVersion != "" {
+		d.deleteOpts.ResourceVersion = node.ResourceVersion
+	}
+	return nil
+}
+
+// removeFinalizer removes the NodeDecommissionFinalizer from the node.  This is only needed when
+// the datastore does not delete the node itself.
+func (d *nodeDecommission) removeFinalizer(ctx context.Context) error {
+	if d.node == nil || !hasFinalizer(d.node.Finalizers, apiv3.NodeDecommissionFinalizer) {
+		return nil
+	}
+	var finalizers []string
+	for _, f := range d.node.Finalizers {
+		if f != apiv3.NodeDecommissionFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	d.node.Finalizers = finalizers
+	node, err := d.nodes.Update(ctx, d.node, options.SetOptions{})
+	if err != nil {
+		return err
+	}
+	d.node = node
+	return nil
+}
+
+// recordCompleted records the completed steps in the node status, so that the decommission can be
+// resumed.  This is only called when a step fails, so that a successful decommission does not write
+// the status at all.  Nothing is recorded for a dry run or if the node does not exist.  Failing to
+// record the steps is not an error, since the steps are idempotent and will be run again.
+func (d *nodeDecommission) recordCompleted(ctx context.Context, completed []string) {
+	if d.dryRun || d.node == nil || len(completed) == len(d.node.Status.DecommissionCompletedSteps) {
+		return
+	}
+	d.node.Status.DecommissionCompletedSteps = completed
+
+	_, err := d.nodes.UpdateStatus(ctx, d.node, options.SetOptions{})
+	if _, ok := err.(errors.ErrorOperationNotSupported); ok {
+		log.WithField("node", d.name).Debug("Datastore does not support node status, not recording decommission progress")
+	} else if err != nil {
+		log.WithError(err).WithField("node", d.name).Warning("Failed to record decommission progress")
+	}
+}
+
+// workloadEndpoints returns the workload endpoints on the node.
+func (d *nodeDecommission) workloadEndpoints(ctx context.Context) ([]apiv3.WorkloadEndpoint, error) {
+	pname, err := names.WorkloadEndpointIdentifiers{Node: d.name}.CalculateWorkloadEndpointName(true)
+	if err != nil {
+		return nil, err
+	}
+	weps, err := d.nodes.client.WorkloadEndpoints().List(ctx, options.ListOptions{
+		Prefix: true,
+		Name:   pname,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The prefix match is unfortunately not a perfect match on the Node (since it is theoretically possible for
+	// another node to match the prefix (e.g. a node name of the format <thisnode>-foobar would also match a prefix
+	// search of the node <thisnode>). Therefore, we will also need to check that the Spec.Node field matches the Node.
+	var nodeWeps []apiv3.WorkloadEndpoint
+	for _, wep := range weps.Items {
+		if wep.Spec.Node == d.name {
+			nodeWeps = append(nodeWeps, wep)
+		}
+	}
+	return nodeWeps, nil
+}
+
+func (d *nodeDecommission) releaseWorkloadIPs(ctx context.Context) ([]string, error) {
+	weps, err := d.workloadEndpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collate all IPs across all endpoints, and then release those IPs.  Releasing the IPs also
+	// releases the IPs from their handles.
+	ips := []cnet.IP{}
+	var released []string
+	for _, wep := range weps {
+		for _, ip := range wep.Spec.IPNetworks {
+			ipAddr, _, err := cnet.ParseCIDROrIP(ip)
+			if err == nil {
+				ips = append(ips, *ipAddr)
+				released = append(released, ipAddr.String())
+			} else {
+				// Validation for wep insists upon CIDR, so we should always succeed
+				log.WithError(err).Warnf("Failed to parse CIDR: %s", ip)
+			}
+		}
+	}
+	if d.dryRun || len(ips) == 0 {
+		return released, nil
+	}
+	_, err = d.nodes.client.IPAM().ReleaseIPs(ctx, ips)
+	return released, ignoreMissing(err)
+}
+
+func (d *nodeDecommission) deleteWorkloadEndpoints(ctx context.Context) ([]string, error) {
+	weps, err := d.workloadEndpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []string
+	for _, wep := range weps {
+		deleted = append(deleted, wep.Namespace+"/"+wep.Name)
+		if d.dryRun {
+			continue
+		}
+		_, err = d.nodes.client.WorkloadEndpoints().Delete(ctx, wep.Namespace, wep.Name, options.DeleteOptions{})
+		if err = ignoreMissing(err); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// releaseNodeIPs releases the addresses that are assigned to the node, rather than to one of its
+// workload endpoints, such as the node's IPIP and VXLAN tunnel addresses.  Releasing the addresses
+// also releases them from their handles.
+func (d *nodeDecommission) releaseNodeIPs(ctx context.Context) ([]string, error) {
+	weps, err := d.workloadEndpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wepIPs := map[string]bool{}
+	for _, wep := range weps {
+		for _, ip := range wep.Spec.IPNetworks {
+			if ipAddr, _, err := cnet.ParseCIDROrIP(ip); err == nil {
+				wepIPs[ipAddr.String()] = true
+			}
+		}
+	}
+
+	blocks, err := d.nodes.client.backend.List(ctx, model.BlockListOptions{}, "")
+	if err = ignoreMissing(err); err != nil || blocks == nil {
+		return nil, err
+	}
+	ips := []cnet.IP{}
+	var released []string
+	for _, kvp := range blocks.KVPairs {
+		b := kvp.Value.(*model.AllocationBlock)
+		for ordinal, attrIdx := range b.Allocations {
+			if attrIdx == nil || *attrIdx >= len(b.Attributes) {
+				continue
+			}
+			if b.Attributes[*attrIdx].AttrSecondary[model.IPAMBlockAttributeNode] != d.name {
+				continue
+			}
+			ip := b.OrdinalToIP(ordinal)
+			if wepIPs[ip.String()] {
+				continue
+			}
+			ips = append(ips, ip)
+			released = append(released, ip.String())
+		}
+	}
+	if d.dryRun || len(ips) == 0 {
+		return released, nil
+	}
+	_, err = d.nodes.client.IPAM().ReleaseIPs(ctx, ips)
+	return released, ignoreMissing(err)
+}
+
+func (d *nodeDecommission) deleteHostEndpoints(ctx context.Context) ([]string, error) {
+	heps, err := d.nodes.client.HostEndpoints().List(ctx, options.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var deleted []string
+	for _, hep := range heps.Items {
+		if !isOwnedByNode(hep.OwnerReferences, d.name) {
+			continue
+		}
+		deleted = append(deleted, hep.Name)
+		if d.dryRun {
+			continue
+		}
+		_, err = d.nodes.client.HostEndpoints().Delete(ctx, hep.Name, options.DeleteOptions{})
+		if err = ignoreMissing(err); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+func (d *nodeDecommission) releaseBlockAffinities(ctx context.Context) ([]string, error) {
+	var affinities []string
+	kvps, err := d.nodes.client.backend.List(ctx, model.BlockAffinityListOptions{Host: d.name}, "")
+	if err = ignoreMissing(err); err != nil {
+		return nil, err
+	} else if kvps != nil {
+		for _, kvp := range kvps.KVPairs {
+			affinities = append(affinities, kvp.Key.(model.BlockAffinityKey).CIDR.String())
+		}
+	}
+	if d.dryRun {
+		return affinities, nil
+	}
+
+	// Remove the node from the IPAM data if it exists.
+	err = d.nodes.client.IPAM().RemoveIPAMHost(ctx, d.name)
+	return affinities, ignoreMissing(err)
+}
+
+func (d *nodeDecommission) deleteBGPPeers(ctx context.Context) ([]string, error) {
+	bgpPeers, err := d.nodes.client.BGPPeers().List(ctx, options.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var deleted []string
+	for _, peer := range bgpPeers.Items {
+		if peer.Spec.Node != d.name {
+			continue
+		}
+		deleted = append(deleted, peer.Name)
+		if d.dryRun {
+			continue
+		}
+		_, err = d.nodes.client.BGPPeers().Delete(ctx, peer.Name, options.DeleteOptions{})
+		if err = ignoreMissing(err); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+func (d *nodeDecommission) deleteFelixConfiguration(ctx context.Context) ([]string, error) {
+	name := nodeConfigName(d.name)
+	if d.dryRun {
+		_, err := d.nodes.client.FelixConfigurations().Get(ctx, name, options.GetOptions{})
+		return existingResource(name, err)
+	}
+	_, err := d.nodes.client.FelixConfigurations().Delete(ctx, name, options.DeleteOptions{})
+	return existingResource(name, err)
+}
+
+func (d *nodeDecommission) deleteBGPConfiguration(ctx context.Context) ([]string, error) {
+	name := nodeConfigName(d.name)
+	if d.dryRun {
+		_, err := d.nodes.client.BGPConfigurations().Get(ctx, name, options.GetOptions{})
+		return existingResource(name, err)
+	}
+	_, err := d.nodes.client.BGPConfigurations().Delete(ctx, name, options.DeleteOptions{})
+	return existingResource(name, err)
+}
+
+func (d *nodeDecommission) deleteNode(ctx context.Context) ([]string, error) {
+	if d.dryRun {
+		if d.node == nil {
+			return nil, nil
+		}
+		return []string{d.name}, nil
+	}
+
+	var out resource
+	var err error
+	if d.admitted {
+		out, err = d.nodes.client.resources.DeleteAdmitted(ctx, d.deleteOpts, apiv3.KindNode, noNamespace, d.name)
+	} else {
+		out, err = d.nodes.client.resources.Delete(ctx, d.deleteOpts, apiv3.KindNode, noNamespace, d.name)
+	}
+	if out != nil {
+		d.deleted = out.(*apiv3.Node)
+	}
+	d.deleteErr = err
+	if _, ok := err.(errors.ErrorOperationNotSupported); ok {
+		// The datastore does not delete nodes (on Kubernetes, the node is deleted by Kubernetes), so
+		// remove the finalizer to allow the node to be deleted.
+		return nil, d.removeFinalizer(ctx)
+	}
+	if d.deleted == nil {
+		return nil, ignoreMissing(err)
+	}
+	return []string{d.name}, ignoreMissing(err)
+}
+
+// nodeConfigName returns the name of the per-node FelixConfiguration and BGPConfiguration.
+func nodeConfigName(nodeName string) string {
+	return fmt.Sprintf("node.%s", nodeName)
+}
+
+// isOwnedByNode returns true if one of the owner references refers to the named Calico node.
+func isOwnedByNode(refs []metav1.OwnerReference, name string) bool {
+	for _, ref := range refs {
+		if ref.Kind == apiv3.KindNode && ref.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFinalizer returns true if the finalizers include the named finalizer.
+func hasFinalizer(finalizers []string, name string) bool {
+	for _, f := range finalizers {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreMissing returns nil for errors that indicate there is nothing to clean up.
+func ignoreMissing(err error) error {
+	switch err.(type) {
+	case nil, errors.ErrorResourceDoesNotExist, errors.ErrorOperationNotSupported:
+		return nil
+	}
+	return err
+}
+
+// existingResource converts the result of getting or deleting a single resource into the step
+// result, listing the resource if it existed.
+func existingResource(name string, err error) ([]string, error) {
+	if err == nil {
+		return []string{name}, nil
+	}
+	return nil, ignoreMissing(err)
+}
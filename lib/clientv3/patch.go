@@ -0,0 +1,114 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientv3
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/types"
+
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+	"github.com/unai-ttxu/libcalico-go/lib/options"
+	"github.com/unai-ttxu/libcalico-go/lib/patch"
+)
+
+// patchResource applies a JSON merge patch or JSON patch to a resource, and updates the resource
+// through the client for its kind.  Unless the options require a particular version of the
+// resource, the patch is reapplied to the latest version if the update conflicts.
+func (c client) patchResource(ctx context.Context, kind, ns, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (resource, error) {
+	kc := c.kindClient(kind)
+	var err error
+	for i := 0; i < maxApplyRetries; i++ {
+		var current resource
+		if current, err = kc.get(ctx, ns, name); err != nil {
+			return nil, err
+		}
+		logCxt := logWithResource(current).WithField("Retry", i)
+		if opts.ResourceVersion != "" && opts.ResourceVersion != current.GetObjectMeta().GetResourceVersion() {
+			logCxt.Info("Rejecting patch for a different resource version")
+			return nil, cerrors.ErrorResourceUpdateConflict{Identifier: name}
+		}
+
+		var out resource
+		if out, err = patchedResource(current, pt, data); err != nil {
+			return nil, err
+		}
+
+		logCxt.Debug("Updating patched resource")
+		var updated resource
+		updated, err = kc.update(ctx, out, options.SetOptions{})
+		if _, ok := err.(cerrors.ErrorResourceUpdateConflict); ok && opts.ResourceVersion == "" {
+			logCxt.Debug("Resource updated by another client - retry patch")
+			continue
+		}
+		return updated, err
+	}
+
+	log.WithError(err).Info("Too many conflict failures attempting to patch resource")
+	return nil, err
+}
+
+// patchedResource returns a copy of the resource with the patch applied.  The patch may not
+// change the identity or version of the resource.
+func patchedResource(current resource, pt types.PatchType, data []byte) (resource, error) {
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := patch.Apply(pt, doc, data)
+	if err != nil {
+		return nil, cerrors.ErrorValidation{
+			ErroredFields: []cerrors.ErroredField{{
+				Name:   "Patch",
+				Reason: err.Error(),
+			}},
+		}
+	}
+
+	out := reflect.New(reflect.TypeOf(current).Elem()).Interface().(resource)
+	if err := json.Unmarshal(patched, out); err != nil {
+		return nil, cerrors.ErrorValidation{
+			ErroredFields: []cerrors.ErroredField{{
+				Name:   "Patch",
+				Reason: "patched resource is invalid: " + err.Error(),
+			}},
+		}
+	}
+
+	for _, f := range []struct {
+		name            string
+		current, parsed string
+	}{
+		{"Kind", current.GetObjectKind().GroupVersionKind().Kind, out.GetObjectKind().GroupVersionKind().Kind},
+		{"Metadata.Name", current.GetObjectMeta().GetName(), out.GetObjectMeta().GetName()},
+		{"Metadata.Namespace", current.GetObjectMeta().GetNamespace(), out.GetObjectMeta().GetNamespace()},
+		{"Metadata.UID", string(current.GetObjectMeta().GetUID()), string(out.GetObjectMeta().GetUID())},
+		{"Metadata.ResourceVersion", current.GetObjectMeta().GetResourceVersion(), out.GetObjectMeta().GetResourceVersion()},
+	} {
+		if f.current != f.parsed {
+			return nil, cerrors.ErrorValidation{
+				ErroredFields: []cerrors.ErroredField{{
+					Name:   f.name,
+					Reason: "field cannot be changed by a patch",
+					Value:  f.parsed,
+				}},
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientv3_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/unai-ttxu/libcalico-go/lib/apiconfig"
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	"github.com/unai-ttxu/libcalico-go/lib/backend"
+	"github.com/unai-ttxu/libcalico-go/lib/clientv3"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+	"github.com/unai-ttxu/libcalico-go/lib/options"
+	"github.com/unai-ttxu/libcalico-go/lib/testutils"
+)
+
+var _ = testutils.E2eDatastoreDescribe("Patch tests", testutils.DatastoreAll, func(config apiconfig.CalicoAPIConfig) {
+	ctx := context.Background()
+	var c clientv3.Interface
+	var rv string
+
+	BeforeEach(func() {
+		var err error
+		c, err = clientv3.New(config)
+		Expect(err).NotTo(HaveOccurred())
+
+		be, err := backend.NewClient(config)
+		Expect(err).NotTo(HaveOccurred())
+		be.Clean()
+
+		logSeverity := "Info"
+		fc := apiv3.NewFelixConfiguration()
+		fc.Name = "default"
+		fc.Spec.LogSeverityScreen = logSeverity
+		fc.Spec.DataplaneDriver = "test-driver"
+		fc, err = c.FelixConfigurations().Create(ctx, fc, options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		rv = fc.ResourceVersion
+	})
+
+	It("should apply a JSON merge patch", func() {
+		fc, err := c.FelixConfigurations().Patch(ctx, "default", types.MergePatchType,
+			[]byte(`{"spec":{"logSeverityScreen":"Debug","dataplaneDriver":null}}`), options.PatchOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fc.Spec.LogSeverityScreen).To(Equal("Debug"))
+		Expect(fc.Spec.DataplaneDriver).To(Equal(""))
+		Expect(fc.ResourceVersion).NotTo(Equal(rv))
+	})
+
+	It("should apply a JSON patch", func() {
+		fc, err := c.FelixConfigurations().Patch(ctx, "default", types.JSONPatchType,
+			[]byte(`[{"op":"test","path":"/spec/logSeverityScreen","value":"Info"},{"op":"replace","path":"/spec/logSeverityScreen","value":"Warning"}]`),
+			options.PatchOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fc.Spec.LogSeverityScreen).To(Equal("Warning"))
+		Expect(fc.Spec.DataplaneDriver).To(Equal("test-driver"))
+	})
+
+	It("should only patch the required resource version", func() {
+		_, err := c.FelixConfigurations().Patch(ctx, "default", types.MergePatchType,
+			[]byte(`{"spec":{"logSeverityScreen":"Debug"}}`), options.PatchOptions{ResourceVersion: rv})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = c.FelixConfigurations().Patch(ctx, "default", types.MergePatchType,
+			[]byte(`{"spec":{"logSeverityScreen":"Error"}}`), options.PatchOptions{ResourceVersion: rv})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorResourceUpdateConflict{}))
+	})
+
+	It("should reject invalid patches", func() {
+		_, err := c.FelixConfigurations().Patch(ctx, "default", types.MergePatchType,
+			[]byte(`{"metadata":{"name":"other"}}`), options.PatchOptions{})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorValidation{}))
+
+		_, err = c.FelixConfigurations().Patch(ctx, "default", types.JSONPatchType,
+			[]byte(`[{"op":"remove","path":"/spec/ipipEnabled"}]`), options.PatchOptions{})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorValidation{}))
+
+		_, err = c.FelixConfigurations().Patch(ctx, "default", types.MergePatchType,
+			[]byte(`{"spec":{"logSeverityScreen":"Loud"}}`), options.PatchOptions{})
+		Expect(err).To(HaveOccurred())
+
+		_, err = c.FelixConfigurations().Patch(ctx, "missing", types.MergePatchType,
+			[]byte(`{}`), options.PatchOptions{})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorResourceDoesNotExist{}))
+	})
+})
@@ -17,6 +17,8 @@ package clientv3
 import (
 	"context"
 
+	"k8s.io/apimachinery/pkg/types"
+
 	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
 	"github.com/unai-ttxu/libcalico-go/lib/options"
 	validator "github.com/unai-ttxu/libcalico-go/lib/validator/v3"
@@ -27,6 +29,7 @@ import (
 type ProfileInterface interface {
 	Create(ctx context.Context, res *apiv3.Profile, opts options.SetOptions) (*apiv3.Profile, error)
 	Update(ctx context.Context, res *apiv3.Profile, opts options.SetOptions) (*apiv3.Profile, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.Profile, error)
 	Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.Profile, error)
 	Get(ctx context.Context, name string, opts options.GetOptions) (*apiv3.Profile, error)
 	List(ctx context.Context, opts options.ListOptions) (*apiv3.ProfileList, error)
@@ -66,6 +69,16 @@ func (r profiles) Update(ctx context.Context, res *apiv3.Profile, opts options.S
 	return nil, err
 }
 
+// Patch applies a JSON merge patch or JSON patch to the Profile and updates it.  Returns the
+// stored representation of the Profile, and an error, if there is any.
+func (r profiles) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.Profile, error) {
+	out, err := r.client.patchResource(ctx, apiv3.KindProfile, noNamespace, name, pt, data, opts)
+	if out != nil {
+		return out.(*apiv3.Profile), err
+	}
+	return nil, err
+}
+
 // Delete takes name of the Profile and deletes it. Returns an error if one occurs.
 func (r profiles) Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.Profile, error) {
 	out, err := r.client.resources.Delete(ctx, opts, apiv3.KindProfile, noNamespace, name)
@@ -55,27 +55,84 @@ type resourceList interface {
 // resourceInterface has methods to work with generic resource types.
 type resourceInterface interface {
 	Create(ctx context.Context, opts options.SetOptions, kind string, in resource) (resource, error)
+	CreateAdmitted(ctx context.Context, opts options.SetOptions, kind string, in resource) (resource, error)
 	Update(ctx context.Context, opts options.SetOptions, kind string, in resource) (resource, error)
+	UpdateAdmitted(ctx context.Context, opts options.SetOptions, kind string, in resource) (resource, error)
+	UpdateStatus(ctx context.Context, opts options.SetOptions, kind string, in resource) (resource, error)
 	Delete(ctx context.Context, opts options.DeleteOptions, kind, ns, name string) (resource, error)
+	DeleteAdmitted(ctx context.Context, opts options.DeleteOptions, kind, ns, name string) (resource, error)
 	Get(ctx context.Context, opts options.GetOptions, kind, ns, name string) (resource, error)
 	List(ctx context.Context, opts options.ListOptions, kind, listkind string, inout resourceList) error
 	Watch(ctx context.Context, opts options.ListOptions, kind string, converter watcherConverter) (watch.Interface, error)
+	Admit(ctx context.Context, op AdmissionOperation, kind, ns, name string, in resource) error
 }
 
 // resources implements resourceInterface.
 type resources struct {
 	backend bapi.Client
+	hooks   admissionHooks
 }
 
 // Create creates a resource in the backend datastore.
 func (c *resources) Create(ctx context.Context, opts options.SetOptions, kind string, in resource) (resource, error) {
+	if err := c.Admit(ctx, AdmissionCreate, kind, in.GetObjectMeta().GetNamespace(), in.GetObjectMeta().GetName(), in); err != nil {
+		return nil, err
+	}
+	return c.CreateAdmitted(ctx, opts, kind, in)
+}
+
+// CreateAdmitted creates a resource in the backend datastore without running the admission hooks.
+// It is used by clients that admit the create before making changes that the create depends on,
+// so that a create that is not admitted has no side effects.
+func (c *resources) CreateAdmitted(ctx context.Context, opts options.SetOptions, kind string, in resource) (resource, error) {
+	if opts.DryRun {
+		return c.dryRun(ctx, AdmissionCreate, kind, in)
+	}
+
+	// Convert the resource to a KVPair and pass that to the backend datastore, converting
+	// the response (if we get one) back to a resource.
+	kvp, err := c.backend.Create(ctx, c.resourceToKVPair(opts, kind, in))
+	if kvp != nil {
+		return c.kvPairToResource(kvp), err
+	}
+	return nil, err
+}
+
+// Update updates a resource in the backend datastore.
+func (c *resources) Update(ctx context.Context, opts options.SetOptions, kind string, in resource) (resource, error) {
+	if err := c.Admit(ctx, AdmissionUpdate, kind, in.GetObjectMeta().GetNamespace(), in.GetObjectMeta().GetName(), in); err != nil {
+		return nil, err
+	}
+	return c.UpdateAdmitted(ctx, opts, kind, in)
+}
+
+// UpdateAdmitted updates a resource in the backend datastore without running the admission hooks.
+// It is used by clients that admit the update before making changes that the update depends on,
+// so that an update that is not admitted has no side effects.
+func (c *resources) UpdateAdmitted(ctx context.Context, opts options.SetOptions, kind string, in resource) (resource, error) {
+	if opts.DryRun {
+		return c.dryRun(ctx, AdmissionUpdate, kind, in)
+	}
+
+	// Convert the resource to a KVPair and pass that to the backend datastore, converting
+	// the response (if we get one) back to a resource.
+	kvp, err := c.backend.Update(ctx, c.resourceToKVPair(opts, kind, in))
+	if kvp != nil {
+		return c.kvPairToResource(kvp), err
+	}
+	return nil, err
+}
+
+// checkCreate checks that the resource can be created, and sets its UID and creation timestamp if
+// they are not set.
+func (c *resources) checkCreate(kind string, in resource) error {
 	// Resource must have a Name.  Currently we do not support GenerateName.
 	if len(in.GetObjectMeta().GetName()) == 0 {
 		var generateNameMessage string
 		if len(in.GetObjectMeta().GetGenerateName()) != 0 {
 			generateNameMessage = " (GenerateName is not supported)"
 		}
-		return nil, cerrors.ErrorValidation{
+		return cerrors.ErrorValidation{
 			ErroredFields: []cerrors.ErroredField{{
 				Name:   "Metadata.Name",
 				Reason: "field must be set for a Create request" + generateNameMessage,
@@ -87,7 +144,7 @@ func (c *resources) Create(ctx context.Context, opts options.SetOptions, kind st
 	// A ResourceVersion should never be specified on a Create.
 	if len(in.GetObjectMeta().GetResourceVersion()) != 0 {
 		logWithResource(in).Info("Rejecting Create request with non-empty resource version")
-		return nil, cerrors.ErrorValidation{
+		return cerrors.ErrorValidation{
 			ErroredFields: []cerrors.ErroredField{{
 				Name:   "Metadata.ResourceVersion",
 				Reason: "field must not be set for a Create request",
@@ -96,7 +153,7 @@ func (c *resources) Create(ctx context.Context, opts options.SetOptions, kind st
 		}
 	}
 	if err := c.checkNamespace(in.GetObjectMeta().GetNamespace(), kind); err != nil {
-		return nil, err
+		return err
 	}
 
 	// Add in the UID and creation timestamp for the resource if needed.
@@ -107,22 +164,15 @@ func (c *resources) Create(ctx context.Context, opts options.SetOptions, kind st
 	if in.GetObjectMeta().GetUID() == "" {
 		in.GetObjectMeta().SetUID(uuid.NewUUID())
 	}
-
-	// Convert the resource to a KVPair and pass that to the backend datastore, converting
-	// the response (if we get one) back to a resource.
-	kvp, err := c.backend.Create(ctx, c.resourceToKVPair(opts, kind, in))
-	if kvp != nil {
-		return c.kvPairToResource(kvp), err
-	}
-	return nil, err
+	return nil
 }
 
-// Update updates a resource in the backend datastore.
-func (c *resources) Update(ctx context.Context, opts options.SetOptions, kind string, in resource) (resource, error) {
+// checkUpdate checks that the resource can be updated.
+func (c *resources) checkUpdate(kind string, in resource) error {
 	// A ResourceVersion should always be specified on an Update.
 	if len(in.GetObjectMeta().GetResourceVersion()) == 0 {
 		logWithResource(in).Info("Rejecting Update request with empty resource version")
-		return nil, cerrors.ErrorValidation{
+		return cerrors.ErrorValidation{
 			ErroredFields: []cerrors.ErroredField{{
 				Name:   "Metadata.ResourceVersion",
 				Reason: "field must be set for an Update request",
@@ -131,11 +181,11 @@ func (c *resources) Update(ctx context.Context, opts options.SetOptions, kind st
 		}
 	}
 	if err := c.checkNamespace(in.GetObjectMeta().GetNamespace(), kind); err != nil {
-		return nil, err
+		return err
 	}
 	creationTimestamp := in.GetObjectMeta().GetCreationTimestamp()
 	if creationTimestamp.IsZero() {
-		return nil, cerrors.ErrorValidation{
+		return cerrors.ErrorValidation{
 			ErroredFields: []cerrors.ErroredField{{
 				Name:   "Metadata.CreationTimestamp",
 				Reason: "field must be set for an Update request",
@@ -144,7 +194,7 @@ func (c *resources) Update(ctx context.Context, opts options.SetOptions, kind st
 		}
 	}
 	if in.GetObjectMeta().GetUID() == "" {
-		return nil, cerrors.ErrorValidation{
+		return cerrors.ErrorValidation{
 			ErroredFields: []cerrors.ErroredField{{
 				Name:   "Metadata.UID",
 				Reason: "field must be set for an Update request",
@@ -152,10 +202,27 @@ func (c *resources) Update(ctx context.Context, opts options.SetOptions, kind st
 			}},
 		}
 	}
+	return nil
+}
+
+// UpdateStatus updates the status of a resource in the backend datastore.  The rest of the
+// resource is not updated, and the ResourceVersion is ignored, so that status updates do not
+// conflict with updates to the rest of the resource.
+func (c *resources) UpdateStatus(ctx context.Context, opts options.SetOptions, kind string, in resource) (resource, error) {
+	if err := c.checkNamespace(in.GetObjectMeta().GetNamespace(), kind); err != nil {
+		return nil, err
+	}
+	backend, ok := c.backend.(bapi.StatusClient)
+	if !ok {
+		return nil, cerrors.ErrorOperationNotSupported{
+			Identifier: in.GetObjectMeta().GetName(),
+			Operation:  "UpdateStatus",
+		}
+	}
 
 	// Convert the resource to a KVPair and pass that to the backend datastore, converting
 	// the response (if we get one) back to a resource.
-	kvp, err := c.backend.Update(ctx, c.resourceToKVPair(opts, kind, in))
+	kvp, err := backend.UpdateStatus(ctx, c.resourceToKVPair(opts, kind, in))
 	if kvp != nil {
 		return c.kvPairToResource(kvp), err
 	}
@@ -167,6 +234,16 @@ func (c *resources) Delete(ctx context.Context, opts options.DeleteOptions, kind
 	if err := c.checkNamespace(ns, kind); err != nil {
 		return nil, err
 	}
+	if err := c.Admit(ctx, AdmissionDelete, kind, ns, name, nil); err != nil {
+		return nil, err
+	}
+	return c.DeleteAdmitted(ctx, opts, kind, ns, name)
+}
+
+// DeleteAdmitted deletes a resource from the backend datastore without running the admission hooks.  It
+// is used by clients that admit the delete before cleaning up the data associated with the
+// resource, so that a delete that is not admitted has no side effects.
+func (c *resources) DeleteAdmitted(ctx context.Context, opts options.DeleteOptions, kind, ns, name string) (resource, error) {
 	// Create a ResourceKey and pass that to the backend datastore.
 	key := model.ResourceKey{
 		Kind:      kind,
@@ -260,6 +337,67 @@ func (c *resources) Watch(ctx context.Context, opts options.ListOptions, kind st
 	return w, nil
 }
 
+// Admit runs the admission hooks for a create, update or delete of a resource, reading the
+// stored resource for an update or delete.  A resource that is created or updated is first checked
+// as for Create or Update.
+func (c *resources) Admit(ctx context.Context, op AdmissionOperation, kind, ns, name string, in resource) error {
+	switch op {
+	case AdmissionCreate:
+		if err := c.checkCreate(kind, in); err != nil {
+			return err
+		}
+	case AdmissionUpdate:
+		if err := c.checkUpdate(kind, in); err != nil {
+			return err
+		}
+	}
+	if c.hooks.empty() {
+		return nil
+	}
+	req := AdmissionRequest{Operation: op, Kind: kind, Namespace: ns, Name: name}
+	if in != nil {
+		req.New = in
+	}
+	if op != AdmissionCreate {
+		kvp, err := c.backend.Get(ctx, model.ResourceKey{Kind: kind, Name: name, Namespace: ns}, "")
+		if err != nil {
+			return err
+		}
+		req.Old = c.kvPairToResource(kvp)
+	}
+	return c.hooks.run(ctx, req)
+}
+
+// dryRun checks that a create or update of the resource would not fail in the datastore, and
+// returns the resource without writing it.  A create fails if the resource already exists, and an
+// update fails if the resource has been modified since it was read.
+func (c *resources) dryRun(ctx context.Context, op AdmissionOperation, kind string, in resource) (resource, error) {
+	key := model.ResourceKey{
+		Kind:      kind,
+		Name:      in.GetObjectMeta().GetName(),
+		Namespace: in.GetObjectMeta().GetNamespace(),
+	}
+	kvp, err := c.backend.Get(ctx, key, "")
+	if op == AdmissionCreate {
+		if err == nil {
+			return nil, cerrors.ErrorResourceAlreadyExists{Identifier: key}
+		} else if _, ok := err.(cerrors.ErrorResourceDoesNotExist); !ok {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	} else if kvp.Revision != in.GetObjectMeta().GetResourceVersion() {
+		logWithResource(in).Info("Rejecting dry run of an update to a modified resource")
+		return nil, cerrors.ErrorResourceUpdateConflict{Identifier: key}
+	}
+	in.GetObjectKind().SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   apiv3.Group,
+		Version: apiv3.VersionCurrent,
+		Kind:    kind,
+	})
+	return in, nil
+}
+
 // resourceToKVPair converts the resource to a KVPair that can be consumed by the
 // backend datastore client.
 func (c *resources) resourceToKVPair(opts options.SetOptions, kind string, in resource) *model.KVPair {
@@ -0,0 +1,106 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clientv3_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/unai-ttxu/libcalico-go/lib/apiconfig"
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	"github.com/unai-ttxu/libcalico-go/lib/backend"
+	"github.com/unai-ttxu/libcalico-go/lib/clientv3"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+	"github.com/unai-ttxu/libcalico-go/lib/numorstring"
+	"github.com/unai-ttxu/libcalico-go/lib/options"
+	"github.com/unai-ttxu/libcalico-go/lib/testutils"
+)
+
+var _ = testutils.E2eDatastoreDescribe("Resource status tests", testutils.DatastoreAll, func(config apiconfig.CalicoAPIConfig) {
+	ctx := context.Background()
+	var c clientv3.Interface
+
+	sessions := func(state apiv3.BGPSessionState) []apiv3.BGPSessionStatus {
+		return []apiv3.BGPSessionStatus{{Node: "node1", PeerIP: "10.0.0.1", State: state}}
+	}
+
+	BeforeEach(func() {
+		var err error
+		c, err = clientv3.New(config)
+		Expect(err).NotTo(HaveOccurred())
+
+		be, err := backend.NewClient(config)
+		Expect(err).NotTo(HaveOccurred())
+		be.Clean()
+	})
+
+	It("should only write the status of a resource with UpdateStatus", func() {
+		peer := apiv3.NewBGPPeer()
+		peer.Name = "peer1"
+		peer.Spec.PeerIP = "10.0.0.1"
+		peer.Spec.ASNumber = numorstring.ASNumber(64512)
+		peer.Status.Sessions = sessions(apiv3.BGPSessionStateIdle)
+		created, err := c.BGPPeers().Create(ctx, peer, options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(created.Status).To(Equal(apiv3.BGPPeerStatus{}))
+
+		By("Updating the status")
+		created.Spec.ASNumber = numorstring.ASNumber(64513)
+		created.Status.Sessions = sessions(apiv3.BGPSessionStateEstablished)
+		updated, err := c.BGPPeers().UpdateStatus(ctx, created, options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Spec.ASNumber).To(Equal(numorstring.ASNumber(64512)))
+		Expect(updated.Status.Sessions).To(Equal(sessions(apiv3.BGPSessionStateEstablished)))
+
+		got, err := c.BGPPeers().Get(ctx, "peer1", options.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(got.Status).To(Equal(updated.Status))
+
+		By("Updating the rest of the resource")
+		got.Spec.ASNumber = numorstring.ASNumber(64513)
+		got.Status = apiv3.BGPPeerStatus{}
+		updated, err = c.BGPPeers().Update(ctx, got, options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Spec.ASNumber).To(Equal(numorstring.ASNumber(64513)))
+		Expect(updated.Status.Sessions).To(Equal(sessions(apiv3.BGPSessionStateEstablished)))
+
+		list, err := c.BGPPeers().List(ctx, options.ListOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(list.Items).To(HaveLen(1))
+		Expect(list.Items[0].Status.Sessions).To(Equal(sessions(apiv3.BGPSessionStateEstablished)))
+
+		By("Deleting and recreating the resource")
+		_, err = c.BGPPeers().Delete(ctx, "peer1", options.DeleteOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		peer.Status = apiv3.BGPPeerStatus{}
+		_, err = c.BGPPeers().Create(ctx, peer, options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		got, err = c.BGPPeers().Get(ctx, "peer1", options.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(got.Status).To(Equal(apiv3.BGPPeerStatus{}))
+	})
+
+	It("should fail to update the status of a resource that does not exist", func() {
+		peer := apiv3.NewBGPPeer()
+		peer.Name = "peer1"
+		peer.Spec.PeerIP = "10.0.0.1"
+		peer.Spec.ASNumber = numorstring.ASNumber(64512)
+		peer.Status.Sessions = sessions(apiv3.BGPSessionStateIdle)
+		_, err := c.BGPPeers().UpdateStatus(ctx, peer, options.SetOptions{})
+		Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorResourceDoesNotExist{}))
+	})
+})
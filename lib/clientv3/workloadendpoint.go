@@ -18,6 +18,8 @@ import (
 	"context"
 	"fmt"
 
+	"k8s.io/apimachinery/pkg/types"
+
 	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
 	"github.com/unai-ttxu/libcalico-go/lib/errors"
 	"github.com/unai-ttxu/libcalico-go/lib/names"
@@ -30,6 +32,7 @@ import (
 type WorkloadEndpointInterface interface {
 	Create(ctx context.Context, res *apiv3.WorkloadEndpoint, opts options.SetOptions) (*apiv3.WorkloadEndpoint, error)
 	Update(ctx context.Context, res *apiv3.WorkloadEndpoint, opts options.SetOptions) (*apiv3.WorkloadEndpoint, error)
+	Patch(ctx context.Context, namespace, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.WorkloadEndpoint, error)
 	Delete(ctx context.Context, namespace, name string, opts options.DeleteOptions) (*apiv3.WorkloadEndpoint, error)
 	Get(ctx context.Context, namespace, name string, opts options.GetOptions) (*apiv3.WorkloadEndpoint, error)
 	List(ctx context.Context, opts options.ListOptions) (*apiv3.WorkloadEndpointList, error)
@@ -85,6 +88,16 @@ func (r workloadEndpoints) Update(ctx context.Context, res *apiv3.WorkloadEndpoi
 	return nil, err
 }
 
+// Patch applies a JSON merge patch or JSON patch to the WorkloadEndpoint and updates it.  Returns the
+// stored representation of the WorkloadEndpoint, and an error, if there is any.
+func (r workloadEndpoints) Patch(ctx context.Context, namespace, name string, pt types.PatchType, data []byte, opts options.PatchOptions) (*apiv3.WorkloadEndpoint, error) {
+	out, err := r.client.patchResource(ctx, apiv3.KindWorkloadEndpoint, namespace, name, pt, data, opts)
+	if out != nil {
+		return out.(*apiv3.WorkloadEndpoint), err
+	}
+	return nil, err
+}
+
 // Delete takes name of the WorkloadEndpoint and deletes it. Returns an error if one occurs.
 func (r workloadEndpoints) Delete(ctx context.Context, namespace, name string, opts options.DeleteOptions) (*apiv3.WorkloadEndpoint, error) {
 	out, err := r.client.resources.Delete(ctx, opts, apiv3.KindWorkloadEndpoint, namespace, name)
@@ -0,0 +1,505 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package garbagecollector deletes Calico resources whose owners have been deleted, and reports
+// dangling references between resources.
+package garbagecollector
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	bapi "github.com/unai-ttxu/libcalico-go/lib/backend/api"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/watchersyncer"
+	"github.com/unai-ttxu/libcalico-go/lib/clientv3"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+	"github.com/unai-ttxu/libcalico-go/lib/namespace"
+	"github.com/unai-ttxu/libcalico-go/lib/options"
+)
+
+const (
+	// The interval before retrying a failed delete, which doubles with each failure of the same
+	// resource up to the maximum.
+	defaultRetryInterval = 1 * time.Second
+	maxRetryInterval     = 5 * time.Minute
+)
+
+// The kinds of resource that may own, or be owned by, other resources.
+var watchedKinds = []string{
+	apiv3.KindBGPConfiguration,
+	apiv3.KindBGPPeer,
+	apiv3.KindClusterInformation,
+	apiv3.KindFelixConfiguration,
+	apiv3.KindGlobalNetworkPolicy,
+	apiv3.KindGlobalNetworkSet,
+	apiv3.KindHostEndpoint,
+	apiv3.KindIPPool,
+	apiv3.KindIPReservation,
+	apiv3.KindNetworkPolicy,
+	apiv3.KindNetworkSet,
+	apiv3.KindNode,
+	apiv3.KindProfile,
+	apiv3.KindWorkloadEndpoint,
+}
+
+// GarbageCollector deletes resources once all of their owners, given by the owner references in
+// their metadata, have been deleted.  Since deleting a resource may leave the resources that it
+// owns without owners, deletions cascade through chains of owner references.  Resources are
+// deleted in the background: owner references do not block the deletion of their owners.
+//
+// Only owner references to Calico resources are followed.  References to owners of other kinds,
+// such as Kubernetes resources, are ignored, since the garbage collector cannot tell whether those
+// owners exist.
+//
+// The garbage collector is driven by a syncer watching all of the resource kinds, and deletes
+// resources through the Calico client, so that they are cleaned up and admitted in the same way
+// as any other delete.  It only deletes resources once the syncer is in sync, so that it does not
+// mistake an owner that has not been listed yet for a deleted owner.  Resources to delete are
+// queued to a worker, so that deletes do not block the syncer, and deletes that fail are retried
+// with backoff.
+type GarbageCollector struct {
+	client        clientv3.Interface
+	syncer        bapi.Syncer
+	retryInterval time.Duration
+
+	lock    sync.Mutex
+	inSync  bool
+	stopped bool
+
+	// The resources queued for deletion, the number of times that deleting each resource has
+	// failed, and the channels used to wake and to stop the worker that deletes them.
+	queue    map[model.ResourceKey]bool
+	failures map[model.ResourceKey]int
+	kick     chan struct{}
+	stop     chan struct{}
+
+	// The resources in the datastore, indexed by key and by UID, and the resources that own
+	// them, indexed by the UIDs of their owners.
+	resources  map[model.ResourceKey]*resource
+	uids       map[types.UID]model.ResourceKey
+	dependents map[types.UID]map[model.ResourceKey]bool
+}
+
+// resource is the data that the garbage collector holds for a resource.
+type resource struct {
+	uid      types.UID
+	revision string
+	owners   []metav1.OwnerReference
+	profiles []string
+}
+
+// DanglingReference is a reference from one resource to another resource that does not exist.
+type DanglingReference struct {
+	// The resource holding the reference.
+	From model.ResourceKey
+	// The field holding the reference, for example "spec.profiles".
+	Field string
+	// The resource that does not exist.  For an owner reference, Name is the name of the owner,
+	// which is identified by its UID.
+	To model.ResourceKey
+	// For an owner reference, the UID of the owner.
+	UID types.UID
+}
+
+// backendClientAccessor is an interface used to access the backend client from the main clientv3.
+type backendClientAccessor interface {
+	Backend() bapi.Client
+}
+
+// New returns a garbage collector for the resources in the datastore.  Start must be called to
+// start it.
+func New(client clientv3.Interface) *GarbageCollector {
+	gc := &GarbageCollector{
+		client:        client,
+		retryInterval: defaultRetryInterval,
+		queue:         map[model.ResourceKey]bool{},
+		failures:      map[model.ResourceKey]int{},
+		kick:          make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		resources:     map[model.ResourceKey]*resource{},
+		uids:          map[types.UID]model.ResourceKey{},
+		dependents:    map[types.UID]map[model.ResourceKey]bool{},
+	}
+	var resourceTypes []watchersyncer.ResourceType
+	for _, kind := range watchedKinds {
+		resourceTypes = append(resourceTypes, watchersyncer.ResourceType{
+			ListInterface: model.ResourceListOptions{Kind: kind},
+		})
+	}
+	gc.syncer = watchersyncer.New(client.(backendClientAccessor).Backend(), resourceTypes, gc)
+	return gc
+}
+
+// Start starts the garbage collector.
+func (gc *GarbageCollector) Start() {
+	go gc.run()
+	gc.syncer.Start()
+}
+
+// Stop stops the garbage collector.
+func (gc *GarbageCollector) Stop() {
+	// The syncer reports all of the resources as deleted when it stops, so ignore any further
+	// updates before stopping it.
+	gc.lock.Lock()
+	if gc.stopped {
+		gc.lock.Unlock()
+		return
+	}
+	gc.stopped = true
+	close(gc.stop)
+	gc.lock.Unlock()
+	gc.syncer.Stop()
+}
+
+// OnStatusUpdated is called by the syncer when its sync status changes.  Once the syncer is in
+// sync, the garbage collector deletes the resources whose owners do not exist.
+func (gc *GarbageCollector) OnStatusUpdated(status bapi.SyncStatus) {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+	if gc.stopped || status != bapi.InSync || gc.inSync {
+		return
+	}
+
+	log.WithField("resources", len(gc.resources)).Info("Garbage collector in sync")
+	gc.inSync = true
+	for _, ref := range gc.danglingReferences() {
+		log.WithField("reference", ref).Warning("Dangling reference")
+	}
+	for key := range gc.resources {
+		gc.collect(key)
+	}
+}
+
+// OnUpdates is called by the syncer with updates to the resources.
+func (gc *GarbageCollector) OnUpdates(updates []bapi.Update) {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+	if gc.stopped {
+		return
+	}
+
+	for _, u := range updates {
+		key, ok := u.Key.(model.ResourceKey)
+		if !ok {
+			continue
+		}
+		old := gc.resources[key]
+		gc.remove(key)
+		var res *resource
+		if u.Value != nil {
+			if res = newResource(key, u.Value, u.Revision); res != nil {
+				gc.add(key, res)
+			}
+		}
+		if !gc.inSync {
+			continue
+		}
+		if res != nil {
+			gc.collect(key)
+		}
+		if old != nil && (res == nil || res.uid != old.uid) {
+			// The resource has been deleted, or replaced by a new resource, so collect the
+			// resources that it owned.
+			for dependent := range gc.dependents[old.uid] {
+				gc.collect(dependent)
+			}
+		}
+	}
+}
+
+// newResource returns the data held for the resource value, or nil if it is not a resource.
+func newResource(key model.ResourceKey, value interface{}, revision string) *resource {
+	r, ok := value.(metav1.ObjectMetaAccessor)
+	if !ok {
+		return nil
+	}
+	meta := r.GetObjectMeta()
+	res := &resource{
+		uid:      meta.GetUID(),
+		revision: revision,
+	}
+	for _, owner := range meta.GetOwnerReferences() {
+		// Only Calico resources are watched, so whether any other owner exists is unknown.
+		if owner.APIVersion != apiv3.GroupVersionCurrent || !isWatchedKind(owner.Kind) {
+			log.WithFields(log.Fields{"resource": key, "owner": owner.Name, "kind": owner.Kind}).Debug("Ignoring owner reference to a resource that is not watched")
+			continue
+		}
+		// Owners are identified by their UIDs, so an owner reference without a UID can never
+		// be satisfied.  Ignore it rather than deleting the resource.
+		if owner.UID == "" {
+			log.WithFields(log.Fields{"resource": key, "owner": owner.Name}).Warning("Ignoring owner reference without a UID")
+			continue
+		}
+		res.owners = append(res.owners, owner)
+	}
+	switch v := value.(type) {
+	case *apiv3.WorkloadEndpoint:
+		res.profiles = v.Spec.Profiles
+	case *apiv3.HostEndpoint:
+		res.profiles = v.Spec.Profiles
+	}
+	return res
+}
+
+// isWatchedKind returns true if the kind is one of the kinds watched by the garbage collector.
+func isWatchedKind(kind string) bool {
+	for _, k := range watchedKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds the resource to the indexes.
+func (gc *GarbageCollector) add(key model.ResourceKey, res *resource) {
+	gc.resources[key] = res
+	if res.uid != "" {
+		gc.uids[res.uid] = key
+	}
+	for _, owner := range res.owners {
+		if gc.dependents[owner.UID] == nil {
+			gc.dependents[owner.UID] = map[model.ResourceKey]bool{}
+		}
+		gc.dependents[owner.UID][key] = true
+	}
+}
+
+// remove removes the resource from the indexes.
+func (gc *GarbageCollector) remove(key model.ResourceKey) {
+	res, ok := gc.resources[key]
+	if !ok {
+		return
+	}
+	delete(gc.resources, key)
+	if gc.uids[res.uid] == key {
+		delete(gc.uids, res.uid)
+	}
+	for _, owner := range res.owners {
+		delete(gc.dependents[owner.UID], key)
+		if len(gc.dependents[owner.UID]) == 0 {
+			delete(gc.dependents, owner.UID)
+		}
+	}
+}
+
+// collect queues the resource for deletion if it has owners, and none of them exist.  The syncer
+// reports the deletion, and the resources that the resource owned are collected in turn.
+func (gc *GarbageCollector) collect(key model.ResourceKey) {
+	if !gc.collectable(key) {
+		return
+	}
+	gc.enqueue(key)
+}
+
+// collectable returns true if the resource has owners, and none of them exist.
+func (gc *GarbageCollector) collectable(key model.ResourceKey) bool {
+	res := gc.resources[key]
+	if res == nil || len(res.owners) == 0 {
+		return false
+	}
+	for _, owner := range res.owners {
+		if _, ok := gc.uids[owner.UID]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// enqueue queues the resource for deletion and wakes the worker.
+func (gc *GarbageCollector) enqueue(key model.ResourceKey) {
+	gc.queue[key] = true
+	select {
+	case gc.kick <- struct{}{}:
+	default:
+	}
+}
+
+// run deletes the queued resources until the garbage collector is stopped.
+func (gc *GarbageCollector) run() {
+	for {
+		select {
+		case <-gc.stop:
+			return
+		case <-gc.kick:
+		}
+		for key, revision := range gc.dequeue() {
+			gc.delete(key, revision)
+		}
+	}
+}
+
+// dequeue empties the queue, and returns the revisions of the queued resources that are still to
+// be deleted.  A resource may have been deleted, or gained an owner, since it was queued.
+func (gc *GarbageCollector) dequeue() map[model.ResourceKey]string {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+	revisions := map[model.ResourceKey]string{}
+	for key := range gc.queue {
+		delete(gc.queue, key)
+		if gc.stopped || !gc.collectable(key) {
+			delete(gc.failures, key)
+			continue
+		}
+		revisions[key] = gc.resources[key].revision
+	}
+	return revisions
+}
+
+// delete deletes the resource at the revision, and schedules a retry if the delete fails with an
+// error that may not recur, such as a datastore error or a rejection by an admission hook.
+func (gc *GarbageCollector) delete(key model.ResourceKey, revision string) {
+	logCxt := log.WithField("resource", key)
+	logCxt.Info("Deleting resource whose owners have been deleted")
+	err := deleteResource(context.Background(), gc.client, key, revision)
+	switch err.(type) {
+	case nil, cerrors.ErrorResourceDoesNotExist:
+	case cerrors.ErrorResourceUpdateConflict:
+		// The resource has been updated, and the update will be collected in its turn.
+		logCxt.Debug("Resource updated while deleting it")
+	case cerrors.ErrorOperationNotSupported:
+		logCxt.WithError(err).Warning("Cannot delete resource whose owners have been deleted")
+	default:
+		gc.retry(key, err)
+		return
+	}
+
+	gc.lock.Lock()
+	delete(gc.failures, key)
+	gc.lock.Unlock()
+}
+
+// retry queues the resource for deletion again once the retry interval for the number of times
+// that deleting it has failed has passed.
+func (gc *GarbageCollector) retry(key model.ResourceKey, err error) {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+	if gc.stopped {
+		return
+	}
+	interval := gc.retryInterval << uint(gc.failures[key])
+	if interval <= 0 || interval > maxRetryInterval {
+		interval = maxRetryInterval
+	} else {
+		gc.failures[key]++
+	}
+	log.WithError(err).WithFields(log.Fields{"resource": key, "retryInterval": interval}).Warning("Failed to delete resource whose owners have been deleted, will retry")
+	time.AfterFunc(interval, func() {
+		gc.lock.Lock()
+		defer gc.lock.Unlock()
+		if !gc.stopped {
+			gc.enqueue(key)
+		}
+	})
+}
+
+// deleteResource deletes the resource at the revision through the client for its kind.
+func deleteResource(ctx context.Context, c clientv3.Interface, key model.ResourceKey, revision string) error {
+	opts := options.DeleteOptions{ResourceVersion: revision}
+	var err error
+	switch key.Kind {
+	case apiv3.KindBGPConfiguration:
+		_, err = c.BGPConfigurations().Delete(ctx, key.Name, opts)
+	case apiv3.KindBGPPeer:
+		_, err = c.BGPPeers().Delete(ctx, key.Name, opts)
+	case apiv3.KindClusterInformation:
+		_, err = c.ClusterInformation().Delete(ctx, key.Name, opts)
+	case apiv3.KindFelixConfiguration:
+		_, err = c.FelixConfigurations().Delete(ctx, key.Name, opts)
+	case apiv3.KindGlobalNetworkPolicy:
+		_, err = c.GlobalNetworkPolicies().Delete(ctx, policyName(key.Name), opts)
+	case apiv3.KindGlobalNetworkSet:
+		_, err = c.GlobalNetworkSets().Delete(ctx, key.Name, opts)
+	case apiv3.KindHostEndpoint:
+		_, err = c.HostEndpoints().Delete(ctx, key.Name, opts)
+	case apiv3.KindIPPool:
+		_, err = c.IPPools().Delete(ctx, key.Name, opts)
+	case apiv3.KindIPReservation:
+		_, err = c.IPReservations().Delete(ctx, key.Name, opts)
+	case apiv3.KindNetworkPolicy:
+		_, err = c.NetworkPolicies().Delete(ctx, key.Namespace, policyName(key.Name), opts)
+	case apiv3.KindNetworkSet:
+		_, err = c.NetworkSets().Delete(ctx, key.Namespace, key.Name, opts)
+	case apiv3.KindNode:
+		_, err = c.Nodes().Delete(ctx, key.Name, opts)
+	case apiv3.KindProfile:
+		_, err = c.Profiles().Delete(ctx, key.Name, opts)
+	case apiv3.KindWorkloadEndpoint:
+		_, err = c.WorkloadEndpoints().Delete(ctx, key.Namespace, key.Name, opts)
+	default:
+		return cerrors.ErrorOperationNotSupported{Operation: "Delete", Identifier: key}
+	}
+	return err
+}
+
+// policyName returns the name of a policy as used by the policy clients.  Policies in the default
+// tier are stored with the tier prefixed to their name, which the clients add back.
+func policyName(name string) string {
+	return strings.TrimPrefix(name, "default.")
+}
+
+// DanglingReferences returns the references between resources that refer to resources that do not
+// exist, in order of the resource holding the reference.  Returns nil until the garbage
+// collector is in sync.
+func (gc *GarbageCollector) DanglingReferences() []DanglingReference {
+	gc.lock.Lock()
+	defer gc.lock.Unlock()
+	if !gc.inSync {
+		return nil
+	}
+	return gc.danglingReferences()
+}
+
+func (gc *GarbageCollector) danglingReferences() []DanglingReference {
+	var refs []DanglingReference
+	for key, res := range gc.resources {
+		for _, owner := range res.owners {
+			if _, ok := gc.uids[owner.UID]; !ok {
+				// Owners of namespaced resources are in the same namespace.
+				to := model.ResourceKey{Kind: owner.Kind, Name: owner.Name}
+				if namespace.IsNamespaced(owner.Kind) {
+					to.Namespace = key.Namespace
+				}
+				refs = append(refs, DanglingReference{
+					From:  key,
+					Field: "metadata.ownerReferences",
+					To:    to,
+					UID:   owner.UID,
+				})
+			}
+		}
+		for _, profile := range res.profiles {
+			to := model.ResourceKey{Kind: apiv3.KindProfile, Name: profile}
+			if _, ok := gc.resources[to]; !ok {
+				refs = append(refs, DanglingReference{From: key, Field: "spec.profiles", To: to})
+			}
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].From != refs[j].From {
+			return refs[i].From.String() < refs[j].From.String()
+		}
+		return refs[i].To.String() < refs[j].To.String()
+	})
+	return refs
+}
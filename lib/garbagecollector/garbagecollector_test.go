@@ -0,0 +1,288 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package garbagecollector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	bapi "github.com/unai-ttxu/libcalico-go/lib/backend/api"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	"github.com/unai-ttxu/libcalico-go/lib/clientv3"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+	"github.com/unai-ttxu/libcalico-go/lib/options"
+)
+
+// fakeClient is a Calico client that records the host endpoints and global network policies
+// deleted through it.  The first failures deletes fail with a datastore error.
+type fakeClient struct {
+	clientv3.Interface
+	lock     sync.Mutex
+	failures int
+	deleted  []model.ResourceKey
+}
+
+func (c *fakeClient) delete(key model.ResourceKey) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.failures > 0 {
+		c.failures--
+		return cerrors.ErrorDatastoreError{Err: context.DeadlineExceeded, Identifier: key}
+	}
+	c.deleted = append(c.deleted, key)
+	return nil
+}
+
+func (c *fakeClient) Deleted() []model.ResourceKey {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]model.ResourceKey(nil), c.deleted...)
+}
+
+func (c *fakeClient) Backend() bapi.Client {
+	return nil
+}
+
+func (c *fakeClient) HostEndpoints() clientv3.HostEndpointInterface {
+	return fakeHostEndpoints{client: c}
+}
+
+func (c *fakeClient) GlobalNetworkPolicies() clientv3.GlobalNetworkPolicyInterface {
+	return fakeGlobalNetworkPolicies{client: c}
+}
+
+type fakeHostEndpoints struct {
+	clientv3.HostEndpointInterface
+	client *fakeClient
+}
+
+func (f fakeHostEndpoints) Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.HostEndpoint, error) {
+	if err := f.client.delete(model.ResourceKey{Kind: apiv3.KindHostEndpoint, Name: name}); err != nil {
+		return nil, err
+	}
+	return apiv3.NewHostEndpoint(), nil
+}
+
+type fakeGlobalNetworkPolicies struct {
+	clientv3.GlobalNetworkPolicyInterface
+	client *fakeClient
+}
+
+func (f fakeGlobalNetworkPolicies) Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.GlobalNetworkPolicy, error) {
+	if err := f.client.delete(model.ResourceKey{Kind: apiv3.KindGlobalNetworkPolicy, Name: name}); err != nil {
+		return nil, err
+	}
+	return apiv3.NewGlobalNetworkPolicy(), nil
+}
+
+// fakeSyncer is a syncer that does nothing, so that the tests can drive the garbage collector.
+type fakeSyncer struct{}
+
+func (fakeSyncer) Start() {}
+func (fakeSyncer) Stop()  {}
+
+var _ = Describe("Garbage collector", func() {
+	var client *fakeClient
+	var gc *GarbageCollector
+
+	nodeKey := model.ResourceKey{Kind: apiv3.KindNode, Name: "node1"}
+	hepKey := model.ResourceKey{Kind: apiv3.KindHostEndpoint, Name: "node1-eth0"}
+	poolKey := model.ResourceKey{Kind: apiv3.KindIPPool, Name: "pool1"}
+	policyKey := model.ResourceKey{Kind: apiv3.KindGlobalNetworkPolicy, Name: "default.allow-node1"}
+
+	ownerRef := func(kind, name string) metav1.OwnerReference {
+		return metav1.OwnerReference{
+			APIVersion: apiv3.GroupVersionCurrent,
+			Kind:       kind,
+			Name:       name,
+			UID:        types.UID(name + "-uid"),
+		}
+	}
+	node := func() bapi.Update {
+		n := apiv3.NewNode()
+		n.Name = nodeKey.Name
+		n.UID = "node1-uid"
+		return bapi.Update{KVPair: model.KVPair{Key: nodeKey, Value: n, Revision: "1"}, UpdateType: bapi.UpdateTypeKVNew}
+	}
+	pool := func() bapi.Update {
+		p := apiv3.NewIPPool()
+		p.Name = poolKey.Name
+		p.UID = "pool1-uid"
+		return bapi.Update{KVPair: model.KVPair{Key: poolKey, Value: p, Revision: "2"}, UpdateType: bapi.UpdateTypeKVNew}
+	}
+	hostEndpoint := func(owners ...metav1.OwnerReference) bapi.Update {
+		h := apiv3.NewHostEndpoint()
+		h.Name = hepKey.Name
+		h.UID = "hep-uid"
+		h.OwnerReferences = owners
+		h.Spec.Profiles = []string{"profile1"}
+		return bapi.Update{KVPair: model.KVPair{Key: hepKey, Value: h, Revision: "3"}, UpdateType: bapi.UpdateTypeKVNew}
+	}
+	policy := func(owners ...metav1.OwnerReference) bapi.Update {
+		p := apiv3.NewGlobalNetworkPolicy()
+		p.Name = policyKey.Name
+		p.UID = "policy-uid"
+		p.OwnerReferences = owners
+		return bapi.Update{KVPair: model.KVPair{Key: policyKey, Value: p, Revision: "4"}, UpdateType: bapi.UpdateTypeKVNew}
+	}
+	deleted := func(key model.ResourceKey) bapi.Update {
+		return bapi.Update{KVPair: model.KVPair{Key: key}, UpdateType: bapi.UpdateTypeKVDeleted}
+	}
+
+	BeforeEach(func() {
+		client = &fakeClient{}
+		gc = New(client)
+		gc.syncer = fakeSyncer{}
+		gc.retryInterval = 10 * time.Millisecond
+		gc.Start()
+	})
+
+	AfterEach(func() {
+		gc.Stop()
+	})
+
+	It("should not delete resources until it is in sync", func() {
+		gc.OnUpdates([]bapi.Update{hostEndpoint(ownerRef(apiv3.KindNode, "node1"))})
+		gc.OnStatusUpdated(bapi.ResyncInProgress)
+		Consistently(client.Deleted).Should(BeEmpty())
+		Expect(gc.DanglingReferences()).To(BeNil())
+
+		gc.OnStatusUpdated(bapi.InSync)
+		Eventually(client.Deleted).Should(Equal([]model.ResourceKey{hepKey}))
+	})
+
+	It("should not delete resources without owners, or with an owner that exists", func() {
+		gc.OnUpdates([]bapi.Update{
+			node(),
+			pool(),
+			policy(),
+			hostEndpoint(ownerRef(apiv3.KindNode, "node1"), ownerRef(apiv3.KindIPPool, "pool2")),
+		})
+		gc.OnStatusUpdated(bapi.InSync)
+		Consistently(client.Deleted).Should(BeEmpty())
+
+		// Updating an owner does not affect the resources it owns.
+		gc.OnUpdates([]bapi.Update{node()})
+		Consistently(client.Deleted).Should(BeEmpty())
+	})
+
+	It("should cascade the deletion of an owner through the resources that it owns", func() {
+		gc.OnUpdates([]bapi.Update{
+			node(),
+			hostEndpoint(ownerRef(apiv3.KindNode, "node1")),
+			policy(metav1.OwnerReference{APIVersion: apiv3.GroupVersionCurrent, Kind: apiv3.KindHostEndpoint, Name: hepKey.Name, UID: "hep-uid"}),
+		})
+		gc.OnStatusUpdated(bapi.InSync)
+		Consistently(client.Deleted).Should(BeEmpty())
+
+		gc.OnUpdates([]bapi.Update{deleted(nodeKey)})
+		Eventually(client.Deleted).Should(Equal([]model.ResourceKey{hepKey}))
+
+		gc.OnUpdates([]bapi.Update{deleted(hepKey)})
+		Eventually(client.Deleted).Should(Equal([]model.ResourceKey{
+			hepKey,
+			{Kind: apiv3.KindGlobalNetworkPolicy, Name: "allow-node1"},
+		}))
+	})
+
+	It("should delete resources owned by a resource that is replaced", func() {
+		gc.OnUpdates([]bapi.Update{node(), hostEndpoint(ownerRef(apiv3.KindNode, "node1"))})
+		gc.OnStatusUpdated(bapi.InSync)
+
+		replaced := node()
+		replaced.Value.(*apiv3.Node).UID = "node1-uid2"
+		gc.OnUpdates([]bapi.Update{replaced})
+		Eventually(client.Deleted).Should(Equal([]model.ResourceKey{hepKey}))
+	})
+
+	It("should ignore owner references without a UID", func() {
+		gc.OnUpdates([]bapi.Update{hostEndpoint(metav1.OwnerReference{APIVersion: apiv3.GroupVersionCurrent, Kind: apiv3.KindNode, Name: "node2"})})
+		gc.OnStatusUpdated(bapi.InSync)
+		Consistently(client.Deleted).Should(BeEmpty())
+	})
+
+	It("should ignore owner references to resources that are not Calico resources", func() {
+		gc.OnUpdates([]bapi.Update{
+			hostEndpoint(metav1.OwnerReference{APIVersion: "v1", Kind: "Pod", Name: "pod1", UID: "pod1-uid"}),
+			policy(metav1.OwnerReference{APIVersion: "v1", Kind: apiv3.KindNode, Name: "node1", UID: "node1-uid"}),
+		})
+		gc.OnStatusUpdated(bapi.InSync)
+		Consistently(client.Deleted).Should(BeEmpty())
+		Expect(gc.DanglingReferences()).To(Equal([]DanglingReference{
+			{From: hepKey, Field: "spec.profiles", To: model.ResourceKey{Kind: apiv3.KindProfile, Name: "profile1"}},
+		}))
+	})
+
+	It("should report dangling references", func() {
+		gc.OnUpdates([]bapi.Update{
+			pool(),
+			hostEndpoint(ownerRef(apiv3.KindNode, "node1"), ownerRef(apiv3.KindIPPool, "pool1")),
+			policy(ownerRef(apiv3.KindNode, "node2")),
+		})
+		gc.OnStatusUpdated(bapi.InSync)
+		Expect(gc.DanglingReferences()).To(Equal([]DanglingReference{
+			{From: policyKey, Field: "metadata.ownerReferences", To: model.ResourceKey{Kind: apiv3.KindNode, Name: "node2"}, UID: "node2-uid"},
+			{From: hepKey, Field: "metadata.ownerReferences", To: nodeKey, UID: "node1-uid"},
+			{From: hepKey, Field: "spec.profiles", To: model.ResourceKey{Kind: apiv3.KindProfile, Name: "profile1"}},
+		}))
+
+		profile := apiv3.NewProfile()
+		profile.Name = "profile1"
+		gc.OnUpdates([]bapi.Update{
+			node(),
+			{KVPair: model.KVPair{Key: model.ResourceKey{Kind: apiv3.KindProfile, Name: "profile1"}, Value: profile}},
+		})
+		Expect(gc.DanglingReferences()).To(HaveLen(1))
+	})
+
+	It("should retry deletes that fail", func() {
+		client.failures = 2
+		gc.OnUpdates([]bapi.Update{hostEndpoint(ownerRef(apiv3.KindNode, "node1"))})
+		gc.OnStatusUpdated(bapi.InSync)
+		Eventually(client.Deleted).Should(Equal([]model.ResourceKey{hepKey}))
+	})
+
+	It("should not delete a resource that gains an owner while it is queued for a retry", func() {
+		gc.retryInterval = 200 * time.Millisecond
+		client.failures = 1
+		gc.OnUpdates([]bapi.Update{hostEndpoint(ownerRef(apiv3.KindNode, "node1"))})
+		gc.OnStatusUpdated(bapi.InSync)
+		Eventually(func() int {
+			client.lock.Lock()
+			defer client.lock.Unlock()
+			return client.failures
+		}).Should(Equal(0))
+
+		gc.OnUpdates([]bapi.Update{node()})
+		Consistently(client.Deleted, "500ms").Should(BeEmpty())
+	})
+
+	It("should ignore updates once stopped", func() {
+		gc.OnUpdates([]bapi.Update{node(), hostEndpoint(ownerRef(apiv3.KindNode, "node1"))})
+		gc.OnStatusUpdated(bapi.InSync)
+
+		gc.Stop()
+		gc.OnUpdates([]bapi.Update{deleted(nodeKey)})
+		Consistently(client.Deleted).Should(BeEmpty())
+	})
+})
@@ -0,0 +1,267 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostendpoints
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	"github.com/unai-ttxu/libcalico-go/lib/clientv3"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+	cnet "github.com/unai-ttxu/libcalico-go/lib/net"
+	"github.com/unai-ttxu/libcalico-go/lib/options"
+	"github.com/unai-ttxu/libcalico-go/lib/watch"
+)
+
+const (
+	// The suffix appended to the node name to give the name of the node's automatic host endpoint.
+	autoHostEndpointSuffix = "-auto-hep"
+
+	// The interval between attempts to resync after a failure.
+	defaultRetryInterval = 5 * time.Second
+)
+
+// Controller keeps an automatic, wildcard host endpoint in sync with each Calico node.
+//
+// Each automatic host endpoint applies to all of the interfaces of its node, carries the labels of
+// the node, and has the node's addresses as its expected IPs.  The host endpoint is owned by the
+// node (via an owner reference), so it is also removed when the node is deleted using the clientv3.
+type Controller struct {
+	client        clientv3.Interface
+	retryInterval time.Duration
+}
+
+// NewController returns a Controller that uses the supplied client to watch nodes and manage
+// their host endpoints.
+func NewController(c clientv3.Interface) *Controller {
+	return &Controller{
+		client:        c,
+		retryInterval: defaultRetryInterval,
+	}
+}
+
+// Run resyncs the host endpoints of all nodes and then watches for node changes, until the context
+// is cancelled.  If the watch fails, the controller resyncs and starts a new watch.
+func (c *Controller) Run(ctx context.Context) {
+	for {
+		revision, err := c.Resync(ctx)
+		if err == nil {
+			err = c.watchNodes(ctx, revision)
+		}
+		if ctx.Err() != nil {
+			log.Info("Host endpoint controller stopping")
+			return
+		}
+		if err != nil {
+			log.WithError(err).Warning("Host endpoint controller failed, will resync")
+		}
+		select {
+		case <-ctx.Done():
+			log.Info("Host endpoint controller stopping")
+			return
+		case <-time.After(c.retryInterval):
+		}
+	}
+}
+
+// Resync creates or updates the automatic host endpoint of every node, and deletes automatic host
+// endpoints whose node no longer exists.  It returns the revision of the node list.
+func (c *Controller) Resync(ctx context.Context) (string, error) {
+	nodes, err := c.client.Nodes().List(ctx, options.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+	nodeNames := map[string]bool{}
+	for i := range nodes.Items {
+		nodeNames[nodes.Items[i].Name] = true
+		if err := c.SyncNode(ctx, &nodes.Items[i]); err != nil {
+			return "", err
+		}
+	}
+
+	heps, err := c.client.HostEndpoints().List(ctx, options.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+	for _, hep := range heps.Items {
+		nodeName := autoHostEndpointNode(&hep)
+		if nodeName == "" || nodeNames[nodeName] {
+			continue
+		}
+		if err := c.DeleteNode(ctx, nodeName); err != nil {
+			return "", err
+		}
+	}
+	return nodes.ResourceVersion, nil
+}
+
+// SyncNode creates or updates the automatic host endpoint for the node.  A host endpoint with the
+// name of the automatic host endpoint that is not owned by the node was created by someone else,
+// so it is left alone.
+func (c *Controller) SyncNode(ctx context.Context, node *apiv3.Node) error {
+	logCxt := log.WithField("node", node.Name)
+	desired := HostEndpointForNode(node)
+
+	current, err := c.client.HostEndpoints().Get(ctx, desired.Name, options.GetOptions{})
+	if _, ok := err.(cerrors.ErrorResourceDoesNotExist); ok {
+		logCxt.Info("Creating automatic host endpoint")
+		_, err = c.client.HostEndpoints().Create(ctx, desired, options.SetOptions{})
+		return err
+	} else if err != nil {
+		return err
+	}
+	if autoHostEndpointNode(current) != node.Name {
+		logCxt.WithField("hostEndpoint", current.Name).Warning("Host endpoint is not owned by the node, not updating it")
+		return nil
+	}
+
+	if reflect.DeepEqual(current.Labels, desired.Labels) &&
+		reflect.DeepEqual(current.OwnerReferences, desired.OwnerReferences) &&
+		reflect.DeepEqual(current.Spec, desired.Spec) {
+		logCxt.Debug("Automatic host endpoint is up to date")
+		return nil
+	}
+	logCxt.Info("Updating automatic host endpoint")
+	current.Labels = desired.Labels
+	current.OwnerReferences = desired.OwnerReferences
+	current.Spec = desired.Spec
+	_, err = c.client.HostEndpoints().Update(ctx, current, options.SetOptions{})
+	return err
+}
+
+// DeleteNode deletes the automatic host endpoint for the named node, if it exists.  As for SyncNode,
+// a host endpoint with the same name that is not owned by the node is left alone.
+func (c *Controller) DeleteNode(ctx context.Context, nodeName string) error {
+	logCxt := log.WithField("node", nodeName)
+	hep, err := c.client.HostEndpoints().Get(ctx, AutoHostEndpointName(nodeName), options.GetOptions{})
+	if _, ok := err.(cerrors.ErrorResourceDoesNotExist); ok {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if autoHostEndpointNode(hep) != nodeName {
+		logCxt.WithField("hostEndpoint", hep.Name).Warning("Host endpoint is not owned by the node, not deleting it")
+		return nil
+	}
+
+	logCxt.Info("Deleting automatic host endpoint")
+	_, err = c.client.HostEndpoints().Delete(ctx, hep.Name, options.DeleteOptions{ResourceVersion: hep.ResourceVersion})
+	if _, ok := err.(cerrors.ErrorResourceDoesNotExist); ok {
+		return nil
+	}
+	return err
+}
+
+// watchNodes handles node events until the watch terminates.
+func (c *Controller) watchNodes(ctx context.Context, revision string) error {
+	w, err := c.client.Nodes().Watch(ctx, options.ListOptions{ResourceVersion: revision})
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+
+	for event := range w.ResultChan() {
+		switch event.Type {
+		case watch.Added, watch.Modified:
+			err = c.SyncNode(ctx, event.Object.(*apiv3.Node))
+		case watch.Deleted:
+			err = c.DeleteNode(ctx, event.Previous.(*apiv3.Node).Name)
+		case watch.Error:
+			err = event.Error
+		}
+		if err != nil {
+			return err
+		}
+	}
+	log.Debug("Node watch terminated")
+	return nil
+}
+
+// AutoHostEndpointName returns the name of the automatic host endpoint for the named node.
+func AutoHostEndpointName(nodeName string) string {
+	return nodeName + autoHostEndpointSuffix
+}
+
+// HostEndpointForNode returns the automatic host endpoint for the node.
+func HostEndpointForNode(node *apiv3.Node) *apiv3.HostEndpoint {
+	hep := apiv3.NewHostEndpoint()
+	hep.Name = AutoHostEndpointName(node.Name)
+	if len(node.Labels) != 0 {
+		hep.Labels = make(map[string]string, len(node.Labels))
+		for k, v := range node.Labels {
+			hep.Labels[k] = v
+		}
+	}
+	hep.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: apiv3.GroupVersionCurrent,
+		Kind:       apiv3.KindNode,
+		Name:       node.Name,
+		UID:        node.UID,
+	}}
+	hep.Spec = apiv3.HostEndpointSpec{
+		Node:          node.Name,
+		InterfaceName: "*",
+		ExpectedIPs:   nodeIPs(node),
+	}
+	return hep
+}
+
+// nodeIPs returns the addresses of the node and its tunnels.
+func nodeIPs(node *apiv3.Node) []string {
+	var addrs []string
+	if bgp := node.Spec.BGP; bgp != nil {
+		addrs = append(addrs, bgp.IPv4Address, bgp.IPv6Address, bgp.IPv4IPIPTunnelAddr)
+	}
+	addrs = append(addrs, node.Spec.IPv4VXLANTunnelAddr)
+
+	var ips []string
+	seen := map[string]bool{}
+	for _, addr := range addrs {
+		if addr == "" {
+			continue
+		}
+		// The BGP addresses include the subnet, so strip that off.
+		ip, _, err := cnet.ParseCIDROrIP(addr)
+		if err != nil {
+			log.WithError(err).WithField("node", node.Name).Warnf("Ignoring invalid node address: %s", addr)
+			continue
+		}
+		if s := ip.String(); !seen[s] {
+			seen[s] = true
+			ips = append(ips, s)
+		}
+	}
+	return ips
+}
+
+// autoHostEndpointNode returns the name of the node that owns the automatic host endpoint, or an
+// empty string if the host endpoint is not an automatic host endpoint.
+func autoHostEndpointNode(hep *apiv3.HostEndpoint) string {
+	for _, ref := range hep.OwnerReferences {
+		if ref.Kind == apiv3.KindNode && hep.Name == AutoHostEndpointName(ref.Name) {
+			return ref.Name
+		}
+	}
+	if strings.HasSuffix(hep.Name, autoHostEndpointSuffix) {
+		log.WithField("hostEndpoint", hep.Name).Debug("Host endpoint is not owned by its node, ignoring")
+	}
+	return ""
+}
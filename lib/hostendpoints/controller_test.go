@@ -0,0 +1,314 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostendpoints_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	"github.com/unai-ttxu/libcalico-go/lib/clientv3"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+	"github.com/unai-ttxu/libcalico-go/lib/hostendpoints"
+	"github.com/unai-ttxu/libcalico-go/lib/options"
+	"github.com/unai-ttxu/libcalico-go/lib/watch"
+)
+
+// fakeClient is a Calico client that holds nodes and host endpoints in memory.  The node watch
+// returns the events sent on the events channel.
+type fakeClient struct {
+	clientv3.Interface
+
+	lock     sync.Mutex
+	revision int
+	nodes    []apiv3.Node
+	heps     map[string]apiv3.HostEndpoint
+	events   chan watch.Event
+}
+
+func newFakeClient(nodes ...*apiv3.Node) *fakeClient {
+	c := &fakeClient{heps: map[string]apiv3.HostEndpoint{}, events: make(chan watch.Event)}
+	for _, n := range nodes {
+		c.nodes = append(c.nodes, *n)
+	}
+	return c
+}
+
+func (c *fakeClient) Nodes() clientv3.NodeInterface {
+	return fakeNodes{client: c}
+}
+
+func (c *fakeClient) HostEndpoints() clientv3.HostEndpointInterface {
+	return fakeHostEndpoints{client: c}
+}
+
+// hep returns the named host endpoint, or nil if it does not exist.
+func (c *fakeClient) hep(name string) *apiv3.HostEndpoint {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if hep, ok := c.heps[name]; ok {
+		return &hep
+	}
+	return nil
+}
+
+// put stores the host endpoint at a new revision.
+func (c *fakeClient) put(hep apiv3.HostEndpoint) *apiv3.HostEndpoint {
+	c.revision++
+	hep.ResourceVersion = strconv.Itoa(c.revision)
+	c.heps[hep.Name] = hep
+	return &hep
+}
+
+type fakeNodes struct {
+	clientv3.NodeInterface
+	client *fakeClient
+}
+
+func (f fakeNodes) List(ctx context.Context, opts options.ListOptions) (*apiv3.NodeList, error) {
+	f.client.lock.Lock()
+	defer f.client.lock.Unlock()
+	list := apiv3.NewNodeList()
+	list.Items = append(list.Items, f.client.nodes...)
+	list.ResourceVersion = strconv.Itoa(f.client.revision)
+	return list, nil
+}
+
+func (f fakeNodes) Watch(ctx context.Context, opts options.ListOptions) (watch.Interface, error) {
+	return fakeWatch{events: f.client.events}, nil
+}
+
+type fakeWatch struct {
+	events chan watch.Event
+}
+
+func (w fakeWatch) Stop() {}
+
+func (w fakeWatch) ResultChan() <-chan watch.Event {
+	return w.events
+}
+
+type fakeHostEndpoints struct {
+	clientv3.HostEndpointInterface
+	client *fakeClient
+}
+
+func (f fakeHostEndpoints) Create(ctx context.Context, res *apiv3.HostEndpoint, opts options.SetOptions) (*apiv3.HostEndpoint, error) {
+	f.client.lock.Lock()
+	defer f.client.lock.Unlock()
+	if _, ok := f.client.heps[res.Name]; ok {
+		return nil, cerrors.ErrorResourceAlreadyExists{Identifier: res.Name}
+	}
+	return f.client.put(*res), nil
+}
+
+func (f fakeHostEndpoints) Update(ctx context.Context, res *apiv3.HostEndpoint, opts options.SetOptions) (*apiv3.HostEndpoint, error) {
+	f.client.lock.Lock()
+	defer f.client.lock.Unlock()
+	current, ok := f.client.heps[res.Name]
+	if !ok {
+		return nil, cerrors.ErrorResourceDoesNotExist{Identifier: res.Name}
+	} else if current.ResourceVersion != res.ResourceVersion {
+		return nil, cerrors.ErrorResourceUpdateConflict{Identifier: res.Name}
+	}
+	return f.client.put(*res), nil
+}
+
+func (f fakeHostEndpoints) Delete(ctx context.Context, name string, opts options.DeleteOptions) (*apiv3.HostEndpoint, error) {
+	f.client.lock.Lock()
+	defer f.client.lock.Unlock()
+	current, ok := f.client.heps[name]
+	if !ok {
+		return nil, cerrors.ErrorResourceDoesNotExist{Identifier: name}
+	} else if opts.ResourceVersion != "" && current.ResourceVersion != opts.ResourceVersion {
+		return nil, cerrors.ErrorResourceUpdateConflict{Identifier: name}
+	}
+	delete(f.client.heps, name)
+	return &current, nil
+}
+
+func (f fakeHostEndpoints) Get(ctx context.Context, name string, opts options.GetOptions) (*apiv3.HostEndpoint, error) {
+	if hep := f.client.hep(name); hep != nil {
+		return hep, nil
+	}
+	return nil, cerrors.ErrorResourceDoesNotExist{Identifier: name}
+}
+
+func (f fakeHostEndpoints) List(ctx context.Context, opts options.ListOptions) (*apiv3.HostEndpointList, error) {
+	f.client.lock.Lock()
+	defer f.client.lock.Unlock()
+	list := apiv3.NewHostEndpointList()
+	for _, hep := range f.client.heps {
+		list.Items = append(list.Items, hep)
+	}
+	return list, nil
+}
+
+var _ = Describe("Automatic host endpoints", func() {
+	It("should create a wildcard host endpoint for a node with no addresses", func() {
+		node := apiv3.NewNode()
+		node.Name = "node1"
+
+		hep := hostendpoints.HostEndpointForNode(node)
+		Expect(hep.Name).To(Equal("node1-auto-hep"))
+		Expect(hep.Labels).To(BeNil())
+		Expect(hep.Spec).To(Equal(apiv3.HostEndpointSpec{
+			Node:          "node1",
+			InterfaceName: "*",
+		}))
+	})
+
+	It("should copy the labels and addresses of the node", func() {
+		node := apiv3.NewNode()
+		node.Name = "node1"
+		node.UID = "abcd-1234"
+		node.Labels = map[string]string{"rack": "r1"}
+		node.Spec.BGP = &apiv3.NodeBGPSpec{
+			IPv4Address:        "10.0.0.1/24",
+			IPv6Address:        "fd00::1/64",
+			IPv4IPIPTunnelAddr: "192.168.10.1",
+		}
+		node.Spec.IPv4VXLANTunnelAddr = "192.168.20.1"
+
+		hep := hostendpoints.HostEndpointForNode(node)
+		Expect(hep.Labels).To(Equal(map[string]string{"rack": "r1"}))
+		Expect(hep.OwnerReferences).To(Equal([]metav1.OwnerReference{{
+			APIVersion: apiv3.GroupVersionCurrent,
+			Kind:       apiv3.KindNode,
+			Name:       "node1",
+			UID:        "abcd-1234",
+		}}))
+		Expect(hep.Spec).To(Equal(apiv3.HostEndpointSpec{
+			Node:          "node1",
+			InterfaceName: "*",
+			ExpectedIPs:   []string{"10.0.0.1", "fd00::1", "192.168.10.1", "192.168.20.1"},
+		}))
+
+		By("not sharing the labels map with the node")
+		hep.Labels["rack"] = "r2"
+		Expect(node.Labels["rack"]).To(Equal("r1"))
+	})
+
+	It("should ignore duplicate and invalid addresses", func() {
+		node := apiv3.NewNode()
+		node.Name = "node1"
+		node.Spec.BGP = &apiv3.NodeBGPSpec{
+			IPv4Address:        "10.0.0.1/24",
+			IPv4IPIPTunnelAddr: "10.0.0.1",
+		}
+		node.Spec.IPv4VXLANTunnelAddr = "not-an-ip"
+
+		hep := hostendpoints.HostEndpointForNode(node)
+		Expect(hep.Spec.ExpectedIPs).To(Equal([]string{"10.0.0.1"}))
+	})
+})
+
+var _ = Describe("Automatic host endpoint controller", func() {
+	ctx := context.Background()
+
+	newNode := func(name string, labels map[string]string) *apiv3.Node {
+		node := apiv3.NewNode()
+		node.Name = name
+		node.UID = types.UID("uid-" + name)
+		node.Labels = labels
+		return node
+	}
+	userHostEndpoint := func(name string) *apiv3.HostEndpoint {
+		hep := apiv3.NewHostEndpoint()
+		hep.Name = name
+		hep.Spec.Node = "node1"
+		hep.Spec.InterfaceName = "eth0"
+		return hep
+	}
+
+	It("should create, update and delete the automatic host endpoints on resync", func() {
+		client := newFakeClient(newNode("node1", map[string]string{"rack": "r1"}), newNode("node2", nil))
+		_, err := client.HostEndpoints().Create(ctx, hostendpoints.HostEndpointForNode(newNode("node1", nil)), options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.HostEndpoints().Create(ctx, hostendpoints.HostEndpointForNode(newNode("node3", nil)), options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.HostEndpoints().Create(ctx, userHostEndpoint("node1-eth0"), options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = hostendpoints.NewController(client).Resync(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(client.hep("node1-auto-hep").Labels).To(Equal(map[string]string{"rack": "r1"}))
+		Expect(client.hep("node2-auto-hep")).NotTo(BeNil())
+		Expect(client.hep("node3-auto-hep")).To(BeNil())
+		Expect(client.hep("node1-eth0")).NotTo(BeNil())
+	})
+
+	It("should not update a host endpoint that is up to date", func() {
+		client := newFakeClient()
+		c := hostendpoints.NewController(client)
+		node := newNode("node1", map[string]string{"rack": "r1"})
+		Expect(c.SyncNode(ctx, node)).To(Succeed())
+		revision := client.hep("node1-auto-hep").ResourceVersion
+
+		Expect(c.SyncNode(ctx, node)).To(Succeed())
+		Expect(client.hep("node1-auto-hep").ResourceVersion).To(Equal(revision))
+	})
+
+	It("should not overwrite or delete a host endpoint that is not owned by the node", func() {
+		client := newFakeClient()
+		c := hostendpoints.NewController(client)
+		_, err := client.HostEndpoints().Create(ctx, userHostEndpoint("node1-auto-hep"), options.SetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(c.SyncNode(ctx, newNode("node1", map[string]string{"rack": "r1"}))).To(Succeed())
+		hep := client.hep("node1-auto-hep")
+		Expect(hep.Labels).To(BeNil())
+		Expect(hep.Spec.InterfaceName).To(Equal("eth0"))
+
+		Expect(c.DeleteNode(ctx, "node1")).To(Succeed())
+		Expect(client.hep("node1-auto-hep")).NotTo(BeNil())
+
+		By("ignoring a node without a host endpoint")
+		Expect(c.DeleteNode(ctx, "node2")).To(Succeed())
+	})
+
+	It("should sync the host endpoints with the node events", func() {
+		client := newFakeClient(newNode("node1", nil))
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			hostendpoints.NewController(client).Run(ctx)
+		}()
+		Eventually(func() *apiv3.HostEndpoint { return client.hep("node1-auto-hep") }).ShouldNot(BeNil())
+
+		By("creating the host endpoint of an added node")
+		client.events <- watch.Event{Type: watch.Added, Object: newNode("node2", nil)}
+		Eventually(func() *apiv3.HostEndpoint { return client.hep("node2-auto-hep") }).ShouldNot(BeNil())
+
+		By("updating the host endpoint of a modified node")
+		client.events <- watch.Event{Type: watch.Modified, Previous: newNode("node2", nil), Object: newNode("node2", map[string]string{"rack": "r2"})}
+		Eventually(func() map[string]string { return client.hep("node2-auto-hep").Labels }).Should(Equal(map[string]string{"rack": "r2"}))
+
+		By("deleting the host endpoint of a deleted node")
+		client.events <- watch.Event{Type: watch.Deleted, Previous: newNode("node1", nil)}
+		Eventually(func() *apiv3.HostEndpoint { return client.hep("node1-auto-hep") }).Should(BeNil())
+
+		cancel()
+		close(client.events)
+		Eventually(done).Should(BeClosed())
+	})
+})
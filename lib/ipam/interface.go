@@ -36,6 +36,15 @@ type Interface interface {
 	// In case of error, returns the IPs allocated so far along with the error.
 	AutoAssign(ctx context.Context, args AutoAssignArgs) ([]cnet.IPNet, []cnet.IPNet, error)
 
+	// AutoAssignBatch automatically assigns IP addresses for each of the requests in the
+	// batch, on a single host.  Each block is updated at most once per attempt for the
+	// whole batch, and new blocks are claimed for the total demand of the batch, so this
+	// is much cheaper than calling AutoAssign for each request.  Returns a result for
+	// each request, in the same order as the requests.
+	//
+	// In case of error, returns the IPs allocated so far along with the error.
+	AutoAssignBatch(ctx context.Context, args AutoAssignBatchArgs) ([]AutoAssignResult, error)
+
 	// ReleaseIPs releases any of the given IP addresses that are currently assigned,
 	// so that they are available to be used in another assignment.
 	ReleaseIPs(ctx context.Context, ips []cnet.IP) ([]cnet.IP, error)
@@ -53,6 +62,11 @@ type Interface interface {
 	// are assigned with the given handle.
 	ReleaseByHandle(ctx context.Context, handleID string) error
 
+	// ReleaseByHandles releases all IP addresses that have been assigned using any
+	// of the provided handles.  Each block is updated at most once per attempt for all
+	// of the handles.  Returns a result for each handle, in the same order as the handles.
+	ReleaseByHandles(ctx context.Context, handleIDs []string) []ReleaseByHandleResult
+
 	// ClaimAffinity claims affinity to the given host for all blocks
 	// within the given CIDR.  The given CIDR must fall within a configured
 	// pool. If an empty string is passed as the host, then the value returned by os.Hostname is used.
@@ -76,13 +90,20 @@ type Interface interface {
 	// the specified pool across all hosts.
 	ReleasePoolAffinities(ctx context.Context, pool cnet.IPNet) error
 
+	// ReclaimBlocks releases the affinity of the empty blocks that hosts hold in excess of a
+	// retention count, so that the blocks can be claimed by other hosts, and migrates the
+	// affinity of sparsely used blocks to the host that uses them.  Blocks that are assigned
+	// from concurrently are not reclaimed.  Returns the number of blocks reclaimed and
+	// migrated in each pool.
+	ReclaimBlocks(ctx context.Context, args ReclaimBlocksArgs) ([]*PoolReclaim, error)
+
 	// GetIPAMConfig returns the global IPAM configuration.  If no IPAM configuration
 	// has been set, returns a default configuration with StrictAffinity disabled
 	// and AutoAllocateBlocks enabled.
 	GetIPAMConfig(ctx context.Context) (*IPAMConfig, error)
 
-	// SetIPAMConfig sets global IPAM configuration.  This can only
-	// be done when there are no allocated blocks and IP addresses.
+	// SetIPAMConfig sets global IPAM configuration.  StrictAffinity, AutoAllocateBlocks and
+	// HonorPodCIDRs can only be changed when there are no allocated blocks and IP addresses.
 	SetIPAMConfig(ctx context.Context, cfg IPAMConfig) error
 
 	// RemoveIPAMHost releases affinity for all blocks on the given host,
@@ -91,6 +112,16 @@ type Interface interface {
 	// If an empty string is passed as the host then the value returned by os.Hostname is used.
 	RemoveIPAMHost(ctx context.Context, host string) error
 
+	// BorrowedIPs returns the IP addresses that are assigned to a host other than the host
+	// that their block is affine to, so that the routes to them can be audited.  If host is
+	// not empty, only the addresses borrowed by that host are returned.
+	BorrowedIPs(ctx context.Context, host string) ([]BorrowedIP, error)
+
+	// AllocationHistory returns the events in the IPAM audit log that match the query,
+	// oldest first.  Events are only recorded while the audit log is enabled by the
+	// AuditRetention IPAM configuration, and only by the etcdv3 datastore.
+	AllocationHistory(ctx context.Context, args AllocationHistoryArgs) ([]AllocationEvent, error)
+
 	// GetUtilization returns IP utilization info for the specified pools, or for all pools.
 	GetUtilization(ctx context.Context, args GetUtilizationArgs) ([]*PoolUtilization, error)
 }
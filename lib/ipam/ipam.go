@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -279,6 +280,18 @@ func (c ipamClient) determinePools(requestedPoolNets []net.IPNet, version int, n
 }
 
 func (c ipamClient) autoAssign(ctx context.Context, num int, handleID *string, attrs map[string]string, requestedPools []net.IPNet, version int, host string, maxNumBlocks int) ([]net.IPNet, error) {
+	reqs := assignRequests{{handleID: handleID, attrs: attrs, num: num}}
+	return c.autoAssignRequests(ctx, reqs, requestedPools, version, host, maxNumBlocks)
+}
+
+// autoAssignRequests assigns addresses of the given IP version to each of the requests, from the
+// blocks of a single host.  The blocks are claimed and searched as for a single request, but each
+// block is updated once for all of the requests that it can satisfy.  The addresses assigned to each
+// request are recorded in the request.  Returns all of the addresses that were assigned.
+func (c ipamClient) autoAssignRequests(ctx context.Context, reqs assignRequests, requestedPools []net.IPNet, version int, host string, maxNumBlocks int) ([]net.IPNet, error) {
+	num := reqs.remaining()
+	handleID := reqs.handleID()
+
 	// Retrieve node for given hostname to use for ip pool node selection
 	node, err := c.client.Get(ctx, model.ResourceKey{Kind: v3.KindNode, Name: host}, "")
 	if err != nil {
@@ -303,6 +316,26 @@ func (c ipamClient) autoAssign(ctx context.Context, num int, handleID *string, a
 		return nil, fmt.Errorf("no configured Calico pools for node %v", host)
 	}
 
+	// Load the addresses that must not be automatically assigned.
+	reserved, err := c.getReservedIPs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the IPAM configuration, which determines whether we may assign from new or non-affine
+	// blocks, and for how long released addresses must cool down before they are reused.
+	config, err := c.GetIPAMConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// If we honour the nodes' pod CIDRs, make sure that the host has claimed its own pod CIDRs.
+	if config.HonorPodCIDRs {
+		if err := c.claimPodCIDRs(ctx, host, version, pools); err != nil {
+			return nil, err
+		}
+	}
+
 	// First, we try to assign addresses from one of the existing host-affine blocks.  We
 	// always do strict checking at this stage, so it doesn't matter whether
 	// globally we have strict_affinity or not.
@@ -360,6 +393,11 @@ func (c ipamClient) autoAssign(ctx context.Context, num int, handleID *string, a
 	}
 
 	logCtx.Debugf("Found %d affine IPv%d blocks for host: %v", len(affBlocks), version, affBlocks)
+	if !usesDefaultStrategy(pools) {
+		affBlocks = c.orderAffineBlocks(ctx, affBlocks, pools, handleID)
+		logCtx.Debugf("Ordered affine blocks by allocation strategy: %v", affBlocks)
+	}
+
 	ips := []net.IPNet{}
 	newIPs := []net.IPNet{}
 
@@ -398,7 +436,7 @@ func (c ipamClient) autoAssign(ctx context.Context, num int, handleID *string, a
 			}
 
 			// Assign IPs from the block.
-			newIPs, err = c.assignFromExistingBlock(ctx, b, num, handleID, attrs, host, true)
+			newIPs, err = c.assignFromExistingBlock(ctx, b, reqs, host, true, reservedForBlock(cidr, pools, reserved), strategyForBlock(cidr, pools), *config)
 			if err != nil {
 				if _, ok := err.(cerrors.ErrorResourceUpdateConflict); ok {
 					logCtx.WithError(err).Debug("CAS error assigning from affine block - retry")
@@ -417,12 +455,18 @@ func (c ipamClient) autoAssign(ctx context.Context, num int, handleID *string, a
 	// existing blocks with affinity to this host.  Before we can assign new blocks or assign in
 	// non-affine blocks, we need to check that our IPAM configuration
 	// allows that.
-	config, err := c.GetIPAMConfig(ctx)
-	if err != nil {
-		return ips, err
-	}
 	logCtx.Debugf("Allocate new blocks? Config: %+v", config)
 	if config.AutoAllocateBlocks == true {
+		// If we honour the nodes' pod CIDRs, don't claim new blocks that overlap the pod CIDRs of
+		// other hosts.
+		var foreign *net.CIDRSet
+		if config.HonorPodCIDRs && num > len(ips) {
+			foreign, err = c.foreignPodCIDRs(ctx, host, version)
+			if err != nil {
+				return ips, err
+			}
+		}
+
 		rem := num - len(ips)
 		retries := datastoreRetries
 		for rem > 0 && retries > 0 {
@@ -438,7 +482,7 @@ func (c ipamClient) autoAssign(ctx context.Context, num int, handleID *string, a
 
 			// First, try to find an unclaimed block.
 			logCtx.Info("Looking for an unclaimed block")
-			subnet, err := c.blockReaderWriter.findUnclaimedBlock(ctx, host, version, pools, *config)
+			subnet, err := c.blockReaderWriter.findUnclaimedBlock(ctx, host, version, pools, *config, reserved, foreign)
 			if err != nil {
 				if _, ok := err.(noFreeBlocksError); ok {
 					// No free blocks.  Break.
@@ -483,7 +527,7 @@ func (c ipamClient) autoAssign(ctx context.Context, num int, handleID *string, a
 				// Claim successful.  Assign addresses from the new block.
 				logCtx.Infof("Claimed new block %v - assigning %d addresses", b, rem)
 				numBlocksOwned++
-				newIPs, err := c.assignFromExistingBlock(ctx, b, rem, handleID, attrs, host, config.StrictAffinity)
+				newIPs, err := c.assignFromExistingBlock(ctx, b, reqs, host, config.StrictAffinity, reservedForBlock(*subnet, pools, reserved), strategyForBlock(*subnet, pools), *config)
 				if err != nil {
 					if _, ok := err.(cerrors.ErrorResourceUpdateConflict); ok {
 						log.WithError(err).Debug("CAS Error assigning from new block - retry")
@@ -527,7 +571,9 @@ func (c ipamClient) autoAssign(ctx context.Context, num int, handleID *string, a
 		logCtx.Info("Looking for blocks with free IP addresses")
 		for _, p := range pools {
 			logCtx.Debugf("Assigning from non-affine blocks in pool %s", p.Spec.CIDR)
-			newBlock := randomBlockGenerator(p, host)
+			strategy := strategyForPool(&p)
+			newBlock := strategy.blocks(p, host)
+			borrowFrom := map[string]bool{}
 			for rem > 0 {
 				// Grab a new random block.
 				blockCIDR := newBlock()
@@ -543,9 +589,26 @@ func (c ipamClient) autoAssign(ctx context.Context, num int, handleID *string, a
 						break
 					}
 
+					// Check that the pool allows us to borrow from the host that the block is affine to.
+					if owner := getHostAffinity(b.Value.(*model.AllocationBlock)); owner != "" && owner != host {
+						allowed, ok := borrowFrom[owner]
+						if !ok {
+							allowed, err = c.borrowingAllowed(ctx, &p, v3n, owner)
+							if err != nil {
+								logCtx.WithError(err).Warn("Failed to determine whether borrowing is allowed")
+								break
+							}
+							borrowFrom[owner] = allowed
+						}
+						if !allowed {
+							logCtx.Debugf("Pool %s does not allow borrowing from block %s of host %s", p.Spec.CIDR, blockCIDR, owner)
+							break
+						}
+					}
+
 					// Attempt to assign from the block.
 					logCtx.Infof("Attempting to assign IPs from non-affine block %s", blockCIDR.String())
-					newIPs, err := c.assignFromExistingBlock(ctx, b, rem, handleID, attrs, host, false)
+					newIPs, err := c.assignFromExistingBlock(ctx, b, reqs, host, false, reservedForBlock(*blockCIDR, pools, reserved), strategy, *config)
 					if err != nil {
 						if _, ok := err.(cerrors.ErrorResourceUpdateConflict); ok {
 							logCtx.WithError(err).Debug("CAS error assigning from non-affine block - retry")
@@ -590,6 +653,12 @@ func (c ipamClient) AssignIP(ctx context.Context, args AssignIPArgs) error {
 		return errors.New("The provided IP address is not in a configured pool\n")
 	}
 
+	cfg, err := c.GetIPAMConfig(ctx)
+	if err != nil {
+		log.Errorf("Error getting IPAM Config: %v", err)
+		return err
+	}
+
 	blockCIDR := getBlockCIDRForAddress(args.IP, pool)
 	log.Debugf("IP %s is in block '%s'", args.IP.String(), blockCIDR.String())
 	for i := 0; i < datastoreRetries; i++ {
@@ -601,11 +670,6 @@ func (c ipamClient) AssignIP(ctx context.Context, args AssignIPArgs) error {
 			}
 
 			log.Debugf("Block for IP %s does not yet exist, creating", args.IP)
-			cfg, err := c.GetIPAMConfig(ctx)
-			if err != nil {
-				log.Errorf("Error getting IPAM Config: %v", err)
-				return err
-			}
 
 			pa, err := c.blockReaderWriter.getPendingAffinity(ctx, hostname, blockCIDR)
 			if err != nil {
@@ -661,6 +725,7 @@ func (c ipamClient) AssignIP(ctx context.Context, args AssignIPArgs) error {
 			}
 			return err
 		}
+		c.recordEvents(ctx, cfg.AuditRetention, block.allocationEvents(model.AllocationEventAssign, []net.IP{args.IP}, hostname, time.Now().UnixNano()))
 		return nil
 	}
 	return errors.New("Max retries hit - excessive concurrent IPAM requests")
@@ -725,6 +790,14 @@ func (c ipamClient) ReleaseIPs(ctx context.Context, ips []net.IP) ([]net.IP, err
 
 func (c ipamClient) releaseIPsFromBlock(ctx context.Context, ips []net.IP, blockCIDR net.IPNet) ([]net.IP, error) {
 	logCtx := log.WithField("cidr", blockCIDR)
+	config, err := c.GetIPAMConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	record, err := c.recordReleases(config, blockCIDR)
+	if err != nil {
+		return nil, err
+	}
 	for i := 0; i < datastoreRetries; i++ {
 		logCtx.Info("Getting block so we can release IPs")
 
@@ -742,7 +815,8 @@ func (c ipamClient) releaseIPsFromBlock(ctx context.Context, ips []net.IP, block
 
 		// Release the IPs.
 		b := allocationBlock{obj.Value.(*model.AllocationBlock)}
-		unallocated, handles, err2 := b.release(ips)
+		events := b.allocationEvents(model.AllocationEventRelease, ips, "", time.Now().UnixNano())
+		unallocated, handles, err2 := b.release(ips, record)
 		if err2 != nil {
 			return nil, err2
 		}
@@ -778,7 +852,8 @@ func (c ipamClient) releaseIPsFromBlock(ctx context.Context, ips []net.IP, block
 			}
 		}
 
-		// Success - decrement handles.
+		// Success - record the releases and decrement handles.
+		c.recordEvents(ctx, config.AuditRetention, events)
 		logCtx.Debugf("Decrementing handles: %v", handles)
 		for handleID, amount := range handles {
 			if err := c.decrementHandle(ctx, handleID, blockCIDR, amount); err != nil {
@@ -795,52 +870,180 @@ func (c ipamClient) releaseIPsFromBlock(ctx context.Context, ips []net.IP, block
 	return nil, errors.New("Max retries hit - excessive concurrent IPAM requests")
 }
 
-func (c ipamClient) assignFromExistingBlock(ctx context.Context, block *model.KVPair, num int, handleID *string, attrs map[string]string, host string, affCheck bool) ([]net.IPNet, error) {
+func (c ipamClient) assignFromExistingBlock(ctx context.Context, block *model.KVPair, reqs assignRequests, host string, affCheck bool, reserved *net.CIDRSet, strategy allocationStrategy, config IPAMConfig) ([]net.IPNet, error) {
 	blockCIDR := block.Key.(model.BlockKey).CIDR
 	logCtx := log.WithFields(log.Fields{"host": host, "block": blockCIDR})
-	if handleID != nil {
+	if handleID := reqs.handleID(); handleID != nil {
 		logCtx = logCtx.WithField("handle", *handleID)
 	}
-	logCtx.Infof("Attempting to assign %d addresses from block", num)
+	logCtx.Infof("Attempting to assign %d addresses from block", reqs.remaining())
 
 	// Pull out the block.
 	b := allocationBlock{block.Value.(*model.AllocationBlock)}
 
-	ips, err := b.autoAssign(num, handleID, host, attrs, affCheck)
-	if err != nil {
-		logCtx.WithError(err).Errorf("Error in auto assign")
-		return nil, err
+	// Assign as many addresses as we can to each request in turn, so that the block only needs to be
+	// written once.
+	ips := []net.IPNet{}
+	assigned := make([][]net.IPNet, len(reqs))
+	for i, r := range reqs {
+		rem := r.remaining()
+		if rem == 0 {
+			continue
+		}
+		newIPs, err := b.autoAssign(rem, r.handleID, host, r.attrs, affCheck, reserved, strategy, config.ReleaseCooldown)
+		if err != nil {
+			logCtx.WithError(err).Errorf("Error in auto assign")
+			return nil, err
+		}
+		assigned[i] = newIPs
+		ips = append(ips, newIPs...)
+		if len(newIPs) < rem {
+			break
+		}
 	}
 	if len(ips) == 0 {
 		logCtx.Infof("Block is full")
 		return []net.IPNet{}, nil
 	}
 
-	// Increment handle count.
-	if handleID != nil {
-		logCtx.Debug("Incrementing handle")
-		c.incrementHandle(ctx, *handleID, blockCIDR, num)
+	// Increment handle counts.
+	for i, r := range reqs {
+		if r.handleID != nil && len(assigned[i]) != 0 {
+			logCtx.WithField("handle", *r.handleID).Debug("Incrementing handle")
+			c.incrementHandle(ctx, *r.handleID, blockCIDR, len(assigned[i]))
+		}
 	}
 
 	// Update the block using CAS by passing back the original
 	// KVPair.
 	logCtx.Info("Writing block in order to claim IPs")
 	block.Value = b.AllocationBlock
-	_, err = c.blockReaderWriter.updateBlock(ctx, block)
+	_, err := c.blockReaderWriter.updateBlock(ctx, block)
 	if err != nil {
 		logCtx.WithError(err).Infof("Failed to update block")
-		if handleID != nil {
-			logCtx.Debug("Decrementing handle since we failed to allocate IP(s)")
-			if err := c.decrementHandle(ctx, *handleID, blockCIDR, num); err != nil {
-				logCtx.WithError(err).Warnf("Failed to decrement handle")
+		for i, r := range reqs {
+			if r.handleID != nil && len(assigned[i]) != 0 {
+				logCtx.WithField("handle", *r.handleID).Debug("Decrementing handle since we failed to allocate IP(s)")
+				if err := c.decrementHandle(ctx, *r.handleID, blockCIDR, len(assigned[i])); err != nil {
+					logCtx.WithError(err).Warnf("Failed to decrement handle")
+				}
 			}
 		}
 		return nil, err
 	}
+	now := time.Now().UnixNano()
+	for i, r := range reqs {
+		r.ips = append(r.ips, assigned[i]...)
+		addrs := make([]net.IP, 0, len(assigned[i]))
+		for _, ipNet := range assigned[i] {
+			addrs = append(addrs, net.IP{IP: ipNet.IP})
+		}
+		c.recordEvents(ctx, config.AuditRetention, b.allocationEvents(model.AllocationEventAssign, addrs, host, now))
+	}
 	logCtx.Infof("Successfully claimed IPs: %v", ips)
 	return ips, nil
 }
 
+// orderAffineBlocks returns the host's affine blocks in the order in which addresses should be assigned
+// from them, according to the allocation strategies of their pools.
+func (c ipamClient) orderAffineBlocks(ctx context.Context, cidrs []net.IPNet, pools []v3.IPPool, handleID *string) []net.IPNet {
+	blocks := make([]*allocationBlock, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		kvp, err := c.blockReaderWriter.queryBlock(ctx, cidr, "")
+		if err != nil {
+			// The block may not have been created yet, in which case it has no allocations.
+			if _, ok := err.(cerrors.ErrorResourceDoesNotExist); !ok {
+				log.WithError(err).WithField("block", cidr).Warn("Failed to read affine block, treating as empty")
+			}
+			b := newBlock(cidr)
+			blocks = append(blocks, &b)
+			continue
+		}
+		blocks = append(blocks, &allocationBlock{kvp.Value.(*model.AllocationBlock)})
+	}
+	orderBlocks(blocks, pools, handleID)
+
+	ordered := make([]net.IPNet, 0, len(blocks))
+	for _, b := range blocks {
+		ordered = append(ordered, b.CIDR)
+	}
+	return ordered
+}
+
+// borrowingAllowed returns true if the borrowing policy of the pool allows the node to borrow
+// addresses from a block of the pool that is affine to the owner host.
+func (c ipamClient) borrowingAllowed(ctx context.Context, pool *v3.IPPool, node *v3.Node, owner string) (bool, error) {
+	switch pool.Spec.BorrowingPolicy {
+	case v3.BorrowingPolicyNever:
+		return false, nil
+	case v3.BorrowingPolicySameZone:
+		zone, ok := node.Labels[v3.LabelZone]
+		if !ok {
+			return false, nil
+		}
+		kvp, err := c.client.Get(ctx, model.ResourceKey{Kind: v3.KindNode, Name: owner}, "")
+		if err != nil {
+			if _, ok := err.(cerrors.ErrorResourceDoesNotExist); ok {
+				return false, nil
+			}
+			return false, err
+		}
+		ownerZone, ok := kvp.Value.(*v3.Node).Labels[v3.LabelZone]
+		return ok && ownerZone == zone, nil
+	}
+	return true, nil
+}
+
+// BorrowedIPs returns the addresses that are assigned to a host other than the host that their
+// block is affine to.  Such addresses need their own routes.  If host is not empty, only the
+// addresses borrowed by that host are returned.
+//
+// Only addresses with a node attribute are returned, since the host of other addresses is not
+// known.  Calico IPAM sets the node attribute whenever it assigns a borrowed address.
+func (c ipamClient) BorrowedIPs(ctx context.Context, host string) ([]BorrowedIP, error) {
+	blocks, err := c.client.List(ctx, model.BlockListOptions{}, "")
+	if err != nil {
+		return nil, err
+	}
+	borrowed := []BorrowedIP{}
+	for _, kvp := range blocks.KVPairs {
+		b := allocationBlock{kvp.Value.(*model.AllocationBlock)}
+		for _, ip := range b.borrowedIPs() {
+			if host == "" || ip.Host == host {
+				borrowed = append(borrowed, ip)
+			}
+		}
+	}
+	return borrowed, nil
+}
+
+// getReservedIPs returns the set of addresses that have been excluded from automatic assignment
+// by IPReservation resources.
+func (c ipamClient) getReservedIPs(ctx context.Context) (*net.CIDRSet, error) {
+	reserved := net.NewCIDRSet()
+	kvps, err := c.client.List(ctx, model.ResourceListOptions{Kind: v3.KindIPReservation}, "")
+	if err != nil {
+		if _, ok := err.(cerrors.ErrorResourceDoesNotExist); ok {
+			// The datastore doesn't support reservations, so there aren't any.
+			return reserved, nil
+		}
+		log.WithError(err).Error("Failed to list IP reservations")
+		return nil, err
+	}
+	for _, kvp := range kvps.KVPairs {
+		res := kvp.Value.(*v3.IPReservation)
+		for _, cidr := range res.Spec.ReservedCIDRs {
+			_, ipNet, err := net.ParseCIDROrIP(cidr)
+			if err != nil {
+				log.WithError(err).WithField("reservation", res.Name).Warnf("Ignoring invalid reserved CIDR %s", cidr)
+				continue
+			}
+			reserved.Add(*ipNet)
+		}
+	}
+	return reserved, nil
+}
+
 // ClaimAffinity makes a best effort to claim affinity to the given host for all blocks
 // within the given CIDR.  The given CIDR must fall within a configured
 // pool.  Returns a list of blocks that were claimed, as well as a
@@ -1179,8 +1382,36 @@ func (c ipamClient) ReleaseByHandle(ctx context.Context, handleID string) error
 	return nil
 }
 
+// recordReleases returns true if the releases of addresses from the block need to be recorded in the
+// block, because the release cooldown is enabled or the allocation strategy of the block's pool uses
+// them.
+func (c ipamClient) recordReleases(config *IPAMConfig, blockCIDR net.IPNet) (bool, error) {
+	if config.ReleaseCooldown > 0 {
+		return true, nil
+	}
+	pools, err := c.pools.GetAllPools()
+	if err != nil {
+		return false, err
+	}
+	return strategyForBlock(blockCIDR, pools).usesReleases(), nil
+}
+
 func (c ipamClient) releaseByHandle(ctx context.Context, handleID string, blockCIDR net.IPNet) error {
-	logCtx := log.WithFields(log.Fields{"handle": handleID, "cidr": blockCIDR})
+	return c.releaseByHandles(ctx, []string{handleID}, blockCIDR)
+}
+
+// releaseByHandles releases the addresses of all of the given handles from the block, with a single
+// update of the block.
+func (c ipamClient) releaseByHandles(ctx context.Context, handleIDs []string, blockCIDR net.IPNet) error {
+	logCtx := log.WithFields(log.Fields{"handles": handleIDs, "cidr": blockCIDR})
+	config, err := c.GetIPAMConfig(ctx)
+	if err != nil {
+		return err
+	}
+	record, err := c.recordReleases(config, blockCIDR)
+	if err != nil {
+		return err
+	}
 	for i := 0; i < datastoreRetries; i++ {
 		logCtx.Debug("Querying block so we can release IPs by handle")
 		obj, err := c.blockReaderWriter.queryBlock(ctx, blockCIDR, "")
@@ -1195,16 +1426,26 @@ func (c ipamClient) releaseByHandle(ctx context.Context, handleID string, blockC
 			}
 		}
 
-		// Release the IP by handle.
+		// Release the IPs by handle.
 		block := allocationBlock{obj.Value.(*model.AllocationBlock)}
-		num := block.releaseByHandle(handleID)
-		if num == 0 {
-			// Block has no addresses with this handle, so
+		released := map[string]int{}
+		total := 0
+		var events []model.IPAMEvent
+		now := time.Now().UnixNano()
+		for _, handleID := range handleIDs {
+			events = append(events, block.allocationEvents(model.AllocationEventRelease, block.ipsByHandle(handleID), "", now)...)
+			if num := block.releaseByHandle(handleID, record); num != 0 {
+				released[handleID] = num
+				total += num
+			}
+		}
+		if total == 0 {
+			// Block has no addresses with these handles, so
 			// all addresses are already unallocated.
-			logCtx.Debug("Block has no addresses with the given handle")
+			logCtx.Debug("Block has no addresses with the given handles")
 			return nil
 		}
-		logCtx.Debugf("Block has %d IPs with the given handle", num)
+		logCtx.Debugf("Block has %d IPs with the given handles", total)
 
 		if block.empty() && block.Affinity == nil {
 			logCtx.Info("Deleting block because it is now empty and has no affinity")
@@ -1241,8 +1482,11 @@ func (c ipamClient) releaseByHandle(ctx context.Context, handleID string, blockC
 			}
 			logCtx.Debug("Successfully released IPs from block")
 		}
-		if err = c.decrementHandle(ctx, handleID, blockCIDR, num); err != nil {
-			logCtx.WithError(err).Warn("Failed to decrement handle")
+		c.recordEvents(ctx, config.AuditRetention, events)
+		for handleID, num := range released {
+			if err = c.decrementHandle(ctx, handleID, blockCIDR, num); err != nil {
+				logCtx.WithError(err).WithField("handle", handleID).Warn("Failed to decrement handle")
+			}
 		}
 
 		// Determine whether or not the block's pool still matches the node.
@@ -1390,8 +1634,8 @@ func (c ipamClient) GetIPAMConfig(ctx context.Context) (*IPAMConfig, error) {
 	return c.convertBackendToIPAMConfig(obj.Value.(*model.IPAMConfig)), nil
 }
 
-// SetIPAMConfig sets global IPAM configuration.  This can only
-// be done when there are no allocated blocks and IP addresses.
+// SetIPAMConfig sets global IPAM configuration.  StrictAffinity, AutoAllocateBlocks and
+// HonorPodCIDRs can only be changed when there are no allocated blocks and IP addresses.
 func (c ipamClient) SetIPAMConfig(ctx context.Context, cfg IPAMConfig) error {
 	current, err := c.GetIPAMConfig(ctx)
 	if err != nil {
@@ -1406,9 +1650,36 @@ func (c ipamClient) SetIPAMConfig(ctx context.Context, cfg IPAMConfig) error {
 		return errors.New("Cannot disable 'StrictAffinity' and 'AutoAllocateBlocks' at the same time")
 	}
 
-	allObjs, err := c.client.List(ctx, model.BlockListOptions{}, "")
-	if len(allObjs.KVPairs) != 0 {
-		return errors.New("Cannot change IPAM config while allocations exist")
+	if cfg.ReleaseCooldown < 0 {
+		return errors.New("'ReleaseCooldown' must not be negative")
+	}
+
+	if cfg.AuditRetention < 0 {
+		return errors.New("'AuditRetention' must not be negative")
+	}
+
+	if cfg.AuditRetention > 0 {
+		// The audit log is not supported by every datastore.  Probe for support with a Get of an
+		// event, which fails with ErrorOperationNotSupported if the datastore cannot store events.
+		key := model.IPAMEventKey{IP: net.MustParseIP("0.0.0.0"), Type: model.AllocationEventAssign}
+		_, err := c.client.Get(ctx, key, "")
+		if _, ok := err.(cerrors.ErrorOperationNotSupported); ok {
+			return errors.New("'AuditRetention' is not supported by the datastore")
+		}
+	}
+
+	// The release cooldown and audit retention only affect future assignments, so they may be
+	// changed at any time.
+	if cfg.StrictAffinity != current.StrictAffinity ||
+		cfg.AutoAllocateBlocks != current.AutoAllocateBlocks ||
+		cfg.HonorPodCIDRs != current.HonorPodCIDRs {
+		allObjs, err := c.client.List(ctx, model.BlockListOptions{}, "")
+		if err != nil {
+			return err
+		}
+		if len(allObjs.KVPairs) != 0 {
+			return errors.New("Cannot change IPAM config while allocations exist")
+		}
 	}
 
 	// Write to datastore.
@@ -1428,6 +1699,9 @@ func (c ipamClient) convertIPAMConfigToBackend(cfg *IPAMConfig) *model.IPAMConfi
 	return &model.IPAMConfig{
 		StrictAffinity:     cfg.StrictAffinity,
 		AutoAllocateBlocks: cfg.AutoAllocateBlocks,
+		ReleaseCooldown:    cfg.ReleaseCooldown,
+		AuditRetention:     cfg.AuditRetention,
+		HonorPodCIDRs:      cfg.HonorPodCIDRs,
 	}
 }
 
@@ -1435,6 +1709,9 @@ func (c ipamClient) convertBackendToIPAMConfig(cfg *model.IPAMConfig) *IPAMConfi
 	return &IPAMConfig{
 		StrictAffinity:     cfg.StrictAffinity,
 		AutoAllocateBlocks: cfg.AutoAllocateBlocks,
+		ReleaseCooldown:    cfg.ReleaseCooldown,
+		AuditRetention:     cfg.AuditRetention,
+		HonorPodCIDRs:      cfg.HonorPodCIDRs,
 	}
 }
 
@@ -1538,6 +1815,20 @@ func (c ipamClient) GetUtilization(ctx context.Context, args GetUtilizationArgs)
 		return nil, err
 	}
 
+	// Read the reservations, which are reported separately from the available addresses.
+	reserved, err := c.getReservedIPs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the IPAM configuration, since addresses within their release cooldown are also
+	// reported separately.
+	config, err := c.GetIPAMConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixNano()
+
 	// Identify the ones we want and create a PoolUtilization for each of those.
 	wantAllPools := len(args.Pools) == 0
 	wantedPools := set.FromArray(args.Pools)
@@ -1545,10 +1836,15 @@ func (c ipamClient) GetUtilization(ctx context.Context, args GetUtilizationArgs)
 		if wantAllPools ||
 			wantedPools.Contains(pool.Name) ||
 			wantedPools.Contains(pool.Spec.CIDR) {
-			usage = append(usage, &PoolUtilization{
+			poolCIDR := net.MustParseNetwork(pool.Spec.CIDR)
+			poolUse := &PoolUtilization{
 				Name: pool.Name,
-				CIDR: net.MustParseNetwork(pool.Spec.CIDR).IPNet,
-			})
+				CIDR: poolCIDR.IPNet,
+			}
+			for _, n := range reserved.Intersect(net.NewCIDRSet(poolCIDR)).Nets() {
+				poolUse.Reserved = append(poolUse.Reserved, n.IPNet)
+			}
+			usage = append(usage, poolUse)
 		}
 	}
 
@@ -1564,22 +1860,34 @@ func (c ipamClient) GetUtilization(ctx context.Context, args GetUtilizationArgs)
 	}
 
 	// Read all allocation blocks.
-	blocks, err := c.client.List(ctx, model.BlockListOptions{}, "")
+	blocks, err := c.listBlocks(ctx)
 	if err != nil {
 		return nil, err
 	}
-	for _, kvp := range blocks.KVPairs {
-		b := kvp.Value.(*model.AllocationBlock)
+	for _, b := range blocks {
 		log.Debugf("Got block: %v", b)
 
 		// Find which pool this block belongs to.
 		for _, poolUse := range usage {
 			if b.CIDR.IsNetOverlap(poolUse.CIDR) {
 				log.Debugf("Block CIDR %v belongs to pool %v", b.CIDR, poolUse.Name)
+				blockReserved := reservedForBlock(b.CIDR, allPools, reserved)
+				numReserved := b.numReservedAddresses(blockReserved)
+				numCoolingDown := b.numCoolingDownAddresses(blockReserved, now, config.ReleaseCooldown)
+				borrowed := b.borrowedIPs()
+				for _, ip := range borrowed {
+					if poolUse.BorrowedByHost == nil {
+						poolUse.BorrowedByHost = map[string]int{}
+					}
+					poolUse.BorrowedByHost[ip.Host]++
+				}
 				poolUse.Blocks = append(poolUse.Blocks, BlockUtilization{
-					CIDR:      b.CIDR.IPNet,
-					Capacity:  b.NumAddresses(),
-					Available: len(b.Unallocated),
+					CIDR:        b.CIDR.IPNet,
+					Capacity:    b.NumAddresses(),
+					Available:   b.numFreeAddresses() - numReserved - numCoolingDown,
+					Reserved:    numReserved,
+					CoolingDown: numCoolingDown,
+					Borrowed:    len(borrowed),
 				})
 				break
 			}
@@ -0,0 +1,176 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipam
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	"github.com/unai-ttxu/libcalico-go/lib/net"
+)
+
+// assignRequest tracks the addresses of a single IP version that have been assigned to one request
+// in a batch.
+type assignRequest struct {
+	handleID *string
+	attrs    map[string]string
+	num      int
+	ips      []net.IPNet
+}
+
+// remaining returns the number of addresses that still need to be assigned for the request.
+func (r *assignRequest) remaining() int {
+	return r.num - len(r.ips)
+}
+
+// assignRequests is a batch of requests that are satisfied together.
+type assignRequests []*assignRequest
+
+// remaining returns the total number of addresses that still need to be assigned for the batch.
+func (rs assignRequests) remaining() int {
+	num := 0
+	for _, r := range rs {
+		num += r.remaining()
+	}
+	return num
+}
+
+// handleID returns the handle of the request, if the batch consists of a single request, and
+// nil otherwise.
+func (rs assignRequests) handleID() *string {
+	if len(rs) == 1 {
+		return rs[0].handleID
+	}
+	return nil
+}
+
+// AutoAssignBatch automatically assigns IP addresses for each of the requests in the batch, on a
+// single host.  Each block is updated at most once per attempt for the whole batch, and new blocks
+// are claimed for the total demand of the batch.  Returns a result for each request, in the same
+// order as the requests.
+//
+// In case of error, returns the IPs allocated so far along with the error.  The error is also
+// recorded in the result of each request that was not fully satisfied.
+func (c ipamClient) AutoAssignBatch(ctx context.Context, args AutoAssignBatchArgs) ([]AutoAssignResult, error) {
+	hostname, err := decideHostname(args.Hostname)
+	if err != nil {
+		return nil, err
+	}
+	for _, pool := range args.IPv4Pools {
+		if pool.IP.To4() == nil {
+			return nil, fmt.Errorf("provided IPv4 IPPools list contains one or more IPv6 IPPools")
+		}
+	}
+	for _, pool := range args.IPv6Pools {
+		if pool.IP.To4() != nil {
+			return nil, fmt.Errorf("provided IPv6 IPPools list contains one or more IPv4 IPPools")
+		}
+	}
+
+	v4reqs := make(assignRequests, len(args.Requests))
+	v6reqs := make(assignRequests, len(args.Requests))
+	for i, r := range args.Requests {
+		v4reqs[i] = &assignRequest{handleID: r.HandleID, attrs: r.Attrs, num: r.Num4}
+		v6reqs[i] = &assignRequest{handleID: r.HandleID, attrs: r.Attrs, num: r.Num6}
+	}
+	log.Infof("Auto-assign %d ipv4, %d ipv6 addrs for %d requests on host '%s'",
+		v4reqs.remaining(), v6reqs.remaining(), len(args.Requests), hostname)
+
+	results := func(err error) []AutoAssignResult {
+		results := make([]AutoAssignResult, len(args.Requests))
+		for i, r := range args.Requests {
+			results[i] = AutoAssignResult{HandleID: r.HandleID, IPv4: v4reqs[i].ips, IPv6: v6reqs[i].ips}
+			if v4reqs[i].remaining() != 0 || v6reqs[i].remaining() != 0 {
+				results[i].Error = err
+			}
+		}
+		return results
+	}
+
+	if v4reqs.remaining() != 0 {
+		log.Debugf("Assigning IPv4 addresses")
+		if _, err := c.autoAssignRequests(ctx, v4reqs, args.IPv4Pools, 4, hostname, args.MaxBlocksPerHost); err != nil {
+			log.Errorf("Error assigning IPV4 addresses: %v", err)
+			return results(err), err
+		}
+	}
+	if v6reqs.remaining() != 0 {
+		log.Debugf("Assigning IPv6 addresses")
+		if _, err := c.autoAssignRequests(ctx, v6reqs, args.IPv6Pools, 6, hostname, args.MaxBlocksPerHost); err != nil {
+			log.Errorf("Error assigning IPV6 addresses: %v", err)
+			return results(err), err
+		}
+	}
+	return results(nil), nil
+}
+
+// ReleaseByHandles releases all IP addresses that have been assigned using any of the provided
+// handles.  The handles' addresses are grouped by block, so that each block is updated at most once
+// per attempt.  Returns a result for each handle, in the same order as the handles.
+func (c ipamClient) ReleaseByHandles(ctx context.Context, handleIDs []string) []ReleaseByHandleResult {
+	log.Infof("Releasing all IPs with %d handles", len(handleIDs))
+
+	// Determine which blocks each handle has addresses in.
+	errs := map[string]error{}
+	blockHandles := map[string][]string{}
+	for _, handleID := range handleIDs {
+		if _, ok := errs[handleID]; ok {
+			// Duplicate handle.
+			continue
+		}
+		errs[handleID] = nil
+		obj, err := c.blockReaderWriter.queryHandle(ctx, handleID, "")
+		if err != nil {
+			errs[handleID] = err
+			continue
+		}
+		handle := allocationHandle{obj.Value.(*model.IPAMHandle)}
+		for blockStr := range handle.Block {
+			blockHandles[blockStr] = append(blockHandles[blockStr], handleID)
+		}
+	}
+
+	// Release the addresses from each block in turn.
+	blocks := make([]string, 0, len(blockHandles))
+	for blockStr := range blockHandles {
+		blocks = append(blocks, blockStr)
+	}
+	sort.Strings(blocks)
+	for _, blockStr := range blocks {
+		handles := blockHandles[blockStr]
+		_, blockCIDR, err := net.ParseCIDR(blockStr)
+		if err == nil {
+			err = c.releaseByHandles(ctx, handles, *blockCIDR)
+		}
+		if err != nil {
+			log.WithError(err).WithField("cidr", blockStr).Warn("Failed to release IPs by handle")
+			for _, handleID := range handles {
+				if errs[handleID] == nil {
+					errs[handleID] = err
+				}
+			}
+		}
+	}
+
+	results := make([]ReleaseByHandleResult, len(handleIDs))
+	for i, handleID := range handleIDs {
+		results[i] = ReleaseByHandleResult{HandleID: handleID, Error: errs[handleID]}
+	}
+	return results
+}
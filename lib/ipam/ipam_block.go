@@ -21,6 +21,7 @@ import (
 	"net"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/unai-ttxu/libcalico-go/lib/apis/v3"
 	log "github.com/sirupsen/logrus"
@@ -52,8 +53,12 @@ func newBlock(cidr cnet.IPNet) allocationBlock {
 	return allocationBlock{&b}
 }
 
+// autoAssign assigns up to num addresses from the block, in the order chosen by the allocation
+// strategy (which defaults to the order in which the addresses became free if nil).  Addresses in the
+// reserved set are skipped and left unallocated, so that they may still be assigned explicitly.
 func (b *allocationBlock) autoAssign(
-	num int, handleID *string, host string, attrs map[string]string, affinityCheck bool) ([]cnet.IPNet, error) {
+	num int, handleID *string, host string, attrs map[string]string, affinityCheck bool, reserved *cnet.CIDRSet, strategy allocationStrategy,
+	cooldown time.Duration) ([]cnet.IPNet, error) {
 
 	// Determine if we need to check for affinity.
 	checkAffinity := b.StrictAffinity || affinityCheck
@@ -69,12 +74,34 @@ func (b *allocationBlock) autoAssign(
 		}
 	}
 
-	// Walk the allocations until we find enough addresses.
+	// Record the host against addresses that it borrows from another host's block, so that the
+	// borrowed addresses can be identified.
+	if owner := getHostAffinity(b.AllocationBlock); owner != "" && owner != host {
+		attrs = borrowerAttributes(attrs, host)
+	}
+
+	// Walk the free addresses in the order chosen by the strategy until we find enough addresses.
+	if strategy == nil {
+		strategy = randomStrategy{}
+	}
+	checkReserved := reserved != nil && reserved.Overlaps(b.CIDR)
+	now := time.Now().UnixNano()
 	ordinals := []int{}
-	for len(b.Unallocated) > 0 && len(ordinals) < num {
-		ordinals = append(ordinals, b.Unallocated[0])
-		b.Unallocated = b.Unallocated[1:]
+	for _, o := range strategy.ordinals(b, handleID) {
+		if len(ordinals) == num {
+			break
+		}
+		if checkReserved && reserved.ContainsIP(b.OrdinalToIP(o)) {
+			log.Debugf("Block %s skipping reserved ordinal %d", b.CIDR.String(), o)
+			continue
+		}
+		if b.coolingDown(o, now, cooldown) {
+			log.Debugf("Block %s skipping recently released ordinal %d", b.CIDR.String(), o)
+			continue
+		}
+		ordinals = append(ordinals, o)
 	}
+	b.removeUnallocated(ordinals)
 
 	// Create slice of IPs and perform the allocations.
 	ips := []cnet.IPNet{}
@@ -82,6 +109,7 @@ func (b *allocationBlock) autoAssign(
 	for _, o := range ordinals {
 		attrIndex := b.findOrAddAttribute(handleID, attrs)
 		b.Allocations[o] = &attrIndex
+		delete(b.Released, o)
 		ipNets := cnet.IPNet(*mask)
 		ipNets.IP = cnet.IncrementIP(cnet.IP{b.CIDR.IP}, big.NewInt(int64(o))).IP
 		ips = append(ips, ipNets)
@@ -117,15 +145,76 @@ func (b *allocationBlock) assign(address cnet.IP, handleID *string, attrs map[st
 	// Set up attributes.
 	attrIndex := b.findOrAddAttribute(handleID, attrs)
 	b.Allocations[ordinal] = &attrIndex
+	delete(b.Released, ordinal)
 
 	// Remove from unallocated.
-	for i, unallocated := range b.Unallocated {
-		if unallocated == ordinal {
-			b.Unallocated = append(b.Unallocated[:i], b.Unallocated[i+1:]...)
-			break
+	b.removeUnallocated([]int{ordinal})
+	return nil
+}
+
+// removeUnallocated removes the ordinals from the list of unallocated ordinals, preserving the order
+// of the remaining ordinals.
+func (b *allocationBlock) removeUnallocated(ordinals []int) {
+	if len(ordinals) == 0 {
+		return
+	}
+	remove := map[int]bool{}
+	for _, o := range ordinals {
+		remove[o] = true
+	}
+	unallocated := make([]int, 0, len(b.Unallocated))
+	for _, o := range b.Unallocated {
+		if !remove[o] {
+			unallocated = append(unallocated, o)
 		}
 	}
-	return nil
+	b.Unallocated = unallocated
+}
+
+// markReleased records the release of the ordinal, and the handle that held it.  If record is not
+// set, any previous record for the ordinal is removed instead, so that the block only carries release
+// records while a cooldown or an allocation strategy needs them.
+func (b *allocationBlock) markReleased(ordinal int, handleID *string, now int64, record bool) {
+	if !record {
+		delete(b.Released, ordinal)
+		if len(b.Released) == 0 {
+			b.Released = nil
+		}
+		return
+	}
+	if b.Released == nil {
+		b.Released = map[int]model.ReleasedAddress{}
+	}
+	b.Released[ordinal] = model.ReleasedAddress{HandleID: handleID, Time: now}
+}
+
+// releaseTime returns the time at which the ordinal was last released, or zero if it has not been
+// allocated since the block was created.
+func (b allocationBlock) releaseTime(ordinal int) int64 {
+	return b.Released[ordinal].Time
+}
+
+// coolingDown returns true if the ordinal was released less than the cooldown before now, and so
+// should not yet be automatically assigned.
+func (b allocationBlock) coolingDown(ordinal int, now int64, cooldown time.Duration) bool {
+	t := b.releaseTime(ordinal)
+	return cooldown > 0 && t > 0 && now-t < int64(cooldown)
+}
+
+// releasedBy returns true if the ordinal was last held by the handle.
+func (b allocationBlock) releasedBy(ordinal int, handleID string) bool {
+	r, ok := b.Released[ordinal]
+	return ok && r.HandleID != nil && *r.HandleID == handleID
+}
+
+// heldByHandle returns true if any of the block's unallocated ordinals was last held by the handle.
+func (b allocationBlock) heldByHandle(handleID string) bool {
+	for _, o := range b.Unallocated {
+		if b.releasedBy(o, handleID) {
+			return true
+		}
+	}
+	return false
 }
 
 // hostAffinityMatches checks if the provided host matches the provided affinity.
@@ -144,11 +233,89 @@ func (b allocationBlock) numFreeAddresses() int {
 	return len(b.Unallocated)
 }
 
+// numReservedAddresses returns the number of unallocated addresses in the block that are in the
+// reserved set.
+func (b allocationBlock) numReservedAddresses(reserved *cnet.CIDRSet) int {
+	if reserved == nil || !reserved.Overlaps(b.CIDR) {
+		return 0
+	}
+	num := 0
+	for _, o := range b.Unallocated {
+		if reserved.ContainsIP(b.OrdinalToIP(o)) {
+			num++
+		}
+	}
+	return num
+}
+
+// numCoolingDownAddresses returns the number of unallocated addresses in the block that are still
+// within their release cooldown.  Reserved addresses are not included.
+func (b allocationBlock) numCoolingDownAddresses(reserved *cnet.CIDRSet, now int64, cooldown time.Duration) int {
+	if cooldown <= 0 {
+		return 0
+	}
+	checkReserved := reserved != nil && reserved.Overlaps(b.CIDR)
+	num := 0
+	for _, o := range b.Unallocated {
+		if checkReserved && reserved.ContainsIP(b.OrdinalToIP(o)) {
+			continue
+		}
+		if b.coolingDown(o, now, cooldown) {
+			num++
+		}
+	}
+	return num
+}
+
+// borrowedIPs returns the addresses in the block that are assigned to a host other than the block's
+// affine host.  Addresses without a node attribute are not included.
+func (b allocationBlock) borrowedIPs() []BorrowedIP {
+	var borrowed []BorrowedIP
+	owner := getHostAffinity(b.AllocationBlock)
+	for ordinal, attrIdx := range b.Allocations {
+		if attrIdx == nil {
+			continue
+		}
+		if *attrIdx >= len(b.Attributes) {
+			log.WithField("block", b.CIDR).Warnf("Missing attributes for IP with ordinal %d", ordinal)
+			continue
+		}
+		attrs := b.Attributes[*attrIdx]
+		host := attrs.AttrSecondary[model.IPAMBlockAttributeNode]
+		if host == "" || host == owner {
+			continue
+		}
+		borrowed = append(borrowed, BorrowedIP{
+			IP:        b.OrdinalToIP(ordinal),
+			Host:      host,
+			BlockHost: owner,
+			Block:     b.CIDR,
+			HandleID:  attrs.AttrPrimary,
+		})
+	}
+	return borrowed
+}
+
+// borrowerAttributes returns the attributes to store with addresses borrowed by the host.  The node
+// attribute is set to the host, unless the caller has already set it.
+func borrowerAttributes(attrs map[string]string, host string) map[string]string {
+	if _, ok := attrs[model.IPAMBlockAttributeNode]; ok {
+		return attrs
+	}
+	borrowerAttrs := map[string]string{model.IPAMBlockAttributeNode: host}
+	for k, v := range attrs {
+		borrowerAttrs[k] = v
+	}
+	return borrowerAttrs
+}
+
 func (b allocationBlock) empty() bool {
 	return b.numFreeAddresses() == b.NumAddresses()
 }
 
-func (b *allocationBlock) release(addresses []cnet.IP) ([]cnet.IP, map[string]int, error) {
+// release releases the addresses from the block.  If recordReleases is set, the time at which each
+// address was released, and the handle that held it, is recorded in the block.
+func (b *allocationBlock) release(addresses []cnet.IP, recordReleases bool) ([]cnet.IP, map[string]int, error) {
 	// Store return values.
 	unallocated := []cnet.IP{}
 	countByHandle := map[string]int{}
@@ -157,6 +324,7 @@ func (b *allocationBlock) release(addresses []cnet.IP) ([]cnet.IP, map[string]in
 	var ordinals []int
 	delRefCounts := map[int]int{}
 	attrsToDelete := []int{}
+	handles := map[int]*string{}
 
 	// De-duplicate addresses to ensure reference counting is correcet
 	uniqueAddresses := make(map[string]struct{})
@@ -199,6 +367,7 @@ func (b *allocationBlock) release(addresses []cnet.IP) ([]cnet.IP, map[string]in
 		// exists.
 		log.Debugf("Looking up attribute with index %d", *attrIdx)
 		handleID := b.Attributes[*attrIdx].AttrPrimary
+		handles[ordinal] = handleID
 		if handleID != nil {
 			log.Debugf("HandleID is %s", *handleID)
 			handleCount := 0
@@ -231,10 +400,12 @@ func (b *allocationBlock) release(addresses []cnet.IP) ([]cnet.IP, map[string]in
 	// Release requested addresses.
 	log.Debugf("Allocations: %v", b.Allocations)
 	log.Debugf("Releasing ordinals: %v", ordinals)
+	now := time.Now().UnixNano()
 	for _, ordinal := range ordinals {
 		log.Debugf("Releasing ordinal %d", ordinal)
 		b.Allocations[ordinal] = nil
 		b.Unallocated = append(b.Unallocated, ordinal)
+		b.markReleased(ordinal, handles[ordinal], now, recordReleases)
 	}
 	return unallocated, countByHandle, nil
 }
@@ -294,7 +465,9 @@ func (b allocationBlock) attributeIndexesByHandle(handleID string) []int {
 	return indexes
 }
 
-func (b *allocationBlock) releaseByHandle(handleID string) int {
+// releaseByHandle releases the addresses held by the handle, recording the releases in the block if
+// recordReleases is set.
+func (b *allocationBlock) releaseByHandle(handleID string, recordReleases bool) int {
 	attrIndexes := b.attributeIndexesByHandle(handleID)
 	log.Debugf("Attribute indexes to release: %v", attrIndexes)
 	if len(attrIndexes) == 0 {
@@ -318,9 +491,11 @@ func (b *allocationBlock) releaseByHandle(handleID string) int {
 	b.deleteAttributes(attrIndexes, ordinals)
 
 	// Release the addresses.
+	now := time.Now().UnixNano()
 	for _, o := range ordinals {
 		b.Allocations[o] = nil
 		b.Unallocated = append(b.Unallocated, o)
+		b.markReleased(o, &handleID, now, recordReleases)
 	}
 	return len(ordinals)
 }
@@ -373,19 +548,72 @@ func (b *allocationBlock) findOrAddAttribute(handleID *string, attrs map[string]
 	return attrIndex
 }
 
-func getBlockCIDRForAddress(addr cnet.IP, pool *v3.IPPool) cnet.IPNet {
-	var mask net.IPMask
-	if addr.Version() == 6 {
-		// This is an IPv6 address.
-		mask = net.CIDRMask(pool.Spec.BlockSize, 128)
-	} else {
-		// This is an IPv4 address.
-		mask = net.CIDRMask(pool.Spec.BlockSize, 32)
+// The block sizes that are used for pools that don't specify one.
+const (
+	defaultIPv4BlockSize = 26
+	defaultIPv6BlockSize = 122
+)
+
+// blockSizeForPool returns the block size of the pool, or the default block size for the given IP
+// version if the pool doesn't specify one.
+func blockSizeForPool(pool *v3.IPPool, version int) int {
+	if pool.Spec.BlockSize != 0 {
+		return pool.Spec.BlockSize
+	}
+	if version == 6 {
+		return defaultIPv6BlockSize
 	}
+	return defaultIPv4BlockSize
+}
+
+// blockMaskForPool returns the mask of the pool's blocks for the given IP version.
+func blockMaskForPool(pool *v3.IPPool, version int) net.IPMask {
+	if version == 6 {
+		return net.CIDRMask(blockSizeForPool(pool, version), 128)
+	}
+	return net.CIDRMask(blockSizeForPool(pool, version), 32)
+}
+
+func getBlockCIDRForAddress(addr cnet.IP, pool *v3.IPPool) cnet.IPNet {
+	mask := blockMaskForPool(pool, addr.Version())
 	masked := addr.Mask(mask)
 	return cnet.IPNet{IPNet: net.IPNet{IP: masked, Mask: mask}}
 }
 
+// blockEdges returns the addresses at the start and end of the block that the pool reserves from
+// automatic assignment.
+func blockEdges(cidr cnet.IPNet, pool *v3.IPPool) *cnet.CIDRSet {
+	edges := cnet.NewCIDRSet()
+	ones, bits := cidr.Mask.Size()
+	numAddrs := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	addEdge := func(offset *big.Int) {
+		ip := cnet.IncrementIP(cnet.IP{IP: cidr.IP}, offset)
+		edges.Add(cnet.IPNet{IPNet: net.IPNet{IP: ip.IP, Mask: net.CIDRMask(bits, bits)}})
+	}
+	for i := 0; i < pool.Spec.ReservedBlockStart && big.NewInt(int64(i)).Cmp(numAddrs) < 0; i++ {
+		addEdge(big.NewInt(int64(i)))
+	}
+	for i := 1; i <= pool.Spec.ReservedBlockEnd && big.NewInt(int64(i)).Cmp(numAddrs) <= 0; i++ {
+		addEdge(new(big.Int).Sub(numAddrs, big.NewInt(int64(i))))
+	}
+	return edges
+}
+
+// reservedForBlock returns the addresses within the block that must not be automatically assigned:
+// the reserved addresses, and the addresses at the edges of the block that its pool (out of the
+// supplied pools) reserves.
+func reservedForBlock(cidr cnet.IPNet, pools []v3.IPPool, reserved *cnet.CIDRSet) *cnet.CIDRSet {
+	i := poolIndexForBlock(cidr, pools)
+	if i < 0 || (pools[i].Spec.ReservedBlockStart == 0 && pools[i].Spec.ReservedBlockEnd == 0) {
+		return reserved
+	}
+	edges := blockEdges(cidr, &pools[i])
+	if reserved == nil {
+		return edges
+	}
+	return edges.Union(reserved)
+}
+
 func getIPVersion(ip cnet.IP) int {
 	if ip.To4() == nil {
 		return 6
@@ -395,7 +623,7 @@ func getIPVersion(ip cnet.IP) int {
 
 func largerThanOrEqualToBlock(blockCIDR cnet.IPNet, pool *v3.IPPool) bool {
 	ones, _ := blockCIDR.Mask.Size()
-	return ones <= pool.Spec.BlockSize
+	return ones <= blockSizeForPool(pool, blockCIDR.Version())
 }
 
 func intInSlice(searchInt int, slice []int) bool {
@@ -0,0 +1,66 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipam
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	cnet "github.com/unai-ttxu/libcalico-go/lib/net"
+)
+
+var _ = Describe("IPAM block sizes and reserved block edges", func() {
+	DescribeTable("should default the block size of pools that don't specify one",
+		func(pool v3.IPPool, addr, expected string) {
+			Expect(getBlockCIDRForAddress(cnet.MustParseIP(addr), &pool).String()).To(Equal(expected))
+		},
+		Entry("IPv4 default", v3.IPPool{Spec: v3.IPPoolSpec{CIDR: "10.0.0.0/16"}}, "10.0.1.70", "10.0.1.64/26"),
+		Entry("IPv6 default", v3.IPPool{Spec: v3.IPPoolSpec{CIDR: "fd00::/64"}}, "fd00::4a", "fd00::40/122"),
+		Entry("IPv4 configured", v3.IPPool{Spec: v3.IPPoolSpec{CIDR: "10.0.0.0/16", BlockSize: 29}}, "10.0.1.70", "10.0.1.64/29"),
+	)
+
+	DescribeTable("should reserve the addresses at the edges of the pool's blocks",
+		func(block string, start, end int, expected []string) {
+			pools := []v3.IPPool{{Spec: v3.IPPoolSpec{CIDR: "10.0.0.0/24", ReservedBlockStart: start, ReservedBlockEnd: end}}, {Spec: v3.IPPoolSpec{CIDR: "fd00::/120", ReservedBlockStart: start, ReservedBlockEnd: end}}}
+			reserved := reservedForBlock(cnet.MustParseNetwork(block), pools, cnet.NewCIDRSet(cnet.MustParseNetwork("10.0.0.4/32")))
+			var nets []string
+			for _, n := range reserved.Nets() {
+				nets = append(nets, n.String())
+			}
+			Expect(nets).To(ConsistOf(expected))
+		},
+		Entry("no edges", "10.0.0.0/29", 0, 0, []string{"10.0.0.4/32"}),
+		Entry("IPv4 edges", "10.0.0.8/29", 2, 1, []string{"10.0.0.4/32", "10.0.0.8/31", "10.0.0.15/32"}),
+		Entry("IPv6 edges", "fd00::/126", 1, 1, []string{"10.0.0.4/32", "fd00::/128", "fd00::3/128"}),
+		Entry("block outside the pools", "10.1.0.0/29", 1, 1, []string{"10.0.0.4/32"}),
+	)
+
+	It("should not automatically assign the reserved edges of a block", func() {
+		pools := []v3.IPPool{{Spec: v3.IPPoolSpec{CIDR: "10.0.0.0/24", ReservedBlockStart: 1, ReservedBlockEnd: 2}}}
+		b := newBlock(cnet.MustParseNetwork("10.0.0.0/29"))
+		reserved := reservedForBlock(b.CIDR, pools, cnet.NewCIDRSet())
+		ips, err := b.autoAssign(8, nil, "host", nil, false, reserved, sequentialStrategy{}, 0)
+		Expect(err).NotTo(HaveOccurred())
+
+		var assigned []string
+		for _, ip := range ips {
+			assigned = append(assigned, ip.IP.String())
+		}
+		Expect(assigned).To(Equal([]string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5"}))
+		Expect(b.numReservedAddresses(reserved)).To(Equal(3))
+	})
+})
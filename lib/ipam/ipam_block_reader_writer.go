@@ -92,8 +92,9 @@ func (rw blockReaderWriter) getAffineBlocks(ctx context.Context, host string, ve
 // findUnclaimedBlock finds a block cidr which does not yet exist within the given list of pools. The provided pools
 // should already be sanitized and only enclude existing, enabled pools. Note that the block may become claimed
 // between receiving the cidr from this function and attempting to claim the corresponding block as this function
-// does not reserve the returned IPNet.
-func (rw blockReaderWriter) findUnclaimedBlock(ctx context.Context, host string, version int, pools []v3.IPPool, config IPAMConfig) (*cnet.IPNet, error) {
+// does not reserve the returned IPNet.  Blocks whose addresses are all reserved, and blocks that overlap the
+// excluded set, are never returned.
+func (rw blockReaderWriter) findUnclaimedBlock(ctx context.Context, host string, version int, pools []v3.IPPool, config IPAMConfig, reserved, excluded *cnet.CIDRSet) (*cnet.IPNet, error) {
 	// If there are no pools, we cannot assign addresses.
 	if len(pools) == 0 {
 		return nil, fmt.Errorf("no configured Calico pools for node %s", host)
@@ -101,11 +102,21 @@ func (rw blockReaderWriter) findUnclaimedBlock(ctx context.Context, host string,
 
 	// Iterate through pools to find a new block.
 	for _, pool := range pools {
-		// Use a block generator to iterate through all of the blocks
+		// Use the pool's block generator to iterate through all of the blocks
 		// that fall within the pool.
 		log.Debugf("Looking for blocks in pool %+v", pool)
-		blocks := randomBlockGenerator(pool, host)
+		blocks := strategyForPool(&pool).blocks(pool, host)
 		for subnet := blocks(); subnet != nil; subnet = blocks() {
+			// There's no point claiming a block that we can't assign any addresses from.
+			if reserved != nil && reserved.Contains(*subnet) {
+				log.Debugf("Block %s is reserved", subnet.String())
+				continue
+			}
+			if excluded != nil && excluded.Overlaps(*subnet) {
+				log.Debugf("Block %s overlaps an excluded CIDR", subnet.String())
+				continue
+			}
+
 			// Check if a block already exists for this subnet.
 			log.Debugf("Getting block: %s", subnet.String())
 			_, err := rw.queryBlock(ctx, *subnet, "")
@@ -343,6 +354,47 @@ func (rw blockReaderWriter) releaseBlockAffinity(ctx context.Context, host strin
 	return nil
 }
 
+// transferBlockAffinity transfers the affinity of the given block from one host to another.  The
+// block is updated with a compare-and-swap, so the transfer fails with an update conflict if the
+// block has changed since it was read.
+func (rw blockReaderWriter) transferBlockAffinity(ctx context.Context, obj *model.KVPair, from, to string) error {
+	blockCIDR := obj.Key.(model.BlockKey).CIDR
+	logCtx := log.WithFields(log.Fields{"host": from, "newHost": to, "subnet": blockCIDR.String()})
+
+	// Claim a pending affinity for the new host before we update the block, so that the
+	// block is never affine to a host without a corresponding affinity.
+	aff, err := rw.getPendingAffinity(ctx, to, blockCIDR)
+	if err != nil {
+		return err
+	}
+
+	b := allocationBlock{obj.Value.(*model.AllocationBlock)}
+	affinityKeyStr := "host:" + to
+	b.Affinity = &affinityKeyStr
+	obj.Value = b.AllocationBlock
+	if _, err = rw.updateBlock(ctx, obj); err != nil {
+		logCtx.WithError(err).Warn("Failed to transfer block affinity, delete the pending affinity")
+		if err := rw.deleteAffinity(ctx, aff); err != nil {
+			logCtx.WithError(err).Warn("Failed to delete pending affinity")
+		}
+		return err
+	}
+	if _, err = rw.confirmAffinity(ctx, aff); err != nil {
+		return err
+	}
+
+	// The block is no longer affine to the old host, so delete its affinity.
+	old, err := rw.queryAffinity(ctx, from, blockCIDR, "")
+	if err == nil {
+		err = rw.deleteAffinity(ctx, old)
+	}
+	if _, ok := err.(cerrors.ErrorResourceDoesNotExist); err != nil && !ok {
+		logCtx.WithError(err).Error("Error deleting block affinity")
+		return err
+	}
+	return nil
+}
+
 // queryAffinity gets an affinity for the given host + CIDR key.
 func (rw blockReaderWriter) queryAffinity(ctx context.Context, host string, cidr cnet.IPNet, revision string) (*model.KVPair, error) {
 	return rw.client.Get(ctx, model.BlockAffinityKey{Host: host, CIDR: cidr}, revision)
@@ -421,12 +473,7 @@ func (rw blockReaderWriter) getPoolForIP(ip cnet.IP, enabledPools []v3.IPPool) (
 func blockGenerator(pool *v3.IPPool, cidr cnet.IPNet) func() *cnet.IPNet {
 	ip := cnet.IP{IP: cidr.IP}
 
-	var blockMask net.IPMask
-	if ip.Version() == 4 {
-		blockMask = net.CIDRMask(pool.Spec.BlockSize, 32)
-	} else {
-		blockMask = net.CIDRMask(pool.Spec.BlockSize, 128)
-	}
+	blockMask := blockMaskForPool(pool, ip.Version())
 
 	ones, size := blockMask.Size()
 	blockSize := new(big.Int).Exp(big.NewInt(2), big.NewInt(int64(size-ones)), nil)
@@ -474,12 +521,7 @@ func randomBlockGenerator(ipPool v3.IPPool, hostName string) func() *cnet.IPNet
 	// Determine the IP type to use.
 	baseIP := cnet.IP{IP: pool.IP}
 	version := getIPVersion(baseIP)
-	var blockMask net.IPMask
-	if version == 4 {
-		blockMask = net.CIDRMask(ipPool.Spec.BlockSize, 32)
-	} else {
-		blockMask = net.CIDRMask(ipPool.Spec.BlockSize, 128)
-	}
+	blockMask := blockMaskForPool(&ipPool, version)
 
 	// Determine the number of blocks within this pool.
 	ones, size := pool.Mask.Size()
@@ -130,6 +130,10 @@ func (c *fakeClient) Clean() error {
 	return nil
 }
 
+func (c *fakeClient) Close() error {
+	return nil
+}
+
 func (c *fakeClient) List(ctx context.Context, list model.ListInterface, revision string) (*model.KVPairList, error) {
 	if f, ok := c.listFuncs[fmt.Sprintf("%s", list)]; ok {
 		return f(ctx, list, revision)
@@ -811,7 +815,7 @@ var _ = testutils.E2eDatastoreDescribe("IPAM affine block allocation tests", tes
 							return nil, err
 						}
 						b1 := allocationBlock{kvpb.Value.(*model.AllocationBlock)}
-						b1.autoAssign(1, nil, hostA, nil, false)
+						b1.autoAssign(1, nil, hostA, nil, false, nil, nil, 0)
 						if _, err := bc.Update(ctx, kvpb); err != nil {
 							return nil, err
 						}
@@ -0,0 +1,72 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipam
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	cnet "github.com/unai-ttxu/libcalico-go/lib/net"
+)
+
+var _ = Describe("IPAM block borrowing", func() {
+	var b allocationBlock
+	handle := "handle"
+
+	BeforeEach(func() {
+		b = newBlock(cnet.MustParseNetwork("10.0.0.0/29"))
+		affinity := "host:host-a"
+		b.Affinity = &affinity
+	})
+
+	It("should not record the node of addresses assigned to the block's host", func() {
+		_, err := b.autoAssign(1, &handle, "host-a", nil, false, nil, nil, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Attributes).To(Equal([]model.AllocationAttribute{{AttrPrimary: &handle}}))
+		Expect(b.borrowedIPs()).To(BeEmpty())
+	})
+
+	It("should record the node of addresses borrowed by another host", func() {
+		attrs := map[string]string{model.IPAMBlockAttributePod: "pod"}
+		ips, err := b.autoAssign(2, &handle, "host-b", attrs, false, nil, nil, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(attrs).To(HaveLen(1), "caller's attributes should not be modified")
+		Expect(b.Attributes).To(Equal([]model.AllocationAttribute{{
+			AttrPrimary: &handle,
+			AttrSecondary: map[string]string{
+				model.IPAMBlockAttributePod:  "pod",
+				model.IPAMBlockAttributeNode: "host-b",
+			},
+		}}))
+
+		borrowed := b.borrowedIPs()
+		Expect(borrowed).To(HaveLen(2))
+		Expect(borrowed[0]).To(Equal(BorrowedIP{
+			IP:        cnet.IP{IP: ips[0].IP},
+			Host:      "host-b",
+			BlockHost: "host-a",
+			Block:     b.CIDR,
+			HandleID:  &handle,
+		}))
+	})
+
+	It("should not override a node attribute set by the caller", func() {
+		attrs := map[string]string{model.IPAMBlockAttributeNode: "host-c"}
+		_, err := b.autoAssign(1, nil, "host-b", attrs, false, nil, nil, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.borrowedIPs()[0].Host).To(Equal("host-c"))
+	})
+})
@@ -0,0 +1,120 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipam
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+	cnet "github.com/unai-ttxu/libcalico-go/lib/net"
+)
+
+// allocationEvents returns the events for the addresses of the block that are currently assigned,
+// out of the given addresses.  It is called after the addresses are assigned, or before they are
+// released, so that the events carry the handle and attributes of the assignment.  If host is
+// empty, the host is taken from the node attribute of the address or from the block's affinity.
+func (b allocationBlock) allocationEvents(eventType string, ips []cnet.IP, host string, now int64) []model.IPAMEvent {
+	var events []model.IPAMEvent
+	for _, ip := range ips {
+		ordinal, err := b.IPToOrdinal(ip)
+		if err != nil || b.Allocations[ordinal] == nil {
+			continue
+		}
+		attrs := b.Attributes[*b.Allocations[ordinal]]
+		h := host
+		if h == "" {
+			h = attrs.AttrSecondary[model.IPAMBlockAttributeNode]
+		}
+		if h == "" {
+			h = getHostAffinity(b.AllocationBlock)
+		}
+		events = append(events, model.IPAMEvent{
+			Type:     eventType,
+			IP:       ip,
+			HandleID: attrs.AttrPrimary,
+			Attrs:    attrs.AttrSecondary,
+			Host:     h,
+			Time:     now,
+		})
+	}
+	return events
+}
+
+// recordEvents writes the events to the IPAM audit log, if it is enabled.  Each event is written
+// under both the address and handle indexes, with a TTL of the audit retention so that the
+// datastore expires it.  The audit log is best effort: a failure to write an event is logged but
+// does not fail the assignment or release, which has already been committed.
+func (c ipamClient) recordEvents(ctx context.Context, retention time.Duration, events []model.IPAMEvent) {
+	if retention <= 0 {
+		return
+	}
+	for i := range events {
+		e := &events[i]
+		keys := []model.Key{model.IPAMEventKey{IP: e.IP, Time: e.Time, Type: e.Type}}
+		if e.HandleID != nil {
+			keys = append(keys, model.IPAMEventHandleKey{HandleID: *e.HandleID, IP: e.IP, Time: e.Time, Type: e.Type})
+		}
+		for _, k := range keys {
+			_, err := c.client.Create(ctx, &model.KVPair{Key: k, Value: e, TTL: retention})
+			if _, ok := err.(cerrors.ErrorOperationNotSupported); ok {
+				log.WithError(err).Debug("Datastore does not support the IPAM audit log")
+				return
+			} else if err != nil {
+				log.WithError(err).WithField("key", k).Warn("Failed to record IPAM event")
+			}
+		}
+	}
+}
+
+// AllocationHistory returns the events in the IPAM audit log that match the query, oldest first.
+//
+// Events are only recorded while the audit log is enabled (see IPAMConfig.AuditRetention), and are
+// kept for the retention that was configured when they were recorded.  The audit log is not
+// supported by the Kubernetes datastore.
+func (c ipamClient) AllocationHistory(ctx context.Context, args AllocationHistoryArgs) ([]AllocationEvent, error) {
+	kvps, err := c.client.List(ctx, model.IPAMEventListOptions{IP: args.IP, HandleID: args.HandleID}, "")
+	if err != nil {
+		return nil, err
+	}
+
+	events := []AllocationEvent{}
+	for _, kvp := range kvps.KVPairs {
+		e := kvp.Value.(*model.IPAMEvent)
+		t := time.Unix(0, e.Time)
+		if !args.Start.IsZero() && t.Before(args.Start) {
+			continue
+		}
+		if !args.End.IsZero() && !t.Before(args.End) {
+			continue
+		}
+		events = append(events, AllocationEvent{
+			Type:     AllocationEventType(e.Type),
+			IP:       e.IP,
+			HandleID: e.HandleID,
+			Attrs:    e.Attrs,
+			Host:     e.Host,
+			Time:     t,
+		})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+	return events, nil
+}
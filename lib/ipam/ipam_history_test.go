@@ -0,0 +1,64 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipam
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	cnet "github.com/unai-ttxu/libcalico-go/lib/net"
+)
+
+var _ = Describe("IPAM allocation events", func() {
+	var b allocationBlock
+	handle := "handle"
+	attrs := map[string]string{model.IPAMBlockAttributePod: "pod"}
+
+	BeforeEach(func() {
+		b = newBlock(cnet.MustParseNetwork("10.0.0.0/29"))
+		affinity := "host:host-a"
+		b.Affinity = &affinity
+	})
+
+	It("should describe the assigned addresses", func() {
+		ips, err := b.autoAssign(2, &handle, "host-a", attrs, false, nil, sequentialStrategy{}, 0)
+		Expect(err).NotTo(HaveOccurred())
+
+		events := b.allocationEvents(model.AllocationEventAssign, []cnet.IP{{IP: ips[0].IP}, {IP: ips[1].IP}}, "host-a", 42)
+		Expect(events).To(Equal([]model.IPAMEvent{
+			{Type: model.AllocationEventAssign, IP: cnet.MustParseIP("10.0.0.0"), HandleID: &handle, Attrs: attrs, Host: "host-a", Time: 42},
+			{Type: model.AllocationEventAssign, IP: cnet.MustParseIP("10.0.0.1"), HandleID: &handle, Attrs: attrs, Host: "host-a", Time: 42},
+		}))
+	})
+
+	It("should skip addresses that are not assigned", func() {
+		Expect(b.assign(cnet.MustParseIP("10.0.0.5"), nil, nil, "host-a")).NotTo(HaveOccurred())
+		events := b.allocationEvents(model.AllocationEventRelease, []cnet.IP{cnet.MustParseIP("10.0.0.4"), cnet.MustParseIP("10.0.0.5"), cnet.MustParseIP("10.0.1.0")}, "", 42)
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].IP.String()).To(Equal("10.0.0.5"))
+	})
+
+	It("should take the host of a release from the borrower or the block's affinity", func() {
+		_, err := b.autoAssign(1, &handle, "host-b", nil, false, nil, sequentialStrategy{}, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.assign(cnet.MustParseIP("10.0.0.5"), nil, nil, "host-a")).NotTo(HaveOccurred())
+
+		events := b.allocationEvents(model.AllocationEventRelease, []cnet.IP{cnet.MustParseIP("10.0.0.0"), cnet.MustParseIP("10.0.0.5")}, "", 42)
+		Expect(events).To(HaveLen(2))
+		Expect(events[0].Host).To(Equal("host-b"))
+		Expect(events[1].Host).To(Equal("host-a"))
+	})
+})
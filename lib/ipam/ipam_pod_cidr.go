@@ -0,0 +1,142 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipam
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+	"github.com/unai-ttxu/libcalico-go/lib/net"
+)
+
+// claimPodCIDRs claims affinity to the host for the blocks within the host's pod CIDRs that fall
+// within the given pools, if it hasn't already.  Only the host's own pod CIDRs are read, so this
+// does not depend on the number of hosts.
+func (c ipamClient) claimPodCIDRs(ctx context.Context, host string, version int, pools []v3.IPPool) error {
+	kvps, err := c.client.List(ctx, model.PodCIDRListOptions{Host: host, IPVersion: version}, "")
+	if err != nil {
+		if _, ok := err.(cerrors.ErrorOperationNotSupported); ok {
+			// The datastore doesn't store pod CIDRs, so there aren't any.
+			return nil
+		}
+		log.WithError(err).Error("Failed to list the host's pod CIDRs")
+		return err
+	}
+
+	var affine *net.CIDRSet
+	for _, kvp := range kvps.KVPairs {
+		k := kvp.Key.(model.BlockAffinityKey)
+		logCtx := log.WithFields(log.Fields{"host": host, "cidr": k.CIDR})
+		i := poolIndexForBlock(k.CIDR, pools)
+		if i < 0 {
+			logCtx.Debug("Pod CIDR is not within a pool")
+			continue
+		}
+		if !largerThanOrEqualToBlock(k.CIDR, &pools[i]) {
+			logCtx.Debug("Pod CIDR is smaller than a block, not claiming it")
+			continue
+		}
+
+		// Load the host's affine blocks the first time that we need them.
+		if affine == nil {
+			cidrs, _, err := c.blockReaderWriter.getAffineBlocks(ctx, host, version, nil)
+			if err != nil {
+				return err
+			}
+			affine = net.NewCIDRSet(cidrs...)
+		}
+		if podCIDRClaimed(&pools[i], k.CIDR, affine) {
+			continue
+		}
+
+		logCtx.Info("Claiming blocks within the host's pod CIDR")
+		_, failed, err := c.ClaimAffinity(ctx, k.CIDR, host)
+		if err != nil {
+			return err
+		}
+		if len(failed) != 0 {
+			logCtx.WithField("blocks", failed).Warn("Blocks within the host's pod CIDR are claimed by other hosts")
+		}
+	}
+	return nil
+}
+
+// foreignPodCIDRs returns the pod CIDRs of the hosts other than the given host.  This reads the pod
+// CIDRs of every host, so it is only called when the host needs to claim a new block.
+func (c ipamClient) foreignPodCIDRs(ctx context.Context, host string, version int) (*net.CIDRSet, error) {
+	foreign := net.NewCIDRSet()
+	kvps, err := c.client.List(ctx, model.PodCIDRListOptions{IPVersion: version}, "")
+	if err != nil {
+		if _, ok := err.(cerrors.ErrorOperationNotSupported); ok {
+			return foreign, nil
+		}
+		log.WithError(err).Error("Failed to list pod CIDRs")
+		return nil, err
+	}
+	for _, kvp := range kvps.KVPairs {
+		if k := kvp.Key.(model.BlockAffinityKey); k.Host != host {
+			foreign.Add(k.CIDR)
+		}
+	}
+	return foreign, nil
+}
+
+// podCIDRClaimed returns true if all of the pool's blocks within the pod CIDR are affine to the
+// host, given the set of the host's affine blocks.
+func podCIDRClaimed(pool *v3.IPPool, podCIDR net.IPNet, affine *net.CIDRSet) bool {
+	blocks := blockGenerator(pool, podCIDR)
+	for cidr := blocks(); cidr != nil; cidr = blocks() {
+		if !affine.Contains(*cidr) {
+			return false
+		}
+	}
+	return true
+}
+
+// listBlocks returns all of the allocation blocks.  When host-local IPAM assigns addresses from
+// the nodes' pod CIDRs, the datastore does not store allocation blocks, so an empty block affine to
+// the node is returned for each pod CIDR instead.  Host-local IPAM does not record its assignments
+// in the datastore.
+func (c ipamClient) listBlocks(ctx context.Context) ([]allocationBlock, error) {
+	var blocks []allocationBlock
+	kvps, err := c.client.List(ctx, model.BlockListOptions{}, "")
+	if err == nil {
+		for _, kvp := range kvps.KVPairs {
+			blocks = append(blocks, allocationBlock{kvp.Value.(*model.AllocationBlock)})
+		}
+		return blocks, nil
+	}
+	if _, ok := err.(cerrors.ErrorOperationNotSupported); !ok {
+		return nil, err
+	}
+
+	log.Debug("Datastore does not store allocation blocks, using the nodes' pod CIDRs")
+	kvps, err = c.client.List(ctx, model.BlockAffinityListOptions{}, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, kvp := range kvps.KVPairs {
+		k := kvp.Key.(model.BlockAffinityKey)
+		b := newBlock(k.CIDR)
+		affinity := "host:" + k.Host
+		b.Affinity = &affinity
+		blocks = append(blocks, b)
+	}
+	return blocks, nil
+}
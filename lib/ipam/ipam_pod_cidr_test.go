@@ -0,0 +1,39 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipam
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	cnet "github.com/unai-ttxu/libcalico-go/lib/net"
+)
+
+var _ = DescribeTable("IPAM pod CIDR claims",
+	func(podCIDR string, affine []string, expected bool) {
+		pool := v3.IPPool{Spec: v3.IPPoolSpec{CIDR: "10.0.0.0/24", BlockSize: 26}}
+		set := cnet.NewCIDRSet()
+		for _, cidr := range affine {
+			set.Add(cnet.MustParseNetwork(cidr))
+		}
+		Expect(podCIDRClaimed(&pool, cnet.MustParseNetwork(podCIDR), set)).To(Equal(expected))
+	},
+	Entry("no affine blocks", "10.0.0.0/25", nil, false),
+	Entry("some of the blocks", "10.0.0.0/25", []string{"10.0.0.0/26"}, false),
+	Entry("all of the blocks", "10.0.0.0/25", []string{"10.0.0.0/26", "10.0.0.64/26"}, true),
+	Entry("blocks outside the pod CIDR", "10.0.0.128/25", []string{"10.0.0.0/26", "10.0.0.64/26"}, false),
+	Entry("a single block", "10.0.0.64/26", []string{"10.0.0.64/26"}, true),
+)
@@ -0,0 +1,288 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipam
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+	"github.com/unai-ttxu/libcalico-go/lib/net"
+	"github.com/unai-ttxu/libcalico-go/lib/set"
+)
+
+// affinityChange is a change to the affinity of a block that is planned by ReclaimBlocks.
+type affinityChange struct {
+	cidr net.IPNet
+
+	// The host that the block is affine to.
+	host string
+
+	// The host that the block's affinity is migrated to, or empty if the affinity is released.
+	newHost string
+}
+
+// migrationTarget returns the host that the block's affinity should be migrated to, if the block
+// has no more than threshold addresses assigned and they are all borrowed by a single other host.
+// Returns an empty string otherwise.
+func (b allocationBlock) migrationTarget(threshold int) string {
+	inUse := b.NumAddresses() - b.numFreeAddresses()
+	if inUse == 0 || inUse > threshold || getHostAffinity(b.AllocationBlock) == "" {
+		return ""
+	}
+	borrowed := b.borrowedIPs()
+	if len(borrowed) != inUse {
+		// Some of the addresses are used by the block's host, or by an unknown host.
+		return ""
+	}
+	for _, ip := range borrowed[1:] {
+		if ip.Host != borrowed[0].Host {
+			return ""
+		}
+	}
+	return borrowed[0].Host
+}
+
+// planReclaim returns the affinities of the given blocks that should be released, and those that
+// should be migrated to another host, as specified by the args.  Each host keeps the lowest
+// args.RetainEmptyBlocks of its empty blocks for each IP version.
+func planReclaim(blocks []allocationBlock, args ReclaimBlocksArgs) (release, migrate []affinityChange) {
+	wantAllHosts := len(args.Hosts) == 0
+	wantedHosts := set.FromArray(args.Hosts)
+
+	// Sort the blocks so that the blocks that are retained do not depend on the order in which
+	// the datastore lists them.
+	sorted := append([]allocationBlock(nil), blocks...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].CIDR.IP.To16(), sorted[j].CIDR.IP.To16()) < 0
+	})
+
+	type hostVersion struct {
+		host    string
+		version int
+	}
+	numEmpty := map[hostVersion]int{}
+	for _, b := range sorted {
+		host := getHostAffinity(b.AllocationBlock)
+		if host == "" || !(wantAllHosts || wantedHosts.Contains(host)) {
+			continue
+		}
+		if b.empty() {
+			hv := hostVersion{host, b.CIDR.Version()}
+			if numEmpty[hv]++; numEmpty[hv] > args.RetainEmptyBlocks {
+				release = append(release, affinityChange{cidr: b.CIDR, host: host})
+			}
+			continue
+		}
+		if target := b.migrationTarget(args.MigrateThreshold); target != "" {
+			migrate = append(migrate, affinityChange{cidr: b.CIDR, host: host, newHost: target})
+		}
+	}
+	return release, migrate
+}
+
+// ReclaimBlocks releases the affinity of the empty blocks that hosts hold in excess of
+// args.RetainEmptyBlocks, which deletes the blocks so that they can be claimed by other hosts.  It
+// also migrates the affinity of sparsely used blocks to the host that is using them.  Returns the
+// number of blocks that were reclaimed and migrated in each pool.
+//
+// Each block is updated with a compare-and-swap, and its state is checked again after every
+// conflict, so blocks that are assigned from concurrently are never reclaimed.
+func (c ipamClient) ReclaimBlocks(ctx context.Context, args ReclaimBlocksArgs) ([]*PoolReclaim, error) {
+	allPools, err := c.pools.GetAllPools()
+	if err != nil {
+		log.WithError(err).Errorf("Error getting IP pools")
+		return nil, err
+	}
+
+	// Identify the pools that we want, in the same way as GetUtilization.
+	var reclaims []*PoolReclaim
+	wantAllPools := len(args.Pools) == 0
+	wantedPools := set.FromArray(args.Pools)
+	for _, pool := range allPools {
+		if wantAllPools ||
+			wantedPools.Contains(pool.Name) ||
+			wantedPools.Contains(pool.Spec.CIDR) {
+			reclaims = append(reclaims, &PoolReclaim{
+				Name: pool.Name,
+				CIDR: net.MustParseNetwork(pool.Spec.CIDR).IPNet,
+			})
+		}
+	}
+	if wantAllPools {
+		reclaims = append(reclaims, &PoolReclaim{
+			Name: "orphaned allocation blocks",
+			CIDR: net.MustParseNetwork("0.0.0.0/0").IPNet,
+		})
+	}
+	poolForBlock := func(cidr net.IPNet) *PoolReclaim {
+		for _, r := range reclaims {
+			if cidr.IsNetOverlap(r.CIDR) {
+				return r
+			}
+		}
+		return nil
+	}
+
+	// Plan the changes using the blocks of the wanted pools.
+	objs, err := c.client.List(ctx, model.BlockListOptions{}, "")
+	if err != nil {
+		return nil, err
+	}
+	var blocks []allocationBlock
+	for _, kvp := range objs.KVPairs {
+		b := allocationBlock{kvp.Value.(*model.AllocationBlock)}
+		if poolForBlock(b.CIDR) != nil {
+			blocks = append(blocks, b)
+		}
+	}
+	release, migrate := planReclaim(blocks, args)
+
+	for _, change := range migrate {
+		allowed, err := c.migrationAllowed(ctx, change, allPools)
+		if err != nil {
+			return reclaims, err
+		}
+		if !allowed {
+			log.WithFields(log.Fields{"host": change.host, "newHost": change.newHost, "cidr": change.cidr}).Info(
+				"Pool does not allow the block to be migrated to the new host")
+			continue
+		}
+		migrated, err := c.migrateBlockAffinity(ctx, change, args.MigrateThreshold)
+		if err != nil {
+			return reclaims, err
+		}
+		if migrated {
+			poolForBlock(change.cidr).MigratedBlocks++
+		}
+	}
+	for _, change := range release {
+		reclaimed, err := c.reclaimBlock(ctx, change)
+		if err != nil {
+			return reclaims, err
+		}
+		if reclaimed {
+			poolForBlock(change.cidr).ReclaimedBlocks++
+		}
+	}
+	return reclaims, nil
+}
+
+// reclaimBlock releases the host's affinity to the block, provided that the block is still empty.
+// Returns true if the block was reclaimed.
+func (c ipamClient) reclaimBlock(ctx context.Context, change affinityChange) (bool, error) {
+	logCtx := log.WithFields(log.Fields{"host": change.host, "cidr": change.cidr})
+	for i := 0; i < datastoreRetries; i++ {
+		err := c.blockReaderWriter.releaseBlockAffinity(ctx, change.host, change.cidr, true)
+		if err == nil {
+			logCtx.Info("Reclaimed empty block")
+			return true, nil
+		}
+		switch err.(type) {
+		case cerrors.ErrorResourceUpdateConflict:
+			logCtx.WithError(err).Debug("CAS error reclaiming block - retry")
+			continue
+		case errBlockNotEmpty:
+			// An address was assigned from the block while we were reclaiming it.  The
+			// affinity may have been marked for deletion, so confirm it again.
+			logCtx.Info("Block is no longer empty, not reclaiming it")
+			return false, c.restoreAffinity(ctx, change)
+		case errBlockClaimConflict, cerrors.ErrorResourceDoesNotExist:
+			logCtx.Debug("Block is no longer affine to the host, not reclaiming it")
+			return false, nil
+		}
+		logCtx.WithError(err).Error("Error reclaiming block")
+		return false, err
+	}
+	return false, errors.New("Max retries hit - excessive concurrent IPAM requests")
+}
+
+// restoreAffinity confirms the host's affinity to the block, if it is pending deletion.
+func (c ipamClient) restoreAffinity(ctx context.Context, change affinityChange) error {
+	aff, err := c.blockReaderWriter.queryAffinity(ctx, change.host, change.cidr, "")
+	if err != nil {
+		if _, ok := err.(cerrors.ErrorResourceDoesNotExist); ok {
+			return nil
+		}
+		return err
+	}
+	if aff.Value.(*model.BlockAffinity).State != model.StatePendingDeletion {
+		return nil
+	}
+	_, err = c.blockReaderWriter.confirmAffinity(ctx, aff)
+	return err
+}
+
+// migrationAllowed returns true if the block's affinity may be migrated to the new host: the
+// block's pool must select the new host, and the pool's borrowing policy must allow the new host
+// to use addresses from a block that is affine to the current host.  Blocks that are not in any
+// of the pools may be migrated to any host.
+func (c ipamClient) migrationAllowed(ctx context.Context, change affinityChange, pools []v3.IPPool) (bool, error) {
+	i := poolIndexForBlock(change.cidr, pools)
+	if i < 0 {
+		return true, nil
+	}
+	kvp, err := c.client.Get(ctx, model.ResourceKey{Kind: v3.KindNode, Name: change.newHost}, "")
+	if err != nil {
+		if _, ok := err.(cerrors.ErrorResourceDoesNotExist); ok {
+			return false, nil
+		}
+		return false, err
+	}
+	node := kvp.Value.(*v3.Node)
+	if sel, err := pools[i].SelectsNode(*node); err != nil || !sel {
+		return false, err
+	}
+	return c.borrowingAllowed(ctx, &pools[i], node, change.host)
+}
+
+// migrateBlockAffinity migrates the affinity of the block to the new host, provided that the
+// block is still sparsely used by the new host alone.  Returns true if the block was migrated.
+func (c ipamClient) migrateBlockAffinity(ctx context.Context, change affinityChange, threshold int) (bool, error) {
+	logCtx := log.WithFields(log.Fields{"host": change.host, "newHost": change.newHost, "cidr": change.cidr})
+	for i := 0; i < datastoreRetries; i++ {
+		obj, err := c.blockReaderWriter.queryBlock(ctx, change.cidr, "")
+		if err != nil {
+			if _, ok := err.(cerrors.ErrorResourceDoesNotExist); ok {
+				return false, nil
+			}
+			return false, err
+		}
+		b := allocationBlock{obj.Value.(*model.AllocationBlock)}
+		if getHostAffinity(b.AllocationBlock) != change.host || b.migrationTarget(threshold) != change.newHost {
+			logCtx.Info("Block has changed, not migrating its affinity")
+			return false, nil
+		}
+
+		err = c.blockReaderWriter.transferBlockAffinity(ctx, obj, change.host, change.newHost)
+		if err == nil {
+			logCtx.Info("Migrated block affinity")
+			return true, nil
+		}
+		if _, ok := err.(cerrors.ErrorResourceUpdateConflict); ok {
+			logCtx.WithError(err).Debug("CAS error migrating block affinity - retry")
+			continue
+		}
+		logCtx.WithError(err).Error("Error migrating block affinity")
+		return false, err
+	}
+	return false, errors.New("Max retries hit - excessive concurrent IPAM requests")
+}
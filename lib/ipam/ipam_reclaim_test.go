@@ -0,0 +1,76 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipam
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	cnet "github.com/unai-ttxu/libcalico-go/lib/net"
+)
+
+var _ = Describe("IPAM block reclaim", func() {
+	// newAffineBlock returns a block affine to the host, with an address assigned to each of
+	// the given hosts.
+	newAffineBlock := func(cidr, host string, users ...string) allocationBlock {
+		b := newBlock(cnet.MustParseNetwork(cidr))
+		affinity := "host:" + host
+		b.Affinity = &affinity
+		for _, user := range users {
+			_, err := b.autoAssign(1, nil, user, nil, false, nil, nil, 0)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		return b
+	}
+
+	cidrs := func(changes []affinityChange) []string {
+		var out []string
+		for _, c := range changes {
+			out = append(out, c.cidr.String()+" "+c.host+"->"+c.newHost)
+		}
+		return out
+	}
+
+	It("should release the empty blocks beyond the retention count for each host and IP version", func() {
+		blocks := []allocationBlock{
+			newAffineBlock("10.0.0.16/29", "host-a"),
+			newAffineBlock("10.0.0.8/29", "host-a"),
+			newAffineBlock("10.0.0.0/29", "host-a"),
+			newAffineBlock("10.0.0.24/29", "host-a", "host-a"),
+			newAffineBlock("fd00::/125", "host-a"),
+			newAffineBlock("10.0.0.32/29", "host-b"),
+		}
+		release, migrate := planReclaim(blocks, ReclaimBlocksArgs{RetainEmptyBlocks: 1})
+		Expect(cidrs(release)).To(Equal([]string{"10.0.0.8/29 host-a->", "10.0.0.16/29 host-a->"}))
+		Expect(migrate).To(BeEmpty())
+
+		release, _ = planReclaim(blocks, ReclaimBlocksArgs{Hosts: []string{"host-b"}})
+		Expect(cidrs(release)).To(Equal([]string{"10.0.0.32/29 host-b->"}))
+	})
+
+	It("should migrate sparsely used blocks to the host that uses them", func() {
+		blocks := []allocationBlock{
+			newAffineBlock("10.0.0.0/29", "host-a", "host-b", "host-b"),
+			newAffineBlock("10.0.0.8/29", "host-a", "host-b", "host-a"),
+			newAffineBlock("10.0.0.16/29", "host-a", "host-b", "host-c"),
+			newAffineBlock("10.0.0.24/29", "host-a", "host-b", "host-b", "host-b"),
+		}
+		_, migrate := planReclaim(blocks, ReclaimBlocksArgs{MigrateThreshold: 2})
+		Expect(cidrs(migrate)).To(Equal([]string{"10.0.0.0/29 host-a->host-b"}))
+
+		_, migrate = planReclaim(blocks, ReclaimBlocksArgs{})
+		Expect(migrate).To(BeEmpty())
+	})
+})
@@ -0,0 +1,237 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipam
+
+import (
+	"bytes"
+	"math"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	cnet "github.com/unai-ttxu/libcalico-go/lib/net"
+)
+
+// allocationStrategy determines the order in which the blocks of an IP pool are claimed, and the
+// order in which the addresses of a block are assigned.
+type allocationStrategy interface {
+	// blocks returns a generator of the pool's block CIDRs, in the order in which they should be
+	// claimed.  The generator returns nil when there are no blocks left.
+	blocks(pool v3.IPPool, host string) func() *cnet.IPNet
+
+	// ordinals returns the unallocated ordinals of the block, in the order in which they should be
+	// assigned to the handle.  The handle may be nil.
+	ordinals(b *allocationBlock, handleID *string) []int
+
+	// preferBlock returns true if addresses should be assigned to the handle from block b1 before
+	// block b2.  This is used to order a host's existing affine blocks.
+	preferBlock(b1, b2 *allocationBlock, handleID *string) bool
+
+	// usesReleases returns true if the strategy orders addresses by when, or by which handle, they
+	// were released, and so needs the releases to be recorded in the block.
+	usesReleases() bool
+}
+
+// strategyForPool returns the allocation strategy configured on the pool.  A nil pool uses the
+// default strategy.
+func strategyForPool(pool *v3.IPPool) allocationStrategy {
+	if pool == nil {
+		return randomStrategy{}
+	}
+	switch pool.Spec.AllocationStrategy {
+	case v3.AllocationStrategySequential:
+		return sequentialStrategy{}
+	case v3.AllocationStrategyLeastRecentlyReleased:
+		return leastRecentlyReleasedStrategy{}
+	case v3.AllocationStrategySticky:
+		return stickyStrategy{}
+	}
+	return randomStrategy{}
+}
+
+// strategyForBlock returns the allocation strategy of the pool (out of the supplied pools) that
+// contains the block.
+func strategyForBlock(cidr cnet.IPNet, pools []v3.IPPool) allocationStrategy {
+	if i := poolIndexForBlock(cidr, pools); i >= 0 {
+		return strategyForPool(&pools[i])
+	}
+	return randomStrategy{}
+}
+
+// poolIndexForBlock returns the index of the pool that contains the block, or -1 if there isn't one.
+func poolIndexForBlock(cidr cnet.IPNet, pools []v3.IPPool) int {
+	for i, p := range pools {
+		_, poolNet, err := cnet.ParseCIDR(p.Spec.CIDR)
+		if err != nil {
+			log.WithError(err).WithField("pool", p.Name).Warn("Error parsing pool CIDR")
+			continue
+		}
+		if poolNet.Contains(cidr.IP) {
+			return i
+		}
+	}
+	return -1
+}
+
+// randomStrategy claims blocks in a pseudo-random order seeded by the hostname, and assigns addresses
+// in the order in which they became free.
+type randomStrategy struct{}
+
+func (randomStrategy) blocks(pool v3.IPPool, host string) func() *cnet.IPNet {
+	return randomBlockGenerator(pool, host)
+}
+
+func (randomStrategy) ordinals(b *allocationBlock, handleID *string) []int {
+	return append([]int(nil), b.Unallocated...)
+}
+
+func (randomStrategy) preferBlock(b1, b2 *allocationBlock, handleID *string) bool {
+	return false
+}
+
+func (randomStrategy) usesReleases() bool {
+	return false
+}
+
+// sequentialStrategy claims blocks, and assigns addresses, lowest first.
+type sequentialStrategy struct{}
+
+func (sequentialStrategy) blocks(pool v3.IPPool, host string) func() *cnet.IPNet {
+	_, cidr, err := cnet.ParseCIDR(pool.Spec.CIDR)
+	if err != nil {
+		log.Errorf("Error parsing CIDR: %s %v", pool.Spec.CIDR, err)
+		return func() *cnet.IPNet { return nil }
+	}
+	return blockGenerator(&pool, *cidr)
+}
+
+func (sequentialStrategy) ordinals(b *allocationBlock, handleID *string) []int {
+	ordinals := append([]int(nil), b.Unallocated...)
+	sort.Ints(ordinals)
+	return ordinals
+}
+
+func (sequentialStrategy) preferBlock(b1, b2 *allocationBlock, handleID *string) bool {
+	return bytes.Compare(b1.CIDR.IP.To16(), b2.CIDR.IP.To16()) < 0
+}
+
+func (sequentialStrategy) usesReleases() bool {
+	return false
+}
+
+// leastRecentlyReleasedStrategy claims blocks in the same order as randomStrategy, and assigns the
+// addresses that have never been used first, followed by those that were released longest ago.
+type leastRecentlyReleasedStrategy struct{}
+
+func (leastRecentlyReleasedStrategy) blocks(pool v3.IPPool, host string) func() *cnet.IPNet {
+	return randomBlockGenerator(pool, host)
+}
+
+func (leastRecentlyReleasedStrategy) ordinals(b *allocationBlock, handleID *string) []int {
+	ordinals := append([]int(nil), b.Unallocated...)
+	sort.SliceStable(ordinals, func(i, j int) bool {
+		return b.releaseTime(ordinals[i]) < b.releaseTime(ordinals[j])
+	})
+	return ordinals
+}
+
+func (leastRecentlyReleasedStrategy) preferBlock(b1, b2 *allocationBlock, handleID *string) bool {
+	return oldestReleaseTime(b1) < oldestReleaseTime(b2)
+}
+
+func (leastRecentlyReleasedStrategy) usesReleases() bool {
+	return true
+}
+
+// oldestReleaseTime returns the release time of the block's least recently released free address,
+// or MaxInt64 if the block is full.
+func oldestReleaseTime(b *allocationBlock) int64 {
+	oldest := int64(math.MaxInt64)
+	for _, o := range b.Unallocated {
+		if t := b.releaseTime(o); t < oldest {
+			oldest = t
+		}
+	}
+	return oldest
+}
+
+// stickyStrategy assigns the addresses that were last held by the handle first.  Otherwise, it
+// behaves like leastRecentlyReleasedStrategy, which also avoids assigning the addresses that other
+// handles have recently released.
+type stickyStrategy struct {
+	leastRecentlyReleasedStrategy
+}
+
+func (s stickyStrategy) ordinals(b *allocationBlock, handleID *string) []int {
+	ordinals := s.leastRecentlyReleasedStrategy.ordinals(b, handleID)
+	if handleID == nil {
+		return ordinals
+	}
+
+	// Move the handle's own addresses to the front, most recently released first.
+	var own, others []int
+	for _, o := range ordinals {
+		if b.releasedBy(o, *handleID) {
+			own = append(own, o)
+		} else {
+			others = append(others, o)
+		}
+	}
+	for i, j := 0, len(own)-1; i < j; i, j = i+1, j-1 {
+		own[i], own[j] = own[j], own[i]
+	}
+	return append(own, others...)
+}
+
+func (s stickyStrategy) preferBlock(b1, b2 *allocationBlock, handleID *string) bool {
+	if handleID != nil {
+		held1, held2 := b1.heldByHandle(*handleID), b2.heldByHandle(*handleID)
+		if held1 != held2 {
+			return held1
+		}
+	}
+	return s.leastRecentlyReleasedStrategy.preferBlock(b1, b2, handleID)
+}
+
+// orderBlocks sorts the blocks into the order in which addresses should be assigned from them.  The
+// blocks are grouped by pool, in the order of the supplied pools, and then ordered by the allocation
+// strategy of the pool.
+func orderBlocks(blocks []*allocationBlock, pools []v3.IPPool, handleID *string) {
+	poolIndexes := map[*allocationBlock]int{}
+	for _, b := range blocks {
+		poolIndexes[b] = poolIndexForBlock(b.CIDR, pools)
+	}
+	sort.SliceStable(blocks, func(i, j int) bool {
+		pi, pj := poolIndexes[blocks[i]], poolIndexes[blocks[j]]
+		if pi != pj {
+			return pi < pj
+		}
+		if pi < 0 {
+			return false
+		}
+		return strategyForPool(&pools[pi]).preferBlock(blocks[i], blocks[j], handleID)
+	})
+}
+
+// usesDefaultStrategy returns true if all of the pools use the default allocation strategy.
+func usesDefaultStrategy(pools []v3.IPPool) bool {
+	for i := range pools {
+		if _, ok := strategyForPool(&pools[i]).(randomStrategy); !ok {
+			return false
+		}
+	}
+	return true
+}
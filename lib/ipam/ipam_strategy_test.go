@@ -0,0 +1,255 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipam
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	cnet "github.com/unai-ttxu/libcalico-go/lib/net"
+)
+
+var _ = Describe("IPAM allocation strategies", func() {
+	handleA := "handle-a"
+	handleB := "handle-b"
+
+	// newTestBlock returns a /29 block with the given unallocated ordinals (the rest are allocated),
+	// and release records.
+	newTestBlock := func(unallocated []int, released map[int]model.ReleasedAddress) *allocationBlock {
+		b := newBlock(cnet.MustParseNetwork("10.0.0.0/29"))
+		attr := b.findOrAddAttribute(nil, nil)
+		for o := range b.Allocations {
+			b.Allocations[o] = &attr
+		}
+		for _, o := range unallocated {
+			b.Allocations[o] = nil
+		}
+		b.Unallocated = unallocated
+		b.Released = released
+		return &b
+	}
+
+	assign := func(b *allocationBlock, num int, handleID *string, strategy allocationStrategy) []string {
+		ips, err := b.autoAssign(num, handleID, "host", nil, false, nil, strategy, 0)
+		Expect(err).NotTo(HaveOccurred())
+		var out []string
+		for _, ip := range ips {
+			out = append(out, ip.IP.String())
+		}
+		return out
+	}
+
+	DescribeTable("should pick the strategy configured on the pool",
+		func(strategy v3.AllocationStrategy, expected allocationStrategy) {
+			pool := v3.IPPool{Spec: v3.IPPoolSpec{CIDR: "10.0.0.0/24", AllocationStrategy: strategy}}
+			Expect(strategyForPool(&pool)).To(Equal(expected))
+		},
+		Entry("default", v3.AllocationStrategy(""), randomStrategy{}),
+		Entry("Random", v3.AllocationStrategyRandom, randomStrategy{}),
+		Entry("Sequential", v3.AllocationStrategy(v3.AllocationStrategySequential), sequentialStrategy{}),
+		Entry("LeastRecentlyReleased", v3.AllocationStrategy(v3.AllocationStrategyLeastRecentlyReleased), leastRecentlyReleasedStrategy{}),
+		Entry("Sticky", v3.AllocationStrategy(v3.AllocationStrategySticky), stickyStrategy{}),
+	)
+
+	It("should record released addresses and forget them when they are assigned", func() {
+		b := newBlock(cnet.MustParseNetwork("10.0.0.0/29"))
+		Expect(assign(&b, 2, &handleA, nil)).To(Equal([]string{"10.0.0.0", "10.0.0.1"}))
+
+		_, _, err := b.release([]cnet.IP{cnet.MustParseIP("10.0.0.1")}, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Released).To(HaveLen(1))
+		Expect(*b.Released[1].HandleID).To(Equal(handleA))
+		Expect(b.Released[1].Time).NotTo(BeZero())
+
+		Expect(b.releaseByHandle(handleA, true)).To(Equal(1))
+		Expect(b.Released).To(HaveLen(2))
+		Expect(b.releasedBy(0, handleA)).To(BeTrue())
+
+		Expect(b.assign(cnet.MustParseIP("10.0.0.0"), &handleB, nil, "host")).NotTo(HaveOccurred())
+		Expect(b.Released).To(HaveLen(1))
+		Expect(b.Released).To(HaveKey(1))
+	})
+
+	It("should not record released addresses unless asked to", func() {
+		b := newBlock(cnet.MustParseNetwork("10.0.0.0/29"))
+		Expect(assign(&b, 2, &handleA, nil)).To(Equal([]string{"10.0.0.0", "10.0.0.1"}))
+
+		Expect(b.releaseByHandle(handleA, true)).To(Equal(2))
+		Expect(b.Released).To(HaveLen(2))
+		Expect(assign(&b, 1, &handleB, sequentialStrategy{})).To(Equal([]string{"10.0.0.0"}))
+
+		_, _, err := b.release([]cnet.IP{cnet.MustParseIP("10.0.0.0")}, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Released).To(HaveLen(1))
+		Expect(b.releaseByHandle(handleA, false)).To(Equal(0))
+
+		Expect(assign(&b, 2, &handleB, sequentialStrategy{})).To(Equal([]string{"10.0.0.0", "10.0.0.1"}))
+		_, _, err = b.release([]cnet.IP{cnet.MustParseIP("10.0.0.0"), cnet.MustParseIP("10.0.0.1")}, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(b.Released).To(BeNil())
+	})
+
+	It("should only use the releases for the strategies that need them", func() {
+		Expect(randomStrategy{}.usesReleases()).To(BeFalse())
+		Expect(sequentialStrategy{}.usesReleases()).To(BeFalse())
+		Expect(leastRecentlyReleasedStrategy{}.usesReleases()).To(BeTrue())
+		Expect(stickyStrategy{}.usesReleases()).To(BeTrue())
+	})
+
+	Describe("Random", func() {
+		It("should assign addresses in the order that they became free", func() {
+			b := newTestBlock([]int{5, 2, 7}, nil)
+			Expect(assign(b, 2, nil, randomStrategy{})).To(Equal([]string{"10.0.0.5", "10.0.0.2"}))
+			Expect(b.Unallocated).To(Equal([]int{7}))
+		})
+
+		It("should be used when no strategy is given", func() {
+			b := newTestBlock([]int{5, 2, 7}, nil)
+			Expect(assign(b, 1, nil, nil)).To(Equal([]string{"10.0.0.5"}))
+		})
+
+		It("should not reorder blocks", func() {
+			b1 := newTestBlock([]int{1}, nil)
+			b2 := newTestBlock([]int{}, nil)
+			Expect(randomStrategy{}.preferBlock(b1, b2, nil)).To(BeFalse())
+			Expect(randomStrategy{}.preferBlock(b2, b1, nil)).To(BeFalse())
+		})
+	})
+
+	Describe("Sequential", func() {
+		It("should assign the lowest addresses first", func() {
+			b := newTestBlock([]int{5, 2, 7, 3}, nil)
+			Expect(assign(b, 2, nil, sequentialStrategy{})).To(Equal([]string{"10.0.0.2", "10.0.0.3"}))
+			Expect(b.Unallocated).To(Equal([]int{5, 7}))
+		})
+
+		It("should claim the lowest blocks first", func() {
+			pool := v3.IPPool{Spec: v3.IPPoolSpec{CIDR: "10.0.0.0/24", BlockSize: 26}}
+			blocks := sequentialStrategy{}.blocks(pool, "host")
+			var cidrs []string
+			for b := blocks(); b != nil; b = blocks() {
+				cidrs = append(cidrs, b.String())
+			}
+			Expect(cidrs).To(Equal([]string{"10.0.0.0/26", "10.0.0.64/26", "10.0.0.128/26", "10.0.0.192/26"}))
+		})
+	})
+
+	Describe("LeastRecentlyReleased", func() {
+		It("should assign unused addresses first, then those released longest ago", func() {
+			b := newTestBlock([]int{5, 3, 7, 6}, map[int]model.ReleasedAddress{
+				5: {Time: 300},
+				3: {Time: 100},
+				6: {Time: 200},
+			})
+			Expect(assign(b, 3, nil, leastRecentlyReleasedStrategy{})).To(Equal([]string{"10.0.0.7", "10.0.0.3", "10.0.0.6"}))
+			Expect(b.Unallocated).To(Equal([]int{5}))
+		})
+
+		It("should prefer the block with the least recently released free address", func() {
+			recent := newTestBlock([]int{1}, map[int]model.ReleasedAddress{1: {Time: 200}})
+			old := newTestBlock([]int{2, 3}, map[int]model.ReleasedAddress{2: {Time: 300}, 3: {Time: 100}})
+			full := newTestBlock([]int{}, nil)
+			Expect(leastRecentlyReleasedStrategy{}.preferBlock(old, recent, nil)).To(BeTrue())
+			Expect(leastRecentlyReleasedStrategy{}.preferBlock(recent, old, nil)).To(BeFalse())
+			Expect(leastRecentlyReleasedStrategy{}.preferBlock(recent, full, nil)).To(BeTrue())
+		})
+	})
+
+	Describe("Sticky", func() {
+		released := func() map[int]model.ReleasedAddress {
+			return map[int]model.ReleasedAddress{
+				3: {HandleID: &handleA, Time: 100},
+				5: {HandleID: &handleB, Time: 50},
+				6: {HandleID: &handleA, Time: 200},
+			}
+		}
+
+		It("should assign the addresses last held by the handle first", func() {
+			b := newTestBlock([]int{3, 5, 6, 7}, released())
+			Expect(assign(b, 4, &handleA, stickyStrategy{})).To(Equal([]string{"10.0.0.6", "10.0.0.3", "10.0.0.7", "10.0.0.5"}))
+		})
+
+		It("should behave like LeastRecentlyReleased for other handles", func() {
+			b := newTestBlock([]int{3, 5, 6, 7}, released())
+			Expect(assign(b, 4, nil, stickyStrategy{})).To(Equal([]string{"10.0.0.7", "10.0.0.5", "10.0.0.3", "10.0.0.6"}))
+		})
+
+		It("should prefer the block holding an address last held by the handle", func() {
+			held := newTestBlock([]int{3}, released())
+			unused := newTestBlock([]int{7}, nil)
+			Expect(stickyStrategy{}.preferBlock(held, unused, &handleA)).To(BeTrue())
+			Expect(stickyStrategy{}.preferBlock(unused, held, &handleA)).To(BeFalse())
+			Expect(stickyStrategy{}.preferBlock(unused, held, &handleB)).To(BeTrue())
+		})
+	})
+
+	Describe("release cooldown", func() {
+		It("should skip addresses released within the cooldown", func() {
+			now := time.Now().UnixNano()
+			b := newTestBlock([]int{3, 5, 6, 7}, map[int]model.ReleasedAddress{
+				3: {Time: now},
+				5: {Time: now - int64(2*time.Minute)},
+				6: {Time: now - int64(30*time.Second)},
+			})
+			Expect(b.numCoolingDownAddresses(nil, now, time.Minute)).To(Equal(2))
+			Expect(b.numCoolingDownAddresses(nil, now, 0)).To(Equal(0))
+
+			ips, err := b.autoAssign(4, nil, "host", nil, false, nil, leastRecentlyReleasedStrategy{}, time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ips).To(HaveLen(2))
+			Expect(ips[0].IP.String()).To(Equal("10.0.0.7"))
+			Expect(ips[1].IP.String()).To(Equal("10.0.0.5"))
+			Expect(b.Unallocated).To(ConsistOf(3, 6))
+		})
+
+		It("should not count reserved addresses as cooling down", func() {
+			now := time.Now().UnixNano()
+			b := newTestBlock([]int{3, 5}, map[int]model.ReleasedAddress{3: {Time: now}, 5: {Time: now}})
+			reserved := cnet.NewCIDRSet(cnet.MustParseNetwork("10.0.0.3/32"))
+			Expect(b.numCoolingDownAddresses(reserved, now, time.Minute)).To(Equal(1))
+		})
+	})
+
+	It("should order blocks by pool, and then by the pool's strategy", func() {
+		pools := []v3.IPPool{
+			{Spec: v3.IPPoolSpec{CIDR: "10.1.0.0/16", AllocationStrategy: v3.AllocationStrategySequential}},
+			{Spec: v3.IPPoolSpec{CIDR: "10.0.0.0/16"}},
+		}
+		newPoolBlock := func(cidr string) *allocationBlock {
+			b := newBlock(cnet.MustParseNetwork(cidr))
+			return &b
+		}
+		blocks := []*allocationBlock{
+			newPoolBlock("10.0.1.0/26"),
+			newPoolBlock("10.1.0.64/26"),
+			newPoolBlock("10.0.0.0/26"),
+			newPoolBlock("10.1.0.0/26"),
+		}
+		orderBlocks(blocks, pools, nil)
+
+		var cidrs []string
+		for _, b := range blocks {
+			cidrs = append(cidrs, b.CIDR.String())
+		}
+		Expect(cidrs).To(Equal([]string{"10.1.0.0/26", "10.1.0.64/26", "10.0.1.0/26", "10.0.0.0/26"}))
+		Expect(usesDefaultStrategy(pools)).To(BeFalse())
+		Expect(usesDefaultStrategy(pools[1:])).To(BeTrue())
+	})
+})
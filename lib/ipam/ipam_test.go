@@ -21,6 +21,7 @@ import (
 	"net"
 	"sort"
 	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
@@ -48,6 +49,9 @@ import (
 // data to be persisted in etcd.
 type ipPoolAccessor struct {
 	pools map[string]pool
+
+	// The borrowing policy of each pool, for those pools that don't use the default.
+	borrowingPolicies map[string]v3.BorrowingPolicy
 }
 
 type pool struct {
@@ -78,7 +82,7 @@ func (i *ipPoolAccessor) getPools(sorted []string, ipVersion int, caller string)
 	for _, p := range sorted {
 		c := cnet.MustParseCIDR(p)
 		if (ipVersion == 0) || (c.Version() == ipVersion) {
-			pool := v3.IPPool{Spec: v3.IPPoolSpec{CIDR: p, NodeSelector: i.pools[p].nodeSelector}}
+			pool := v3.IPPool{Spec: v3.IPPoolSpec{CIDR: p, NodeSelector: i.pools[p].nodeSelector, BorrowingPolicy: i.borrowingPolicies[p]}}
 			if i.pools[p].blockSize == 0 {
 				if ipVersion == 4 {
 					pool.Spec.BlockSize = 26
@@ -108,7 +112,7 @@ func (i *ipPoolAccessor) GetAllPools() ([]v3.IPPool, error) {
 }
 
 var (
-	ipPools = &ipPoolAccessor{pools: map[string]pool{}}
+	ipPools = &ipPoolAccessor{pools: map[string]pool{}, borrowingPolicies: map[string]v3.BorrowingPolicy{}}
 )
 
 type testArgsClaimAff struct {
@@ -1157,6 +1161,572 @@ var _ = testutils.E2eDatastoreDescribe("IPAM tests", testutils.DatastoreAll, fun
 		})
 	})
 
+	Describe("IPAM reservations", func() {
+		host := "host-a"
+		reserved := []string{"10.0.0.0/30", "10.0.0.64/26", "10.0.0.128/25"}
+
+		BeforeEach(func() {
+			bc.Clean()
+			deleteAllPools()
+
+			err := applyNode(bc, kc, host, nil)
+			Expect(err).NotTo(HaveOccurred())
+			applyPool("10.0.0.0/24", true, "")
+
+			res := v3.NewIPReservation()
+			res.Name = "reservation"
+			res.Spec.ReservedCIDRs = reserved
+			_, err = bc.Create(context.Background(), &model.KVPair{
+				Key:   model.ResourceKey{Kind: v3.KindIPReservation, Name: res.Name},
+				Value: res,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should not auto-assign reserved addresses", func() {
+			reservedSet := cnet.NewCIDRSet()
+			for _, r := range reserved {
+				reservedSet.Add(cnet.MustParseNetwork(r))
+			}
+
+			// Only 60 addresses in the pool are not reserved, and they are all in the first block.
+			v4, _, err := ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 64, Hostname: host})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v4).To(HaveLen(60))
+			for _, ip := range v4 {
+				Expect(reservedSet.ContainsIP(cnet.IP{IP: ip.IP})).To(BeFalse(), fmt.Sprintf("%s is reserved", ip.IP))
+			}
+			blocks := getAffineBlocks(bc, host)
+			Expect(blocks).To(HaveLen(1))
+			Expect(blocks[0].String()).To(Equal("10.0.0.0/26"))
+		})
+
+		It("should allow reserved addresses to be assigned explicitly", func() {
+			err := ic.AssignIP(context.Background(), AssignIPArgs{
+				IP:       cnet.MustParseIP("10.0.0.1"),
+				Hostname: host,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should report reservations separately in the utilization", func() {
+			_, _, err := ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 1, Hostname: host})
+			Expect(err).NotTo(HaveOccurred())
+
+			usage, err := ic.GetUtilization(context.Background(), GetUtilizationArgs{Pools: []string{"10.0.0.0/24"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(usage).To(HaveLen(1))
+			Expect(usage[0].Reserved).To(HaveLen(3))
+			Expect(usage[0].Reserved[0].String()).To(Equal("10.0.0.0/30"))
+			Expect(usage[0].Blocks).To(HaveLen(1))
+			Expect(usage[0].Blocks[0].CIDR.String()).To(Equal("10.0.0.0/26"))
+			Expect(usage[0].Blocks[0].Capacity).To(Equal(64))
+			Expect(usage[0].Blocks[0].Available).To(Equal(59))
+			Expect(usage[0].Blocks[0].Reserved).To(Equal(4))
+		})
+	})
+
+	Describe("IPAM release cooldown", func() {
+		host := "host-a"
+
+		BeforeEach(func() {
+			bc.Clean()
+			deleteAllPools()
+
+			err := applyNode(bc, kc, host, nil)
+			Expect(err).NotTo(HaveOccurred())
+			applyPoolWithBlockSize("10.0.0.0/30", true, "", 30)
+
+			err = ic.SetIPAMConfig(context.Background(), IPAMConfig{AutoAllocateBlocks: true, ReleaseCooldown: time.Hour})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should not auto-assign addresses until their cooldown has expired", func() {
+			v4, _, err := ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 4, Hostname: host})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v4).To(HaveLen(4))
+
+			released := cnet.IP{IP: v4[0].IP}
+			unallocated, err := ic.ReleaseIPs(context.Background(), []cnet.IP{released})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(unallocated).To(BeEmpty())
+
+			v4, _, err = ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 1, Hostname: host})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v4).To(BeEmpty())
+
+			usage, err := ic.GetUtilization(context.Background(), GetUtilizationArgs{Pools: []string{"10.0.0.0/30"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(usage[0].Blocks).To(HaveLen(1))
+			Expect(usage[0].Blocks[0].Available).To(Equal(0))
+			Expect(usage[0].Blocks[0].CoolingDown).To(Equal(1))
+
+			// The address can still be assigned explicitly.
+			err = ic.AssignIP(context.Background(), AssignIPArgs{IP: released, Hostname: host})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = ic.ReleaseIPs(context.Background(), []cnet.IP{released})
+			Expect(err).NotTo(HaveOccurred())
+
+			// Once the cooldown is disabled, the address is reused.
+			err = ic.SetIPAMConfig(context.Background(), IPAMConfig{AutoAllocateBlocks: true})
+			Expect(err).NotTo(HaveOccurred())
+			v4, _, err = ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 1, Hostname: host})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v4).To(HaveLen(1))
+			Expect(v4[0].IP.String()).To(Equal(released.String()))
+		})
+	})
+
+	Describe("IPAM batch operations", func() {
+		host := "host-a"
+		handles := []string{"handle-1", "handle-2", "handle-3"}
+
+		BeforeEach(func() {
+			bc.Clean()
+			deleteAllPools()
+
+			err := applyNode(bc, kc, host, nil)
+			Expect(err).NotTo(HaveOccurred())
+			applyPool("10.0.0.0/24", true, "")
+			applyPool("fe80:ba:ad:beef::00/120", true, "")
+		})
+
+		It("should assign and release addresses for a batch of handles", func() {
+			args := AutoAssignBatchArgs{Hostname: host}
+			for i := range handles {
+				args.Requests = append(args.Requests, AutoAssignRequest{
+					Num4:     2,
+					Num6:     i,
+					HandleID: &handles[i],
+					Attrs:    map[string]string{"pod": handles[i]},
+				})
+			}
+			results, err := ic.AutoAssignBatch(context.Background(), args)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results).To(HaveLen(3))
+
+			seen := map[string]bool{}
+			for i, r := range results {
+				Expect(*r.HandleID).To(Equal(handles[i]))
+				Expect(r.Error).NotTo(HaveOccurred())
+				Expect(r.IPv4).To(HaveLen(2))
+				Expect(r.IPv6).To(HaveLen(i))
+				for _, ip := range append(r.IPv4, r.IPv6...) {
+					Expect(seen[ip.IP.String()]).To(BeFalse(), fmt.Sprintf("%s assigned twice", ip.IP))
+					seen[ip.IP.String()] = true
+				}
+
+				ips, err := ic.IPsByHandle(context.Background(), handles[i])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ips).To(HaveLen(2 + i))
+				attrs, err := ic.GetAssignmentAttributes(context.Background(), cnet.IP{IP: r.IPv4[0].IP})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(attrs).To(Equal(map[string]string{"pod": handles[i]}))
+			}
+			Expect(getAffineBlocks(bc, host)).To(HaveLen(1))
+
+			releaseResults := ic.ReleaseByHandles(context.Background(), []string{"handle-1", "handle-3", "missing"})
+			Expect(releaseResults).To(HaveLen(3))
+			Expect(releaseResults[0]).To(Equal(ReleaseByHandleResult{HandleID: "handle-1"}))
+			Expect(releaseResults[1]).To(Equal(ReleaseByHandleResult{HandleID: "handle-3"}))
+			Expect(releaseResults[2].HandleID).To(Equal("missing"))
+			Expect(releaseResults[2].Error).To(BeAssignableToTypeOf(cerrors.ErrorResourceDoesNotExist{}))
+
+			_, err = ic.IPsByHandle(context.Background(), "handle-1")
+			Expect(err).To(HaveOccurred())
+			ips, err := ic.IPsByHandle(context.Background(), "handle-2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ips).To(HaveLen(3))
+		})
+
+		It("should reject IPv6 pools in the IPv4 pool list", func() {
+			_, err := ic.AutoAssignBatch(context.Background(), AutoAssignBatchArgs{
+				Requests:  []AutoAssignRequest{{Num4: 1}},
+				Hostname:  host,
+				IPv4Pools: []cnet.IPNet{cnet.MustParseNetwork("fe80:ba:ad:beef::00/120")},
+			})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("IPAM borrowing policy", func() {
+		BeforeEach(func() {
+			bc.Clean()
+			deleteAllPools()
+
+			zones := map[string]string{"host-a": "zone-1", "host-b": "zone-1", "host-c": "zone-2"}
+			for host, zone := range zones {
+				err := applyNode(bc, kc, host, map[string]string{v3.LabelZone: zone})
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		// assignFromFullPool claims the pool's only block for host-a, and then attempts to assign an
+		// address to the host, which can only be satisfied by borrowing from host-a.
+		assignFromFullPool := func(policy v3.BorrowingPolicy, host string) []cnet.IPNet {
+			applyPoolWithBorrowingPolicy("10.0.0.0/29", 29, policy)
+			v4, _, err := ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 1, Hostname: "host-a"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v4).To(HaveLen(1))
+
+			handle := "borrower"
+			v4, _, err = ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 1, Hostname: host, HandleID: &handle})
+			Expect(err).NotTo(HaveOccurred())
+			return v4
+		}
+
+		It("should borrow from any host by default", func() {
+			Expect(assignFromFullPool("", "host-c")).To(HaveLen(1))
+		})
+
+		It("should not borrow when the policy is Never", func() {
+			Expect(assignFromFullPool(v3.BorrowingPolicyNever, "host-b")).To(BeEmpty())
+		})
+
+		It("should only borrow within the same zone when the policy is SameZone", func() {
+			Expect(assignFromFullPool(v3.BorrowingPolicySameZone, "host-c")).To(BeEmpty())
+			v4, _, err := ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 1, Hostname: "host-b"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v4).To(HaveLen(1))
+		})
+
+		It("should report borrowed addresses", func() {
+			v4 := assignFromFullPool(v3.BorrowingPolicyAlways, "host-b")
+			Expect(v4).To(HaveLen(1))
+
+			borrowed, err := ic.BorrowedIPs(context.Background(), "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(borrowed).To(HaveLen(1))
+			Expect(borrowed[0].IP.String()).To(Equal(v4[0].IP.String()))
+			Expect(borrowed[0].Host).To(Equal("host-b"))
+			Expect(borrowed[0].BlockHost).To(Equal("host-a"))
+			Expect(*borrowed[0].HandleID).To(Equal("borrower"))
+
+			borrowed, err = ic.BorrowedIPs(context.Background(), "host-a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(borrowed).To(BeEmpty())
+
+			usage, err := ic.GetUtilization(context.Background(), GetUtilizationArgs{Pools: []string{"10.0.0.0/29"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(usage[0].BorrowedByHost).To(Equal(map[string]int{"host-b": 1}))
+			Expect(usage[0].Blocks[0].Borrowed).To(Equal(1))
+		})
+	})
+
+	Describe("IPAM block reclaim", func() {
+		BeforeEach(func() {
+			bc.Clean()
+			deleteAllPools()
+
+			for _, host := range []string{"host-a", "host-b"} {
+				err := applyNode(bc, kc, host, nil)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			applyPoolWithBlockSize("10.0.0.0/24", true, "", 29)
+		})
+
+		// claimBlocks makes the host claim the given number of blocks, and then releases the
+		// addresses so that the blocks are empty.
+		claimBlocks := func(host string, num int) {
+			handle := "burst-" + host
+			v4, _, err := ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: num * 8, Hostname: host, HandleID: &handle})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v4).To(HaveLen(num * 8))
+			Expect(ic.ReleaseByHandle(context.Background(), handle)).NotTo(HaveOccurred())
+		}
+
+		It("should reclaim empty blocks beyond the retention count", func() {
+			claimBlocks("host-a", 3)
+			claimBlocks("host-b", 1)
+
+			reclaims, err := ic.ReclaimBlocks(context.Background(), ReclaimBlocksArgs{RetainEmptyBlocks: 1})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reclaims).To(HaveLen(2))
+			Expect(reclaims[0].CIDR.String()).To(Equal("10.0.0.0/24"))
+			Expect(reclaims[0].ReclaimedBlocks).To(Equal(2))
+			Expect(reclaims[0].MigratedBlocks).To(Equal(0))
+			Expect(reclaims[1].ReclaimedBlocks).To(Equal(0))
+
+			Expect(getAffineBlocks(bc, "host-a")).To(HaveLen(1))
+			Expect(getAffineBlocks(bc, "host-b")).To(HaveLen(1))
+			blocks, err := bc.List(context.Background(), model.BlockListOptions{}, "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(blocks.KVPairs).To(HaveLen(2))
+		})
+
+		It("should not reclaim blocks that are in use", func() {
+			claimBlocks("host-a", 2)
+			v4, _, err := ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 1, Hostname: "host-a"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v4).To(HaveLen(1))
+
+			reclaims, err := ic.ReclaimBlocks(context.Background(), ReclaimBlocksArgs{Hosts: []string{"host-a"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reclaims[0].ReclaimedBlocks).To(Equal(1))
+			Expect(getAffineBlocks(bc, "host-a")).To(HaveLen(1))
+
+			attrs, err := ic.GetAssignmentAttributes(context.Background(), cnet.IP{IP: v4[0].IP})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(attrs).NotTo(BeNil())
+		})
+
+		It("should migrate the affinity of sparsely used blocks to the host that uses them", func() {
+			// Use a pool with a single block, so that host-b has to borrow from host-a.
+			deleteAllPools()
+			applyPoolWithBlockSize("10.0.0.0/29", true, "", 29)
+			v4, _, err := ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 1, Hostname: "host-a"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v4).To(HaveLen(1))
+			borrowed, _, err := ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 2, Hostname: "host-b"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(borrowed).To(HaveLen(2))
+
+			// The block is not migrated while host-a is still using it.
+			reclaims, err := ic.ReclaimBlocks(context.Background(), ReclaimBlocksArgs{MigrateThreshold: 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reclaims[0].MigratedBlocks).To(Equal(0))
+
+			_, err = ic.ReleaseIPs(context.Background(), []cnet.IP{{IP: v4[0].IP}})
+			Expect(err).NotTo(HaveOccurred())
+			reclaims, err = ic.ReclaimBlocks(context.Background(), ReclaimBlocksArgs{MigrateThreshold: 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reclaims[0].MigratedBlocks).To(Equal(1))
+			Expect(getAffineBlocks(bc, "host-a")).To(BeEmpty())
+			Expect(getAffineBlocks(bc, "host-b")).To(ConsistOf(cnet.MustParseNetwork("10.0.0.0/29")))
+
+			ips, err := ic.BorrowedIPs(context.Background(), "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ips).To(BeEmpty())
+		})
+
+		// borrowBlock makes host-b borrow the only address in use from host-a's block.
+		borrowBlock := func() {
+			v4, _, err := ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 1, Hostname: "host-a"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v4).To(HaveLen(1))
+			borrowed, _, err := ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 1, Hostname: "host-b"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(borrowed).To(HaveLen(1))
+			_, err = ic.ReleaseIPs(context.Background(), []cnet.IP{{IP: v4[0].IP}})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		It("should not migrate a block to a host that the pool does not select", func() {
+			deleteAllPools()
+			applyPoolWithBlockSize("10.0.0.0/29", true, "", 29)
+			borrowBlock()
+
+			applyPoolWithBlockSize("10.0.0.0/29", true, "!has(excluded)", 29)
+			Expect(applyNode(bc, kc, "host-b", map[string]string{"excluded": "true"})).NotTo(HaveOccurred())
+			reclaims, err := ic.ReclaimBlocks(context.Background(), ReclaimBlocksArgs{MigrateThreshold: 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reclaims[0].MigratedBlocks).To(Equal(0))
+			Expect(getAffineBlocks(bc, "host-a")).To(ConsistOf(cnet.MustParseNetwork("10.0.0.0/29")))
+		})
+
+		It("should not migrate a block to a host that the borrowing policy does not allow", func() {
+			deleteAllPools()
+			applyPoolWithBlockSize("10.0.0.0/29", true, "", 29)
+			borrowBlock()
+
+			applyPoolWithBorrowingPolicy("10.0.0.0/29", 29, v3.BorrowingPolicyNever)
+			reclaims, err := ic.ReclaimBlocks(context.Background(), ReclaimBlocksArgs{MigrateThreshold: 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reclaims[0].MigratedBlocks).To(Equal(0))
+			Expect(getAffineBlocks(bc, "host-a")).To(ConsistOf(cnet.MustParseNetwork("10.0.0.0/29")))
+		})
+	})
+
+	Describe("IPAM with node pod CIDRs", func() {
+		// setPodCIDR sets the pod CIDR of the Kubernetes node.
+		setPodCIDR := func(host string, podCIDR string) {
+			n, err := kc.CoreV1().Nodes().Get(host, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			n.Spec.PodCIDR = podCIDR
+			_, err = kc.CoreV1().Nodes().Update(n)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		BeforeEach(func() {
+			if kc == nil {
+				Skip("Pod CIDRs are only stored by the Kubernetes datastore")
+			}
+			bc.Clean()
+			deleteAllPools()
+
+			for _, host := range []string{"host-a", "host-b"} {
+				err := applyNode(bc, kc, host, nil)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			setPodCIDR("host-a", "10.0.0.0/25")
+			setPodCIDR("host-b", "10.0.0.128/25")
+			applyPoolWithBlockSize("10.0.0.0/24", true, "", 26)
+			applyPoolWithBlockSize("fd00::/112", true, "", 122)
+
+			err := ic.SetIPAMConfig(context.Background(), IPAMConfig{AutoAllocateBlocks: true, HonorPodCIDRs: true})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			if kc != nil {
+				setPodCIDR("host-a", "")
+				setPodCIDR("host-b", "")
+			}
+		})
+
+		It("should assign addresses from the host's pod CIDR, and from the pools for the other IP version", func() {
+			v4, v6, err := ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 1, Num6: 1, Hostname: "host-a"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v4).To(HaveLen(1))
+			Expect(v6).To(HaveLen(1))
+			podCIDR, pool6 := cnet.MustParseNetwork("10.0.0.0/25"), cnet.MustParseNetwork("fd00::/112")
+			Expect(podCIDR.Contains(v4[0].IP)).To(BeTrue())
+			Expect(pool6.Contains(v6[0].IP)).To(BeTrue())
+			Expect(getAffineBlocks(bc, "host-a")).To(ConsistOf(
+				cnet.MustParseNetwork("10.0.0.0/26"),
+				cnet.MustParseNetwork("10.0.0.64/26"),
+			))
+		})
+
+		It("should not claim blocks within the pod CIDRs of other hosts", func() {
+			// Fill host-a's pod CIDR, so that it needs another block.
+			v4, _, err := ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 129, Hostname: "host-a"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v4).To(HaveLen(128))
+			Expect(getAffineBlocks(bc, "host-a")).To(HaveLen(2))
+
+			v4, _, err = ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 1, Hostname: "host-b"})
+			Expect(err).NotTo(HaveOccurred())
+			podCIDR := cnet.MustParseNetwork("10.0.0.128/25")
+			Expect(podCIDR.Contains(v4[0].IP)).To(BeTrue())
+		})
+
+		It("should not claim blocks that overlap the pod CIDRs of other hosts", func() {
+			// Give host-b a pod CIDR that is smaller than a block.
+			setPodCIDR("host-b", "10.0.0.128/28")
+			v4, _, err := ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 129, Hostname: "host-a"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v4).To(HaveLen(129))
+			Expect(getAffineBlocks(bc, "host-a")).To(ConsistOf(
+				cnet.MustParseNetwork("10.0.0.0/26"),
+				cnet.MustParseNetwork("10.0.0.64/26"),
+				cnet.MustParseNetwork("10.0.0.192/26"),
+			))
+		})
+
+		It("should report utilization", func() {
+			_, _, err := ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 1, Hostname: "host-b"})
+			Expect(err).NotTo(HaveOccurred())
+
+			usage, err := ic.GetUtilization(context.Background(), GetUtilizationArgs{Pools: []string{"10.0.0.0/24"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(usage[0].Blocks).To(HaveLen(2))
+		})
+	})
+
+	Describe("IPAM audit log", func() {
+		host := "host-a"
+		handle := "audit-handle"
+
+		BeforeEach(func() {
+			bc.Clean()
+			deleteAllPools()
+
+			err := applyNode(bc, kc, host, nil)
+			Expect(err).NotTo(HaveOccurred())
+			applyPool("10.0.0.0/24", true, "")
+		})
+
+		It("should not be supported by the Kubernetes datastore", func() {
+			if config.Spec.DatastoreType != apiconfig.Kubernetes {
+				Skip("The audit log is supported by etcd")
+			}
+			_, err := ic.AllocationHistory(context.Background(), AllocationHistoryArgs{})
+			Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorOperationNotSupported{}))
+		})
+
+		It("should not record events when the audit log is disabled", func() {
+			if config.Spec.DatastoreType == apiconfig.Kubernetes {
+				Skip("The audit log is not supported by the Kubernetes datastore")
+			}
+			v4, _, err := ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 1, Hostname: host, HandleID: &handle})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v4).To(HaveLen(1))
+
+			events, err := ic.AllocationHistory(context.Background(), AllocationHistoryArgs{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(BeEmpty())
+		})
+
+		It("should record assignments and releases and query them", func() {
+			if config.Spec.DatastoreType == apiconfig.Kubernetes {
+				Skip("The audit log is not supported by the Kubernetes datastore")
+			}
+			err := ic.SetIPAMConfig(context.Background(), IPAMConfig{AutoAllocateBlocks: true, AuditRetention: time.Hour})
+			Expect(err).NotTo(HaveOccurred())
+
+			start := time.Now()
+			attrs := map[string]string{AttributePod: "pod-1", AttributeNamespace: "default"}
+			v4, _, err := ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 2, Hostname: host, HandleID: &handle, Attrs: attrs})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v4).To(HaveLen(2))
+			Expect(ic.ReleaseByHandle(context.Background(), handle)).NotTo(HaveOccurred())
+			other := "other-handle"
+			_, _, err = ic.AutoAssign(context.Background(), AutoAssignArgs{Num4: 1, Hostname: host, HandleID: &other})
+			Expect(err).NotTo(HaveOccurred())
+
+			ip := cnet.IP{IP: v4[0].IP}
+			events, err := ic.AllocationHistory(context.Background(), AllocationHistoryArgs{IP: &ip})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(len(events)).To(BeNumerically(">=", 2))
+			Expect(events[0].Type).To(Equal(AllocationEventAssign))
+			Expect(events[0].IP.String()).To(Equal(ip.String()))
+			Expect(*events[0].HandleID).To(Equal(handle))
+			Expect(events[0].Attrs).To(Equal(attrs))
+			Expect(events[0].Host).To(Equal(host))
+			Expect(events[1].Type).To(Equal(AllocationEventRelease))
+			Expect(events[1].Host).To(Equal(host))
+
+			events, err = ic.AllocationHistory(context.Background(), AllocationHistoryArgs{HandleID: &handle})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(HaveLen(4))
+
+			events, err = ic.AllocationHistory(context.Background(), AllocationHistoryArgs{Start: start, End: start.Add(-time.Second)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(BeEmpty())
+
+			events, err = ic.AllocationHistory(context.Background(), AllocationHistoryArgs{Start: start})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(HaveLen(5))
+		})
+
+		It("should keep the events of a block after the block is deleted", func() {
+			if config.Spec.DatastoreType == apiconfig.Kubernetes {
+				Skip("The audit log is not supported by the Kubernetes datastore")
+			}
+			err := ic.SetIPAMConfig(context.Background(), IPAMConfig{AutoAllocateBlocks: true, AuditRetention: time.Hour})
+			Expect(err).NotTo(HaveOccurred())
+
+			ip := cnet.MustParseIP("10.0.0.5")
+			err = ic.AssignIP(context.Background(), AssignIPArgs{IP: ip, HandleID: &handle, Hostname: host})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ic.ReleaseAffinity(context.Background(), cnet.MustParseNetwork("10.0.0.0/26"), host, false)).NotTo(HaveOccurred())
+			_, err = ic.ReleaseIPs(context.Background(), []cnet.IP{ip})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = bc.Get(context.Background(), model.BlockKey{CIDR: cnet.MustParseNetwork("10.0.0.0/26")}, "")
+			Expect(err).To(BeAssignableToTypeOf(cerrors.ErrorResourceDoesNotExist{}))
+
+			events, err := ic.AllocationHistory(context.Background(), AllocationHistoryArgs{IP: &ip})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(HaveLen(2))
+			Expect(events[0].Type).To(Equal(AllocationEventAssign))
+			Expect(events[0].Host).To(Equal(host))
+			Expect(events[1].Type).To(Equal(AllocationEventRelease))
+			Expect(*events[1].HandleID).To(Equal(handle))
+		})
+	})
+
 	Describe("IPAM AutoAssign from different pools - multi", func() {
 		host := "host-a"
 		pool1 := cnet.MustParseNetwork("10.0.0.0/24")
@@ -1532,6 +2102,36 @@ var _ = testutils.E2eDatastoreDescribe("IPAM tests", testutils.DatastoreAll, fun
 			Expect(err).NotTo(HaveOccurred())
 			Expect(*cfg2).To(Equal(cfg))
 		})
+
+		It("should only allow the release cooldown to be changed while allocations exist", func() {
+			deleteAllPools()
+			applyPool("10.0.0.0/24", true, "")
+			_, _, err := ic.AutoAssign(ctx, AutoAssignArgs{Num4: 1, Hostname: "host-a"})
+			Expect(err).NotTo(HaveOccurred())
+
+			cfg := IPAMConfig{AutoAllocateBlocks: true, ReleaseCooldown: 5 * time.Minute}
+			Expect(ic.SetIPAMConfig(ctx, cfg)).NotTo(HaveOccurred())
+			cfg2, err := ic.GetIPAMConfig(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*cfg2).To(Equal(cfg))
+
+			cfg.StrictAffinity = true
+			Expect(ic.SetIPAMConfig(ctx, cfg)).To(HaveOccurred())
+		})
+
+		It("should only allow the audit log to be enabled if the datastore supports it", func() {
+			cfg := IPAMConfig{AutoAllocateBlocks: true, AuditRetention: time.Hour}
+			err := ic.SetIPAMConfig(ctx, cfg)
+			if config.Spec.DatastoreType == apiconfig.Kubernetes {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("not supported by the datastore"))
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			cfg2, err := ic.GetIPAMConfig(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*cfg2).To(Equal(cfg))
+		})
 	})
 })
 
@@ -1637,6 +2237,7 @@ func getAffineBlocks(backend bapi.Client, host string) []cnet.IPNet {
 func deleteAllPools() {
 	log.Infof("Deleting all pools")
 	ipPools.pools = map[string]pool{}
+	ipPools.borrowingPolicies = map[string]v3.BorrowingPolicy{}
 }
 
 func applyPool(cidr string, enabled bool, nodeSelector string) {
@@ -1647,6 +2248,11 @@ func applyPoolWithBlockSize(cidr string, enabled bool, nodeSelector string, bloc
 	ipPools.pools[cidr] = pool{enabled: enabled, nodeSelector: nodeSelector, blockSize: blockSize}
 }
 
+func applyPoolWithBorrowingPolicy(cidr string, blockSize int, policy v3.BorrowingPolicy) {
+	ipPools.pools[cidr] = pool{enabled: true, blockSize: blockSize}
+	ipPools.borrowingPolicies[cidr] = policy
+}
+
 func applyNode(c bapi.Client, kc *kubernetes.Clientset, host string, labels map[string]string) error {
 	if kc != nil {
 		// If a k8s clientset was provided, create the node in Kubernetes.
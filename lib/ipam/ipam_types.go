@@ -16,7 +16,9 @@ package ipam
 
 import (
 	"net"
+	"time"
 
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
 	cnet "github.com/unai-ttxu/libcalico-go/lib/net"
 )
 
@@ -72,6 +74,92 @@ type AutoAssignArgs struct {
 	MaxBlocksPerHost int
 }
 
+// AutoAssignBatchArgs defines the set of arguments for assigning IP addresses to
+// a batch of handles on the same host.
+type AutoAssignBatchArgs struct {
+	// The requests to satisfy.  Each request is typically for a single handle.
+	Requests []AutoAssignRequest
+
+	// If specified, the hostname of the host on which IP addresses
+	// will be allocated.  If not specified, this will default
+	// to the value provided by os.Hostname.
+	Hostname string
+
+	// If specified, the previously configured IPv4 pools from which
+	// to assign IPv4 addresses.  If not specified, this defaults to all IPv4 pools.
+	IPv4Pools []cnet.IPNet
+
+	// If specified, the previously configured IPv6 pools from which
+	// to assign IPv6 addresses.  If not specified, this defaults to all IPv6 pools.
+	IPv6Pools []cnet.IPNet
+
+	// If non-zero, limit on the number of affine blocks this host is allowed to claim
+	// (per IP version).
+	MaxBlocksPerHost int
+}
+
+// AutoAssignRequest defines the addresses to assign for a single request in a batch.
+type AutoAssignRequest struct {
+	// The number of IPv4 addresses to automatically assign.
+	Num4 int
+
+	// The number of IPv6 addresses to automatically assign.
+	Num6 int
+
+	// If specified, a handle which can be used to retrieve / release
+	// the allocated IP addresses in the future.
+	HandleID *string
+
+	// A key/value mapping of metadata to store with the allocations.
+	Attrs map[string]string
+}
+
+// AutoAssignResult reports the IP addresses assigned for a single request in a batch.
+type AutoAssignResult struct {
+	// The handle of the request.
+	HandleID *string
+
+	// The assigned IPv4 addresses.
+	IPv4 []cnet.IPNet
+
+	// The assigned IPv6 addresses.
+	IPv6 []cnet.IPNet
+
+	// Set if the request could not be fully satisfied because of an error.  The
+	// addresses assigned before the error are still returned.
+	Error error
+}
+
+// ReleaseByHandleResult reports the result of releasing the IP addresses of a single
+// handle in a batch.
+type ReleaseByHandleResult struct {
+	// The handle whose addresses were released.
+	HandleID string
+
+	// Set if the handle's addresses could not be released.
+	Error error
+}
+
+// BorrowedIP describes an IP address that is assigned to a host other than the host that its
+// block is affine to.
+type BorrowedIP struct {
+	// The borrowed IP address.
+	IP cnet.IP
+
+	// The host that the address is assigned to.
+	Host string
+
+	// The host that the address's block is affine to.  This is empty if the block is not
+	// affine to any host.
+	BlockHost string
+
+	// The address's block.
+	Block cnet.IPNet
+
+	// The handle that the address was assigned with, if any.
+	HandleID *string
+}
+
 // IPAMConfig contains global configuration options for Calico IPAM.
 // This IPAM configuration is stored in the datastore and configures the behavior
 // of Calico IPAM across an entire Calico cluster.
@@ -85,6 +173,102 @@ type IPAMConfig struct {
 	// allocate blocks of IP address to hosts as needed to assign addresses.
 	// If false, then StrictAffinity must be true.  The default value is true.
 	AutoAllocateBlocks bool
+
+	// ReleaseCooldown is the minimum time after an address is released before it
+	// may be automatically assigned again.  Addresses can still be explicitly assigned
+	// during the cooldown.  The default value is zero, which disables the cooldown.
+	ReleaseCooldown time.Duration
+
+	// AuditRetention is how long assignments and releases of addresses are kept in the
+	// IPAM audit log.  The default value is zero, which disables the audit log.  The audit
+	// log is not supported by the Kubernetes datastore, so it cannot be enabled there.
+	AuditRetention time.Duration
+
+	// When HonorPodCIDRs is true, the pod CIDRs that Kubernetes allocates to each node
+	// are claimed as blocks affine to that node, and other nodes do not claim blocks within
+	// them.  This allows Calico IPAM to coexist with the Kubernetes node IPAM controller.
+	// The default value is false.
+	HonorPodCIDRs bool
+}
+
+// AllocationHistoryArgs defines the query for events in the IPAM audit log.  Only events that
+// match all of the specified fields are returned.
+type AllocationHistoryArgs struct {
+	// If specified, the IP address whose events are returned.
+	IP *cnet.IP
+
+	// If specified, the handle whose events are returned.
+	HandleID *string
+
+	// If specified, only events at or after this time are returned.
+	Start time.Time
+
+	// If specified, only events before this time are returned.
+	End time.Time
+}
+
+// AllocationEventType is the type of an event in the IPAM audit log.
+type AllocationEventType string
+
+const (
+	AllocationEventAssign  AllocationEventType = model.AllocationEventAssign
+	AllocationEventRelease AllocationEventType = model.AllocationEventRelease
+)
+
+// AllocationEvent is an assignment or release of an IP address, recorded in the IPAM audit log.
+type AllocationEvent struct {
+	// Whether the address was assigned or released.
+	Type AllocationEventType
+
+	// The IP address.
+	IP cnet.IP
+
+	// The handle that the address was assigned with, if any.
+	HandleID *string
+
+	// The attributes that the address was assigned with.
+	Attrs map[string]string
+
+	// The host that the address was assigned on, if known.
+	Host string
+
+	// The time of the event.
+	Time time.Time
+}
+
+// ReclaimBlocksArgs defines the set of arguments for reclaiming blocks.
+type ReclaimBlocksArgs struct {
+	// If specified, the hosts whose blocks should be reclaimed.  If not specified, this
+	// defaults to all hosts.
+	Hosts []string
+
+	// If specified, the pools whose blocks should be reclaimed.  Each string here can be a pool
+	// name or CIDR.  If not specified, this defaults to all pools.
+	Pools []string
+
+	// The number of empty blocks that each host keeps for each IP version, so that it can
+	// assign addresses without claiming a new block.  Empty blocks beyond this are reclaimed.
+	RetainEmptyBlocks int
+
+	// If non-zero, the affinity of any block with no more than this many addresses assigned,
+	// which are all assigned to a single host other than the block's host, is migrated to that
+	// host.  This avoids the need for a route to each borrowed address.
+	MigrateThreshold int
+}
+
+// PoolReclaim reports the blocks reclaimed from a single IP pool.
+type PoolReclaim struct {
+	// This pool's name.
+	Name string
+
+	// This pool's CIDR.
+	CIDR net.IPNet
+
+	// Number of empty blocks whose affinity was released.
+	ReclaimedBlocks int
+
+	// Number of blocks whose affinity was migrated to another host.
+	MigratedBlocks int
 }
 
 // GetUtilizationArgs defines the set of arguments for requesting IP utilization.
@@ -102,8 +286,22 @@ type BlockUtilization struct {
 	// Number of possible IPs in this block.
 	Capacity int
 
-	// Number of available IPs in this block.
+	// Number of available IPs in this block.  This does not include reserved IPs or IPs that are
+	// cooling down after release.
 	Available int
+
+	// Number of unallocated IPs in this block that are reserved, and so will not be automatically
+	// assigned.
+	Reserved int
+
+	// Number of unallocated IPs in this block that were released within the release cooldown, and
+	// so will not be automatically assigned until the cooldown expires.  This does not include
+	// reserved IPs.
+	CoolingDown int
+
+	// Number of IPs in this block that are assigned to hosts other than the host that the block
+	// is affine to.
+	Borrowed int
 }
 
 // PoolUtilization reports IP utilization for a single IP pool.
@@ -116,4 +314,11 @@ type PoolUtilization struct {
 
 	// Utilization for each of this pool's blocks.
 	Blocks []BlockUtilization
+
+	// The reserved ranges within this pool.
+	Reserved []net.IPNet
+
+	// The number of IPs in this pool that each host has borrowed from blocks that are affine to
+	// other hosts.
+	BorrowedByHost map[string]int
 }
@@ -0,0 +1,19 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+/*
+Package lint analyses a complete set of Calico policy resources and reports problems that cannot
+be detected by validating each resource in isolation, such as rules that are shadowed by earlier
+rules, policies that select no endpoints, and endpoints that are missed by egress policy.
+*/
+package lint
@@ -0,0 +1,437 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"fmt"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	"github.com/unai-ttxu/libcalico-go/lib/selector"
+)
+
+// FindingType identifies the kind of problem reported in a Finding.
+type FindingType string
+
+const (
+	// A rule that can never be hit because an earlier rule, in the same policy or in a policy
+	// with a lower order that applies to at least the same endpoints, matches all of the same
+	// traffic and has a terminal action.
+	FindingShadowedRule FindingType = "ShadowedRule"
+
+	// A policy whose selector does not match any of the supplied endpoints.
+	FindingUnmatchedSelector FindingType = "UnmatchedSelector"
+
+	// A rule whose Nets are entirely excluded by its NotNets, so the rule can never match.
+	FindingUnsatisfiableNets FindingType = "UnsatisfiableNets"
+
+	// Two policies with the same Order that apply to at least one common endpoint.  The
+	// policies are evaluated in name order, which is rarely what was intended.
+	FindingDuplicateOrder FindingType = "DuplicateOrder"
+
+	// An endpoint that is not selected by any policy that applies to egress, when egress policy
+	// is in use for other endpoints.  Egress traffic from the endpoint is not subject to the
+	// default deny that applies to the other endpoints.
+	FindingEgressGap FindingType = "EgressGap"
+)
+
+// PolicySet is the complete set of resources to lint.  The endpoints are used to determine which
+// endpoints each policy applies to, so should be all of the current endpoints.
+type PolicySet struct {
+	GlobalNetworkPolicies []apiv3.GlobalNetworkPolicy
+	NetworkPolicies       []apiv3.NetworkPolicy
+	Profiles              []apiv3.Profile
+	WorkloadEndpoints     []apiv3.WorkloadEndpoint
+	HostEndpoints         []apiv3.HostEndpoint
+}
+
+// Ref identifies the resource, and optionally the rule within that resource, that a Finding
+// refers to.  Direction is empty if the Ref does not refer to a rule, otherwise RuleIndex is the
+// index of the rule in the Ingress or Egress rules of the resource.
+type Ref struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Direction apiv3.PolicyType
+	RuleIndex int
+}
+
+func (r Ref) String() string {
+	name := r.Name
+	if r.Namespace != "" {
+		name = r.Namespace + "/" + r.Name
+	}
+	if r.Direction == "" {
+		return fmt.Sprintf("%s(%s)", r.Kind, name)
+	}
+	return fmt.Sprintf("%s(%s) %s rule %d", r.Kind, name, r.Direction, r.RuleIndex)
+}
+
+// Finding is a single problem found in the PolicySet.
+type Finding struct {
+	Type FindingType
+
+	// The resource (and rule) that the finding is about.
+	Ref Ref
+
+	// The other resource (and rule) involved in the finding, if any.  For a shadowed rule this is
+	// the rule that shadows it, and for a duplicate order this is the other policy.
+	Related *Ref
+
+	// A human readable description of the finding.
+	Message string
+}
+
+// Lint analyses the PolicySet as a whole and returns the findings, ordered by resource and then
+// by rule.
+func Lint(ps PolicySet) []Finding {
+	l := newLinter(ps)
+	l.checkUnsatisfiableNets()
+	l.checkShadowedRules()
+	l.checkUnmatchedSelectors()
+	l.checkDuplicateOrders()
+	l.checkEgressGaps()
+	sort.SliceStable(l.findings, func(i, j int) bool {
+		return refLess(l.findings[i].Ref, l.findings[j].Ref)
+	})
+	return l.findings
+}
+
+// policy is the linter's view of a GlobalNetworkPolicy or NetworkPolicy.
+type policy struct {
+	ref      Ref
+	order    *float64
+	selector selector.Selector
+	ingress  []apiv3.Rule
+	egress   []apiv3.Rule
+	types    []apiv3.PolicyType
+}
+
+// endpoint is the linter's view of a WorkloadEndpoint or HostEndpoint.
+type endpoint struct {
+	ref    Ref
+	labels map[string]string
+}
+
+type linter struct {
+	policies  []*policy
+	profiles  []*policy
+	endpoints []*endpoint
+	findings  []Finding
+}
+
+func newLinter(ps PolicySet) *linter {
+	l := &linter{}
+	for _, gnp := range ps.GlobalNetworkPolicies {
+		l.addPolicy(Ref{Kind: apiv3.KindGlobalNetworkPolicy, Name: gnp.Name},
+			gnp.Spec.Order, gnp.Spec.Selector, gnp.Spec.Ingress, gnp.Spec.Egress, gnp.Spec.Types)
+	}
+	for _, np := range ps.NetworkPolicies {
+		l.addPolicy(Ref{Kind: apiv3.KindNetworkPolicy, Namespace: np.Namespace, Name: np.Name},
+			np.Spec.Order, np.Spec.Selector, np.Spec.Ingress, np.Spec.Egress, np.Spec.Types)
+	}
+	sort.SliceStable(l.policies, func(i, j int) bool {
+		return policyLess(l.policies[i], l.policies[j])
+	})
+
+	for _, prof := range ps.Profiles {
+		l.profiles = append(l.profiles, &policy{
+			ref:     Ref{Kind: apiv3.KindProfile, Name: prof.Name},
+			ingress: prof.Spec.Ingress,
+			egress:  prof.Spec.Egress,
+			types:   []apiv3.PolicyType{apiv3.PolicyTypeIngress, apiv3.PolicyTypeEgress},
+		})
+	}
+
+	for _, wep := range ps.WorkloadEndpoints {
+		// Policy selectors may match on the namespace and orchestrator labels that are implicitly
+		// added to every workload endpoint.
+		labels := map[string]string{
+			apiv3.LabelNamespace:    wep.Namespace,
+			apiv3.LabelOrchestrator: wep.Spec.Orchestrator,
+		}
+		for k, v := range wep.Labels {
+			labels[k] = v
+		}
+		l.endpoints = append(l.endpoints, &endpoint{
+			ref:    Ref{Kind: apiv3.KindWorkloadEndpoint, Namespace: wep.Namespace, Name: wep.Name},
+			labels: labels,
+		})
+	}
+	for _, hep := range ps.HostEndpoints {
+		l.endpoints = append(l.endpoints, &endpoint{
+			ref:    Ref{Kind: apiv3.KindHostEndpoint, Name: hep.Name},
+			labels: hep.Labels,
+		})
+	}
+	return l
+}
+
+func (l *linter) addPolicy(ref Ref, order *float64, sel string, ingress, egress []apiv3.Rule, types []apiv3.PolicyType) {
+	parsed, err := selector.Parse(sel)
+	if err != nil {
+		// Invalid selectors are reported by the validator, so just skip the policy.
+		log.WithError(err).WithField("policy", ref).Debug("Skipping policy with invalid selector")
+		return
+	}
+	l.policies = append(l.policies, &policy{
+		ref:      ref,
+		order:    order,
+		selector: parsed,
+		ingress:  ingress,
+		egress:   egress,
+		types:    defaultPolicyTypes(ingress, egress, types),
+	})
+}
+
+func (l *linter) report(t FindingType, ref Ref, related *Ref, format string, args ...interface{}) {
+	l.findings = append(l.findings, Finding{
+		Type:    t,
+		Ref:     ref,
+		Related: related,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// checkUnsatisfiableNets reports rules where the NotNets exclude all of the Nets.
+func (l *linter) checkUnsatisfiableNets() {
+	for _, p := range append(append([]*policy{}, l.policies...), l.profiles...) {
+		p.iterRules(func(ref Ref, rule *apiv3.Rule) {
+			if entityNetsUnsatisfiable(rule.Source) {
+				l.report(FindingUnsatisfiableNets, ref, nil, "source nets are all excluded by the source notNets")
+			}
+			if entityNetsUnsatisfiable(rule.Destination) {
+				l.report(FindingUnsatisfiableNets, ref, nil, "destination nets are all excluded by the destination notNets")
+			}
+		})
+	}
+}
+
+// checkShadowedRules reports rules that are shadowed by an earlier rule.  Policies are considered
+// in the order they are applied; profile rules are only compared with the other rules in the same
+// profile.
+func (l *linter) checkShadowedRules() {
+	l.checkShadowedRulesIn(l.policies)
+	for _, p := range l.profiles {
+		l.checkShadowedRulesIn([]*policy{p})
+	}
+}
+
+func (l *linter) checkShadowedRulesIn(policies []*policy) {
+	type orderedRule struct {
+		policy *policy
+		ref    Ref
+		rule   *apiv3.Rule
+	}
+	var earlier []orderedRule
+	for _, p := range policies {
+		p.iterRules(func(ref Ref, rule *apiv3.Rule) {
+			for _, e := range earlier {
+				if e.ref.Direction != ref.Direction || !isTerminalAction(e.rule.Action) {
+					continue
+				}
+				if !e.policy.coversEndpointsOf(p) || !ruleCovers(e.rule, rule) {
+					continue
+				}
+				related := e.ref
+				l.report(FindingShadowedRule, ref, &related, "rule is shadowed by %s", related)
+				break
+			}
+			earlier = append(earlier, orderedRule{policy: p, ref: ref, rule: rule})
+		})
+	}
+}
+
+// checkUnmatchedSelectors reports policies that do not apply to any endpoint.
+func (l *linter) checkUnmatchedSelectors() {
+	for _, p := range l.policies {
+		if len(l.endpointsOf(p)) == 0 {
+			l.report(FindingUnmatchedSelector, p.ref, nil, "selector %q does not match any endpoint", p.selector.String())
+		}
+	}
+}
+
+// checkDuplicateOrders reports pairs of policies with the same order that apply to a common
+// endpoint, or that have the same selector and could apply to the same endpoints.  NetworkPolicies
+// in different namespaces can never apply to the same endpoint.
+func (l *linter) checkDuplicateOrders() {
+	for i, p1 := range l.policies {
+		if p1.order == nil {
+			continue
+		}
+		for _, p2 := range l.policies[i+1:] {
+			if p2.order == nil || *p2.order != *p1.order {
+				// Policies are sorted by order, so there are no more policies with this order.
+				break
+			}
+			sameSelector := p1.selector.UniqueID() == p2.selector.UniqueID() && p1.mayShareNamespace(p2)
+			if !sameSelector && !l.shareEndpoint(p1, p2) {
+				continue
+			}
+			related := p1.ref
+			l.report(FindingDuplicateOrder, p2.ref, &related,
+				"policy has the same order (%v) as %s and they apply to the same endpoints", *p1.order, related)
+		}
+	}
+}
+
+// checkEgressGaps reports endpoints that are not selected by any egress policy, if any endpoint
+// is selected by an egress policy.
+func (l *linter) checkEgressGaps() {
+	covered := map[*endpoint]bool{}
+	for _, p := range l.policies {
+		if !p.hasType(apiv3.PolicyTypeEgress) {
+			continue
+		}
+		for _, ep := range l.endpointsOf(p) {
+			covered[ep] = true
+		}
+	}
+	if len(covered) == 0 {
+		return
+	}
+	for _, ep := range l.endpoints {
+		if !covered[ep] {
+			l.report(FindingEgressGap, ep.ref, nil, "endpoint is not selected by any policy that applies to egress")
+		}
+	}
+}
+
+// endpointsOf returns the endpoints that the policy applies to.
+func (l *linter) endpointsOf(p *policy) []*endpoint {
+	var eps []*endpoint
+	for _, ep := range l.endpoints {
+		if p.appliesTo(ep) {
+			eps = append(eps, ep)
+		}
+	}
+	return eps
+}
+
+// shareEndpoint returns true if both policies apply to at least one common endpoint.
+func (l *linter) shareEndpoint(p1, p2 *policy) bool {
+	for _, ep := range l.endpoints {
+		if p1.appliesTo(ep) && p2.appliesTo(ep) {
+			return true
+		}
+	}
+	return false
+}
+
+// iterRules calls f for each rule in the policy that is applied, i.e. for each rule whose direction
+// is one of the policy types.
+func (p *policy) iterRules(f func(ref Ref, rule *apiv3.Rule)) {
+	for _, dir := range []apiv3.PolicyType{apiv3.PolicyTypeIngress, apiv3.PolicyTypeEgress} {
+		if !p.hasType(dir) {
+			continue
+		}
+		rules := p.ingress
+		if dir == apiv3.PolicyTypeEgress {
+			rules = p.egress
+		}
+		for i := range rules {
+			ref := p.ref
+			ref.Direction = dir
+			ref.RuleIndex = i
+			f(ref, &rules[i])
+		}
+	}
+}
+
+func (p *policy) hasType(t apiv3.PolicyType) bool {
+	for _, pt := range p.types {
+		if pt == t {
+			return true
+		}
+	}
+	return false
+}
+
+// appliesTo returns true if the policy applies to the endpoint.  NetworkPolicies only apply to
+// workload endpoints in the same namespace.
+func (p *policy) appliesTo(ep *endpoint) bool {
+	if p.ref.Namespace != "" && (ep.ref.Kind != apiv3.KindWorkloadEndpoint || ep.ref.Namespace != p.ref.Namespace) {
+		return false
+	}
+	return p.selector.Evaluate(ep.labels)
+}
+
+// mayShareNamespace returns true if the policies could apply to endpoints in the same namespace,
+// which is the case unless they are NetworkPolicies in different namespaces.
+func (p *policy) mayShareNamespace(other *policy) bool {
+	return p.ref.Namespace == "" || other.ref.Namespace == "" || p.ref.Namespace == other.ref.Namespace
+}
+
+// coversEndpointsOf returns true if this policy applies to every endpoint that the other policy
+// could apply to.  This is determined from the selectors rather than the current endpoints, and
+// so is conservative.
+func (p *policy) coversEndpointsOf(other *policy) bool {
+	if p == other || p.ref.Kind == apiv3.KindProfile {
+		return p == other
+	}
+	if p.ref.Namespace != "" && p.ref.Namespace != other.ref.Namespace {
+		return false
+	}
+	return p.selector.String() == "all()" || p.selector.UniqueID() == other.selector.UniqueID()
+}
+
+// defaultPolicyTypes returns the policy types, defaulted in the same way as the clientv3 does
+// when the policy is created.
+func defaultPolicyTypes(ingress, egress []apiv3.Rule, types []apiv3.PolicyType) []apiv3.PolicyType {
+	if len(types) != 0 {
+		return types
+	}
+	if len(egress) == 0 {
+		return []apiv3.PolicyType{apiv3.PolicyTypeIngress}
+	} else if len(ingress) == 0 {
+		return []apiv3.PolicyType{apiv3.PolicyTypeEgress}
+	}
+	return []apiv3.PolicyType{apiv3.PolicyTypeIngress, apiv3.PolicyTypeEgress}
+}
+
+// policyLess orders policies by order (with unset orders last) and then by name, which is the
+// order in which they are applied.
+func policyLess(p1, p2 *policy) bool {
+	if p1.order != nil && p2.order != nil && *p1.order != *p2.order {
+		return *p1.order < *p2.order
+	}
+	if (p1.order == nil) != (p2.order == nil) {
+		return p1.order != nil
+	}
+	return policyName(p1.ref) < policyName(p2.ref)
+}
+
+func policyName(ref Ref) string {
+	if ref.Namespace != "" {
+		return ref.Namespace + "/" + ref.Name
+	}
+	return ref.Name
+}
+
+func refLess(r1, r2 Ref) bool {
+	switch {
+	case r1.Kind != r2.Kind:
+		return r1.Kind < r2.Kind
+	case r1.Namespace != r2.Namespace:
+		return r1.Namespace < r2.Namespace
+	case r1.Name != r2.Name:
+		return r1.Name < r2.Name
+	case r1.Direction != r2.Direction:
+		return r1.Direction < r2.Direction
+	}
+	return r1.RuleIndex < r2.RuleIndex
+}
@@ -0,0 +1,280 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	"github.com/unai-ttxu/libcalico-go/lib/lint"
+	"github.com/unai-ttxu/libcalico-go/lib/numorstring"
+)
+
+var (
+	order10 = 10.0
+	order20 = 20.0
+	tcp     = numorstring.ProtocolFromString("TCP")
+)
+
+func gnp(name string, order *float64, selector string, ingress, egress []apiv3.Rule) apiv3.GlobalNetworkPolicy {
+	p := apiv3.NewGlobalNetworkPolicy()
+	p.Name = name
+	p.Spec.Order = order
+	p.Spec.Selector = selector
+	p.Spec.Ingress = ingress
+	p.Spec.Egress = egress
+	return *p
+}
+
+func np(namespace, name string, order *float64, selector string, ingress, egress []apiv3.Rule) apiv3.NetworkPolicy {
+	p := apiv3.NewNetworkPolicy()
+	p.Namespace = namespace
+	p.Name = name
+	p.Spec.Order = order
+	p.Spec.Selector = selector
+	p.Spec.Ingress = ingress
+	p.Spec.Egress = egress
+	return *p
+}
+
+func wep(namespace, name string, labels map[string]string) apiv3.WorkloadEndpoint {
+	w := apiv3.NewWorkloadEndpoint()
+	w.Namespace = namespace
+	w.Name = name
+	w.Labels = labels
+	w.Spec.Orchestrator = "k8s"
+	return *w
+}
+
+func findingsOfType(findings []lint.Finding, t lint.FindingType) []lint.Finding {
+	var out []lint.Finding
+	for _, f := range findings {
+		if f.Type == t {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+var _ = Describe("Policy lint", func() {
+	endpoints := []apiv3.WorkloadEndpoint{
+		wep("ns1", "wep1", map[string]string{"app": "frontend"}),
+		wep("ns1", "wep2", map[string]string{"app": "backend"}),
+		wep("ns2", "wep3", map[string]string{"app": "backend"}),
+	}
+
+	It("should return no findings for a clean policy set", func() {
+		findings := lint.Lint(lint.PolicySet{
+			GlobalNetworkPolicies: []apiv3.GlobalNetworkPolicy{
+				gnp("allow-dns", &order10, "all()", nil, []apiv3.Rule{{
+					Action:      apiv3.Allow,
+					Protocol:    &tcp,
+					Destination: apiv3.EntityRule{Ports: []numorstring.Port{numorstring.SinglePort(53)}},
+				}}),
+			},
+			NetworkPolicies: []apiv3.NetworkPolicy{
+				np("ns1", "backend", &order20, "app == 'backend'", []apiv3.Rule{{
+					Action: apiv3.Allow,
+					Source: apiv3.EntityRule{Selector: "app == 'frontend'"},
+				}}, nil),
+			},
+			WorkloadEndpoints: endpoints,
+		})
+		Expect(findings).To(BeEmpty())
+	})
+
+	It("should report rules that are shadowed within a policy", func() {
+		findings := lint.Lint(lint.PolicySet{
+			GlobalNetworkPolicies: []apiv3.GlobalNetworkPolicy{
+				gnp("policy", &order10, "all()", []apiv3.Rule{
+					{
+						Action:      apiv3.Deny,
+						Protocol:    &tcp,
+						Destination: apiv3.EntityRule{Ports: []numorstring.Port{mustPortRange(1000, 2000)}},
+					},
+					{
+						Action:      apiv3.Allow,
+						Protocol:    &tcp,
+						Destination: apiv3.EntityRule{Ports: []numorstring.Port{numorstring.SinglePort(1500)}},
+					},
+					{
+						Action:      apiv3.Allow,
+						Protocol:    &tcp,
+						Destination: apiv3.EntityRule{Ports: []numorstring.Port{numorstring.SinglePort(2500)}},
+					},
+				}, nil),
+			},
+			WorkloadEndpoints: endpoints,
+		})
+		Expect(findings).To(Equal([]lint.Finding{{
+			Type: lint.FindingShadowedRule,
+			Ref: lint.Ref{
+				Kind:      apiv3.KindGlobalNetworkPolicy,
+				Name:      "policy",
+				Direction: apiv3.PolicyTypeIngress,
+				RuleIndex: 1,
+			},
+			Related: &lint.Ref{
+				Kind:      apiv3.KindGlobalNetworkPolicy,
+				Name:      "policy",
+				Direction: apiv3.PolicyTypeIngress,
+				RuleIndex: 0,
+			},
+			Message: "rule is shadowed by GlobalNetworkPolicy(policy) Ingress rule 0",
+		}}))
+	})
+
+	It("should report rules that are shadowed by a lower order policy", func() {
+		findings := lint.Lint(lint.PolicySet{
+			GlobalNetworkPolicies: []apiv3.GlobalNetworkPolicy{
+				gnp("deny-external", &order10, "all()", []apiv3.Rule{{
+					Action: apiv3.Deny,
+					Source: apiv3.EntityRule{Nets: []string{"0.0.0.0/0"}, NotNets: []string{"10.0.0.0/8"}},
+				}}, nil),
+			},
+			NetworkPolicies: []apiv3.NetworkPolicy{
+				np("ns1", "allow-partner", &order20, "app == 'backend'", []apiv3.Rule{
+					{Action: apiv3.Allow, Source: apiv3.EntityRule{Nets: []string{"192.0.2.0/24"}}},
+					{Action: apiv3.Allow, Source: apiv3.EntityRule{Nets: []string{"10.1.0.0/16"}}},
+				}, nil),
+			},
+			WorkloadEndpoints: endpoints,
+		})
+		shadowed := findingsOfType(findings, lint.FindingShadowedRule)
+		Expect(shadowed).To(HaveLen(1))
+		Expect(shadowed[0].Ref).To(Equal(lint.Ref{
+			Kind:      apiv3.KindNetworkPolicy,
+			Namespace: "ns1",
+			Name:      "allow-partner",
+			Direction: apiv3.PolicyTypeIngress,
+			RuleIndex: 0,
+		}))
+		Expect(shadowed[0].Related.Name).To(Equal("deny-external"))
+	})
+
+	It("should not report rules shadowed by a policy that applies to different endpoints", func() {
+		findings := lint.Lint(lint.PolicySet{
+			NetworkPolicies: []apiv3.NetworkPolicy{
+				np("ns1", "deny-all", &order10, "app == 'frontend'", []apiv3.Rule{{Action: apiv3.Deny}}, nil),
+				np("ns1", "allow-all", &order20, "app == 'backend'", []apiv3.Rule{{Action: apiv3.Allow}}, nil),
+			},
+			WorkloadEndpoints: endpoints,
+		})
+		Expect(findingsOfType(findings, lint.FindingShadowedRule)).To(BeEmpty())
+	})
+
+	It("should report rules whose notNets exclude all of their nets", func() {
+		findings := lint.Lint(lint.PolicySet{
+			Profiles: []apiv3.Profile{{
+				ObjectMeta: apiv3.NewProfile().ObjectMeta,
+				Spec: apiv3.ProfileSpec{
+					Egress: []apiv3.Rule{
+						{
+							Action: apiv3.Allow,
+							Destination: apiv3.EntityRule{
+								Nets:    []string{"10.0.0.0/24", "10.0.1.0/24"},
+								NotNets: []string{"10.0.0.0/23"},
+							},
+						},
+						{
+							Action: apiv3.Allow,
+							Destination: apiv3.EntityRule{
+								Nets:    []string{"10.0.0.0/16"},
+								NotNets: []string{"10.0.0.0/23"},
+							},
+						},
+					},
+				},
+			}},
+		})
+		Expect(findings).To(HaveLen(1))
+		Expect(findings[0].Type).To(Equal(lint.FindingUnsatisfiableNets))
+		Expect(findings[0].Ref.Direction).To(Equal(apiv3.PolicyTypeEgress))
+		Expect(findings[0].Ref.RuleIndex).To(Equal(0))
+	})
+
+	It("should report policies that select no endpoints", func() {
+		findings := lint.Lint(lint.PolicySet{
+			GlobalNetworkPolicies: []apiv3.GlobalNetworkPolicy{
+				gnp("nothing", &order10, "app == 'database'", []apiv3.Rule{{Action: apiv3.Allow}}, nil),
+			},
+			NetworkPolicies: []apiv3.NetworkPolicy{
+				np("ns2", "frontend", &order20, "app == 'frontend'", []apiv3.Rule{{Action: apiv3.Allow}}, nil),
+				np("ns2", "namespace", &order20, "projectcalico.org/namespace == 'ns2'", []apiv3.Rule{{Action: apiv3.Allow}}, nil),
+			},
+			WorkloadEndpoints: endpoints,
+		})
+		unmatched := findingsOfType(findings, lint.FindingUnmatchedSelector)
+		Expect(unmatched).To(HaveLen(2))
+		Expect(unmatched[0].Ref.Name).To(Equal("nothing"))
+		Expect(unmatched[1].Ref.Name).To(Equal("frontend"))
+	})
+
+	It("should report policies with the same order that apply to the same endpoints", func() {
+		findings := lint.Lint(lint.PolicySet{
+			GlobalNetworkPolicies: []apiv3.GlobalNetworkPolicy{
+				gnp("backend-a", &order10, "app == 'backend'", []apiv3.Rule{{Action: apiv3.Allow}}, nil),
+				gnp("backend-b", &order10, "has(app)", []apiv3.Rule{{Action: apiv3.Allow}}, nil),
+				gnp("frontend", &order20, "app == 'frontend'", []apiv3.Rule{{Action: apiv3.Allow}}, nil),
+			},
+			NetworkPolicies: []apiv3.NetworkPolicy{
+				np("ns2", "other", &order20, "app == 'backend'", []apiv3.Rule{{Action: apiv3.Allow}}, nil),
+			},
+			WorkloadEndpoints: endpoints,
+		})
+		Expect(findingsOfType(findings, lint.FindingDuplicateOrder)).To(Equal([]lint.Finding{{
+			Type:    lint.FindingDuplicateOrder,
+			Ref:     lint.Ref{Kind: apiv3.KindGlobalNetworkPolicy, Name: "backend-b"},
+			Related: &lint.Ref{Kind: apiv3.KindGlobalNetworkPolicy, Name: "backend-a"},
+			Message: "policy has the same order (10) as GlobalNetworkPolicy(backend-a) and they apply to the same endpoints",
+		}}))
+	})
+
+	It("should not report NetworkPolicies in different namespaces with the same order and selector", func() {
+		findings := lint.Lint(lint.PolicySet{
+			NetworkPolicies: []apiv3.NetworkPolicy{
+				np("ns1", "backend", &order10, "app == 'backend'", []apiv3.Rule{{Action: apiv3.Allow}}, nil),
+				np("ns2", "backend", &order10, "app == 'backend'", []apiv3.Rule{{Action: apiv3.Allow}}, nil),
+			},
+			WorkloadEndpoints: endpoints,
+		})
+		Expect(findingsOfType(findings, lint.FindingDuplicateOrder)).To(BeEmpty())
+	})
+
+	It("should report endpoints that are missed by egress policy", func() {
+		findings := lint.Lint(lint.PolicySet{
+			NetworkPolicies: []apiv3.NetworkPolicy{
+				np("ns1", "default-deny", &order20, "all()", nil, []apiv3.Rule{{Action: apiv3.Deny}}),
+			},
+			HostEndpoints: []apiv3.HostEndpoint{{
+				ObjectMeta: apiv3.NewHostEndpoint().ObjectMeta,
+			}},
+			WorkloadEndpoints: endpoints,
+		})
+		gaps := findingsOfType(findings, lint.FindingEgressGap)
+		Expect(gaps).To(HaveLen(2))
+		Expect(gaps[0].Ref.Kind).To(Equal(apiv3.KindHostEndpoint))
+		Expect(gaps[1].Ref).To(Equal(lint.Ref{Kind: apiv3.KindWorkloadEndpoint, Namespace: "ns2", Name: "wep3"}))
+	})
+})
+
+func mustPortRange(min, max uint16) numorstring.Port {
+	p, err := numorstring.PortFromRange(min, max)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"reflect"
+	"strings"
+
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	cnet "github.com/unai-ttxu/libcalico-go/lib/net"
+	"github.com/unai-ttxu/libcalico-go/lib/numorstring"
+)
+
+var allNets = cnet.NewCIDRSet(cnet.MustParseNetwork("0.0.0.0/0"), cnet.MustParseNetwork("::/0"))
+
+// isTerminalAction returns true if a rule with the action stops the processing of later rules.
+func isTerminalAction(action apiv3.Action) bool {
+	return action == apiv3.Allow || action == apiv3.Deny || action == apiv3.Pass
+}
+
+// ruleCovers returns true if r1 matches all of the traffic that r2 matches.  Match criteria that
+// cannot easily be compared are treated as different, so this may return false for rules that do
+// actually cover each other.
+func ruleCovers(r1, r2 *apiv3.Rule) bool {
+	if r1.IPVersion != nil && (r2.IPVersion == nil || *r1.IPVersion != *r2.IPVersion) {
+		return false
+	}
+	if r1.Protocol != nil && (r2.Protocol == nil || r1.Protocol.String() != r2.Protocol.String()) {
+		return false
+	}
+	if r1.NotProtocol != nil {
+		excluded := r2.NotProtocol != nil && r2.NotProtocol.String() == r1.NotProtocol.String()
+		other := r2.Protocol != nil && r2.Protocol.String() != r1.NotProtocol.String()
+		if !excluded && !other {
+			return false
+		}
+	}
+	if !optionalEqual(r1.ICMP, r2.ICMP) || !optionalEqual(r1.NotICMP, r2.NotICMP) || !optionalEqual(r1.HTTP, r2.HTTP) {
+		return false
+	}
+	return entityCovers(r1.Source, r2.Source) && entityCovers(r1.Destination, r2.Destination)
+}
+
+// entityCovers returns true if e1 matches all of the traffic that e2 matches.
+func entityCovers(e1, e2 apiv3.EntityRule) bool {
+	if !selectorCovers(e1.Selector, e2.Selector) ||
+		!selectorCovers(e1.NotSelector, e2.NotSelector) ||
+		!selectorCovers(e1.NamespaceSelector, e2.NamespaceSelector) {
+		return false
+	}
+	if e1.ServiceAccounts != nil && !reflect.DeepEqual(e1.ServiceAccounts, e2.ServiceAccounts) {
+		return false
+	}
+	if len(e1.NotPorts) != 0 && !reflect.DeepEqual(e1.NotPorts, e2.NotPorts) {
+		return false
+	}
+	if !portsCover(e1.Ports, e2.Ports) || !domainsCover(e1.Domains, e2.Domains) {
+		return false
+	}
+	if len(e1.Nets) == 0 && len(e1.NotNets) == 0 {
+		return true
+	}
+	return entityNets(e2).Subtract(entityNets(e1)).Empty()
+}
+
+// entityNetsUnsatisfiable returns true if the entity has Nets that are all excluded by its NotNets.
+func entityNetsUnsatisfiable(e apiv3.EntityRule) bool {
+	return len(e.Nets) != 0 && len(e.NotNets) != 0 && entityNets(e).Empty()
+}
+
+// entityNets returns the set of addresses that the entity's Nets and NotNets match.
+func entityNets(e apiv3.EntityRule) *cnet.CIDRSet {
+	nets := allNets
+	if len(e.Nets) != 0 {
+		nets = parseNets(e.Nets)
+	}
+	return nets.Subtract(parseNets(e.NotNets))
+}
+
+func parseNets(nets []string) *cnet.CIDRSet {
+	s := cnet.NewCIDRSet()
+	for _, n := range nets {
+		if _, ipNet, err := cnet.ParseCIDROrIP(n); err == nil {
+			s.Add(*ipNet)
+		}
+	}
+	return s
+}
+
+func selectorCovers(s1, s2 string) bool {
+	s1 = strings.TrimSpace(s1)
+	return s1 == "" || s1 == strings.TrimSpace(s2)
+}
+
+// portsCover returns true if every port matched by p2 is matched by p1.
+func portsCover(p1, p2 []numorstring.Port) bool {
+	if len(p1) == 0 {
+		return true
+	}
+	if len(p2) == 0 {
+		return false
+	}
+	for _, port2 := range p2 {
+		covered := false
+		for _, port1 := range p1 {
+			if port1.PortName != "" || port2.PortName != "" {
+				covered = port1.PortName == port2.PortName
+			} else {
+				covered = port1.MinPort <= port2.MinPort && port2.MaxPort <= port1.MaxPort
+			}
+			if covered {
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+// domainsCover returns true if every domain matched by d2 is also listed in d1.
+func domainsCover(d1, d2 []string) bool {
+	if len(d1) == 0 {
+		return true
+	}
+	if len(d2) == 0 {
+		return false
+	}
+	listed := map[string]bool{}
+	for _, d := range d1 {
+		listed[strings.ToLower(d)] = true
+	}
+	for _, d := range d2 {
+		if !listed[strings.ToLower(d)] {
+			return false
+		}
+	}
+	return true
+}
+
+// optionalEqual returns true if v1 is nil, or if v1 and v2 are equal.
+func optionalEqual(v1, v2 interface{}) bool {
+	if reflect.ValueOf(v1).IsNil() {
+		return true
+	}
+	return reflect.DeepEqual(v1, v2)
+}
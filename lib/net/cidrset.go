@@ -0,0 +1,341 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package net
+
+import (
+	"net"
+)
+
+// CIDRSet is a set of IPv4 and IPv6 addresses, stored as a binary radix tree of CIDRs.
+//
+// The set is kept in its minimal form: a CIDR that is fully contained in the set is stored as a
+// single node, so the CIDRs returned by Nets() and Iter() are the smallest set of CIDRs that covers
+// exactly the addresses in the set.  The zero value is an empty set ready for use.
+type CIDRSet struct {
+	v4 *cidrNode
+	v6 *cidrNode
+}
+
+// cidrNode is a node in the radix tree.  The node at depth d represents the CIDR whose prefix is
+// the path from the root, with prefix length d.  A full node represents a CIDR that is entirely in
+// the set, and never has children.  Nodes that are empty are removed, and nodes where both children
+// are full are collapsed into a single full node.
+type cidrNode struct {
+	children [2]*cidrNode
+	full     bool
+}
+
+// NewCIDRSet returns a CIDRSet containing the union of the supplied CIDRs.
+func NewCIDRSet(nets ...IPNet) *CIDRSet {
+	s := &CIDRSet{}
+	for _, n := range nets {
+		s.Add(n)
+	}
+	return s
+}
+
+// Add adds all of the addresses in the CIDR to the set.  Invalid CIDRs are ignored.
+func (s *CIDRSet) Add(n IPNet) {
+	ip, prefixLen, ok := cidrSetKey(n)
+	if !ok {
+		return
+	}
+	root := s.root(ip)
+	*root = (*root).add(ip, 0, prefixLen)
+}
+
+// Remove removes all of the addresses in the CIDR from the set.  Invalid CIDRs are ignored.
+func (s *CIDRSet) Remove(n IPNet) {
+	ip, prefixLen, ok := cidrSetKey(n)
+	if !ok {
+		return
+	}
+	root := s.root(ip)
+	*root = (*root).remove(ip, 0, prefixLen)
+}
+
+// Contains returns true if every address in the CIDR is in the set.
+func (s *CIDRSet) Contains(n IPNet) bool {
+	ip, prefixLen, ok := cidrSetKey(n)
+	if !ok {
+		return false
+	}
+	return (*s.root(ip)).contains(ip, 0, prefixLen)
+}
+
+// ContainsIP returns true if the IP address is in the set.
+func (s *CIDRSet) ContainsIP(ip IP) bool {
+	if ip.To4() != nil {
+		return s.Contains(IPNet{net.IPNet{IP: ip.IP, Mask: net.CIDRMask(32, 32)}})
+	}
+	return s.Contains(IPNet{net.IPNet{IP: ip.IP, Mask: net.CIDRMask(128, 128)}})
+}
+
+// Overlaps returns true if any address in the CIDR is in the set.
+func (s *CIDRSet) Overlaps(n IPNet) bool {
+	ip, prefixLen, ok := cidrSetKey(n)
+	if !ok {
+		return false
+	}
+	return (*s.root(ip)).overlaps(ip, 0, prefixLen)
+}
+
+// Empty returns true if the set contains no addresses.
+func (s *CIDRSet) Empty() bool {
+	return s.v4 == nil && s.v6 == nil
+}
+
+// Union returns a new set containing the addresses that are in either set.
+func (s *CIDRSet) Union(other *CIDRSet) *CIDRSet {
+	return &CIDRSet{
+		v4: unionNodes(s.v4, other.v4),
+		v6: unionNodes(s.v6, other.v6),
+	}
+}
+
+// Intersect returns a new set containing the addresses that are in both sets.
+func (s *CIDRSet) Intersect(other *CIDRSet) *CIDRSet {
+	return &CIDRSet{
+		v4: intersectNodes(s.v4, other.v4),
+		v6: intersectNodes(s.v6, other.v6),
+	}
+}
+
+// Subtract returns a new set containing the addresses that are in this set but not in the
+// other set.
+func (s *CIDRSet) Subtract(other *CIDRSet) *CIDRSet {
+	return &CIDRSet{
+		v4: subtractNodes(s.v4, other.v4),
+		v6: subtractNodes(s.v6, other.v6),
+	}
+}
+
+// Copy returns a copy of the set.
+func (s *CIDRSet) Copy() *CIDRSet {
+	return &CIDRSet{
+		v4: s.v4.copy(),
+		v6: s.v6.copy(),
+	}
+}
+
+// Equals returns true if both sets contain exactly the same addresses.
+func (s *CIDRSet) Equals(other *CIDRSet) bool {
+	return s.v4.equals(other.v4) && s.v6.equals(other.v6)
+}
+
+// Nets returns the minimal list of CIDRs covering the addresses in the set.  IPv4 CIDRs are
+// returned before IPv6 CIDRs, and the CIDRs of each version are ordered by address.
+func (s *CIDRSet) Nets() []IPNet {
+	var nets []IPNet
+	_ = s.Iter(func(n IPNet) error {
+		nets = append(nets, n)
+		return nil
+	})
+	return nets
+}
+
+// Iter calls the supplied function for each of the CIDRs that would be returned by Nets(), in
+// the same order.  If the function returns an error, iteration stops and the error is returned.
+func (s *CIDRSet) Iter(f func(n IPNet) error) error {
+	if err := s.v4.iter(make([]byte, net.IPv4len), 0, f); err != nil {
+		return err
+	}
+	return s.v6.iter(make([]byte, net.IPv6len), 0, f)
+}
+
+// root returns a pointer to the root node of the tree for the IP version of the address.
+func (s *CIDRSet) root(ip []byte) **cidrNode {
+	if len(ip) == net.IPv4len {
+		return &s.v4
+	}
+	return &s.v6
+}
+
+// cidrSetKey returns the masked address bytes and prefix length of the CIDR.  IPv4 addresses
+// are always returned in their 4-byte form.
+func cidrSetKey(n IPNet) ([]byte, int, bool) {
+	ones, bits := n.Mask.Size()
+	var ip net.IP
+	switch bits {
+	case 8 * net.IPv4len:
+		ip = n.IP.To4()
+	case 8 * net.IPv6len:
+		ip = n.IP.To16()
+	}
+	if ip == nil {
+		return nil, 0, false
+	}
+	return ip.Mask(n.Mask), ones, true
+}
+
+// bitAt returns the value of the bit at the given depth of the address.
+func bitAt(ip []byte, depth int) int {
+	return int(ip[depth/8]>>uint(7-depth%8)) & 1
+}
+
+func (n *cidrNode) add(ip []byte, depth, prefixLen int) *cidrNode {
+	if n == nil {
+		n = &cidrNode{}
+	}
+	if n.full {
+		return n
+	}
+	if depth == prefixLen {
+		return &cidrNode{full: true}
+	}
+	b := bitAt(ip, depth)
+	n.children[b] = n.children[b].add(ip, depth+1, prefixLen)
+	return n.collapse()
+}
+
+func (n *cidrNode) remove(ip []byte, depth, prefixLen int) *cidrNode {
+	if n == nil || depth == prefixLen {
+		return nil
+	}
+	if n.full {
+		// Split the node so that we can remove part of it.
+		n = &cidrNode{children: [2]*cidrNode{{full: true}, {full: true}}}
+	}
+	b := bitAt(ip, depth)
+	n.children[b] = n.children[b].remove(ip, depth+1, prefixLen)
+	return n.prune()
+}
+
+func (n *cidrNode) contains(ip []byte, depth, prefixLen int) bool {
+	for ; n != nil; depth++ {
+		if n.full {
+			return true
+		}
+		if depth == prefixLen {
+			// Only part of the CIDR is in the set.
+			return false
+		}
+		n = n.children[bitAt(ip, depth)]
+	}
+	return false
+}
+
+func (n *cidrNode) overlaps(ip []byte, depth, prefixLen int) bool {
+	for ; n != nil; depth++ {
+		if n.full || depth == prefixLen {
+			// Empty nodes are never stored, so some part of the CIDR is in the set.
+			return true
+		}
+		n = n.children[bitAt(ip, depth)]
+	}
+	return false
+}
+
+func (n *cidrNode) iter(prefix []byte, depth int, f func(n IPNet) error) error {
+	if n == nil {
+		return nil
+	}
+	if n.full {
+		ip := make(net.IP, len(prefix))
+		copy(ip, prefix)
+		return f(IPNet{net.IPNet{IP: ip, Mask: net.CIDRMask(depth, 8*len(prefix))}})
+	}
+	if err := n.children[0].iter(prefix, depth+1, f); err != nil {
+		return err
+	}
+	mask := byte(1) << uint(7-depth%8)
+	prefix[depth/8] |= mask
+	err := n.children[1].iter(prefix, depth+1, f)
+	prefix[depth/8] &^= mask
+	return err
+}
+
+func (n *cidrNode) copy() *cidrNode {
+	if n == nil {
+		return nil
+	}
+	return &cidrNode{
+		children: [2]*cidrNode{n.children[0].copy(), n.children[1].copy()},
+		full:     n.full,
+	}
+}
+
+func (n *cidrNode) equals(other *cidrNode) bool {
+	if n == nil || other == nil {
+		return n == other
+	}
+	return n.full == other.full &&
+		n.children[0].equals(other.children[0]) &&
+		n.children[1].equals(other.children[1])
+}
+
+// collapse replaces the node with a full node if both of its children are full.
+func (n *cidrNode) collapse() *cidrNode {
+	if n.children[0] != nil && n.children[0].full && n.children[1] != nil && n.children[1].full {
+		return &cidrNode{full: true}
+	}
+	return n
+}
+
+// prune returns nil if the node is empty, otherwise the (possibly collapsed) node.
+func (n *cidrNode) prune() *cidrNode {
+	if !n.full && n.children[0] == nil && n.children[1] == nil {
+		return nil
+	}
+	return n.collapse()
+}
+
+func unionNodes(a, b *cidrNode) *cidrNode {
+	switch {
+	case a == nil:
+		return b.copy()
+	case b == nil:
+		return a.copy()
+	case a.full || b.full:
+		return &cidrNode{full: true}
+	}
+	n := &cidrNode{children: [2]*cidrNode{
+		unionNodes(a.children[0], b.children[0]),
+		unionNodes(a.children[1], b.children[1]),
+	}}
+	return n.collapse()
+}
+
+func intersectNodes(a, b *cidrNode) *cidrNode {
+	switch {
+	case a == nil || b == nil:
+		return nil
+	case a.full:
+		return b.copy()
+	case b.full:
+		return a.copy()
+	}
+	n := &cidrNode{children: [2]*cidrNode{
+		intersectNodes(a.children[0], b.children[0]),
+		intersectNodes(a.children[1], b.children[1]),
+	}}
+	return n.prune()
+}
+
+func subtractNodes(a, b *cidrNode) *cidrNode {
+	switch {
+	case a == nil || b != nil && b.full:
+		return nil
+	case b == nil:
+		return a.copy()
+	case a.full:
+		a = &cidrNode{children: [2]*cidrNode{{full: true}, {full: true}}}
+	}
+	n := &cidrNode{children: [2]*cidrNode{
+		subtractNodes(a.children[0], b.children[0]),
+		subtractNodes(a.children[1], b.children[1]),
+	}}
+	return n.prune()
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package net_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/unai-ttxu/libcalico-go/lib/net"
+)
+
+func cidrSet(cidrs ...string) *net.CIDRSet {
+	s := net.NewCIDRSet()
+	for _, c := range cidrs {
+		s.Add(net.MustParseCIDR(c))
+	}
+	return s
+}
+
+func cidrStrings(s *net.CIDRSet) []string {
+	var strs []string
+	for _, n := range s.Nets() {
+		strs = append(strs, n.String())
+	}
+	return strs
+}
+
+var _ = Describe("CIDRSet", func() {
+	It("should be empty", func() {
+		s := net.NewCIDRSet()
+		Expect(s.Empty()).To(BeTrue())
+		Expect(s.Nets()).To(BeNil())
+		Expect(s.Contains(net.MustParseNetwork("10.0.0.0/8"))).To(BeFalse())
+		Expect(s.Overlaps(net.MustParseNetwork("0.0.0.0/0"))).To(BeFalse())
+	})
+
+	DescribeTable("aggregation of added CIDRs",
+		func(cidrs []string, expected []string) {
+			Expect(cidrStrings(cidrSet(cidrs...))).To(Equal(expected))
+		},
+		Entry("single CIDR", []string{"10.0.0.0/24"}, []string{"10.0.0.0/24"}),
+		Entry("unmasked CIDR", []string{"10.0.0.1/24"}, []string{"10.0.0.0/24"}),
+		Entry("duplicates", []string{"10.0.0.0/24", "10.0.0.0/24"}, []string{"10.0.0.0/24"}),
+		Entry("contained CIDR", []string{"10.0.0.10/32", "10.0.0.0/24"}, []string{"10.0.0.0/24"}),
+		Entry("siblings", []string{"10.0.1.0/24", "10.0.0.0/24"}, []string{"10.0.0.0/23"}),
+		Entry("non-siblings", []string{"10.0.1.0/24", "10.0.2.0/24"}, []string{"10.0.1.0/24", "10.0.2.0/24"}),
+		Entry("cascading merge",
+			[]string{"10.0.0.0/25", "10.0.0.128/25", "10.0.1.0/24", "10.0.2.0/23"},
+			[]string{"10.0.0.0/22"}),
+		Entry("mixed versions ordered v4 first",
+			[]string{"fd00::/64", "192.168.0.0/16", "10.0.0.1/32", "fd00:0:0:1::/64"},
+			[]string{"10.0.0.1/32", "192.168.0.0/16", "fd00::/63"}),
+		Entry("everything", []string{"0.0.0.0/1", "128.0.0.0/1"}, []string{"0.0.0.0/0"}),
+	)
+
+	DescribeTable("removing CIDRs",
+		func(cidrs []string, remove string, expected []string) {
+			s := cidrSet(cidrs...)
+			s.Remove(net.MustParseCIDR(remove))
+			Expect(cidrStrings(s)).To(Equal(expected))
+		},
+		Entry("whole CIDR", []string{"10.0.0.0/24"}, "10.0.0.0/24", nil),
+		Entry("containing CIDR", []string{"10.0.0.0/24"}, "10.0.0.0/8", nil),
+		Entry("unrelated CIDR", []string{"10.0.0.0/24"}, "10.0.1.0/24", []string{"10.0.0.0/24"}),
+		Entry("hole in a CIDR", []string{"10.0.0.0/22"}, "10.0.1.0/24",
+			[]string{"10.0.0.0/24", "10.0.2.0/23"}),
+		Entry("single address", []string{"10.0.0.0/30"}, "10.0.0.2/32",
+			[]string{"10.0.0.0/31", "10.0.0.3/32"}),
+	)
+
+	Describe("containment", func() {
+		s := cidrSet("10.0.0.0/24", "10.0.1.0/25", "fd00::/64")
+
+		DescribeTable("Contains",
+			func(cidr string, expected bool) {
+				Expect(s.Contains(net.MustParseCIDR(cidr))).To(Equal(expected))
+			},
+			Entry("exact CIDR", "10.0.0.0/24", true),
+			Entry("contained CIDR", "10.0.0.128/25", true),
+			Entry("partially contained CIDR", "10.0.0.0/23", false),
+			Entry("disjoint CIDR", "10.0.2.0/24", false),
+			Entry("contained IPv6 CIDR", "fd00::1/128", true),
+			Entry("partially contained IPv6 CIDR", "fd00::/48", false),
+		)
+
+		DescribeTable("Overlaps",
+			func(cidr string, expected bool) {
+				Expect(s.Overlaps(net.MustParseCIDR(cidr))).To(Equal(expected))
+			},
+			Entry("exact CIDR", "10.0.0.0/24", true),
+			Entry("contained CIDR", "10.0.1.0/26", true),
+			Entry("containing CIDR", "10.0.0.0/8", true),
+			Entry("disjoint CIDR", "10.0.1.128/25", false),
+			Entry("containing IPv6 CIDR", "fd00::/8", true),
+			Entry("disjoint IPv6 CIDR", "fd01::/64", false),
+		)
+
+		It("should check IP addresses", func() {
+			Expect(s.ContainsIP(net.MustParseIP("10.0.1.127"))).To(BeTrue())
+			Expect(s.ContainsIP(net.MustParseIP("10.0.1.128"))).To(BeFalse())
+			Expect(s.ContainsIP(net.MustParseIP("fd00::1234"))).To(BeTrue())
+			Expect(s.ContainsIP(net.MustParseIP("fd01::1234"))).To(BeFalse())
+		})
+	})
+
+	Describe("set operations", func() {
+		a := cidrSet("10.0.0.0/23", "172.16.0.0/16", "fd00::/64")
+		b := cidrSet("10.0.1.0/24", "10.0.2.0/24", "172.16.0.0/12", "fd00::/65")
+
+		It("should union sets", func() {
+			Expect(cidrStrings(a.Union(b))).To(Equal([]string{
+				"10.0.0.0/23", "10.0.2.0/24", "172.16.0.0/12", "fd00::/64",
+			}))
+		})
+		It("should intersect sets", func() {
+			Expect(cidrStrings(a.Intersect(b))).To(Equal([]string{
+				"10.0.1.0/24", "172.16.0.0/16", "fd00::/65",
+			}))
+		})
+		It("should subtract sets", func() {
+			Expect(cidrStrings(a.Subtract(b))).To(Equal([]string{
+				"10.0.0.0/24", "fd00::8000:0:0:0/65",
+			}))
+		})
+		It("should not modify the original sets", func() {
+			a.Union(b)
+			a.Intersect(b)
+			a.Subtract(b)
+			Expect(a.Equals(cidrSet("10.0.0.0/23", "172.16.0.0/16", "fd00::/64"))).To(BeTrue())
+			Expect(b.Equals(cidrSet("10.0.1.0/24", "10.0.2.0/24", "172.16.0.0/12", "fd00::/65"))).To(BeTrue())
+		})
+		It("should compare sets by their contents", func() {
+			Expect(cidrSet("10.0.0.0/24", "10.0.1.0/24").Equals(cidrSet("10.0.0.0/23"))).To(BeTrue())
+			Expect(cidrSet("10.0.0.0/24").Equals(cidrSet("10.0.0.0/23"))).To(BeFalse())
+			Expect(a.Copy().Equals(a)).To(BeTrue())
+		})
+	})
+
+	It("should stop iterating when the callback returns an error", func() {
+		s := cidrSet("10.0.0.0/24", "10.0.2.0/24", "fd00::/64")
+		stop := errors.New("stop")
+		var seen []string
+		err := s.Iter(func(n net.IPNet) error {
+			seen = append(seen, n.String())
+			if len(seen) == 2 {
+				return stop
+			}
+			return nil
+		})
+		Expect(err).To(Equal(stop))
+		Expect(seen).To(Equal([]string{"10.0.0.0/24", "10.0.2.0/24"}))
+	})
+})
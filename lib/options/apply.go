@@ -0,0 +1,24 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+// ApplyOptions is the options for applying a resource through the Calico API.
+type ApplyOptions struct {
+	// When set, validate and admit the applied resource through the client for its kind, as
+	// for a real apply, and report the changes that the apply would make without modifying the
+	// datastore.
+	// +optional
+	DryRun bool
+}
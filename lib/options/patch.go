@@ -0,0 +1,24 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+// PatchOptions is the options for patching a resource through the Calico API.
+type PatchOptions struct {
+	// When set, the patch is only applied if the resource is at this version.  Otherwise the
+	// patch is applied to the latest version of the resource, and retried if the resource is
+	// updated concurrently.
+	// +optional
+	ResourceVersion string
+}
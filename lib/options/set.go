@@ -22,4 +22,9 @@ type SetOptions struct {
 	// TTL for the datastore entry.
 	// +optional
 	TTL time.Duration
+
+	// When set, validate and admit the resource as normal, and return it as it would be
+	// stored, but do not write it to the datastore.
+	// +optional
+	DryRun bool
 }
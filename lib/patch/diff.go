@@ -0,0 +1,109 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FieldChange is a change to a single field of a resource.
+type FieldChange struct {
+	// The path of the field, as JSON field names from the top of the resource, for example
+	// ["spec", "natOutgoing"].
+	Path []string
+
+	// The values of the field before and after the change, as unstructured JSON.  Nil if the
+	// field is not set.
+	Old interface{}
+	New interface{}
+}
+
+// FieldPath returns the path of the field as a single string, for example "spec.natOutgoing".
+func (c FieldChange) FieldPath() string {
+	return strings.Join(c.Path, ".")
+}
+
+func (c FieldChange) String() string {
+	return fmt.Sprintf("%s: %v -> %v", c.FieldPath(), c.Old, c.New)
+}
+
+// Diff returns the changes to the fields of a resource between the old and new versions of the
+// resource, in order of path.  Either version may be nil.  Objects are compared field by field;
+// other values, including lists, are compared as a whole.
+func Diff(old, new interface{}) ([]FieldChange, error) {
+	oldFields, err := toFields(old)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := toFields(new)
+	if err != nil {
+		return nil, err
+	}
+	return DiffFields(oldFields, newFields), nil
+}
+
+// DiffFields returns the changes between the old and new versions of a resource that are
+// already decoded as unstructured JSON.  See Diff.
+func DiffFields(old, new map[string]interface{}) []FieldChange {
+	return diffValues(nil, old, new)
+}
+
+// toFields returns the resource as unstructured JSON.
+func toFields(res interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// diffValues returns the changes between two unstructured JSON values at the path.
+func diffValues(path []string, old, new interface{}) []FieldChange {
+	oldObj, oldIsObj := old.(map[string]interface{})
+	newObj, newIsObj := new.(map[string]interface{})
+	if (oldIsObj || newIsObj) && (oldIsObj || old == nil) && (newIsObj || new == nil) {
+		keys := map[string]bool{}
+		for k := range oldObj {
+			keys[k] = true
+		}
+		for k := range newObj {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		var changes []FieldChange
+		for _, k := range sorted {
+			fieldPath := append(append([]string{}, path...), k)
+			changes = append(changes, diffValues(fieldPath, oldObj[k], newObj[k])...)
+		}
+		return changes
+	}
+	if reflect.DeepEqual(old, new) {
+		return nil
+	}
+	return []FieldChange{{Path: path, Old: old, New: new}}
+}
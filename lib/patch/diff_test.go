@@ -0,0 +1,77 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+)
+
+var _ = Describe("Diff", func() {
+	newPool := func() *apiv3.IPPool {
+		p := apiv3.NewIPPool()
+		p.Name = "pool1"
+		p.Labels = map[string]string{"projectcalico.org/zone": "a"}
+		p.Spec.CIDR = "10.0.0.0/16"
+		p.Spec.NATOutgoing = true
+		p.Spec.NodeSelector = "all()"
+		return p
+	}
+
+	It("should report no changes for equal resources", func() {
+		changes, err := Diff(newPool(), newPool())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(BeEmpty())
+	})
+
+	It("should report changed, added and removed fields in order of path", func() {
+		old := newPool()
+		new := newPool()
+		new.Labels["projectcalico.org/zone"] = "b"
+		new.Spec.NATOutgoing = false
+		new.Spec.BlockSize = 26
+		new.Spec.NodeSelector = ""
+
+		changes, err := Diff(old, new)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(Equal([]FieldChange{
+			{Path: []string{"metadata", "labels", "projectcalico.org/zone"}, Old: "a", New: "b"},
+			{Path: []string{"spec", "blockSize"}, New: float64(26)},
+			{Path: []string{"spec", "natOutgoing"}, Old: true},
+			{Path: []string{"spec", "nodeSelector"}, Old: "all()"},
+		}))
+		Expect(changes[1].FieldPath()).To(Equal("spec.blockSize"))
+	})
+
+	It("should report the fields of a new resource", func() {
+		changes, err := Diff(nil, newPool())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(ContainElement(FieldChange{Path: []string{"spec", "cidr"}, New: "10.0.0.0/16"}))
+	})
+
+	It("should compare lists as a whole", func() {
+		changes := DiffFields(
+			map[string]interface{}{"spec": map[string]interface{}{"nodes": []interface{}{"n1"}}},
+			map[string]interface{}{"spec": map[string]interface{}{"nodes": []interface{}{"n1", "n2"}}},
+		)
+		Expect(changes).To(Equal([]FieldChange{{
+			Path: []string{"spec", "nodes"},
+			Old:  []interface{}{"n1"},
+			New:  []interface{}{"n1", "n2"},
+		}}))
+	})
+})
@@ -0,0 +1,235 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// operation is a single operation of a JSON patch.
+type operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// jsonPatch applies the operations of a JSON patch to the target value in turn.
+func jsonPatch(target interface{}, ops []operation) (interface{}, error) {
+	for i, op := range ops {
+		var err error
+		if target, err = op.apply(target); err != nil {
+			return nil, fmt.Errorf("JSON patch operation %d (%s %s) failed: %v", i, op.Op, op.Path, err)
+		}
+	}
+	return target, nil
+}
+
+// apply applies the operation to the target value, and returns the updated value.
+func (op operation) apply(target interface{}) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return nil, fmt.Errorf("missing value")
+		}
+		var value interface{}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("invalid value: %v", err)
+		}
+		if op.Op == "test" {
+			current, err := get(target, path)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(current, value) {
+				return nil, fmt.Errorf("value is %v, not %v", current, value)
+			}
+			return target, nil
+		}
+		target, _, err = update(target, path, op.Op, value)
+		return target, err
+	case "remove":
+		target, _, err = update(target, path, op.Op, nil)
+		return target, err
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if op.Op == "move" {
+			if len(from) < len(path) && reflect.DeepEqual(from, path[:len(from)]) {
+				return nil, fmt.Errorf("cannot move %s into itself", op.From)
+			}
+			if target, value, err = update(target, from, "remove", nil); err != nil {
+				return nil, err
+			}
+		} else {
+			if value, err = get(target, from); err != nil {
+				return nil, err
+			}
+			value = copyValue(value)
+		}
+		target, _, err = update(target, path, "add", value)
+		return target, err
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op.Op)
+	}
+}
+
+// parsePointer parses a JSON pointer (RFC 6901) into its reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid path %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// get returns the value at the path.
+func get(target interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch t := target.(type) {
+		case map[string]interface{}:
+			v, ok := t[token]
+			if !ok {
+				return nil, fmt.Errorf("field %s does not exist", token)
+			}
+			target = v
+		case []interface{}:
+			i, err := arrayIndex(token, len(t)-1)
+			if err != nil {
+				return nil, err
+			}
+			target = t[i]
+		default:
+			return nil, fmt.Errorf("cannot index a value that is not an object or array with %s", token)
+		}
+	}
+	return target, nil
+}
+
+// update adds, removes or replaces the value at the path, and returns the updated target value
+// along with any removed value.
+func update(target interface{}, path []string, op string, value interface{}) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		if op == "remove" {
+			return nil, nil, fmt.Errorf("cannot remove the whole document")
+		}
+		return value, target, nil
+	}
+
+	token, last := path[0], len(path) == 1
+	switch t := target.(type) {
+	case map[string]interface{}:
+		current, ok := t[token]
+		if !ok && (op != "add" || !last) {
+			return nil, nil, fmt.Errorf("field %s does not exist", token)
+		}
+		if !last {
+			child, removed, err := update(current, path[1:], op, value)
+			if err != nil {
+				return nil, nil, err
+			}
+			t[token] = child
+			return t, removed, nil
+		}
+		if op == "remove" {
+			delete(t, token)
+		} else {
+			t[token] = value
+		}
+		return t, current, nil
+	case []interface{}:
+		if last && op == "add" {
+			i := len(t)
+			if token != "-" {
+				var err error
+				if i, err = arrayIndex(token, len(t)); err != nil {
+					return nil, nil, err
+				}
+			}
+			t = append(t, nil)
+			copy(t[i+1:], t[i:])
+			t[i] = value
+			return t, nil, nil
+		}
+		i, err := arrayIndex(token, len(t)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		current := t[i]
+		if !last {
+			child, removed, err := update(current, path[1:], op, value)
+			if err != nil {
+				return nil, nil, err
+			}
+			t[i] = child
+			return t, removed, nil
+		}
+		if op == "remove" {
+			t = append(t[:i], t[i+1:]...)
+		} else {
+			t[i] = value
+		}
+		return t, current, nil
+	default:
+		return nil, nil, fmt.Errorf("cannot index a value that is not an object or array with %s", token)
+	}
+}
+
+// arrayIndex parses an array index, which must be between 0 and max.
+func arrayIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %s", token)
+	}
+	return i, nil
+}
+
+// copyValue returns a deep copy of an unstructured JSON value.
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = copyValue(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = copyValue(e)
+		}
+		return out
+	default:
+		return v
+	}
+}
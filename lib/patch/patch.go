@@ -0,0 +1,78 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package patch applies JSON merge patches and JSON patches to resources, and computes the
+// changes to the fields of a resource.
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Apply applies the patch to the JSON document, and returns the patched document.  The patch
+// type may be types.MergePatchType for a JSON merge patch (RFC 7386), or types.JSONPatchType for
+// a JSON patch (RFC 6902).
+func Apply(pt types.PatchType, doc, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, fmt.Errorf("invalid document: %v", err)
+	}
+
+	var patched interface{}
+	var err error
+	switch pt {
+	case types.MergePatchType:
+		var p interface{}
+		if err := json.Unmarshal(patch, &p); err != nil {
+			return nil, fmt.Errorf("invalid merge patch: %v", err)
+		}
+		patched = mergePatch(target, p)
+	case types.JSONPatchType:
+		var ops []operation
+		if err := json.Unmarshal(patch, &ops); err != nil {
+			return nil, fmt.Errorf("invalid JSON patch: %v", err)
+		}
+		if patched, err = jsonPatch(target, ops); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported patch type %q", pt)
+	}
+	return json.Marshal(patched)
+}
+
+// mergePatch applies the JSON merge patch to the target value.  Objects in the patch are merged
+// into the target recursively, with null values removing fields; any other value in the patch
+// replaces the target value.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(targetObj, k)
+		} else {
+			targetObj[k] = mergePatch(targetObj[k], v)
+		}
+	}
+	return targetObj
+}
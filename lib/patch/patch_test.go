@@ -0,0 +1,105 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const patchDoc = `{"metadata":{"name":"pool1","labels":{"a":"1","b":"2"}},"spec":{"cidr":"10.0.0.0/16","natOutgoing":true,"nodes":["n1","n2"]}}`
+
+var _ = DescribeTable("JSON merge patches",
+	func(patch, expected string) {
+		out, err := Apply(types.MergePatchType, []byte(patchDoc), []byte(patch))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(MatchJSON(expected))
+	},
+	Entry("empty patch", `{}`, patchDoc),
+	Entry("set a field",
+		`{"spec":{"natOutgoing":false}}`,
+		`{"metadata":{"name":"pool1","labels":{"a":"1","b":"2"}},"spec":{"cidr":"10.0.0.0/16","natOutgoing":false,"nodes":["n1","n2"]}}`),
+	Entry("remove and add fields",
+		`{"metadata":{"labels":{"a":null,"c":"3"}},"spec":{"blockSize":26}}`,
+		`{"metadata":{"name":"pool1","labels":{"b":"2","c":"3"}},"spec":{"cidr":"10.0.0.0/16","natOutgoing":true,"nodes":["n1","n2"],"blockSize":26}}`),
+	Entry("replace a list",
+		`{"spec":{"nodes":["n3"]}}`,
+		`{"metadata":{"name":"pool1","labels":{"a":"1","b":"2"}},"spec":{"cidr":"10.0.0.0/16","natOutgoing":true,"nodes":["n3"]}}`),
+	Entry("replace an object with a value",
+		`{"metadata":{"labels":"none"}}`,
+		`{"metadata":{"name":"pool1","labels":"none"},"spec":{"cidr":"10.0.0.0/16","natOutgoing":true,"nodes":["n1","n2"]}}`),
+)
+
+var _ = DescribeTable("JSON patches",
+	func(patch, expected string) {
+		out, err := Apply(types.JSONPatchType, []byte(patchDoc), []byte(patch))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(MatchJSON(expected))
+	},
+	Entry("empty patch", `[]`, patchDoc),
+	Entry("add, replace and remove fields",
+		`[{"op":"add","path":"/spec/blockSize","value":26},{"op":"replace","path":"/spec/natOutgoing","value":false},{"op":"remove","path":"/metadata/labels/a"}]`,
+		`{"metadata":{"name":"pool1","labels":{"b":"2"}},"spec":{"cidr":"10.0.0.0/16","natOutgoing":false,"nodes":["n1","n2"],"blockSize":26}}`),
+	Entry("insert into and append to a list",
+		`[{"op":"add","path":"/spec/nodes/0","value":"n0"},{"op":"add","path":"/spec/nodes/-","value":"n3"}]`,
+		`{"metadata":{"name":"pool1","labels":{"a":"1","b":"2"}},"spec":{"cidr":"10.0.0.0/16","natOutgoing":true,"nodes":["n0","n1","n2","n3"]}}`),
+	Entry("replace and remove list items",
+		`[{"op":"replace","path":"/spec/nodes/1","value":"n3"},{"op":"remove","path":"/spec/nodes/0"}]`,
+		`{"metadata":{"name":"pool1","labels":{"a":"1","b":"2"}},"spec":{"cidr":"10.0.0.0/16","natOutgoing":true,"nodes":["n3"]}}`),
+	Entry("move and copy fields",
+		`[{"op":"move","from":"/metadata/labels/a","path":"/metadata/labels/c"},{"op":"copy","from":"/spec/nodes","path":"/spec/peers"}]`,
+		`{"metadata":{"name":"pool1","labels":{"b":"2","c":"1"}},"spec":{"cidr":"10.0.0.0/16","natOutgoing":true,"nodes":["n1","n2"],"peers":["n1","n2"]}}`),
+	Entry("escaped paths",
+		`[{"op":"add","path":"/metadata/labels/projectcalico.org~1x~0y","value":"3"}]`,
+		`{"metadata":{"name":"pool1","labels":{"a":"1","b":"2","projectcalico.org/x~y":"3"}},"spec":{"cidr":"10.0.0.0/16","natOutgoing":true,"nodes":["n1","n2"]}}`),
+	Entry("passing test",
+		`[{"op":"test","path":"/spec/natOutgoing","value":true},{"op":"remove","path":"/spec/natOutgoing"}]`,
+		`{"metadata":{"name":"pool1","labels":{"a":"1","b":"2"}},"spec":{"cidr":"10.0.0.0/16","nodes":["n1","n2"]}}`),
+)
+
+var _ = DescribeTable("Invalid JSON patches",
+	func(patch string) {
+		_, err := Apply(types.JSONPatchType, []byte(patchDoc), []byte(patch))
+		Expect(err).To(HaveOccurred())
+	},
+	Entry("not a list of operations", `{"op":"remove","path":"/spec"}`),
+	Entry("unknown operation", `[{"op":"delete","path":"/spec"}]`),
+	Entry("missing value", `[{"op":"add","path":"/spec/blockSize"}]`),
+	Entry("invalid path", `[{"op":"remove","path":"spec"}]`),
+	Entry("remove a missing field", `[{"op":"remove","path":"/spec/blockSize"}]`),
+	Entry("replace a missing field", `[{"op":"replace","path":"/spec/blockSize","value":26}]`),
+	Entry("add to a missing object", `[{"op":"add","path":"/status/ready","value":true}]`),
+	Entry("list index out of range", `[{"op":"replace","path":"/spec/nodes/2","value":"n3"}]`),
+	Entry("list index with a leading zero", `[{"op":"remove","path":"/spec/nodes/01"}]`),
+	Entry("move into itself", `[{"op":"move","from":"/spec","path":"/spec/inner"}]`),
+	Entry("failing test", `[{"op":"test","path":"/spec/natOutgoing","value":false}]`),
+)
+
+var _ = Describe("Patch types", func() {
+	It("should reject unsupported patch types", func() {
+		_, err := Apply(types.StrategicMergePatchType, []byte(patchDoc), []byte(`{}`))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should reject invalid documents and patches", func() {
+		_, err := Apply(types.MergePatchType, []byte(`{`), []byte(`{}`))
+		Expect(err).To(HaveOccurred())
+		_, err = Apply(types.MergePatchType, []byte(patchDoc), []byte(`{`))
+		Expect(err).To(HaveOccurred())
+	})
+})
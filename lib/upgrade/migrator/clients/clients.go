@@ -55,6 +55,8 @@ type V1ClientInterface interface {
 // If using Kubernetes API as the datastore, only the v3Config, or
 // v3 environments need to be specified.  The v1 client uses identical
 // configuration for this datastore type.
+//
+// The caller must Close the v3 client once the migration is finished.
 func LoadClients(v3Config, v1Config string) (clientv3.Interface, V1ClientInterface, error) {
 	// If the v3Config or v1Config are the default paths, and those files do not exist, then
 	// switch to using environments by settings the path to an empty string.
@@ -0,0 +1,565 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	bapi "github.com/unai-ttxu/libcalico-go/lib/backend/api"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/k8s/conversion"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+	"github.com/unai-ttxu/libcalico-go/lib/upgrade/converters"
+	validatorv3 "github.com/unai-ttxu/libcalico-go/lib/validator/v3"
+)
+
+var (
+	// How long to wait after pausing Calico networking in the source datastore, to allow
+	// orchestrators to finish any current allocations.
+	datastorePauseDuration = 15 * time.Second
+
+	// The kinds of v3 resource that are migrated between datastores, in the order that they are
+	// stored.
+	datastoreMigrationKinds = []string{
+		apiv3.KindClusterInformation,
+		apiv3.KindFelixConfiguration,
+		apiv3.KindBGPConfiguration,
+		apiv3.KindIPPool,
+		apiv3.KindIPReservation,
+		apiv3.KindNode,
+		apiv3.KindBGPPeer,
+		apiv3.KindHostEndpoint,
+		apiv3.KindGlobalNetworkSet,
+		apiv3.KindNetworkSet,
+		apiv3.KindGlobalNetworkPolicy,
+		apiv3.KindNetworkPolicy,
+		apiv3.KindProfile,
+		apiv3.KindWorkloadEndpoint,
+	}
+
+	// The IPAM data that is migrated between datastores, in addition to the IPAM configuration.
+	datastoreMigrationIPAM = []model.ListInterface{
+		model.BlockListOptions{},
+		model.BlockAffinityListOptions{},
+		model.IPAMHandleListOptions{},
+	}
+)
+
+// DatastoreInterface is the migration interface used for migrating the v3 data from an etcdv3
+// datastore to a Kubernetes (KDD) datastore.
+type DatastoreInterface interface {
+	ValidateConversion() (*DatastoreMigrationData, error)
+	IsDestinationEmpty() (bool, error)
+	Migrate() (*DatastoreMigrationData, error)
+	Abort() error
+	Complete() error
+}
+
+// NewDatastoreMigrator creates a new migration helper implementing DatastoreInterface, which
+// migrates the data from the source etcdv3 backend client to the destination Kubernetes backend
+// client.
+func NewDatastoreMigrator(src, dst bapi.Client, statusWriter StatusWriterInterface) DatastoreInterface {
+	return &datastoreMigrationHelper{
+		statusReporter: statusReporter{statusWriter: statusWriter},
+		src:            src,
+		dst:            dst,
+	}
+}
+
+// datastoreMigrationHelper implements the migrate.DatastoreInterface.
+type datastoreMigrationHelper struct {
+	statusReporter
+	src bapi.Client
+	dst bapi.Client
+}
+
+// DatastoreMigrationData includes details about data migrated between datastores.
+type DatastoreMigrationData struct {
+	// The entries to store in the destination datastore: the v3 resources translated for the
+	// destination datastore followed by the IPAM data.
+	KVPairs []*model.KVPair
+
+	// The resources whose names differ in the destination datastore.
+	NameConversions []DatastoreNameConversion
+
+	// Resources that are not migrated because the destination datastore derives them from
+	// Kubernetes resources: Kubernetes network policies, namespace and service account
+	// profiles, and workload endpoints backed by pods.
+	HandledByKubernetes []model.Key
+
+	// Errors hit translating and validating the data.  These need to be resolved before
+	// attempting the migration.
+	ConversionErrors []DatastoreConversionError
+}
+
+// HasErrors returns whether there are any errors contained in the DatastoreMigrationData.
+func (d *DatastoreMigrationData) HasErrors() bool {
+	return len(d.ConversionErrors) != 0
+}
+
+// DatastoreNameConversion contains details about a resource that is renamed in the destination
+// datastore.
+type DatastoreNameConversion struct {
+	Source      model.Key
+	Destination model.Key
+}
+
+// DatastoreConversionError contains details about a specific error translating or validating a
+// resource read from the source datastore.
+type DatastoreConversionError struct {
+	Cause error
+	Key   model.Key
+	Value interface{}
+}
+
+// ValidateConversion validates that the data in the source datastore can be migrated to the
+// destination datastore.  If an error is returned it will be of type MigrationError.
+func (m *datastoreMigrationHelper) ValidateConversion() (*DatastoreMigrationData, error) {
+	m.status("Validating conversion of etcdv3 data to the Kubernetes datastore")
+	data, err := m.queryAndTranslate()
+	if err != nil {
+		m.statusError("Unable to perform validation, please resolve errors and retry")
+		m.statusBullet("Cause: %v", err)
+		return nil, MigrationError{
+			Type: ErrorGeneric,
+			Err:  err,
+		}
+	}
+	if data.HasErrors() {
+		m.statusError("Error converting data, check output for details and resolve issues before starting migration")
+		return data, MigrationError{
+			Type: ErrorConvertingData,
+			Err:  errors.New("error converting data"),
+		}
+	}
+	m.statusBullet("data conversion successful")
+
+	// Check that we found some data to migrate.
+	if len(data.KVPairs) == 0 {
+		m.statusError("No etcdv3 resources detected: is the API configuration correctly configured?")
+		return nil, MigrationError{
+			Type: ErrorGeneric,
+			Err:  errors.New("no etcdv3 resources detected: is the API configuration correctly configured?"),
+		}
+	}
+
+	m.status("Data conversion validated successfully")
+	return data, nil
+}
+
+// IsDestinationEmpty returns true if the destination datastore contains none of the resources
+// that are migrated, ignoring the resources that it derives from Kubernetes resources.
+func (m *datastoreMigrationHelper) IsDestinationEmpty() (bool, error) {
+	m.status("Validating the Kubernetes datastore")
+	lists := append([]model.ListInterface{}, datastoreMigrationIPAM...)
+	for _, kind := range datastoreMigrationKinds {
+		switch kind {
+		case apiv3.KindNode, apiv3.KindProfile, apiv3.KindWorkloadEndpoint:
+			// Backed by Kubernetes nodes, namespaces, service accounts and pods.
+		default:
+			lists = append(lists, model.ResourceListOptions{Kind: kind})
+		}
+	}
+	for _, list := range lists {
+		kvps, err := m.dst.List(context.Background(), list, "")
+		if err != nil {
+			m.statusError("Unable to validate the Kubernetes datastore")
+			m.statusBullet("Cause: %v", err)
+			return false, MigrationError{
+				Type: ErrorGeneric,
+				Err:  fmt.Errorf("unable to validate the Kubernetes datastore: %v", err),
+			}
+		}
+		for _, kvp := range kvps.KVPairs {
+			if k, ok := kvp.Key.(model.ResourceKey); ok && datastoreDerivedResource(k) {
+				continue
+			}
+			m.statusBullet("the Kubernetes datastore is not empty")
+			return false, nil
+		}
+	}
+	m.statusBullet("the Kubernetes datastore is empty")
+	return true, nil
+}
+
+// Migrate migrates the data from the source datastore to the destination datastore, pausing
+// Calico networking in the source datastore while it does so.  The migrated data leaves Calico
+// networking paused in the destination datastore until the migration is completed.  If an error
+// is returned it will be of type MigrationError.
+func (m *datastoreMigrationHelper) Migrate() (*DatastoreMigrationData, error) {
+	m.status("Pausing Calico networking")
+	if err := m.setReady(m.src, false); err != nil {
+		m.statusError("Unable to pause calico networking")
+		return nil, MigrationError{
+			Type: ErrorGeneric,
+			Err:  fmt.Errorf("unable to pause calico networking: %v", err),
+		}
+	}
+
+	// Wait for a short period to allow orchestrators to finish any current allocations.
+	m.status("Calico networking is now paused - waiting for %s", datastorePauseDuration)
+	time.Sleep(datastorePauseDuration)
+
+	// Now query all the data again and translate it - this is the final snapshot that we will use.
+	m.status("Querying current etcdv3 snapshot and converting for the Kubernetes datastore")
+	data, err := m.queryAndTranslate()
+	if err != nil {
+		m.statusError("Unable to convert the etcdv3 snapshot")
+		m.statusBullet("cause: %v", err)
+		return nil, m.abortAfterError(
+			fmt.Errorf("error converting data: %v", err), ErrorGeneric,
+		)
+	}
+	if data.HasErrors() {
+		m.statusError("Error converting data - will attempt to abort migration")
+		return nil, m.abortAfterError(
+			errors.New("error converting data"), ErrorConvertingData,
+		)
+	}
+	m.statusBullet("data converted successfully")
+
+	m.status("Storing data in the Kubernetes datastore")
+	for n, kvp := range data.KVPairs {
+		if err := m.store(kvp); err != nil {
+			m.statusError("Unable to store the data")
+			m.statusBullet("cause: %v", err)
+			return nil, m.abortAfterError(
+				fmt.Errorf("error storing converted data: %v", err), ErrorMigratingData,
+			)
+		}
+		if (n+1)%numAppliesPerUpdate == 0 {
+			m.statusBullet("applied %d entries", n+1)
+		}
+	}
+	m.statusBullet("success: data stored in the Kubernetes datastore")
+
+	m.status("Validating the migrated data")
+	if err := m.verify(data); err != nil {
+		m.statusError("Migrated data is not present in the Kubernetes datastore")
+		m.statusBullet("cause: %v", err)
+		return nil, m.abortAfterError(
+			fmt.Errorf("error validating migrated data: %v", err), ErrorMigratingData,
+		)
+	}
+	m.statusBullet("migrated data validated successfully")
+
+	m.status("Data migration from etcdv3 to the Kubernetes datastore successful")
+	m.statusBullet("check the output for details of the migrated resources")
+	m.statusBullet("continue by reconfiguring your Calico components to use the Kubernetes datastore")
+	return data, nil
+}
+
+func (m *datastoreMigrationHelper) abortAfterError(err error, errType ErrorType) error {
+	if ae := m.Abort(); ae == nil {
+		return MigrationError{Type: errType, Err: err}
+	}
+	return MigrationError{Type: errType, Err: err, NeedsAbort: true}
+}
+
+// Abort aborts the migration by re-enabling Calico networking in the source datastore.  Data
+// that was already stored in the destination datastore is left in place.  If an error is
+// returned it will be of type MigrationError.
+func (m *datastoreMigrationHelper) Abort() error {
+	m.status("Aborting migration")
+	m.status("Re-enabling Calico networking in the etcdv3 datastore")
+	var err error
+	for i := 0; i < forceEnableReadyRetries; i++ {
+		err = m.setReady(m.src, true)
+		if err == nil {
+			break
+		}
+		time.Sleep(1 * time.Second)
+	}
+	if err != nil {
+		m.statusError("Failed to abort migration. Retry command.")
+		m.statusBullet("cause: %v", err)
+		return MigrationError{Type: ErrorGeneric, Err: err, NeedsAbort: true}
+	}
+	m.status("Migration aborted successfully")
+	return nil
+}
+
+// Complete completes the migration by enabling Calico networking in the destination datastore.
+// If an error is returned it will be of type MigrationError.
+func (m *datastoreMigrationHelper) Complete() error {
+	m.status("Completing migration")
+	m.status("Enabling Calico networking in the Kubernetes datastore")
+	var err error
+	for i := 0; i < forceEnableReadyRetries; i++ {
+		err = m.setReady(m.dst, true)
+		if err == nil {
+			break
+		}
+		time.Sleep(1 * time.Second)
+	}
+	if err != nil {
+		m.statusError("Failed to complete migration. Retry command.")
+		m.statusBullet("cause: %v", err)
+		return MigrationError{Type: ErrorGeneric, Err: err}
+	}
+	m.status("Migration completed successfully")
+	return nil
+}
+
+// queryAndTranslate queries the v3 resources and IPAM data from the source datastore and
+// translates them for the destination datastore.  This method returns an error if it is unable
+// to query the data.  Errors translating or validating the data are returned within the
+// DatastoreMigrationData, so that a full pre-migration report can be generated in a single shot.
+func (m *datastoreMigrationHelper) queryAndTranslate() (*DatastoreMigrationData, error) {
+	data := &DatastoreMigrationData{}
+	ctx := context.Background()
+
+	// Keep track of the translated names so that we can determine if we have any name clashes.
+	translatedNames := map[string]model.Key{}
+
+	for _, kind := range datastoreMigrationKinds {
+		m.statusBullet("handling %s resources", kind)
+		kvps, err := m.src.List(ctx, model.ResourceListOptions{Kind: kind}, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, kvp := range kvps.KVPairs {
+			out, err := translateResourceForKDD(kvp)
+			if err != nil {
+				data.ConversionErrors = append(data.ConversionErrors, DatastoreConversionError{
+					Key:   kvp.Key,
+					Value: kvp.Value,
+					Cause: err,
+				})
+				continue
+			}
+			if out == nil {
+				log.WithField("Key", kvp.Key).Info("Skipping resource derived from Kubernetes resources")
+				data.HandledByKubernetes = append(data.HandledByKubernetes, kvp.Key)
+				continue
+			}
+
+			// Check the translated name for clashes, the translated resource validates correctly,
+			// and that Nodes are backed by a Kubernetes node.  Store an error if any check fails
+			// and continue so that we output as much information as possible.
+			valid := true
+			translatedName := out.Key.String()
+			if k, ok := translatedNames[translatedName]; ok {
+				data.ConversionErrors = append(data.ConversionErrors, DatastoreConversionError{
+					Key:   kvp.Key,
+					Value: kvp.Value,
+					Cause: fmt.Errorf("translated name %s clashes with %s", translatedName, k),
+				})
+				valid = false
+			}
+			translatedNames[translatedName] = kvp.Key
+
+			if err := validateForAPI(out.Value.(converters.Resource)); err != nil {
+				data.ConversionErrors = append(data.ConversionErrors, DatastoreConversionError{
+					Key:   kvp.Key,
+					Value: kvp.Value,
+					Cause: err,
+				})
+				valid = false
+			}
+
+			if kind == apiv3.KindNode {
+				if _, err := m.dst.Get(ctx, out.Key, ""); err != nil {
+					if _, ok := err.(cerrors.ErrorResourceDoesNotExist); !ok {
+						return nil, err
+					}
+					data.ConversionErrors = append(data.ConversionErrors, DatastoreConversionError{
+						Key:   kvp.Key,
+						Value: kvp.Value,
+						Cause: errors.New("there is no Kubernetes node with the same name"),
+					})
+					valid = false
+				}
+			}
+
+			if valid {
+				data.KVPairs = append(data.KVPairs, out)
+				if out.Key != kvp.Key {
+					data.NameConversions = append(data.NameConversions, DatastoreNameConversion{
+						Source:      kvp.Key,
+						Destination: out.Key,
+					})
+				}
+			}
+		}
+	}
+
+	m.statusBullet("handling IPAM data")
+	for _, list := range datastoreMigrationIPAM {
+		kvps, err := m.src.List(ctx, list, "")
+		if err != nil {
+			return nil, fmt.Errorf("unable to list IPAM data: %v", err)
+		}
+		for _, kvp := range kvps.KVPairs {
+			kvp.Revision = ""
+			data.KVPairs = append(data.KVPairs, kvp)
+		}
+	}
+	if kvp, err := m.src.Get(ctx, model.IPAMConfigKey{}, ""); err == nil {
+		kvp.Revision = ""
+		data.KVPairs = append(data.KVPairs, kvp)
+	} else if _, ok := err.(cerrors.ErrorResourceDoesNotExist); !ok {
+		return nil, fmt.Errorf("unable to query IPAM configuration: %v", err)
+	}
+
+	return data, nil
+}
+
+// translateResourceForKDD translates a v3 resource read from the etcdv3 datastore to the form
+// that is stored in the Kubernetes datastore.  It returns nil if the Kubernetes datastore
+// derives the resource from Kubernetes resources, so it should not be migrated, and an error if
+// the resource can't be stored in the Kubernetes datastore.
+func translateResourceForKDD(kvp *model.KVPair) (*model.KVPair, error) {
+	key := kvp.Key.(model.ResourceKey)
+	if datastoreDerivedResource(key) {
+		return nil, nil
+	}
+	value := kvp.Value.(converters.Resource).DeepCopyObject().(converters.Resource)
+	value.GetObjectMeta().SetResourceVersion("")
+
+	switch r := value.(type) {
+	case *apiv3.Profile:
+		return nil, errors.New("the Kubernetes datastore only supports the Profiles that it derives from namespaces and service accounts")
+	case *apiv3.WorkloadEndpoint:
+		if r.Spec.Orchestrator == apiv3.OrchestratorKubernetes {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("the Kubernetes datastore only supports WorkloadEndpoints backed by pods, not by orchestrator %s", r.Spec.Orchestrator)
+	case *apiv3.ClusterInformation:
+		// Leave Calico networking paused in the destination datastore until the migration is
+		// completed.
+		ready := false
+		r.Spec.DatastoreReady = &ready
+	case *apiv3.GlobalNetworkPolicy, *apiv3.NetworkPolicy:
+		// Policies written without a tier prefix are stored with the default tier prefix.
+		if !strings.Contains(key.Name, ".") {
+			key.Name = convertPolicyNameForStorage(key.Name)
+			value.GetObjectMeta().SetName(key.Name)
+		}
+	}
+	return &model.KVPair{Key: key, Value: value}, nil
+}
+
+// validateForAPI validates the resource in the form that it is presented by the v3 client, which
+// strips the tier prefix from policy names.
+func validateForAPI(r converters.Resource) error {
+	switch r.(type) {
+	case *apiv3.GlobalNetworkPolicy, *apiv3.NetworkPolicy:
+		r = r.DeepCopyObject().(converters.Resource)
+		r.GetObjectMeta().SetName(convertPolicyNameFromStorage(r.GetObjectMeta().GetName()))
+	}
+	return validatorv3.Validate(r)
+}
+
+// datastoreDerivedResource returns true if the Kubernetes datastore derives the resource from
+// Kubernetes resources.
+func datastoreDerivedResource(key model.ResourceKey) bool {
+	switch key.Kind {
+	case apiv3.KindNetworkPolicy, apiv3.KindGlobalNetworkPolicy:
+		return strings.HasPrefix(key.Name, conversion.K8sNetworkPolicyNamePrefix)
+	case apiv3.KindProfile:
+		return strings.HasPrefix(key.Name, conversion.NamespaceProfileNamePrefix) ||
+			strings.HasPrefix(key.Name, conversion.ServiceAccountProfileNamePrefix)
+	}
+	return false
+}
+
+// store stores the entry in the destination datastore.  Nodes can't be created in the
+// Kubernetes datastore, so their Calico configuration is merged into the Kubernetes node.
+func (m *datastoreMigrationHelper) store(kvp *model.KVPair) error {
+	if k, ok := kvp.Key.(model.ResourceKey); ok && k.Kind == apiv3.KindNode {
+		return m.storeNode(kvp)
+	}
+	return applyToBackendClient(m.dst, kvp)
+}
+
+func (m *datastoreMigrationHelper) storeNode(kvp *model.KVPair) error {
+	src := kvp.Value.(*apiv3.Node)
+	for i := 0; i < maxApplyRetries; i++ {
+		current, err := m.dst.Get(context.Background(), kvp.Key, "")
+		if err != nil {
+			return err
+		}
+		node := current.Value.(*apiv3.Node)
+		node.Spec = src.Spec
+		for k, v := range src.Labels {
+			if _, ok := node.Labels[k]; !ok {
+				if node.Labels == nil {
+					node.Labels = map[string]string{}
+				}
+				node.Labels[k] = v
+			}
+		}
+		_, err = m.dst.Update(context.Background(), current)
+		if _, ok := err.(cerrors.ErrorResourceUpdateConflict); !ok {
+			return err
+		}
+		time.Sleep(retryInterval)
+	}
+	return fmt.Errorf("unable to update node %s: too many update conflicts", src.Name)
+}
+
+// verify checks that all of the migrated entries are present in the destination datastore.
+func (m *datastoreMigrationHelper) verify(data *DatastoreMigrationData) error {
+	for _, kvp := range data.KVPairs {
+		if _, err := m.dst.Get(context.Background(), kvp.Key, ""); err != nil {
+			return fmt.Errorf("unable to query migrated entry %s: %v", kvp.Key, err)
+		}
+	}
+	return nil
+}
+
+// setReady sets the Ready flag in the ClusterInformation of the datastore.
+func (m *datastoreMigrationHelper) setReady(bc bapi.Client, ready bool) error {
+	log.WithField("Ready", ready).Info("Updating Ready flag in ClusterInformation")
+	ctx := context.Background()
+	key := model.ResourceKey{Kind: apiv3.KindClusterInformation, Name: "default"}
+	kvp, err := bc.Get(ctx, key, "")
+	if err == nil {
+		ci := kvp.Value.(*apiv3.ClusterInformation)
+		if !ready && ci.Spec.DatastoreReady != nil && !*ci.Spec.DatastoreReady {
+			m.statusBullet("Calico networking already paused")
+			return errors.New("Calico networking already paused do not continue.")
+		}
+		ci.Spec.DatastoreReady = &ready
+		_, err = bc.Update(ctx, kvp)
+	} else if _, ok := err.(cerrors.ErrorResourceDoesNotExist); ok {
+		ci := apiv3.NewClusterInformation()
+		ci.Name = "default"
+		ci.Spec.DatastoreReady = &ready
+		_, err = bc.Create(ctx, &model.KVPair{Key: key, Value: ci})
+	}
+	if err != nil {
+		if ready {
+			m.statusBullet("failed to resume Calico networking: %v", err)
+		} else {
+			m.statusBullet("failed to pause Calico networking: %v", err)
+		}
+		return err
+	}
+	if ready {
+		m.statusBullet("successfully resumed Calico networking")
+	} else {
+		m.statusBullet("successfully paused Calico networking")
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+// Copyright (c) 2019 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migrator
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	apiv3 "github.com/unai-ttxu/libcalico-go/lib/apis/v3"
+	bapi "github.com/unai-ttxu/libcalico-go/lib/backend/api"
+	"github.com/unai-ttxu/libcalico-go/lib/backend/model"
+	cerrors "github.com/unai-ttxu/libcalico-go/lib/errors"
+	"github.com/unai-ttxu/libcalico-go/lib/net"
+	"github.com/unai-ttxu/libcalico-go/lib/upgrade/converters"
+)
+
+// fakeBackendClient is an in-memory backend client that supports the operations used by the
+// datastore migrator.  Like a real datastore, it stores and returns copies of the resources.
+type fakeBackendClient struct {
+	bapi.Client
+	kvps map[string]*model.KVPair
+}
+
+func newFakeBackendClient(kvps ...*model.KVPair) *fakeBackendClient {
+	c := &fakeBackendClient{kvps: map[string]*model.KVPair{}}
+	for _, kvp := range kvps {
+		c.kvps[kvp.Key.String()] = copyKVPair(kvp)
+	}
+	return c
+}
+
+func copyKVPair(kvp *model.KVPair) *model.KVPair {
+	out := *kvp
+	if r, ok := kvp.Value.(converters.Resource); ok {
+		out.Value = r.DeepCopyObject()
+	}
+	return &out
+}
+
+func (c *fakeBackendClient) Create(ctx context.Context, kvp *model.KVPair) (*model.KVPair, error) {
+	if _, ok := c.kvps[kvp.Key.String()]; ok {
+		return nil, cerrors.ErrorResourceAlreadyExists{Identifier: kvp.Key}
+	}
+	c.kvps[kvp.Key.String()] = copyKVPair(kvp)
+	return kvp, nil
+}
+
+func (c *fakeBackendClient) Update(ctx context.Context, kvp *model.KVPair) (*model.KVPair, error) {
+	if _, ok := c.kvps[kvp.Key.String()]; !ok {
+		return nil, cerrors.ErrorResourceDoesNotExist{Identifier: kvp.Key}
+	}
+	c.kvps[kvp.Key.String()] = copyKVPair(kvp)
+	return kvp, nil
+}
+
+func (c *fakeBackendClient) Get(ctx context.Context, key model.Key, revision string) (*model.KVPair, error) {
+	if kvp, ok := c.kvps[key.String()]; ok {
+		return copyKVPair(kvp), nil
+	}
+	return nil, cerrors.ErrorResourceDoesNotExist{Identifier: key}
+}
+
+func (c *fakeBackendClient) List(ctx context.Context, list model.ListInterface, revision string) (*model.KVPairList, error) {
+	l := &model.KVPairList{}
+	for _, kvp := range c.kvps {
+		var match bool
+		switch lo := list.(type) {
+		case model.ResourceListOptions:
+			k, ok := kvp.Key.(model.ResourceKey)
+			match = ok && k.Kind == lo.Kind
+		case model.BlockListOptions:
+			_, match = kvp.Key.(model.BlockKey)
+		case model.BlockAffinityListOptions:
+			_, match = kvp.Key.(model.BlockAffinityKey)
+		case model.IPAMHandleListOptions:
+			_, match = kvp.Key.(model.IPAMHandleKey)
+		}
+		if match {
+			l.KVPairs = append(l.KVPairs, copyKVPair(kvp))
+		}
+	}
+	return l, nil
+}
+
+func resourceKVPair(r converters.Resource) *model.KVPair {
+	return &model.KVPair{Key: resourceToKey(r), Value: r}
+}
+
+var _ = Describe("etcdv3 to Kubernetes datastore migration", func() {
+	var src, dst *fakeBackendClient
+
+	newPool := func() *apiv3.IPPool {
+		p := apiv3.NewIPPool()
+		p.Name = "pool1"
+		p.Spec.CIDR = "192.168.0.0/16"
+		p.Spec.BlockSize = 26
+		return p
+	}
+	newNode := func(ipv4 string) *apiv3.Node {
+		n := apiv3.NewNode()
+		n.Name = "node1"
+		if ipv4 != "" {
+			n.Spec.BGP = &apiv3.NodeBGPSpec{IPv4Address: ipv4}
+		}
+		return n
+	}
+	newPolicy := func(name string) *apiv3.GlobalNetworkPolicy {
+		p := apiv3.NewGlobalNetworkPolicy()
+		p.Name = name
+		return p
+	}
+	newProfile := func(name string) *apiv3.Profile {
+		p := apiv3.NewProfile()
+		p.Name = name
+		return p
+	}
+	clusterInfo := func(c *fakeBackendClient) *apiv3.ClusterInformation {
+		kvp, err := c.Get(context.Background(), model.ResourceKey{Kind: apiv3.KindClusterInformation, Name: "default"}, "")
+		Expect(err).NotTo(HaveOccurred())
+		return kvp.Value.(*apiv3.ClusterInformation)
+	}
+
+	BeforeEach(func() {
+		datastorePauseDuration = 0
+		ready := true
+		ci := apiv3.NewClusterInformation()
+		ci.Name = "default"
+		ci.Spec.DatastoreReady = &ready
+		wep := apiv3.NewWorkloadEndpoint()
+		wep.Name = "node1-k8s-pod1-eth0"
+		wep.Namespace = "default"
+		wep.Spec.Orchestrator = apiv3.OrchestratorKubernetes
+		_, cidr, _ := net.ParseCIDR("192.168.0.0/26")
+		affinity := "host:node1"
+
+		src = newFakeBackendClient(
+			resourceKVPair(ci),
+			resourceKVPair(newPool()),
+			resourceKVPair(newNode("10.0.0.1/24")),
+			resourceKVPair(newPolicy("default.allow")),
+			resourceKVPair(newPolicy("legacy")),
+			resourceKVPair(newPolicy("knp.default.k8s-policy")),
+			resourceKVPair(newProfile("kns.default")),
+			resourceKVPair(wep),
+			&model.KVPair{Key: model.BlockKey{CIDR: *cidr}, Value: &model.AllocationBlock{CIDR: *cidr, Affinity: &affinity}, Revision: "10"},
+			&model.KVPair{Key: model.IPAMConfigKey{}, Value: &model.IPAMConfig{StrictAffinity: true}, Revision: "11"},
+		)
+		dst = newFakeBackendClient(resourceKVPair(newNode("")))
+	})
+
+	AfterEach(func() {
+		datastorePauseDuration = 15 * time.Second
+	})
+
+	It("should migrate the data, translating it for the Kubernetes datastore", func() {
+		m := NewDatastoreMigrator(src, dst, nil)
+		empty, err := m.IsDestinationEmpty()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(empty).To(BeTrue())
+
+		data, err := m.ValidateConversion()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data.HandledByKubernetes).To(ConsistOf(
+			model.ResourceKey{Kind: apiv3.KindGlobalNetworkPolicy, Name: "knp.default.k8s-policy"},
+			model.ResourceKey{Kind: apiv3.KindProfile, Name: "kns.default"},
+			model.ResourceKey{Kind: apiv3.KindWorkloadEndpoint, Name: "node1-k8s-pod1-eth0", Namespace: "default"},
+		))
+		Expect(data.NameConversions).To(ConsistOf(DatastoreNameConversion{
+			Source:      model.ResourceKey{Kind: apiv3.KindGlobalNetworkPolicy, Name: "legacy"},
+			Destination: model.ResourceKey{Kind: apiv3.KindGlobalNetworkPolicy, Name: "default.legacy"},
+		}))
+
+		_, err = m.Migrate()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*clusterInfo(src).Spec.DatastoreReady).To(BeFalse())
+		Expect(*clusterInfo(dst).Spec.DatastoreReady).To(BeFalse())
+
+		for _, key := range []model.Key{
+			model.ResourceKey{Kind: apiv3.KindIPPool, Name: "pool1"},
+			model.ResourceKey{Kind: apiv3.KindGlobalNetworkPolicy, Name: "default.allow"},
+			model.ResourceKey{Kind: apiv3.KindGlobalNetworkPolicy, Name: "default.legacy"},
+			model.IPAMConfigKey{},
+		} {
+			_, err := dst.Get(context.Background(), key, "")
+			Expect(err).NotTo(HaveOccurred(), key.String())
+		}
+		for _, key := range []model.Key{
+			model.ResourceKey{Kind: apiv3.KindGlobalNetworkPolicy, Name: "knp.default.k8s-policy"},
+			model.ResourceKey{Kind: apiv3.KindProfile, Name: "kns.default"},
+		} {
+			_, err := dst.Get(context.Background(), key, "")
+			Expect(err).To(HaveOccurred(), key.String())
+		}
+		blocks, err := dst.List(context.Background(), model.BlockListOptions{}, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(blocks.KVPairs).To(HaveLen(1))
+
+		By("merging the Calico configuration into the Kubernetes node")
+		node, err := dst.Get(context.Background(), model.ResourceKey{Kind: apiv3.KindNode, Name: "node1"}, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(node.Value.(*apiv3.Node).Spec.BGP.IPv4Address).To(Equal("10.0.0.1/24"))
+
+		By("enabling Calico networking in the Kubernetes datastore on completion")
+		Expect(m.Complete()).NotTo(HaveOccurred())
+		Expect(*clusterInfo(dst).Spec.DatastoreReady).To(BeTrue())
+	})
+
+	It("should resume Calico networking in the etcdv3 datastore on abort", func() {
+		m := NewDatastoreMigrator(src, dst, nil)
+		_, err := m.Migrate()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*clusterInfo(src).Spec.DatastoreReady).To(BeFalse())
+
+		Expect(m.Abort()).NotTo(HaveOccurred())
+		Expect(*clusterInfo(src).Spec.DatastoreReady).To(BeTrue())
+
+		By("reporting that the Kubernetes datastore is no longer empty")
+		empty, err := m.IsDestinationEmpty()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(empty).To(BeFalse())
+	})
+
+	It("should report resources that can't be migrated", func() {
+		src.Create(context.Background(), resourceKVPair(newProfile("custom")))
+		delete(dst.kvps, model.ResourceKey{Kind: apiv3.KindNode, Name: "node1"}.String())
+
+		m := NewDatastoreMigrator(src, dst, nil)
+		data, err := m.ValidateConversion()
+		Expect(err).To(HaveOccurred())
+		Expect(err.(MigrationError).Type).To(Equal(ErrorConvertingData))
+		var keys []model.Key
+		for _, e := range data.ConversionErrors {
+			keys = append(keys, e.Key)
+		}
+		Expect(keys).To(ConsistOf(
+			model.ResourceKey{Kind: apiv3.KindProfile, Name: "custom"},
+			model.ResourceKey{Kind: apiv3.KindNode, Name: "node1"},
+		))
+
+		By("resuming Calico networking if the migration fails to convert the data")
+		_, err = m.Migrate()
+		Expect(err).To(HaveOccurred())
+		Expect(*clusterInfo(src).Spec.DatastoreReady).To(BeTrue())
+	})
+
+	DescribeTable("should determine which resources the Kubernetes datastore derives from Kubernetes resources",
+		func(key model.ResourceKey, derived bool) {
+			Expect(datastoreDerivedResource(key)).To(Equal(derived))
+		},
+		Entry("Kubernetes network policy", model.ResourceKey{Kind: apiv3.KindNetworkPolicy, Name: "knp.default.p", Namespace: "ns"}, true),
+		Entry("Calico network policy", model.ResourceKey{Kind: apiv3.KindNetworkPolicy, Name: "default.p", Namespace: "ns"}, false),
+		Entry("namespace profile", model.ResourceKey{Kind: apiv3.KindProfile, Name: "kns.ns"}, true),
+		Entry("service account profile", model.ResourceKey{Kind: apiv3.KindProfile, Name: "ksa.ns.sa"}, true),
+		Entry("other profile", model.ResourceKey{Kind: apiv3.KindProfile, Name: "custom"}, false),
+		Entry("IP pool", model.ResourceKey{Kind: apiv3.KindIPPool, Name: "knp.default.p"}, false),
+	)
+})
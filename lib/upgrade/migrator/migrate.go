@@ -73,16 +73,22 @@ type StatusWriterInterface interface {
 // New creates a new migration helper implementing Interface.
 func New(clientv3 clientv3.Interface, clientv1 clients.V1ClientInterface, statusWriter StatusWriterInterface) Interface {
 	return &migrationHelper{
-		clientv3:     clientv3,
-		clientv1:     clientv1,
-		statusWriter: statusWriter,
+		clientv3:       clientv3,
+		clientv1:       clientv1,
+		statusReporter: statusReporter{statusWriter: statusWriter},
 	}
 }
 
 // migrationHelper implements the migrate.Interface.
 type migrationHelper struct {
-	clientv3     clientv3.Interface
-	clientv1     clients.V1ClientInterface
+	statusReporter
+	clientv3 clientv3.Interface
+	clientv1 clients.V1ClientInterface
+}
+
+// statusReporter reports the progress of a migration through the optional
+// StatusWriterInterface.
+type statusReporter struct {
 	statusWriter StatusWriterInterface
 }
 
@@ -861,7 +867,7 @@ func convertLogLevel(logLevel string) string {
 }
 
 // Display a 79-char word wrapped status message and log.
-func (m *migrationHelper) status(format string, a ...interface{}) {
+func (m *statusReporter) status(format string, a ...interface{}) {
 	msg := fmt.Sprintf(format, a...)
 	log.Info(strings.TrimSpace(msg))
 	if m.statusWriter != nil {
@@ -870,7 +876,7 @@ func (m *migrationHelper) status(format string, a ...interface{}) {
 }
 
 // Display a 79-char word wrapped sub status (a bulleted message) and log.
-func (m *migrationHelper) statusBullet(format string, a ...interface{}) {
+func (m *statusReporter) statusBullet(format string, a ...interface{}) {
 	msg := fmt.Sprintf(format, a...)
 	log.Info(strings.TrimSpace(msg))
 	if m.statusWriter != nil {
@@ -879,7 +885,7 @@ func (m *migrationHelper) statusBullet(format string, a ...interface{}) {
 }
 
 // Display a 79-char word wrapped error message and log.
-func (m *migrationHelper) statusError(format string, a ...interface{}) {
+func (m *statusReporter) statusError(format string, a ...interface{}) {
 	msg := fmt.Sprintf(format, a...)
 	log.Error(strings.TrimSpace(msg))
 	if m.statusWriter != nil {
@@ -1054,6 +1060,15 @@ func convertPolicyNameForStorage(name string) string {
 	return "default." + name
 }
 
+func convertPolicyNameFromStorage(name string) string {
+	// Do nothing on names prefixed with "knp." or "ossg."
+	if strings.HasPrefix(name, "knp.") || strings.HasPrefix(name, "ossg.") {
+		return name
+	}
+	parts := strings.SplitN(name, ".", 2)
+	return parts[len(parts)-1]
+}
+
 // migrateIPAMData queries, converts and migrates all of the IPAM data from v1
 // to v3 formats.
 func (m *migrationHelper) migrateIPAMData() error {
@@ -1136,8 +1151,11 @@ type backendClientAccessor interface {
 // applyToBackend applies the supplied KVPair directly to the backend datastore.
 func (m *migrationHelper) applyToBackend(kvp *model.KVPair) error {
 	// Extract the backend client API from the v3 client.
-	bc := m.clientv3.(backendClientAccessor).Backend()
+	return applyToBackendClient(m.clientv3.(backendClientAccessor).Backend(), kvp)
+}
 
+// applyToBackendClient applies the supplied KVPair to the backend datastore.
+func applyToBackendClient(bc bapi.Client, kvp *model.KVPair) error {
 	// First try creating the resource. If the resource already exists, try an update.
 	logCxt := log.WithField("Key", kvp.Key)
 	logCxt.Debug("Attempting to create resource")
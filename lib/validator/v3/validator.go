@@ -57,6 +57,13 @@ var (
 	// GlobalNetworkPolicy names must be a simple DNS1123 label format (nameLabelFmt).
 	globalNetworkPolicyNameRegex = regexp.MustCompile("^(" + nameLabelFmt + ")$")
 
+	// Domain names are validated as DNS1123 subdomains, optionally with a leading "*." wildcard
+	// label and a trailing "." (fully qualified form).
+	domainRegex = regexp.MustCompile("^(\\*\\.)?" + nameSubdomainFmt + "\\.?$")
+
+	allocationStrategyRegex = regexp.MustCompile("^(Random|Sequential|LeastRecentlyReleased|Sticky)$")
+	borrowingPolicyRegex    = regexp.MustCompile("^(Always|Never|SameZone)$")
+
 	interfaceRegex        = regexp.MustCompile("^[a-zA-Z0-9_.-]{1,15}$")
 	ifaceFilterRegex      = regexp.MustCompile("^[a-zA-Z0-9:._+-]{1,15}$")
 	actionRegex           = regexp.MustCompile("^(Allow|Deny|Log|Pass)$")
@@ -125,6 +132,8 @@ func init() {
 	registerFieldValidator("ipVersion", validateIPVersion)
 	registerFieldValidator("ipIpMode", validateIPIPMode)
 	registerFieldValidator("vxlanMode", validateVXLANMode)
+	registerFieldValidator("allocationStrategy", validateAllocationStrategy)
+	registerFieldValidator("borrowingPolicy", validateBorrowingPolicy)
 	registerFieldValidator("policyType", validatePolicyType)
 	registerFieldValidator("logLevel", validateLogLevel)
 	registerFieldValidator("dropAcceptReturn", validateFelixEtoHAction)
@@ -135,6 +144,7 @@ func init() {
 	registerFieldValidator("ifaceFilter", validateIfaceFilter)
 	registerFieldValidator("mac", validateMAC)
 	registerFieldValidator("iptablesBackend", validateIptablesBackend)
+	registerFieldValidator("domain", validateDomain)
 
 	// Register network validators (i.e. validating a correctly masked CIDR).  Also
 	// accepts an IP address without a mask (assumes a full mask).
@@ -269,6 +279,18 @@ func validateVXLANMode(fl validator.FieldLevel) bool {
 	return vxlanModeRegex.MatchString(s)
 }
 
+func validateAllocationStrategy(fl validator.FieldLevel) bool {
+	s := fl.Field().String()
+	log.Debugf("Validate allocation strategy: %s", s)
+	return allocationStrategyRegex.MatchString(s)
+}
+
+func validateBorrowingPolicy(fl validator.FieldLevel) bool {
+	s := fl.Field().String()
+	log.Debugf("Validate borrowing policy: %s", s)
+	return borrowingPolicyRegex.MatchString(s)
+}
+
 func validateMAC(fl validator.FieldLevel) bool {
 	s := fl.Field().String()
 	log.Debugf("Validate MAC Address: %s", s)
@@ -285,6 +307,12 @@ func validateIptablesBackend(fl validator.FieldLevel) bool {
 	return s == "" || s == api.IptablesBackendNFTables || s == api.IptablesBackendLegacy
 }
 
+func validateDomain(fl validator.FieldLevel) bool {
+	s := fl.Field().String()
+	log.Debugf("Validate domain: %s", s)
+	return len(s) <= k8svalidation.DNS1123SubdomainMaxLength && domainRegex.MatchString(strings.ToLower(s))
+}
+
 func validateLogLevel(fl validator.FieldLevel) bool {
 	s := fl.Field().String()
 	log.Debugf("Validate Felix log level: %s", s)
@@ -731,17 +759,44 @@ func validateIPPoolSpec(structLevel validator.StructLevel) {
 		}
 	}
 
+	// The block size must be within the range supported by Calico IPAM.
+	ones, bits := cidr.Mask.Size()
+	minBlockSize := bits - 12
+	validBlockSize := pool.BlockSize >= minBlockSize && pool.BlockSize <= bits
+	if !validBlockSize {
+		structLevel.ReportError(reflect.ValueOf(pool.BlockSize),
+			"IPpool.BlockSize", "", reason(fmt.Sprintf("IPv%d block size must be between %d and %d", cidr.Version(), minBlockSize, bits)), "")
+	}
+
 	// The Calico IPAM places restrictions on the minimum IP pool size.  If
 	// the ippool is enabled, check that the pool is at least the minimum size.
-	if !pool.Disabled {
-		ones, _ := cidr.Mask.Size()
+	if validBlockSize && !pool.Disabled {
 		log.Debugf("Pool CIDR: %s, mask: %d, blockSize: %d", cidr.String(), ones, pool.BlockSize)
 		if ones > pool.BlockSize {
 			structLevel.ReportError(reflect.ValueOf(pool.CIDR),
-				"IPpool.CIDR", "", reason("IP pool size is too small for use with Calico IPAM. It must be equal to or greater than the block size."), "")
+				"IPpool.CIDR", "", reason(fmt.Sprintf(
+					"IP pool size /%d is too small for use with Calico IPAM. It must be equal to or greater than the block size /%d.",
+					ones, pool.BlockSize)), "")
 		}
 	}
 
+	// The addresses reserved at the edges of each block must leave at least one address
+	// for automatic assignment.
+	if pool.ReservedBlockStart < 0 {
+		structLevel.ReportError(reflect.ValueOf(pool.ReservedBlockStart),
+			"IPpool.ReservedBlockStart", "", reason("ReservedBlockStart must not be negative"), "")
+	}
+	if pool.ReservedBlockEnd < 0 {
+		structLevel.ReportError(reflect.ValueOf(pool.ReservedBlockEnd),
+			"IPpool.ReservedBlockEnd", "", reason("ReservedBlockEnd must not be negative"), "")
+	}
+	if blockAddrs := 1 << uint(bits-pool.BlockSize); validBlockSize && pool.ReservedBlockStart+pool.ReservedBlockEnd >= blockAddrs {
+		structLevel.ReportError(reflect.ValueOf(pool.ReservedBlockStart),
+			"IPpool.ReservedBlockStart", "", reason(fmt.Sprintf(
+				"ReservedBlockStart and ReservedBlockEnd must leave at least one of the %d addresses in each /%d block unreserved",
+				blockAddrs, pool.BlockSize)), "")
+	}
+
 	// The Calico CIDR should be strictly masked
 	log.Debugf("IPPool CIDR: %s, Masked IP: %d", pool.CIDR, cidr.IP)
 	if cidr.IP.String() != ipAddr.String() {
@@ -1012,6 +1067,7 @@ func validateNetworkPolicy(structLevel validator.StructLevel) {
 
 func validateNetworkSet(structLevel validator.StructLevel) {
 	ns := structLevel.Current().Interface().(api.NetworkSet)
+	validateNetworkSetShardOf(structLevel, ns.GetAnnotations())
 	for k := range ns.GetLabels() {
 		if k == "projectcalico.org/namespace" {
 			// The namespace label should only be used when mapping the real namespace through
@@ -1029,6 +1085,7 @@ func validateNetworkSet(structLevel validator.StructLevel) {
 
 func validateGlobalNetworkSet(structLevel validator.StructLevel) {
 	gns := structLevel.Current().Interface().(api.GlobalNetworkSet)
+	validateNetworkSetShardOf(structLevel, gns.GetAnnotations())
 	for k := range gns.GetLabels() {
 		if k == "projectcalico.org/namespace" {
 			// The namespace label should only be used when mapping the real namespace through
@@ -1044,6 +1101,20 @@ func validateGlobalNetworkSet(structLevel validator.StructLevel) {
 	}
 }
 
+// validateNetworkSetShardOf checks that the logical set name in the network set sharding annotation
+// (if present) is a valid resource name.
+func validateNetworkSetShardOf(structLevel validator.StructLevel, annotations map[string]string) {
+	if v, ok := annotations[api.AnnotationNetworkSetShardOf]; ok && !nameRegex.MatchString(v) {
+		structLevel.ReportError(
+			reflect.ValueOf(v),
+			"Metadata.Annotations ("+api.AnnotationNetworkSetShardOf+")",
+			"",
+			reason("logical network set name must consist of lower case alphanumeric characters, '-' or '.' (regex: "+nameSubdomainFmt+")"),
+			"",
+		)
+	}
+}
+
 func validateGlobalNetworkPolicy(structLevel validator.StructLevel) {
 	gnp := structLevel.Current().Interface().(api.GlobalNetworkPolicy)
 	spec := gnp.Spec
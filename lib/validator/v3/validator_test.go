@@ -276,6 +276,51 @@ func init() {
 			},
 			false,
 		),
+		Entry("should accept GlobalNetworkSetSpec with domains",
+			api.GlobalNetworkSetSpec{
+				Domains: []string{
+					"example.com",
+					"*.example.com",
+					"api.Example.com.",
+				},
+			},
+			true,
+		),
+		Entry("should reject GlobalNetworkSetSpec with a bad domain",
+			api.GlobalNetworkSetSpec{
+				Domains: []string{
+					"exa$mple.com",
+				},
+			},
+			false,
+		),
+		Entry("should reject GlobalNetworkSetSpec with a wildcard that is not the first label",
+			api.GlobalNetworkSetSpec{
+				Domains: []string{
+					"api.*.example.com",
+				},
+			},
+			false,
+		),
+		Entry("should accept IPReservationSpec with CIDRs and IPs",
+			api.IPReservationSpec{
+				ReservedCIDRs: []string{
+					"10.0.0.1",
+					"10.1.0.0/24",
+					"dead:beef::1",
+					"dead:beef::/120",
+				},
+			},
+			true,
+		),
+		Entry("should reject IPReservationSpec with bad CIDR",
+			api.IPReservationSpec{
+				ReservedCIDRs: []string{
+					"10.0.0.0/33",
+				},
+			},
+			false,
+		),
 		Entry("should accept GlobalNetworkSet with labels",
 			api.GlobalNetworkSet{
 				ObjectMeta: v1.ObjectMeta{
@@ -304,6 +349,34 @@ func init() {
 			},
 			false,
 		),
+		Entry("should accept GlobalNetworkSet shard with a valid logical name",
+			api.GlobalNetworkSet{
+				ObjectMeta: v1.ObjectMeta{
+					Name: "testset-1",
+					Annotations: map[string]string{
+						api.AnnotationNetworkSetShardOf: "testset",
+					},
+				},
+				Spec: api.GlobalNetworkSetSpec{
+					Nets: []string{"10.0.0.1"},
+				},
+			},
+			true,
+		),
+		Entry("should reject GlobalNetworkSet shard with a bad logical name",
+			api.GlobalNetworkSet{
+				ObjectMeta: v1.ObjectMeta{
+					Name: "testset-1",
+					Annotations: map[string]string{
+						api.AnnotationNetworkSetShardOf: "test$set",
+					},
+				},
+				Spec: api.GlobalNetworkSetSpec{
+					Nets: []string{"10.0.0.1"},
+				},
+			},
+			false,
+		),
 		Entry("should reject GlobalNetworkSet with bad name",
 			api.GlobalNetworkSet{
 				ObjectMeta: v1.ObjectMeta{
@@ -362,6 +435,20 @@ func init() {
 			},
 			false,
 		),
+		Entry("should reject NetworkSet shard with a bad logical name",
+			api.NetworkSet{
+				ObjectMeta: v1.ObjectMeta{
+					Name: "testset-1",
+					Annotations: map[string]string{
+						api.AnnotationNetworkSetShardOf: "",
+					},
+				},
+				Spec: api.NetworkSetSpec{
+					Nets: []string{"10.0.0.1"},
+				},
+			},
+			false,
+		),
 		Entry("should reject NetworkSet with bad name",
 			api.NetworkSet{
 				ObjectMeta: v1.ObjectMeta{
@@ -832,6 +919,34 @@ func init() {
 		Entry("should reject VXLAN mode never", api.IPPoolSpec{CIDR: "1.2.3.0/24", VXLANMode: "never"}, false),
 		Entry("should reject VXLAN mode badVal", api.IPPoolSpec{CIDR: "1.2.3.0/24", VXLANMode: "badVal"}, false),
 
+		// (API) AllocationStrategy
+		Entry("should accept allocation strategy Sequential", api.IPPoolSpec{CIDR: "1.2.3.0/24", AllocationStrategy: api.AllocationStrategySequential}, true),
+		Entry("should accept allocation strategy Sticky", api.IPPoolSpec{CIDR: "1.2.3.0/24", AllocationStrategy: "Sticky"}, true),
+		Entry("should reject allocation strategy sequential (lower case)", api.IPPoolSpec{CIDR: "1.2.3.0/24", AllocationStrategy: "sequential"}, false),
+
+		// (API) BorrowingPolicy
+		Entry("should accept borrowing policy Never", api.IPPoolSpec{CIDR: "1.2.3.0/24", BorrowingPolicy: api.BorrowingPolicyNever}, true),
+		Entry("should accept borrowing policy SameZone", api.IPPoolSpec{CIDR: "1.2.3.0/24", BorrowingPolicy: "SameZone"}, true),
+		Entry("should reject borrowing policy Sometimes", api.IPPoolSpec{CIDR: "1.2.3.0/24", BorrowingPolicy: "Sometimes"}, false),
+
+		// (API) BlockSize
+		Entry("should accept IPv4 block size 20", api.IPPoolSpec{CIDR: "1.2.0.0/16", BlockSize: 20}, true),
+		Entry("should accept IPv4 block size 32", api.IPPoolSpec{CIDR: "1.2.3.0/24", BlockSize: 32}, true),
+		Entry("should reject IPv4 block size 19", api.IPPoolSpec{CIDR: "1.2.0.0/16", BlockSize: 19}, false),
+		Entry("should reject IPv4 block size 33", api.IPPoolSpec{CIDR: "1.2.3.0/24", BlockSize: 33}, false),
+		Entry("should accept IPv6 block size 116", api.IPPoolSpec{CIDR: "aa:bb::/64", BlockSize: 116, IPIPMode: "Never", VXLANMode: "Never"}, true),
+		Entry("should reject IPv6 block size 26", api.IPPoolSpec{CIDR: "aa:bb::/64", BlockSize: 26, IPIPMode: "Never", VXLANMode: "Never"}, false),
+		Entry("should reject IPv4 pool smaller than its block size", api.IPPoolSpec{CIDR: "1.2.3.0/28", BlockSize: 26}, false),
+		Entry("should accept disabled IPv4 pool smaller than its block size", api.IPPoolSpec{CIDR: "1.2.3.0/28", BlockSize: 26, Disabled: true}, true),
+
+		// (API) ReservedBlockStart and ReservedBlockEnd
+		Entry("should accept reserved block edges", api.IPPoolSpec{CIDR: "1.2.3.0/24", ReservedBlockStart: 2, ReservedBlockEnd: 1}, true),
+		Entry("should accept reserved block edges leaving one address", api.IPPoolSpec{CIDR: "1.2.3.0/24", BlockSize: 29, ReservedBlockStart: 4, ReservedBlockEnd: 3}, true),
+		Entry("should reject reserved block edges covering the block", api.IPPoolSpec{CIDR: "1.2.3.0/24", BlockSize: 29, ReservedBlockStart: 4, ReservedBlockEnd: 4}, false),
+		Entry("should reject reserved block edges covering a /32 block", api.IPPoolSpec{CIDR: "1.2.3.0/24", BlockSize: 32, ReservedBlockStart: 1}, false),
+		Entry("should reject negative ReservedBlockStart", api.IPPoolSpec{CIDR: "1.2.3.0/24", ReservedBlockStart: -1}, false),
+		Entry("should reject negative ReservedBlockEnd", api.IPPoolSpec{CIDR: "1.2.3.0/24", ReservedBlockEnd: -1}, false),
+
 		// (API) IPIP APIv1 backwards compatibility. Read-only field IPIP
 		Entry("should accept a nil IPIP field", api.IPPoolSpec{CIDR: "1.2.3.0/24", IPIPMode: "Never", IPIP: nil}, true),
 		Entry("should accept it when the IPIP field is not specified", api.IPPoolSpec{CIDR: "1.2.3.0/24", IPIPMode: "Never"}, true),
@@ -1101,6 +1216,27 @@ func init() {
 					NotPorts: []numorstring.Port{{MinPort: 0, MaxPort: 100}},
 				},
 			}, false),
+		Entry("should accept Rule with destination domains",
+			api.Rule{
+				Action: "Allow",
+				Destination: api.EntityRule{
+					Domains: []string{"example.com", "*.example.org"},
+				},
+			}, true),
+		Entry("should reject Rule with an empty destination domain",
+			api.Rule{
+				Action: "Allow",
+				Destination: api.EntityRule{
+					Domains: []string{""},
+				},
+			}, false),
+		Entry("should reject Rule with a bare wildcard domain",
+			api.Rule{
+				Action: "Allow",
+				Source: api.EntityRule{
+					Domains: []string{"*"},
+				},
+			}, false),
 		Entry("should reject rule mixed IPv4 (src) and IPv6 (dest)",
 			api.Rule{
 				Action:   "Allow",